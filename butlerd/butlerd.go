@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
 	"sync"
 
 	"github.com/itchio/butler/comm"
@@ -33,6 +34,11 @@ type ServeTCPParams struct {
 	Log       bool
 	KeepAlive bool
 
+	// If true, peers that connect over a unix socket and run as the
+	// same user as the daemon don't need to call Meta.Authenticate.
+	// Only honored on platforms that support peer credentials.
+	TrustPeerCredentials bool
+
 	ShutdownChan chan struct{}
 }
 
@@ -101,19 +107,35 @@ func (s *Server) handleTCPConn(parentCtx context.Context, params ServeTCPParams,
 		inner:  params.Handler,
 	}
 
-	var opts []jsonrpc2.ConnOpt
+	if params.TrustPeerCredentials {
+		trusted, err := isTrustedPeer(tcpConn)
+		if err != nil {
+			comm.Warnf("Could not check peer credentials: %+v", err)
+		}
+		gh.authenticated = trusted
+	}
 
 	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
 
 	stream := jsonrpc2.NewBufferedStream(tcpConn, LFObjectCodec{})
 
-	conn := jsonrpc2.NewConn(ctx, stream, gh, opts...)
+	conn := jsonrpc2.NewConn(ctx, stream, gh, connOpts(params.Log)...)
 	<-conn.DisconnectNotify()
 
 	return nil
 }
 
+// connOpts returns options for JSON-RPC connections, that log
+// all requests and responses to stderr if logMessages is set.
+func connOpts(logMessages bool) []jsonrpc2.ConnOpt {
+	var opts []jsonrpc2.ConnOpt
+	if logMessages {
+		opts = append(opts, jsonrpc2.LogMessages(log.New(os.Stderr, "[rpc] ", log.LstdFlags)))
+	}
+	return opts
+}
+
 //
 
 type gatedHandler struct {
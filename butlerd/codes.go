@@ -17,6 +17,9 @@ var codeMessages = map[Code]string{
 
 	CodeNoLaunchCandidates: "Nothing that can be launched was found.",
 
+	CodePreLaunchHookFailed: "The pre-launch step failed.",
+	CodePostExitHookFailed:  "The post-exit step failed.",
+
 	CodeJavaRuntimeNeeded: "Java Runtime Environment is required to launch this title.",
 
 	CodeNetworkDisconnected: "There is no Internet connection",
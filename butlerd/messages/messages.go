@@ -288,6 +288,46 @@ func (r *NetworkSetBandwidthThrottleType) TestCall(rc *butlerd.RequestContext, p
 
 var NetworkSetBandwidthThrottle *NetworkSetBandwidthThrottleType
 
+// Network.SetMetered (Request)
+
+type NetworkSetMeteredType struct {}
+
+var _ RequestMessage = (*NetworkSetMeteredType)(nil)
+
+func (r *NetworkSetMeteredType) Method() string {
+  return "Network.SetMetered"
+}
+
+func (r *NetworkSetMeteredType) Register(router router, f func(*butlerd.RequestContext, butlerd.NetworkSetMeteredParams) (*butlerd.NetworkSetMeteredResult, error)) {
+  router.Register("Network.SetMetered", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.NetworkSetMeteredParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Network.SetMetered")
+    }
+    return res, nil
+  })
+}
+
+func (r *NetworkSetMeteredType) TestCall(rc *butlerd.RequestContext, params butlerd.NetworkSetMeteredParams) (*butlerd.NetworkSetMeteredResult, error) {
+  var result butlerd.NetworkSetMeteredResult
+  err := rc.Call("Network.SetMetered", params, &result)
+  return &result, err
+}
+
+var NetworkSetMetered *NetworkSetMeteredType
+
 
 //==============================
 // Miscellaneous
@@ -461,6 +501,34 @@ func (r *DownloadsDriveNetworkStatusType) Register(router router, f func(*butler
 
 var DownloadsDriveNetworkStatus *DownloadsDriveNetworkStatusType
 
+// Downloads.Drive.ScheduleStatus (Notification)
+
+type DownloadsDriveScheduleStatusType struct {}
+
+var _ NotificationMessage = (*DownloadsDriveScheduleStatusType)(nil)
+
+func (r *DownloadsDriveScheduleStatusType) Method() string {
+  return "Downloads.Drive.ScheduleStatus"
+}
+
+func (r *DownloadsDriveScheduleStatusType) Notify(rc *butlerd.RequestContext, params butlerd.DownloadsDriveScheduleStatusNotification) (error) {
+  return rc.Notify("Downloads.Drive.ScheduleStatus", params)
+}
+
+func (r *DownloadsDriveScheduleStatusType) Register(router router, f func(*butlerd.RequestContext, butlerd.DownloadsDriveScheduleStatusNotification)) {
+  router.RegisterNotification("Downloads.Drive.ScheduleStatus", func (rc *butlerd.RequestContext) {
+    var params butlerd.DownloadsDriveScheduleStatusNotification
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	// can't even propagate, just return
+    	return
+    }
+    f(rc, params)
+  })
+}
+
+var DownloadsDriveScheduleStatus *DownloadsDriveScheduleStatusType
+
 // Log (Notification)
 
 type LogType struct {}
@@ -1464,6 +1532,46 @@ func (r *FetchCaveType) TestCall(rc *butlerd.RequestContext, params butlerd.Fetc
 
 var FetchCave *FetchCaveType
 
+// Fetch.Cave.Sessions (Request)
+
+type FetchCaveSessionsType struct {}
+
+var _ RequestMessage = (*FetchCaveSessionsType)(nil)
+
+func (r *FetchCaveSessionsType) Method() string {
+  return "Fetch.Cave.Sessions"
+}
+
+func (r *FetchCaveSessionsType) Register(router router, f func(*butlerd.RequestContext, butlerd.FetchCaveSessionsParams) (*butlerd.FetchCaveSessionsResult, error)) {
+  router.Register("Fetch.Cave.Sessions", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.FetchCaveSessionsParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Fetch.Cave.Sessions")
+    }
+    return res, nil
+  })
+}
+
+func (r *FetchCaveSessionsType) TestCall(rc *butlerd.RequestContext, params butlerd.FetchCaveSessionsParams) (*butlerd.FetchCaveSessionsResult, error) {
+  var result butlerd.FetchCaveSessionsResult
+  err := rc.Call("Fetch.Cave.Sessions", params, &result)
+  return &result, err
+}
+
+var FetchCaveSessions *FetchCaveSessionsType
+
 // Fetch.ExpireAll (Request)
 
 type FetchExpireAllType struct {}
@@ -1669,19 +1777,19 @@ func (r *CavesSetPinnedType) TestCall(rc *butlerd.RequestContext, params butlerd
 
 var CavesSetPinned *CavesSetPinnedType
 
-// Install.Perform (Request)
+// Caves.SetSandboxPolicy (Request)
 
-type InstallPerformType struct {}
+type CavesSetSandboxPolicyType struct {}
 
-var _ RequestMessage = (*InstallPerformType)(nil)
+var _ RequestMessage = (*CavesSetSandboxPolicyType)(nil)
 
-func (r *InstallPerformType) Method() string {
-  return "Install.Perform"
+func (r *CavesSetSandboxPolicyType) Method() string {
+  return "Caves.SetSandboxPolicy"
 }
 
-func (r *InstallPerformType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallPerformParams) (*butlerd.InstallPerformResult, error)) {
-  router.Register("Install.Perform", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallPerformParams
+func (r *CavesSetSandboxPolicyType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesSetSandboxPolicyParams) (*butlerd.CavesSetSandboxPolicyResult, error)) {
+  router.Register("Caves.SetSandboxPolicy", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesSetSandboxPolicyParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1695,33 +1803,33 @@ func (r *InstallPerformType) Register(router router, f func(*butlerd.RequestCont
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.Perform")
+    	return nil, errors.New("internal error: nil result for Caves.SetSandboxPolicy")
     }
     return res, nil
   })
 }
 
-func (r *InstallPerformType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallPerformParams) (*butlerd.InstallPerformResult, error) {
-  var result butlerd.InstallPerformResult
-  err := rc.Call("Install.Perform", params, &result)
+func (r *CavesSetSandboxPolicyType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesSetSandboxPolicyParams) (*butlerd.CavesSetSandboxPolicyResult, error) {
+  var result butlerd.CavesSetSandboxPolicyResult
+  err := rc.Call("Caves.SetSandboxPolicy", params, &result)
   return &result, err
 }
 
-var InstallPerform *InstallPerformType
+var CavesSetSandboxPolicy *CavesSetSandboxPolicyType
 
-// Install.Cancel (Request)
+// Caves.GetSandboxPolicy (Request)
 
-type InstallCancelType struct {}
+type CavesGetSandboxPolicyType struct {}
 
-var _ RequestMessage = (*InstallCancelType)(nil)
+var _ RequestMessage = (*CavesGetSandboxPolicyType)(nil)
 
-func (r *InstallCancelType) Method() string {
-  return "Install.Cancel"
+func (r *CavesGetSandboxPolicyType) Method() string {
+  return "Caves.GetSandboxPolicy"
 }
 
-func (r *InstallCancelType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallCancelParams) (*butlerd.InstallCancelResult, error)) {
-  router.Register("Install.Cancel", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallCancelParams
+func (r *CavesGetSandboxPolicyType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesGetSandboxPolicyParams) (*butlerd.CavesGetSandboxPolicyResult, error)) {
+  router.Register("Caves.GetSandboxPolicy", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesGetSandboxPolicyParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1735,33 +1843,33 @@ func (r *InstallCancelType) Register(router router, f func(*butlerd.RequestConte
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.Cancel")
+    	return nil, errors.New("internal error: nil result for Caves.GetSandboxPolicy")
     }
     return res, nil
   })
 }
 
-func (r *InstallCancelType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallCancelParams) (*butlerd.InstallCancelResult, error) {
-  var result butlerd.InstallCancelResult
-  err := rc.Call("Install.Cancel", params, &result)
+func (r *CavesGetSandboxPolicyType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesGetSandboxPolicyParams) (*butlerd.CavesGetSandboxPolicyResult, error) {
+  var result butlerd.CavesGetSandboxPolicyResult
+  err := rc.Call("Caves.GetSandboxPolicy", params, &result)
   return &result, err
 }
 
-var InstallCancel *InstallCancelType
+var CavesGetSandboxPolicy *CavesGetSandboxPolicyType
 
-// Uninstall.Perform (Request)
+// Caves.Verify (Request)
 
-type UninstallPerformType struct {}
+type CavesVerifyType struct {}
 
-var _ RequestMessage = (*UninstallPerformType)(nil)
+var _ RequestMessage = (*CavesVerifyType)(nil)
 
-func (r *UninstallPerformType) Method() string {
-  return "Uninstall.Perform"
+func (r *CavesVerifyType) Method() string {
+  return "Caves.Verify"
 }
 
-func (r *UninstallPerformType) Register(router router, f func(*butlerd.RequestContext, butlerd.UninstallPerformParams) (*butlerd.UninstallPerformResult, error)) {
-  router.Register("Uninstall.Perform", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.UninstallPerformParams
+func (r *CavesVerifyType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesVerifyParams) (*butlerd.CavesVerifyResult, error)) {
+  router.Register("Caves.Verify", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesVerifyParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1775,33 +1883,33 @@ func (r *UninstallPerformType) Register(router router, f func(*butlerd.RequestCo
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Uninstall.Perform")
+    	return nil, errors.New("internal error: nil result for Caves.Verify")
     }
     return res, nil
   })
 }
 
-func (r *UninstallPerformType) TestCall(rc *butlerd.RequestContext, params butlerd.UninstallPerformParams) (*butlerd.UninstallPerformResult, error) {
-  var result butlerd.UninstallPerformResult
-  err := rc.Call("Uninstall.Perform", params, &result)
+func (r *CavesVerifyType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesVerifyParams) (*butlerd.CavesVerifyResult, error) {
+  var result butlerd.CavesVerifyResult
+  err := rc.Call("Caves.Verify", params, &result)
   return &result, err
 }
 
-var UninstallPerform *UninstallPerformType
+var CavesVerify *CavesVerifyType
 
-// Install.VersionSwitch.Queue (Request)
+// Caves.Repair (Request)
 
-type InstallVersionSwitchQueueType struct {}
+type CavesRepairType struct {}
 
-var _ RequestMessage = (*InstallVersionSwitchQueueType)(nil)
+var _ RequestMessage = (*CavesRepairType)(nil)
 
-func (r *InstallVersionSwitchQueueType) Method() string {
-  return "Install.VersionSwitch.Queue"
+func (r *CavesRepairType) Method() string {
+  return "Caves.Repair"
 }
 
-func (r *InstallVersionSwitchQueueType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallVersionSwitchQueueParams) (*butlerd.InstallVersionSwitchQueueResult, error)) {
-  router.Register("Install.VersionSwitch.Queue", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallVersionSwitchQueueParams
+func (r *CavesRepairType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesRepairParams) (*butlerd.CavesRepairResult, error)) {
+  router.Register("Caves.Repair", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesRepairParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1815,33 +1923,33 @@ func (r *InstallVersionSwitchQueueType) Register(router router, f func(*butlerd.
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.VersionSwitch.Queue")
+    	return nil, errors.New("internal error: nil result for Caves.Repair")
     }
     return res, nil
   })
 }
 
-func (r *InstallVersionSwitchQueueType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallVersionSwitchQueueParams) (*butlerd.InstallVersionSwitchQueueResult, error) {
-  var result butlerd.InstallVersionSwitchQueueResult
-  err := rc.Call("Install.VersionSwitch.Queue", params, &result)
+func (r *CavesRepairType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesRepairParams) (*butlerd.CavesRepairResult, error) {
+  var result butlerd.CavesRepairResult
+  err := rc.Call("Caves.Repair", params, &result)
   return &result, err
 }
 
-var InstallVersionSwitchQueue *InstallVersionSwitchQueueType
+var CavesRepair *CavesRepairType
 
-// InstallVersionSwitchPick (Request)
+// Caves.ScanIntegrity (Request)
 
-type InstallVersionSwitchPickType struct {}
+type CavesScanIntegrityType struct {}
 
-var _ RequestMessage = (*InstallVersionSwitchPickType)(nil)
+var _ RequestMessage = (*CavesScanIntegrityType)(nil)
 
-func (r *InstallVersionSwitchPickType) Method() string {
-  return "InstallVersionSwitchPick"
+func (r *CavesScanIntegrityType) Method() string {
+  return "Caves.ScanIntegrity"
 }
 
-func (r *InstallVersionSwitchPickType) TestRegister(router router, f func(*butlerd.RequestContext, butlerd.InstallVersionSwitchPickParams) (*butlerd.InstallVersionSwitchPickResult, error)) {
-  router.Register("InstallVersionSwitchPick", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallVersionSwitchPickParams
+func (r *CavesScanIntegrityType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesScanIntegrityParams) (*butlerd.CavesScanIntegrityResult, error)) {
+  router.Register("Caves.ScanIntegrity", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesScanIntegrityParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1855,33 +1963,33 @@ func (r *InstallVersionSwitchPickType) TestRegister(router router, f func(*butle
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for InstallVersionSwitchPick")
+    	return nil, errors.New("internal error: nil result for Caves.ScanIntegrity")
     }
     return res, nil
   })
 }
 
-func (r *InstallVersionSwitchPickType) Call(rc *butlerd.RequestContext, params butlerd.InstallVersionSwitchPickParams) (*butlerd.InstallVersionSwitchPickResult, error) {
-  var result butlerd.InstallVersionSwitchPickResult
-  err := rc.Call("InstallVersionSwitchPick", params, &result)
+func (r *CavesScanIntegrityType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesScanIntegrityParams) (*butlerd.CavesScanIntegrityResult, error) {
+  var result butlerd.CavesScanIntegrityResult
+  err := rc.Call("Caves.ScanIntegrity", params, &result)
   return &result, err
 }
 
-var InstallVersionSwitchPick *InstallVersionSwitchPickType
+var CavesScanIntegrity *CavesScanIntegrityType
 
-// PickUpload (Request)
+// Caves.GetIntegrity (Request)
 
-type PickUploadType struct {}
+type CavesGetIntegrityType struct {}
 
-var _ RequestMessage = (*PickUploadType)(nil)
+var _ RequestMessage = (*CavesGetIntegrityType)(nil)
 
-func (r *PickUploadType) Method() string {
-  return "PickUpload"
+func (r *CavesGetIntegrityType) Method() string {
+  return "Caves.GetIntegrity"
 }
 
-func (r *PickUploadType) TestRegister(router router, f func(*butlerd.RequestContext, butlerd.PickUploadParams) (*butlerd.PickUploadResult, error)) {
-  router.Register("PickUpload", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.PickUploadParams
+func (r *CavesGetIntegrityType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesGetIntegrityParams) (*butlerd.CavesGetIntegrityResult, error)) {
+  router.Register("Caves.GetIntegrity", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesGetIntegrityParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -1895,37 +2003,37 @@ func (r *PickUploadType) TestRegister(router router, f func(*butlerd.RequestCont
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for PickUpload")
+    	return nil, errors.New("internal error: nil result for Caves.GetIntegrity")
     }
     return res, nil
   })
 }
 
-func (r *PickUploadType) Call(rc *butlerd.RequestContext, params butlerd.PickUploadParams) (*butlerd.PickUploadResult, error) {
-  var result butlerd.PickUploadResult
-  err := rc.Call("PickUpload", params, &result)
+func (r *CavesGetIntegrityType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesGetIntegrityParams) (*butlerd.CavesGetIntegrityResult, error) {
+  var result butlerd.CavesGetIntegrityResult
+  err := rc.Call("Caves.GetIntegrity", params, &result)
   return &result, err
 }
 
-var PickUpload *PickUploadType
+var CavesGetIntegrity *CavesGetIntegrityType
 
-// Progress (Notification)
+// Caves.IntegrityProblem (Notification)
 
-type ProgressType struct {}
+type CavesIntegrityProblemType struct {}
 
-var _ NotificationMessage = (*ProgressType)(nil)
+var _ NotificationMessage = (*CavesIntegrityProblemType)(nil)
 
-func (r *ProgressType) Method() string {
-  return "Progress"
+func (r *CavesIntegrityProblemType) Method() string {
+  return "Caves.IntegrityProblem"
 }
 
-func (r *ProgressType) Notify(rc *butlerd.RequestContext, params butlerd.ProgressNotification) (error) {
-  return rc.Notify("Progress", params)
+func (r *CavesIntegrityProblemType) Notify(rc *butlerd.RequestContext, params butlerd.CavesIntegrityProblemNotification) (error) {
+  return rc.Notify("Caves.IntegrityProblem", params)
 }
 
-func (r *ProgressType) Register(router router, f func(*butlerd.RequestContext, butlerd.ProgressNotification)) {
-  router.RegisterNotification("Progress", func (rc *butlerd.RequestContext) {
-    var params butlerd.ProgressNotification
+func (r *CavesIntegrityProblemType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesIntegrityProblemNotification)) {
+  router.RegisterNotification("Caves.IntegrityProblem", func (rc *butlerd.RequestContext) {
+    var params butlerd.CavesIntegrityProblemNotification
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	// can't even propagate, just return
@@ -1935,77 +2043,101 @@ func (r *ProgressType) Register(router router, f func(*butlerd.RequestContext, b
   })
 }
 
-var Progress *ProgressType
-
-// TaskStarted (Notification)
+var CavesIntegrityProblem *CavesIntegrityProblemType
 
-type TaskStartedType struct {}
+// Caves.ScanSchedule.Get (Request)
 
-var _ NotificationMessage = (*TaskStartedType)(nil)
+type CavesScanScheduleGetType struct {}
 
-func (r *TaskStartedType) Method() string {
-  return "TaskStarted"
-}
+var _ RequestMessage = (*CavesScanScheduleGetType)(nil)
 
-func (r *TaskStartedType) Notify(rc *butlerd.RequestContext, params butlerd.TaskStartedNotification) (error) {
-  return rc.Notify("TaskStarted", params)
+func (r *CavesScanScheduleGetType) Method() string {
+  return "Caves.ScanSchedule.Get"
 }
 
-func (r *TaskStartedType) Register(router router, f func(*butlerd.RequestContext, butlerd.TaskStartedNotification)) {
-  router.RegisterNotification("TaskStarted", func (rc *butlerd.RequestContext) {
-    var params butlerd.TaskStartedNotification
+func (r *CavesScanScheduleGetType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesScanScheduleGetParams) (*butlerd.CavesScanScheduleGetResult, error)) {
+  router.Register("Caves.ScanSchedule.Get", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesScanScheduleGetParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
-    	// can't even propagate, just return
-    	return
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
     }
-    f(rc, params)
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Caves.ScanSchedule.Get")
+    }
+    return res, nil
   })
 }
 
-var TaskStarted *TaskStartedType
+func (r *CavesScanScheduleGetType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesScanScheduleGetParams) (*butlerd.CavesScanScheduleGetResult, error) {
+  var result butlerd.CavesScanScheduleGetResult
+  err := rc.Call("Caves.ScanSchedule.Get", params, &result)
+  return &result, err
+}
 
-// TaskSucceeded (Notification)
+var CavesScanScheduleGet *CavesScanScheduleGetType
 
-type TaskSucceededType struct {}
+// Caves.ScanSchedule.Set (Request)
 
-var _ NotificationMessage = (*TaskSucceededType)(nil)
+type CavesScanScheduleSetType struct {}
 
-func (r *TaskSucceededType) Method() string {
-  return "TaskSucceeded"
-}
+var _ RequestMessage = (*CavesScanScheduleSetType)(nil)
 
-func (r *TaskSucceededType) Notify(rc *butlerd.RequestContext, params butlerd.TaskSucceededNotification) (error) {
-  return rc.Notify("TaskSucceeded", params)
+func (r *CavesScanScheduleSetType) Method() string {
+  return "Caves.ScanSchedule.Set"
 }
 
-func (r *TaskSucceededType) Register(router router, f func(*butlerd.RequestContext, butlerd.TaskSucceededNotification)) {
-  router.RegisterNotification("TaskSucceeded", func (rc *butlerd.RequestContext) {
-    var params butlerd.TaskSucceededNotification
+func (r *CavesScanScheduleSetType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesScanScheduleSetParams) (*butlerd.CavesScanScheduleSetResult, error)) {
+  router.Register("Caves.ScanSchedule.Set", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesScanScheduleSetParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
-    	// can't even propagate, just return
-    	return
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
     }
-    f(rc, params)
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Caves.ScanSchedule.Set")
+    }
+    return res, nil
   })
 }
 
-var TaskSucceeded *TaskSucceededType
+func (r *CavesScanScheduleSetType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesScanScheduleSetParams) (*butlerd.CavesScanScheduleSetResult, error) {
+  var result butlerd.CavesScanScheduleSetResult
+  err := rc.Call("Caves.ScanSchedule.Set", params, &result)
+  return &result, err
+}
 
-// Install.Locations.List (Request)
+var CavesScanScheduleSet *CavesScanScheduleSetType
 
-type InstallLocationsListType struct {}
+// Caves.Rollback (Request)
 
-var _ RequestMessage = (*InstallLocationsListType)(nil)
+type CavesRollbackType struct {}
 
-func (r *InstallLocationsListType) Method() string {
-  return "Install.Locations.List"
+var _ RequestMessage = (*CavesRollbackType)(nil)
+
+func (r *CavesRollbackType) Method() string {
+  return "Caves.Rollback"
 }
 
-func (r *InstallLocationsListType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsListParams) (*butlerd.InstallLocationsListResult, error)) {
-  router.Register("Install.Locations.List", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallLocationsListParams
+func (r *CavesRollbackType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesRollbackParams) (*butlerd.CavesRollbackResult, error)) {
+  router.Register("Caves.Rollback", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesRollbackParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -2019,33 +2151,33 @@ func (r *InstallLocationsListType) Register(router router, f func(*butlerd.Reque
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.Locations.List")
+    	return nil, errors.New("internal error: nil result for Caves.Rollback")
     }
     return res, nil
   })
 }
 
-func (r *InstallLocationsListType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsListParams) (*butlerd.InstallLocationsListResult, error) {
-  var result butlerd.InstallLocationsListResult
-  err := rc.Call("Install.Locations.List", params, &result)
+func (r *CavesRollbackType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesRollbackParams) (*butlerd.CavesRollbackResult, error) {
+  var result butlerd.CavesRollbackResult
+  err := rc.Call("Caves.Rollback", params, &result)
   return &result, err
 }
 
-var InstallLocationsList *InstallLocationsListType
+var CavesRollback *CavesRollbackType
 
-// Install.Locations.Add (Request)
+// Caves.Saves.List (Request)
 
-type InstallLocationsAddType struct {}
+type CavesSavesListType struct {}
 
-var _ RequestMessage = (*InstallLocationsAddType)(nil)
+var _ RequestMessage = (*CavesSavesListType)(nil)
 
-func (r *InstallLocationsAddType) Method() string {
-  return "Install.Locations.Add"
+func (r *CavesSavesListType) Method() string {
+  return "Caves.Saves.List"
 }
 
-func (r *InstallLocationsAddType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsAddParams) (*butlerd.InstallLocationsAddResult, error)) {
-  router.Register("Install.Locations.Add", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallLocationsAddParams
+func (r *CavesSavesListType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesSavesListParams) (*butlerd.CavesSavesListResult, error)) {
+  router.Register("Caves.Saves.List", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesSavesListParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -2059,33 +2191,33 @@ func (r *InstallLocationsAddType) Register(router router, f func(*butlerd.Reques
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.Locations.Add")
+    	return nil, errors.New("internal error: nil result for Caves.Saves.List")
     }
     return res, nil
   })
 }
 
-func (r *InstallLocationsAddType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsAddParams) (*butlerd.InstallLocationsAddResult, error) {
-  var result butlerd.InstallLocationsAddResult
-  err := rc.Call("Install.Locations.Add", params, &result)
+func (r *CavesSavesListType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesSavesListParams) (*butlerd.CavesSavesListResult, error) {
+  var result butlerd.CavesSavesListResult
+  err := rc.Call("Caves.Saves.List", params, &result)
   return &result, err
 }
 
-var InstallLocationsAdd *InstallLocationsAddType
+var CavesSavesList *CavesSavesListType
 
-// Install.Locations.Remove (Request)
+// Caves.Saves.Backup (Request)
 
-type InstallLocationsRemoveType struct {}
+type CavesSavesBackupType struct {}
 
-var _ RequestMessage = (*InstallLocationsRemoveType)(nil)
+var _ RequestMessage = (*CavesSavesBackupType)(nil)
 
-func (r *InstallLocationsRemoveType) Method() string {
-  return "Install.Locations.Remove"
+func (r *CavesSavesBackupType) Method() string {
+  return "Caves.Saves.Backup"
 }
 
-func (r *InstallLocationsRemoveType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsRemoveParams) (*butlerd.InstallLocationsRemoveResult, error)) {
-  router.Register("Install.Locations.Remove", func (rc *butlerd.RequestContext) (interface{}, error) {
-    var params butlerd.InstallLocationsRemoveParams
+func (r *CavesSavesBackupType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesSavesBackupParams) (*butlerd.CavesSavesBackupResult, error)) {
+  router.Register("Caves.Saves.Backup", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesSavesBackupParams
     err := json.Unmarshal(*rc.Params, &params)
     if err != nil {
     	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
@@ -2099,13 +2231,577 @@ func (r *InstallLocationsRemoveType) Register(router router, f func(*butlerd.Req
     	return nil, err
     }
     if res == nil {
-    	return nil, errors.New("internal error: nil result for Install.Locations.Remove")
+    	return nil, errors.New("internal error: nil result for Caves.Saves.Backup")
     }
     return res, nil
   })
 }
 
-func (r *InstallLocationsRemoveType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsRemoveParams) (*butlerd.InstallLocationsRemoveResult, error) {
+func (r *CavesSavesBackupType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesSavesBackupParams) (*butlerd.CavesSavesBackupResult, error) {
+  var result butlerd.CavesSavesBackupResult
+  err := rc.Call("Caves.Saves.Backup", params, &result)
+  return &result, err
+}
+
+var CavesSavesBackup *CavesSavesBackupType
+
+// Caves.Saves.Restore (Request)
+
+type CavesSavesRestoreType struct {}
+
+var _ RequestMessage = (*CavesSavesRestoreType)(nil)
+
+func (r *CavesSavesRestoreType) Method() string {
+  return "Caves.Saves.Restore"
+}
+
+func (r *CavesSavesRestoreType) Register(router router, f func(*butlerd.RequestContext, butlerd.CavesSavesRestoreParams) (*butlerd.CavesSavesRestoreResult, error)) {
+  router.Register("Caves.Saves.Restore", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.CavesSavesRestoreParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Caves.Saves.Restore")
+    }
+    return res, nil
+  })
+}
+
+func (r *CavesSavesRestoreType) TestCall(rc *butlerd.RequestContext, params butlerd.CavesSavesRestoreParams) (*butlerd.CavesSavesRestoreResult, error) {
+  var result butlerd.CavesSavesRestoreResult
+  err := rc.Call("Caves.Saves.Restore", params, &result)
+  return &result, err
+}
+
+var CavesSavesRestore *CavesSavesRestoreType
+
+// Install.Perform (Request)
+
+type InstallPerformType struct {}
+
+var _ RequestMessage = (*InstallPerformType)(nil)
+
+func (r *InstallPerformType) Method() string {
+  return "Install.Perform"
+}
+
+func (r *InstallPerformType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallPerformParams) (*butlerd.InstallPerformResult, error)) {
+  router.Register("Install.Perform", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallPerformParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Perform")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallPerformType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallPerformParams) (*butlerd.InstallPerformResult, error) {
+  var result butlerd.InstallPerformResult
+  err := rc.Call("Install.Perform", params, &result)
+  return &result, err
+}
+
+var InstallPerform *InstallPerformType
+
+// Install.FromFile (Request)
+
+type InstallFromFileType struct {}
+
+var _ RequestMessage = (*InstallFromFileType)(nil)
+
+func (r *InstallFromFileType) Method() string {
+  return "Install.FromFile"
+}
+
+func (r *InstallFromFileType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallFromFileParams) (*butlerd.InstallFromFileResult, error)) {
+  router.Register("Install.FromFile", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallFromFileParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.FromFile")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallFromFileType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallFromFileParams) (*butlerd.InstallFromFileResult, error) {
+  var result butlerd.InstallFromFileResult
+  err := rc.Call("Install.FromFile", params, &result)
+  return &result, err
+}
+
+var InstallFromFile *InstallFromFileType
+
+// Install.Cancel (Request)
+
+type InstallCancelType struct {}
+
+var _ RequestMessage = (*InstallCancelType)(nil)
+
+func (r *InstallCancelType) Method() string {
+  return "Install.Cancel"
+}
+
+func (r *InstallCancelType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallCancelParams) (*butlerd.InstallCancelResult, error)) {
+  router.Register("Install.Cancel", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallCancelParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Cancel")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallCancelType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallCancelParams) (*butlerd.InstallCancelResult, error) {
+  var result butlerd.InstallCancelResult
+  err := rc.Call("Install.Cancel", params, &result)
+  return &result, err
+}
+
+var InstallCancel *InstallCancelType
+
+// Uninstall.Perform (Request)
+
+type UninstallPerformType struct {}
+
+var _ RequestMessage = (*UninstallPerformType)(nil)
+
+func (r *UninstallPerformType) Method() string {
+  return "Uninstall.Perform"
+}
+
+func (r *UninstallPerformType) Register(router router, f func(*butlerd.RequestContext, butlerd.UninstallPerformParams) (*butlerd.UninstallPerformResult, error)) {
+  router.Register("Uninstall.Perform", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.UninstallPerformParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Uninstall.Perform")
+    }
+    return res, nil
+  })
+}
+
+func (r *UninstallPerformType) TestCall(rc *butlerd.RequestContext, params butlerd.UninstallPerformParams) (*butlerd.UninstallPerformResult, error) {
+  var result butlerd.UninstallPerformResult
+  err := rc.Call("Uninstall.Perform", params, &result)
+  return &result, err
+}
+
+var UninstallPerform *UninstallPerformType
+
+// Uninstall.Plan (Request)
+
+type UninstallPlanType struct {}
+
+var _ RequestMessage = (*UninstallPlanType)(nil)
+
+func (r *UninstallPlanType) Method() string {
+  return "Uninstall.Plan"
+}
+
+func (r *UninstallPlanType) Register(router router, f func(*butlerd.RequestContext, butlerd.UninstallPlanParams) (*butlerd.UninstallPlanResult, error)) {
+  router.Register("Uninstall.Plan", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.UninstallPlanParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Uninstall.Plan")
+    }
+    return res, nil
+  })
+}
+
+func (r *UninstallPlanType) TestCall(rc *butlerd.RequestContext, params butlerd.UninstallPlanParams) (*butlerd.UninstallPlanResult, error) {
+  var result butlerd.UninstallPlanResult
+  err := rc.Call("Uninstall.Plan", params, &result)
+  return &result, err
+}
+
+var UninstallPlan *UninstallPlanType
+
+// Install.VersionSwitch.Queue (Request)
+
+type InstallVersionSwitchQueueType struct {}
+
+var _ RequestMessage = (*InstallVersionSwitchQueueType)(nil)
+
+func (r *InstallVersionSwitchQueueType) Method() string {
+  return "Install.VersionSwitch.Queue"
+}
+
+func (r *InstallVersionSwitchQueueType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallVersionSwitchQueueParams) (*butlerd.InstallVersionSwitchQueueResult, error)) {
+  router.Register("Install.VersionSwitch.Queue", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallVersionSwitchQueueParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.VersionSwitch.Queue")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallVersionSwitchQueueType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallVersionSwitchQueueParams) (*butlerd.InstallVersionSwitchQueueResult, error) {
+  var result butlerd.InstallVersionSwitchQueueResult
+  err := rc.Call("Install.VersionSwitch.Queue", params, &result)
+  return &result, err
+}
+
+var InstallVersionSwitchQueue *InstallVersionSwitchQueueType
+
+// InstallVersionSwitchPick (Request)
+
+type InstallVersionSwitchPickType struct {}
+
+var _ RequestMessage = (*InstallVersionSwitchPickType)(nil)
+
+func (r *InstallVersionSwitchPickType) Method() string {
+  return "InstallVersionSwitchPick"
+}
+
+func (r *InstallVersionSwitchPickType) TestRegister(router router, f func(*butlerd.RequestContext, butlerd.InstallVersionSwitchPickParams) (*butlerd.InstallVersionSwitchPickResult, error)) {
+  router.Register("InstallVersionSwitchPick", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallVersionSwitchPickParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for InstallVersionSwitchPick")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallVersionSwitchPickType) Call(rc *butlerd.RequestContext, params butlerd.InstallVersionSwitchPickParams) (*butlerd.InstallVersionSwitchPickResult, error) {
+  var result butlerd.InstallVersionSwitchPickResult
+  err := rc.Call("InstallVersionSwitchPick", params, &result)
+  return &result, err
+}
+
+var InstallVersionSwitchPick *InstallVersionSwitchPickType
+
+// PickUpload (Request)
+
+type PickUploadType struct {}
+
+var _ RequestMessage = (*PickUploadType)(nil)
+
+func (r *PickUploadType) Method() string {
+  return "PickUpload"
+}
+
+func (r *PickUploadType) TestRegister(router router, f func(*butlerd.RequestContext, butlerd.PickUploadParams) (*butlerd.PickUploadResult, error)) {
+  router.Register("PickUpload", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.PickUploadParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for PickUpload")
+    }
+    return res, nil
+  })
+}
+
+func (r *PickUploadType) Call(rc *butlerd.RequestContext, params butlerd.PickUploadParams) (*butlerd.PickUploadResult, error) {
+  var result butlerd.PickUploadResult
+  err := rc.Call("PickUpload", params, &result)
+  return &result, err
+}
+
+var PickUpload *PickUploadType
+
+// Progress (Notification)
+
+type ProgressType struct {}
+
+var _ NotificationMessage = (*ProgressType)(nil)
+
+func (r *ProgressType) Method() string {
+  return "Progress"
+}
+
+func (r *ProgressType) Notify(rc *butlerd.RequestContext, params butlerd.ProgressNotification) (error) {
+  return rc.Notify("Progress", params)
+}
+
+func (r *ProgressType) Register(router router, f func(*butlerd.RequestContext, butlerd.ProgressNotification)) {
+  router.RegisterNotification("Progress", func (rc *butlerd.RequestContext) {
+    var params butlerd.ProgressNotification
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	// can't even propagate, just return
+    	return
+    }
+    f(rc, params)
+  })
+}
+
+var Progress *ProgressType
+
+// TaskStarted (Notification)
+
+type TaskStartedType struct {}
+
+var _ NotificationMessage = (*TaskStartedType)(nil)
+
+func (r *TaskStartedType) Method() string {
+  return "TaskStarted"
+}
+
+func (r *TaskStartedType) Notify(rc *butlerd.RequestContext, params butlerd.TaskStartedNotification) (error) {
+  return rc.Notify("TaskStarted", params)
+}
+
+func (r *TaskStartedType) Register(router router, f func(*butlerd.RequestContext, butlerd.TaskStartedNotification)) {
+  router.RegisterNotification("TaskStarted", func (rc *butlerd.RequestContext) {
+    var params butlerd.TaskStartedNotification
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	// can't even propagate, just return
+    	return
+    }
+    f(rc, params)
+  })
+}
+
+var TaskStarted *TaskStartedType
+
+// TaskSucceeded (Notification)
+
+type TaskSucceededType struct {}
+
+var _ NotificationMessage = (*TaskSucceededType)(nil)
+
+func (r *TaskSucceededType) Method() string {
+  return "TaskSucceeded"
+}
+
+func (r *TaskSucceededType) Notify(rc *butlerd.RequestContext, params butlerd.TaskSucceededNotification) (error) {
+  return rc.Notify("TaskSucceeded", params)
+}
+
+func (r *TaskSucceededType) Register(router router, f func(*butlerd.RequestContext, butlerd.TaskSucceededNotification)) {
+  router.RegisterNotification("TaskSucceeded", func (rc *butlerd.RequestContext) {
+    var params butlerd.TaskSucceededNotification
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	// can't even propagate, just return
+    	return
+    }
+    f(rc, params)
+  })
+}
+
+var TaskSucceeded *TaskSucceededType
+
+// Install.Locations.List (Request)
+
+type InstallLocationsListType struct {}
+
+var _ RequestMessage = (*InstallLocationsListType)(nil)
+
+func (r *InstallLocationsListType) Method() string {
+  return "Install.Locations.List"
+}
+
+func (r *InstallLocationsListType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsListParams) (*butlerd.InstallLocationsListResult, error)) {
+  router.Register("Install.Locations.List", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallLocationsListParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Locations.List")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallLocationsListType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsListParams) (*butlerd.InstallLocationsListResult, error) {
+  var result butlerd.InstallLocationsListResult
+  err := rc.Call("Install.Locations.List", params, &result)
+  return &result, err
+}
+
+var InstallLocationsList *InstallLocationsListType
+
+// Install.Locations.Add (Request)
+
+type InstallLocationsAddType struct {}
+
+var _ RequestMessage = (*InstallLocationsAddType)(nil)
+
+func (r *InstallLocationsAddType) Method() string {
+  return "Install.Locations.Add"
+}
+
+func (r *InstallLocationsAddType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsAddParams) (*butlerd.InstallLocationsAddResult, error)) {
+  router.Register("Install.Locations.Add", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallLocationsAddParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Locations.Add")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallLocationsAddType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsAddParams) (*butlerd.InstallLocationsAddResult, error) {
+  var result butlerd.InstallLocationsAddResult
+  err := rc.Call("Install.Locations.Add", params, &result)
+  return &result, err
+}
+
+var InstallLocationsAdd *InstallLocationsAddType
+
+// Install.Locations.Remove (Request)
+
+type InstallLocationsRemoveType struct {}
+
+var _ RequestMessage = (*InstallLocationsRemoveType)(nil)
+
+func (r *InstallLocationsRemoveType) Method() string {
+  return "Install.Locations.Remove"
+}
+
+func (r *InstallLocationsRemoveType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsRemoveParams) (*butlerd.InstallLocationsRemoveResult, error)) {
+  router.Register("Install.Locations.Remove", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallLocationsRemoveParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Locations.Remove")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallLocationsRemoveType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsRemoveParams) (*butlerd.InstallLocationsRemoveResult, error) {
   var result butlerd.InstallLocationsRemoveResult
   err := rc.Call("Install.Locations.Remove", params, &result)
   return &result, err
@@ -2153,6 +2849,86 @@ func (r *InstallLocationsGetByIDType) TestCall(rc *butlerd.RequestContext, param
 
 var InstallLocationsGetByID *InstallLocationsGetByIDType
 
+// Install.Locations.SetLANSharing (Request)
+
+type InstallLocationsSetLANSharingType struct {}
+
+var _ RequestMessage = (*InstallLocationsSetLANSharingType)(nil)
+
+func (r *InstallLocationsSetLANSharingType) Method() string {
+  return "Install.Locations.SetLANSharing"
+}
+
+func (r *InstallLocationsSetLANSharingType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsSetLANSharingParams) (*butlerd.InstallLocationsSetLANSharingResult, error)) {
+  router.Register("Install.Locations.SetLANSharing", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallLocationsSetLANSharingParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Locations.SetLANSharing")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallLocationsSetLANSharingType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsSetLANSharingParams) (*butlerd.InstallLocationsSetLANSharingResult, error) {
+  var result butlerd.InstallLocationsSetLANSharingResult
+  err := rc.Call("Install.Locations.SetLANSharing", params, &result)
+  return &result, err
+}
+
+var InstallLocationsSetLANSharing *InstallLocationsSetLANSharingType
+
+// Install.Locations.SetStoreLinks (Request)
+
+type InstallLocationsSetStoreLinksType struct {}
+
+var _ RequestMessage = (*InstallLocationsSetStoreLinksType)(nil)
+
+func (r *InstallLocationsSetStoreLinksType) Method() string {
+  return "Install.Locations.SetStoreLinks"
+}
+
+func (r *InstallLocationsSetStoreLinksType) Register(router router, f func(*butlerd.RequestContext, butlerd.InstallLocationsSetStoreLinksParams) (*butlerd.InstallLocationsSetStoreLinksResult, error)) {
+  router.Register("Install.Locations.SetStoreLinks", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.InstallLocationsSetStoreLinksParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Install.Locations.SetStoreLinks")
+    }
+    return res, nil
+  })
+}
+
+func (r *InstallLocationsSetStoreLinksType) TestCall(rc *butlerd.RequestContext, params butlerd.InstallLocationsSetStoreLinksParams) (*butlerd.InstallLocationsSetStoreLinksResult, error) {
+  var result butlerd.InstallLocationsSetStoreLinksResult
+  err := rc.Call("Install.Locations.SetStoreLinks", params, &result)
+  return &result, err
+}
+
+var InstallLocationsSetStoreLinks *InstallLocationsSetStoreLinksType
+
 // Install.Locations.Scan (Request)
 
 type InstallLocationsScanType struct {}
@@ -2586,6 +3362,86 @@ func (r *DownloadsDiscardType) TestCall(rc *butlerd.RequestContext, params butle
 
 var DownloadsDiscard *DownloadsDiscardType
 
+// Downloads.Schedule.Get (Request)
+
+type DownloadsScheduleGetType struct {}
+
+var _ RequestMessage = (*DownloadsScheduleGetType)(nil)
+
+func (r *DownloadsScheduleGetType) Method() string {
+  return "Downloads.Schedule.Get"
+}
+
+func (r *DownloadsScheduleGetType) Register(router router, f func(*butlerd.RequestContext, butlerd.DownloadsScheduleGetParams) (*butlerd.DownloadsScheduleGetResult, error)) {
+  router.Register("Downloads.Schedule.Get", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.DownloadsScheduleGetParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Downloads.Schedule.Get")
+    }
+    return res, nil
+  })
+}
+
+func (r *DownloadsScheduleGetType) TestCall(rc *butlerd.RequestContext, params butlerd.DownloadsScheduleGetParams) (*butlerd.DownloadsScheduleGetResult, error) {
+  var result butlerd.DownloadsScheduleGetResult
+  err := rc.Call("Downloads.Schedule.Get", params, &result)
+  return &result, err
+}
+
+var DownloadsScheduleGet *DownloadsScheduleGetType
+
+// Downloads.Schedule.Set (Request)
+
+type DownloadsScheduleSetType struct {}
+
+var _ RequestMessage = (*DownloadsScheduleSetType)(nil)
+
+func (r *DownloadsScheduleSetType) Method() string {
+  return "Downloads.Schedule.Set"
+}
+
+func (r *DownloadsScheduleSetType) Register(router router, f func(*butlerd.RequestContext, butlerd.DownloadsScheduleSetParams) (*butlerd.DownloadsScheduleSetResult, error)) {
+  router.Register("Downloads.Schedule.Set", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.DownloadsScheduleSetParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Downloads.Schedule.Set")
+    }
+    return res, nil
+  })
+}
+
+func (r *DownloadsScheduleSetType) TestCall(rc *butlerd.RequestContext, params butlerd.DownloadsScheduleSetParams) (*butlerd.DownloadsScheduleSetResult, error) {
+  var result butlerd.DownloadsScheduleSetResult
+  err := rc.Call("Downloads.Schedule.Set", params, &result)
+  return &result, err
+}
+
+var DownloadsScheduleSet *DownloadsScheduleSetType
+
 
 //==============================
 // Update
@@ -2749,6 +3605,46 @@ func (r *LaunchType) TestCall(rc *butlerd.RequestContext, params butlerd.LaunchP
 
 var Launch *LaunchType
 
+// Launch.History (Request)
+
+type LaunchHistoryType struct {}
+
+var _ RequestMessage = (*LaunchHistoryType)(nil)
+
+func (r *LaunchHistoryType) Method() string {
+  return "Launch.History"
+}
+
+func (r *LaunchHistoryType) Register(router router, f func(*butlerd.RequestContext, butlerd.LaunchHistoryParams) (*butlerd.LaunchHistoryResult, error)) {
+  router.Register("Launch.History", func (rc *butlerd.RequestContext) (interface{}, error) {
+    var params butlerd.LaunchHistoryParams
+    err := json.Unmarshal(*rc.Params, &params)
+    if err != nil {
+    	return nil, &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+    }
+    err = params.Validate()
+    if err != nil {
+    	return nil, err
+    }
+    res, err := f(rc, params)
+    if err != nil {
+    	return nil, err
+    }
+    if res == nil {
+    	return nil, errors.New("internal error: nil result for Launch.History")
+    }
+    return res, nil
+  })
+}
+
+func (r *LaunchHistoryType) TestCall(rc *butlerd.RequestContext, params butlerd.LaunchHistoryParams) (*butlerd.LaunchHistoryResult, error) {
+  var result butlerd.LaunchHistoryResult
+  err := rc.Call("Launch.History", params, &result)
+  return &result, err
+}
+
+var LaunchHistory *LaunchHistoryType
+
 // LaunchRunning (Notification)
 
 type LaunchRunningType struct {}
@@ -3392,6 +4288,7 @@ func EnsureAllRequests(router *butlerd.Router) {
   if _, ok := router.Handlers["Version.Get"]; !ok { panic("missing request handler for (Version.Get)") }
   if _, ok := router.Handlers["Network.SetSimulateOffline"]; !ok { panic("missing request handler for (Network.SetSimulateOffline)") }
   if _, ok := router.Handlers["Network.SetBandwidthThrottle"]; !ok { panic("missing request handler for (Network.SetBandwidthThrottle)") }
+  if _, ok := router.Handlers["Network.SetMetered"]; !ok { panic("missing request handler for (Network.SetMetered)") }
   if _, ok := router.Handlers["Profile.List"]; !ok { panic("missing request handler for (Profile.List)") }
   if _, ok := router.Handlers["Profile.LoginWithPassword"]; !ok { panic("missing request handler for (Profile.LoginWithPassword)") }
   if _, ok := router.Handlers["Profile.LoginWithAPIKey"]; !ok { panic("missing request handler for (Profile.LoginWithAPIKey)") }
@@ -3414,19 +4311,36 @@ func EnsureAllRequests(router *butlerd.Router) {
   if _, ok := router.Handlers["Fetch.Commons"]; !ok { panic("missing request handler for (Fetch.Commons)") }
   if _, ok := router.Handlers["Fetch.Caves"]; !ok { panic("missing request handler for (Fetch.Caves)") }
   if _, ok := router.Handlers["Fetch.Cave"]; !ok { panic("missing request handler for (Fetch.Cave)") }
+  if _, ok := router.Handlers["Fetch.Cave.Sessions"]; !ok { panic("missing request handler for (Fetch.Cave.Sessions)") }
   if _, ok := router.Handlers["Fetch.ExpireAll"]; !ok { panic("missing request handler for (Fetch.ExpireAll)") }
   if _, ok := router.Handlers["Game.FindUploads"]; !ok { panic("missing request handler for (Game.FindUploads)") }
   if _, ok := router.Handlers["Install.Queue"]; !ok { panic("missing request handler for (Install.Queue)") }
   if _, ok := router.Handlers["Install.Plan"]; !ok { panic("missing request handler for (Install.Plan)") }
   if _, ok := router.Handlers["Caves.SetPinned"]; !ok { panic("missing request handler for (Caves.SetPinned)") }
+  if _, ok := router.Handlers["Caves.SetSandboxPolicy"]; !ok { panic("missing request handler for (Caves.SetSandboxPolicy)") }
+  if _, ok := router.Handlers["Caves.GetSandboxPolicy"]; !ok { panic("missing request handler for (Caves.GetSandboxPolicy)") }
+  if _, ok := router.Handlers["Caves.Verify"]; !ok { panic("missing request handler for (Caves.Verify)") }
+  if _, ok := router.Handlers["Caves.Repair"]; !ok { panic("missing request handler for (Caves.Repair)") }
+  if _, ok := router.Handlers["Caves.ScanIntegrity"]; !ok { panic("missing request handler for (Caves.ScanIntegrity)") }
+  if _, ok := router.Handlers["Caves.GetIntegrity"]; !ok { panic("missing request handler for (Caves.GetIntegrity)") }
+  if _, ok := router.Handlers["Caves.ScanSchedule.Get"]; !ok { panic("missing request handler for (Caves.ScanSchedule.Get)") }
+  if _, ok := router.Handlers["Caves.ScanSchedule.Set"]; !ok { panic("missing request handler for (Caves.ScanSchedule.Set)") }
+  if _, ok := router.Handlers["Caves.Rollback"]; !ok { panic("missing request handler for (Caves.Rollback)") }
+  if _, ok := router.Handlers["Caves.Saves.List"]; !ok { panic("missing request handler for (Caves.Saves.List)") }
+  if _, ok := router.Handlers["Caves.Saves.Backup"]; !ok { panic("missing request handler for (Caves.Saves.Backup)") }
+  if _, ok := router.Handlers["Caves.Saves.Restore"]; !ok { panic("missing request handler for (Caves.Saves.Restore)") }
   if _, ok := router.Handlers["Install.Perform"]; !ok { panic("missing request handler for (Install.Perform)") }
+  if _, ok := router.Handlers["Install.FromFile"]; !ok { panic("missing request handler for (Install.FromFile)") }
   if _, ok := router.Handlers["Install.Cancel"]; !ok { panic("missing request handler for (Install.Cancel)") }
   if _, ok := router.Handlers["Uninstall.Perform"]; !ok { panic("missing request handler for (Uninstall.Perform)") }
+  if _, ok := router.Handlers["Uninstall.Plan"]; !ok { panic("missing request handler for (Uninstall.Plan)") }
   if _, ok := router.Handlers["Install.VersionSwitch.Queue"]; !ok { panic("missing request handler for (Install.VersionSwitch.Queue)") }
   if _, ok := router.Handlers["Install.Locations.List"]; !ok { panic("missing request handler for (Install.Locations.List)") }
   if _, ok := router.Handlers["Install.Locations.Add"]; !ok { panic("missing request handler for (Install.Locations.Add)") }
   if _, ok := router.Handlers["Install.Locations.Remove"]; !ok { panic("missing request handler for (Install.Locations.Remove)") }
   if _, ok := router.Handlers["Install.Locations.GetByID"]; !ok { panic("missing request handler for (Install.Locations.GetByID)") }
+  if _, ok := router.Handlers["Install.Locations.SetLANSharing"]; !ok { panic("missing request handler for (Install.Locations.SetLANSharing)") }
+  if _, ok := router.Handlers["Install.Locations.SetStoreLinks"]; !ok { panic("missing request handler for (Install.Locations.SetStoreLinks)") }
   if _, ok := router.Handlers["Install.Locations.Scan"]; !ok { panic("missing request handler for (Install.Locations.Scan)") }
   if _, ok := router.Handlers["Downloads.Queue"]; !ok { panic("missing request handler for (Downloads.Queue)") }
   if _, ok := router.Handlers["Downloads.Prioritize"]; !ok { panic("missing request handler for (Downloads.Prioritize)") }
@@ -3436,9 +4350,12 @@ func EnsureAllRequests(router *butlerd.Router) {
   if _, ok := router.Handlers["Downloads.Drive.Cancel"]; !ok { panic("missing request handler for (Downloads.Drive.Cancel)") }
   if _, ok := router.Handlers["Downloads.Retry"]; !ok { panic("missing request handler for (Downloads.Retry)") }
   if _, ok := router.Handlers["Downloads.Discard"]; !ok { panic("missing request handler for (Downloads.Discard)") }
+  if _, ok := router.Handlers["Downloads.Schedule.Get"]; !ok { panic("missing request handler for (Downloads.Schedule.Get)") }
+  if _, ok := router.Handlers["Downloads.Schedule.Set"]; !ok { panic("missing request handler for (Downloads.Schedule.Set)") }
   if _, ok := router.Handlers["CheckUpdate"]; !ok { panic("missing request handler for (CheckUpdate)") }
   if _, ok := router.Handlers["SnoozeCave"]; !ok { panic("missing request handler for (SnoozeCave)") }
   if _, ok := router.Handlers["Launch"]; !ok { panic("missing request handler for (Launch)") }
+  if _, ok := router.Handlers["Launch.History"]; !ok { panic("missing request handler for (Launch.History)") }
   if _, ok := router.Handlers["CleanDownloads.Search"]; !ok { panic("missing request handler for (CleanDownloads.Search)") }
   if _, ok := router.Handlers["CleanDownloads.Apply"]; !ok { panic("missing request handler for (CleanDownloads.Apply)") }
   if _, ok := router.Handlers["System.StatFS"]; !ok { panic("missing request handler for (System.StatFS)") }
@@ -0,0 +1,39 @@
+//+build linux
+
+package butlerd
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// isTrustedPeer returns true if the other end of a unix socket
+// is a process running as the same user as us.
+func isTrustedPeer(conn net.Conn) (bool, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return false, nil
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if credErr != nil {
+		return false, errors.WithStack(credErr)
+	}
+
+	return int(cred.Uid) == os.Getuid(), nil
+}
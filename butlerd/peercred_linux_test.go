@@ -0,0 +1,64 @@
+// +build linux
+
+package butlerd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// accepted returns the server end of a connection to listener
+func accepted(t *testing.T, listener net.Listener) net.Conn {
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(conns)
+			return
+		}
+		conns <- conn
+	}()
+
+	client, err := net.Dial(listener.Addr().Network(), listener.Addr().String())
+	wtest.Must(t, err)
+	defer client.Close()
+
+	conn, ok := <-conns
+	if !ok {
+		t.Fatal("could not accept connection")
+	}
+	return conn
+}
+
+func TestIsTrustedPeer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "butlerd-peercred")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	unixListener, err := net.Listen("unix", filepath.Join(dir, "butlerd.sock"))
+	wtest.Must(t, err)
+	defer unixListener.Close()
+
+	conn := accepted(t, unixListener)
+	trusted, err := isTrustedPeer(conn)
+	conn.Close()
+	wtest.Must(t, err)
+	assert.True(t, trusted, "we're running as ourselves")
+
+	// can't tell who's on the other end of a TCP connection
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	wtest.Must(t, err)
+	defer tcpListener.Close()
+
+	conn = accepted(t, tcpListener)
+	trusted, err = isTrustedPeer(conn)
+	conn.Close()
+	wtest.Must(t, err)
+	assert.False(t, trusted)
+}
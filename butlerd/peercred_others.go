@@ -0,0 +1,12 @@
+//+build !linux
+
+package butlerd
+
+import "net"
+
+// isTrustedPeer always returns false on platforms where we
+// don't know how to read peer credentials: clients have to
+// call Meta.Authenticate.
+func isTrustedPeer(conn net.Conn) (bool, error) {
+	return false, nil
+}
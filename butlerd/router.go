@@ -70,9 +70,16 @@ type Router struct {
 
 	backgroundTaskIDSeed BackgroundTaskID
 
+	// connections that asked for global notifications, see broadcastConn
+	conns     map[*jsonrpc2.Conn]struct{}
+	connsLock sync.Mutex
+
 	ButlerVersion       string
 	ButlerVersionString string
 
+	// Folder of the dedupe store installs should use, if any
+	StoreDir string
+
 	globalConsumer *state.Consumer
 }
 
@@ -101,6 +108,8 @@ func NewRouter(dbPool *sqlite.Pool, getClient GetClientFunc, httpClient *http.Cl
 
 		backgroundTaskIDSeed: 0,
 
+		conns: make(map[*jsonrpc2.Conn]struct{}),
+
 		globalConsumer: &state.Consumer{
 			OnMessage: func(lvl string, msg string) {
 				comm.Logf("[router] [%s] %s", lvl, msg)
@@ -253,12 +262,14 @@ func (r *Router) Dispatch(ctx context.Context, origConn *jsonrpc2.Conn, req *jso
 
 			ButlerVersion:       r.ButlerVersion,
 			ButlerVersionString: r.ButlerVersionString,
+			StoreDir:            r.StoreDir,
 
 			Group:    r.Group,
 			Shutdown: r.initiateShutdown,
 
-			origConn: origConn,
-			method:   method,
+			origConn:          origConn,
+			method:            method,
+			receiveBroadcasts: r.trackConn,
 
 			QueueBackgroundTask: r.QueueBackgroundTask,
 		}
@@ -269,44 +280,7 @@ func (r *Router) Dispatch(ctx context.Context, origConn *jsonrpc2.Conn, req *jso
 			}
 		} else {
 			if h, ok := r.Handlers[method]; ok {
-				rc.Consumer.OnProgress = func(alpha float64) {
-					if rc.tracker == nil {
-						// skip
-						return
-					}
-
-					rc.tracker.SetProgress(alpha)
-					notif := ProgressNotification{
-						Progress: alpha,
-					}
-					stats := rc.tracker.Stats()
-					if stats != nil {
-						if stats.TimeLeft() != nil {
-							notif.ETA = stats.TimeLeft().Seconds()
-						}
-						if stats.BPS() != nil {
-							notif.BPS = stats.BPS().Value
-						} else {
-							notif.BPS = timeout.GetBPS()
-						}
-					}
-					// cannot use autogenerated wrappers to avoid import cycles
-					rc.Notify("Progress", notif)
-				}
-				rc.Consumer.OnProgressLabel = func(label string) {
-					// muffin
-				}
-				rc.Consumer.OnPauseProgress = func() {
-					if rc.tracker != nil {
-						rc.tracker.Pause()
-					}
-				}
-				rc.Consumer.OnResumeProgress = func() {
-					if rc.tracker != nil {
-						rc.tracker.Resume()
-					}
-				}
-
+				rc.trackProgress()
 				res, err = h(rc)
 			} else {
 				err = &RpcError{
@@ -395,7 +369,7 @@ func (r *Router) doBackgroundTask(id BackgroundTaskID, bt BackgroundTask) {
 		Ctx:         r.backgroundContext,
 		Consumer:    consumer,
 		Params:      nil,
-		Conn:        nil,
+		Conn:        &broadcastConn{r},
 		CancelFuncs: r.CancelFuncs,
 		dbPool:      r.dbPool,
 		Client:      r.getClient,
@@ -405,6 +379,7 @@ func (r *Router) doBackgroundTask(id BackgroundTaskID, bt BackgroundTask) {
 
 		ButlerVersion:       r.ButlerVersion,
 		ButlerVersionString: r.ButlerVersionString,
+		StoreDir:            r.StoreDir,
 
 		Group:    r.Group,
 		Shutdown: r.initiateShutdown,
@@ -425,6 +400,55 @@ func (r *Router) doBackgroundTask(id BackgroundTaskID, bt BackgroundTask) {
 	}
 }
 
+// trackConn makes a client connection receive notifications
+// of background tasks, until it's closed
+func (r *Router) trackConn(conn *jsonrpc2.Conn) {
+	r.connsLock.Lock()
+	defer r.connsLock.Unlock()
+
+	if _, ok := r.conns[conn]; ok {
+		return
+	}
+	r.conns[conn] = struct{}{}
+
+	go func() {
+		<-conn.DisconnectNotify()
+		r.connsLock.Lock()
+		delete(r.conns, conn)
+		r.connsLock.Unlock()
+	}()
+}
+
+// broadcastConn is the connection of background tasks: they don't belong
+// to a client, so their notifications are sent to clients that asked for
+// global notifications, see RequestContext.ReceiveBroadcasts.
+type broadcastConn struct {
+	r *Router
+}
+
+var _ Conn = (*broadcastConn)(nil)
+
+func (bc *broadcastConn) Notify(ctx context.Context, method string, params interface{}) error {
+	bc.r.connsLock.Lock()
+	var conns []*jsonrpc2.Conn
+	for conn := range bc.r.conns {
+		conns = append(conns, conn)
+	}
+	bc.r.connsLock.Unlock()
+
+	for _, conn := range conns {
+		err := conn.Notify(ctx, method, params)
+		if err != nil {
+			bc.r.Logf("Could not notify client of %s: %v", method, err)
+		}
+	}
+	return nil
+}
+
+func (bc *broadcastConn) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return errors.Errorf("Background tasks can't call %s on clients", method)
+}
+
 func (r *Router) QueueBackgroundTask(bt BackgroundTask) {
 	r.inflightLock.Lock()
 	id := r.generateBackgroundTaskID()
@@ -460,6 +484,9 @@ type RequestContext struct {
 	ButlerVersion       string
 	ButlerVersionString string
 
+	// Folder of the dedupe store installs should use, if any
+	StoreDir string
+
 	Group    *singleflight.Group
 	Shutdown func()
 
@@ -468,6 +495,32 @@ type RequestContext struct {
 
 	method   string
 	origConn *jsonrpc2.Conn
+
+	receiveBroadcasts func(conn *jsonrpc2.Conn)
+}
+
+// ReceiveBroadcasts makes the connection this request came from receive
+// notifications of background tasks, until it's closed.
+func (rc *RequestContext) ReceiveBroadcasts() {
+	if rc.receiveBroadcasts == nil || rc.origConn == nil {
+		return
+	}
+	rc.receiveBroadcasts(rc.origConn)
+}
+
+// NewRequestContext returns a request context that isn't tied to a
+// client connection, to run endpoints and operations directly, from
+// tests for example. Notifications and calls go to conn.
+func NewRequestContext(ctx context.Context, consumer *state.Consumer, conn Conn, dbPool *sqlite.Pool) *RequestContext {
+	return &RequestContext{
+		Ctx:      ctx,
+		Consumer: consumer,
+		Conn:     conn,
+		CancelFuncs: &CancelFuncs{
+			Funcs: make(map[string]context.CancelFunc),
+		},
+		dbPool: dbPool,
+	}
 }
 
 type WithParamsFunc func() (interface{}, error)
@@ -525,6 +578,63 @@ func (rc *RequestContext) ProfileClient(profileID int64) (*models.Profile, *itch
 	return profile, rc.Client(profile.APIKey)
 }
 
+// trackProgress turns progress reported to rc's consumer
+// into Progress notifications.
+func (rc *RequestContext) trackProgress() {
+	rc.Consumer.OnProgress = func(alpha float64) {
+		if rc.tracker == nil {
+			// skip
+			return
+		}
+
+		rc.tracker.SetProgress(alpha)
+		notif := ProgressNotification{
+			Progress: alpha,
+		}
+		stats := rc.tracker.Stats()
+		if stats != nil {
+			if stats.TimeLeft() != nil {
+				notif.ETA = stats.TimeLeft().Seconds()
+			}
+			if stats.BPS() != nil {
+				notif.BPS = stats.BPS().Value
+			} else {
+				notif.BPS = timeout.GetBPS()
+			}
+		}
+		// cannot use autogenerated wrappers to avoid import cycles
+		rc.Notify("Progress", notif)
+	}
+	rc.Consumer.OnProgressLabel = func(label string) {
+		// muffin
+	}
+	rc.Consumer.OnPauseProgress = func() {
+		if rc.tracker != nil {
+			rc.tracker.Pause()
+		}
+	}
+	rc.Consumer.OnResumeProgress = func() {
+		if rc.tracker != nil {
+			rc.tracker.Resume()
+		}
+	}
+}
+
+// Fork returns a copy of rc for work that runs concurrently with other
+// work of the same request. It has its own context, progress tracking
+// and notification interceptors, and logs to rc's consumer.
+func (rc *RequestContext) Fork(ctx context.Context) *RequestContext {
+	forked := *rc
+	forked.Ctx = ctx
+	forked.Consumer = &state.Consumer{
+		OnMessage: rc.Consumer.OnMessage,
+	}
+	forked.notificationInterceptors = nil
+	forked.tracker = nil
+	forked.trackProgress()
+	return &forked
+}
+
 func (rc *RequestContext) StartProgress() {
 	rc.StartProgressWithTotalBytes(0)
 }
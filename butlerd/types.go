@@ -1,6 +1,7 @@
 package butlerd
 
 import (
+	"regexp"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -8,7 +9,11 @@ import (
 	"github.com/itchio/ox"
 )
 
-// When using TCP transport, must be the first message sent
+// When using TCP transport, must be the first message sent.
+//
+// When using the unix transport with `--trust-peer`, clients running
+// as the same user as the daemon are already authenticated, and
+// may skip this call.
 //
 // @name Meta.Authenticate
 // @category Utilities
@@ -132,6 +137,24 @@ func (p NetworkSetBandwidthThrottleParams) Validate() error {
 
 type NetworkSetBandwidthThrottleResult struct{}
 
+// Tell butler whether the current connection is metered, which
+// the client is in a better position to find out. Downloads pause
+// while it is, if the download schedule says so.
+//
+// @name Network.SetMetered
+// @category Utilities
+// @caller client
+type NetworkSetMeteredParams struct {
+	// If true, the connection is metered
+	Metered bool `json:"metered"`
+}
+
+func (p NetworkSetMeteredParams) Validate() error {
+	return nil
+}
+
+type NetworkSetMeteredResult struct{}
+
 //----------------------------------------------------------------------
 // Profile
 //----------------------------------------------------------------------
@@ -1018,6 +1041,12 @@ type InstallLocationSummary struct {
 	ID       string                   `json:"id"`
 	Path     string                   `json:"path"`
 	SizeInfo *InstallLocationSizeInfo `json:"sizeInfo,omitempty"`
+	// True if builds installed here are shared on the local
+	// network, see @@InstallLocationsSetLANSharingParams
+	LANSharing bool `json:"lanSharing"`
+	// True if files of builds installed here are linked from the
+	// store, see @@InstallLocationsSetStoreLinksParams
+	StoreLinks bool `json:"storeLinks"`
 }
 
 type InstallLocationSizeInfo struct {
@@ -1119,6 +1148,32 @@ type FetchCaveResult struct {
 	Cave *Cave `json:"cave"`
 }
 
+// Retrieve the most recent launch sessions of a cave, along with
+// the end of the game's output. Useful to investigate crashes.
+//
+// @name Fetch.Cave.Sessions
+// @category Fetch
+// @caller client
+type FetchCaveSessionsParams struct {
+	CaveID string `json:"caveId"`
+
+	// Maximum number of sessions to return, defaults to all
+	// the sessions butler kept.
+	// @optional
+	Limit int64 `json:"limit"`
+}
+
+func (p FetchCaveSessionsParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type FetchCaveSessionsResult struct {
+	// Most recent first
+	Sessions []*LaunchSession `json:"sessions"`
+}
+
 // Mark all local data as stale.
 //
 // @name Fetch.ExpireAll
@@ -1228,6 +1283,18 @@ type InstallQueueParams struct {
 	// Don't run install prepare (assume we can just run it at perform time)
 	// @optional
 	FastQueue bool `json:"fastQueue"`
+
+	// Path of a local file or folder to install from, instead of
+	// downloading the upload. Upload must be specified.
+	// See @@InstallFromFileParams.
+	// @optional
+	LocalSource string `json:"localSource,omitempty"`
+
+	// If true, and this turns out to be an upgrade applied by patching,
+	// keep a copy of the files it overwrites, so the cave can be
+	// rolled back to its current build. See @@CavesRollbackParams.
+	// @optional
+	KeepRollback bool `json:"keepRollback,omitempty"`
 }
 
 func (p InstallQueueParams) Validate() error {
@@ -1312,6 +1379,420 @@ func (p CavesSetPinnedParams) Validate() error {
 
 type CavesSetPinnedResult struct{}
 
+// Override the sandbox policy of the manifest action(s) of a cave.
+// It only applies when the game is launched in a sandbox.
+//
+// @name Caves.SetSandboxPolicy
+// @category Install
+// @caller client
+type CavesSetSandboxPolicyParams struct {
+	// ID of the cave to set the policy of
+	CaveID string `json:"caveId"`
+
+	// Fields set here take precedence over the manifest's
+	// policy. If nil, the override is cleared.
+	Policy *SandboxPolicy `json:"policy,omitempty"`
+}
+
+func (p CavesSetSandboxPolicyParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesSetSandboxPolicyResult struct{}
+
+// Retrieve the sandbox policy override set with
+// @@CavesSetSandboxPolicyParams, if any.
+//
+// @name Caves.GetSandboxPolicy
+// @category Install
+// @caller client
+type CavesGetSandboxPolicyParams struct {
+	// ID of the cave to get the policy of
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesGetSandboxPolicyParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesGetSandboxPolicyResult struct {
+	// The player's override, nil if there's none
+	Policy *SandboxPolicy `json:"policy,omitempty"`
+}
+
+// Check the files of an installed cave. For wharf-enabled uploads,
+// every file is checked against the build's signature, fetched from
+// itch.io. For others, only the presence of the files listed in the
+// install receipt is checked.
+//
+// Damaged caves can be fixed with @@CavesRepairParams.
+//
+// @name Caves.Verify
+// @category Install
+// @caller client
+type CavesVerifyParams struct {
+	// ID of the cave to verify
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesVerifyParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesVerifyResult struct {
+	// True if file contents were checked against a signature,
+	// false if only their presence was checked
+	CheckedContents bool `json:"checkedContents"`
+
+	// True if nothing is missing or corrupted
+	Healthy bool `json:"healthy"`
+
+	// What's wrong, file by file
+	Wounds []*CaveWound `json:"wounds"`
+
+	// Total size of missing or corrupted data, in bytes
+	CorruptedBytes int64 `json:"corruptedBytes"`
+}
+
+// CaveWound is a missing or corrupted entry of a cave
+//
+// @category Install
+type CaveWound struct {
+	// Path of the file, directory or symlink, relative to the install folder
+	Path string `json:"path"`
+
+	Kind CaveWoundKind `json:"kind"`
+
+	// Size of the damaged parts, in bytes
+	Size int64 `json:"size"`
+}
+
+// @category Install
+type CaveWoundKind string
+
+const (
+	// The entry isn't there at all
+	CaveWoundKindMissing CaveWoundKind = "missing"
+	// The entry is there, but its contents (or
+	// symlink destination) are wrong
+	CaveWoundKindCorrupted CaveWoundKind = "corrupted"
+)
+
+// Fix a cave's missing or corrupted files. For wharf-enabled uploads,
+// only the damaged files are fetched, by healing the installed build.
+// Others are reinstalled in full.
+//
+// @name Caves.Repair
+// @category Install
+// @caller client
+type CavesRepairParams struct {
+	// ID of the cave to repair
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesRepairParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesRepairResult struct{}
+
+// Quickly check an installed cave for problems, the way background
+// integrity scans do: files are compared to their size and modification
+// time when they were installed, and only files that changed are hashed
+// and checked against the build's signature.
+//
+// See @@CavesVerifyParams for a full check.
+//
+// @name Caves.ScanIntegrity
+// @category Install
+// @caller client
+type CavesScanIntegrityParams struct {
+	// ID of the cave to scan
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesScanIntegrityParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesScanIntegrityResult struct {
+	Integrity *CaveIntegrity `json:"integrity"`
+}
+
+// Retrieve the result of the last integrity scan of a cave.
+//
+// @name Caves.GetIntegrity
+// @category Install
+// @caller client
+type CavesGetIntegrityParams struct {
+	// ID of the cave
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesGetIntegrityParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesGetIntegrityResult struct {
+	// Nil if the cave was never scanned since it was installed
+	// @optional
+	Integrity *CaveIntegrity `json:"integrity,omitempty"`
+}
+
+// CaveIntegrity is the result of an integrity scan of a cave
+//
+// @category Install
+type CaveIntegrity struct {
+	CaveID    string     `json:"caveId"`
+	CheckedAt *time.Time `json:"checkedAt"`
+
+	// True if no problems were found
+	Healthy bool `json:"healthy"`
+	// True if files that changed were checked against the build's
+	// signature. If false, only missing files and files whose size
+	// changed are reported.
+	CheckedContents bool `json:"checkedContents"`
+
+	// What's wrong, file by file
+	Wounds []*CaveWound `json:"wounds"`
+}
+
+// Sent on the @@MetaFlowParams conversation when a background
+// integrity scan finds problems with a cave. It can be fixed with @@CavesRepairParams.
+//
+// @name Caves.IntegrityProblem
+// @category Install
+type CavesIntegrityProblemNotification struct {
+	Integrity *CaveIntegrity `json:"integrity"`
+}
+
+// Retrieve the schedule of background integrity scans, which
+// is persisted in the database.
+//
+// @name Caves.ScanSchedule.Get
+// @category Install
+// @caller client
+type CavesScanScheduleGetParams struct{}
+
+func (p CavesScanScheduleGetParams) Validate() error {
+	return nil
+}
+
+type CavesScanScheduleGetResult struct {
+	Schedule *IntegrityScanSchedule `json:"schedule"`
+}
+
+// Replace the schedule of background integrity scans.
+//
+// @name Caves.ScanSchedule.Set
+// @category Install
+// @caller client
+type CavesScanScheduleSetParams struct {
+	Schedule *IntegrityScanSchedule `json:"schedule"`
+}
+
+func (p CavesScanScheduleSetParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Schedule, validation.Required),
+	)
+}
+
+type CavesScanScheduleSetResult struct{}
+
+// If enabled, while butlerd runs, each cave is scanned in the background
+// every IntervalHours, one cave at a time. Problems are reported via
+// @@CavesIntegrityProblemNotification.
+//
+// @category Install
+type IntegrityScanSchedule struct {
+	// Scan caves in the background. Off by default.
+	Enabled bool `json:"enabled"`
+	// How often to scan each cave, in hours. Defaults to a week.
+	IntervalHours int64 `json:"intervalHours"`
+}
+
+func (iss IntegrityScanSchedule) Validate() error {
+	return validation.ValidateStruct(&iss,
+		validation.Field(&iss.IntervalHours, validation.Min(0)),
+	)
+}
+
+// Restore the build a cave had before its last upgrade. Only works
+// if the upgrade was queued with `keepRollback`, see @@InstallQueueParams,
+// and nothing was installed to the cave since.
+//
+// Only files the upgrade overwrote or deleted are kept, so rolling
+// back doesn't download anything.
+//
+// @name Caves.Rollback
+// @category Install
+// @caller client
+type CavesRollbackParams struct {
+	// ID of the cave to roll back
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesRollbackParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesRollbackResult struct {
+	// The build the cave is now at
+	Build *itchio.Build `json:"build"`
+}
+
+// List save files of a cave, and snapshots previously taken of them.
+//
+// Save files are found by combining the `saves` patterns of the game's
+// manifest, files of the install folder that weren't installed and look
+// like saves, files of the cave's private sandbox home, and folders
+// named after the game in well-known save locations of the current OS.
+//
+// @name Caves.Saves.List
+// @category Install
+// @caller client
+type CavesSavesListParams struct {
+	// ID of the cave whose saves to list
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesSavesListParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesSavesListResult struct {
+	// Save files found right now
+	Files []*SaveFile `json:"files"`
+	// Snapshots of the game's saves, most recent first. They're kept
+	// per game, so they outlive caves.
+	Snapshots []*SaveSnapshot `json:"snapshots"`
+}
+
+// Take a snapshot of a cave's save files, see @@CavesSavesListParams.
+//
+// Snapshots are also taken automatically before uninstalling a cave,
+// and before upgrading it. Only the 10 most recent automatic snapshots
+// of a game are kept.
+//
+// @name Caves.Saves.Backup
+// @category Install
+// @caller client
+type CavesSavesBackupParams struct {
+	// ID of the cave whose saves to back up
+	CaveID string `json:"caveId"`
+}
+
+func (p CavesSavesBackupParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesSavesBackupResult struct {
+	// The snapshot taken, null if no save files were found
+	// @optional
+	Snapshot *SaveSnapshot `json:"snapshot,omitempty"`
+}
+
+// Restore save files of a cave from a snapshot. Current save files
+// are backed up first, and files that aren't in the snapshot are
+// left alone.
+//
+// @name Caves.Saves.Restore
+// @category Install
+// @caller client
+type CavesSavesRestoreParams struct {
+	// ID of the cave whose saves to restore
+	CaveID string `json:"caveId"`
+
+	// ID of the snapshot to restore, defaults to the most recent one
+	// @optional
+	SnapshotID string `json:"snapshotId"`
+}
+
+func (p CavesSavesRestoreParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type CavesSavesRestoreResult struct {
+	// The snapshot that was restored
+	Snapshot *SaveSnapshot `json:"snapshot"`
+}
+
+// A SaveFile is a file a game keeps save data in
+//
+// @category Install
+type SaveFile struct {
+	// Absolute path of the file
+	Path string `json:"path"`
+	// How it was found
+	Source SaveSource `json:"source"`
+	// Size of the file, in bytes
+	Size int64 `json:"size"`
+}
+
+// @category Install
+type SaveSource string
+
+const (
+	// Matched by a pattern of the `saves` list of the game's manifest
+	SaveSourceManifest SaveSource = "manifest"
+	// In the install folder, but not installed, and looks like a save
+	SaveSourceAngel SaveSource = "angel"
+	// In a folder named after the game, in a well-known save location
+	SaveSourceKnownLocation SaveSource = "known-location"
+	// In the cave's private sandbox home, see @@SandboxPolicy
+	SaveSourceSandboxHome SaveSource = "sandbox-home"
+)
+
+// A SaveSnapshot is a zip archive of a game's save files
+//
+// @category Install
+type SaveSnapshot struct {
+	// Identifies the snapshot among those of the same game
+	ID string `json:"id"`
+	// Why the snapshot was taken
+	Reason SaveSnapshotReason `json:"reason"`
+	// When the snapshot was taken
+	CreatedAt *time.Time `json:"createdAt"`
+	// Absolute path of the zip archive
+	Path string `json:"path"`
+	// Size of the zip archive, in bytes
+	Size int64 `json:"size"`
+}
+
+// @category Install
+type SaveSnapshotReason string
+
+const (
+	// Asked for via @@CavesSavesBackupParams
+	SaveSnapshotReasonManual SaveSnapshotReason = "manual"
+	// Taken before uninstalling
+	SaveSnapshotReasonUninstall SaveSnapshotReason = "uninstall"
+	// Taken before upgrading
+	SaveSnapshotReasonUpgrade SaveSnapshotReason = "upgrade"
+	// Taken before restoring another snapshot
+	SaveSnapshotReasonRestore SaveSnapshotReason = "restore"
+)
+
 // Perform an install that was previously queued via
 // @@InstallQueueParams.
 //
@@ -1338,6 +1819,85 @@ func (p InstallPerformParams) Validate() error {
 
 type InstallPerformResult struct{}
 
+// Install a cave from a local file (like the upload's archive) or
+// folder (like the upload, already extracted), without downloading
+// anything.
+//
+// The source is matched to an upload by UploadID if it's given,
+// otherwise by its hash (if it was installed from before), otherwise
+// by its name and size among uploads butler knows about. The resulting
+// cave is just like one installed from itch.io, and can be updated
+// online later.
+//
+// @name Install.FromFile
+// @category Install
+// @caller client
+type InstallFromFileParams struct {
+	// Absolute path of the file or folder to install from
+	Path string `json:"path"`
+
+	// ID of the install location to install to.
+	// Required unless CaveID is given.
+	// @optional
+	InstallLocationID string `json:"installLocationId"`
+
+	// ID of a cave to install over, instead of creating a new one
+	// @optional
+	CaveID string `json:"caveId"`
+
+	// ID of the upload the source is. Its game must have been
+	// fetched before, see @@FetchGameUploadsParams.
+	// @optional
+	UploadID int64 `json:"uploadId"`
+
+	// ID of the build the source is, for wharf-enabled uploads.
+	// Defaults to the upload's latest known build. Either way, the
+	// installed files are checked against its signature, and the
+	// install fails if they don't match.
+	// @optional
+	BuildID int64 `json:"buildId"`
+
+	// If true, do not run windows installers, just extract
+	// whatever to the install folder.
+	// @optional
+	IgnoreInstallers bool `json:"ignoreInstallers,omitempty"`
+}
+
+func (p InstallFromFileParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Path, validation.Required),
+	)
+}
+
+type InstallFromFileResult struct {
+	CaveID        string         `json:"caveId"`
+	Game          *itchio.Game   `json:"game"`
+	Upload        *itchio.Upload `json:"upload"`
+	Build         *itchio.Build  `json:"build,omitempty"`
+	InstallFolder string         `json:"installFolder"`
+
+	// How the source was matched to an upload
+	MatchedBy SourceMatch `json:"matchedBy"`
+
+	// True if the installed files were checked against the
+	// signature of Build. If the signature couldn't be fetched,
+	// the cave is recorded without a build.
+	Verified bool `json:"verified"`
+}
+
+// @category Install
+type SourceMatch string
+
+const (
+	// Matched by the upload ID that was given
+	SourceMatchID SourceMatch = "id"
+	// Matched by the hash of a source installed from before
+	SourceMatchHash SourceMatch = "hash"
+	// Guessed from file name and size, only trusted
+	// if Verified is true as well
+	SourceMatchFilename SourceMatch = "filename"
+)
+
 // Attempt to gracefully cancel an ongoing operation.
 //
 // @name Install.Cancel
@@ -1372,6 +1932,20 @@ type UninstallPerformParams struct {
 	// remove the DB record and burn the install folder to the ground.
 	// @optional
 	Hard bool `json:"hard"`
+
+	// If true, files that weren't installed, like save files or
+	// configuration written by the game, are left in the install folder,
+	// and the cave's private sandbox home is kept as well.
+	// Ignored for hard uninstalls, and when there's no list of
+	// installed files.
+	// @optional
+	KeepAngels bool `json:"keepAngels"`
+
+	// Save files are backed up before uninstalling, see
+	// @@CavesSavesBackupParams. If that fails, the uninstall fails too,
+	// unless this is true.
+	// @optional
+	SkipSavesBackup bool `json:"skipSavesBackup"`
 }
 
 func (p UninstallPerformParams) Validate() error {
@@ -1382,6 +1956,65 @@ func (p UninstallPerformParams) Validate() error {
 
 type UninstallPerformResult struct{}
 
+// Find out what @@UninstallPerformParams would do with the same
+// parameters, without changing anything.
+//
+// @name Uninstall.Plan
+// @category Install
+// @caller client
+type UninstallPlanParams struct {
+	// The cave to uninstall
+	CaveID string `json:"caveId"`
+
+	// See @@UninstallPerformParams
+	// @optional
+	Hard bool `json:"hard"`
+
+	// See @@UninstallPerformParams
+	// @optional
+	KeepAngels bool `json:"keepAngels"`
+}
+
+func (p UninstallPlanParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.CaveID, validation.Required),
+	)
+}
+
+type UninstallPlanResult struct {
+	// Absolute path of the install folder
+	InstallFolder string `json:"installFolder"`
+
+	// The installer whose uninstall manager would run, like
+	// "archive", "msi", "nsis", "inno" or "naked". Empty for
+	// hard uninstalls.
+	Installer string `json:"installer"`
+	// True if uninstalling runs an uninstaller shipped with the game,
+	// or msiexec. Those remove files on their own, so the lists below
+	// are only what's left for butler to remove afterwards.
+	ExternalUninstaller bool `json:"externalUninstaller"`
+
+	// Installed files that would be removed, according to the receipt.
+	// If there's no receipt, all files of the install folder.
+	// (slash-separated paths, relative to the install folder)
+	Files []string `json:"files"`
+	// Folders that would be removed
+	Dirs []string `json:"dirs"`
+	// Files that weren't installed, like save files. They're removed
+	// too, unless `keepAngels` is set.
+	Angels []string `json:"angels"`
+	// Absolute path of the cave's private sandbox home, if it has one.
+	// It's kept or removed along with angels.
+	// @optional
+	SandboxHome string `json:"sandboxHome,omitempty"`
+	// Size of angels and of the sandbox home, in bytes
+	AngelsSize int64 `json:"angelsSize"`
+
+	// Disk space freed by the uninstall, in bytes. Files linked from
+	// the store don't count, their data stays in the store.
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
 // Prepare to queue a version switch. The client will
 // receive an @@InstallVersionSwitchPickParams.
 //
@@ -1483,6 +2116,8 @@ const (
 	TaskReasonInstall TaskReason = "install"
 	// Task was started for an uninstall operation
 	TaskReasonUninstall TaskReason = "uninstall"
+	// Task was started for @@CavesRollbackParams
+	TaskReasonRollback TaskReason = "rollback"
 )
 
 // @category Install
@@ -1499,6 +2134,8 @@ const (
 	TaskTypeUpdate TaskType = "update"
 	// We're healing from a signature and heal source
 	TaskTypeHeal TaskType = "heal"
+	// We're restoring files kept by an upgrade
+	TaskTypeRollback TaskType = "rollback"
 )
 
 // Each operation is made up of one or more tasks. This notification
@@ -1619,6 +2256,66 @@ type InstallLocationsGetByIDResult struct {
 	InstallLocation *InstallLocationSummary `json:"installLocation"`
 }
 
+// Enable or disable LAN sharing for an install location. When enabled,
+// builds installed there are advertised to other butlerd instances on
+// the local network (via mDNS), which may fetch their files instead of
+// downloading them from itch.io. Installs to that location also look for
+// the build on the local network first. Files fetched from peers are
+// checked against the build's signature, so they can't be tampered with.
+// Files are only served to instances that can prove they have that
+// signature, which itch.io only hands out to those who have access to
+// the build.
+//
+// Sharing starts or stops within a few seconds.
+//
+// @name Install.Locations.SetLANSharing
+// @category Install
+// @caller client
+type InstallLocationsSetLANSharingParams struct {
+	// identifier of the install location
+	ID string `json:"id"`
+	// whether to share builds installed there
+	Enabled bool `json:"enabled"`
+}
+
+func (p InstallLocationsSetLANSharingParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.ID, validation.Required),
+	)
+}
+
+type InstallLocationsSetLANSharingResult struct{}
+
+// Enable or disable linking files from the store for an install location.
+// It only has an effect if butlerd was started with a store (`--store-dir`).
+// When enabled, files of builds installed there that are identical to files
+// of other builds only take up disk space once.
+//
+// Where the filesystem doesn't support reflinks, files are hardlinked, and
+// made read-only: games that rewrite their own files fail to, and a game
+// that changes their permissions first changes them in every build that
+// shares them. Only enable it for games that leave their files alone.
+//
+// It applies to the next install, upgrade or repair.
+//
+// @name Install.Locations.SetStoreLinks
+// @category Install
+// @caller client
+type InstallLocationsSetStoreLinksParams struct {
+	// identifier of the install location
+	ID string `json:"id"`
+	// whether to link files of builds installed there from the store
+	Enabled bool `json:"enabled"`
+}
+
+func (p InstallLocationsSetStoreLinksParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.ID, validation.Required),
+	)
+}
+
+type InstallLocationsSetStoreLinksResult struct{}
+
 // @name Install.Locations.Scan
 // @category Install
 // @caller client
@@ -1738,13 +2435,24 @@ func (p DownloadsClearFinishedParams) Validate() error {
 type DownloadsClearFinishedResult struct {
 }
 
-// Drive downloads, which is: perform them one at a time,
+// Drive downloads, which is: perform them, by order of priority,
 // until they're all finished.
 //
+// Downloads being driven are leased in the database, so several butlerd
+// instances sharing a database never perform the same download. All slots
+// share the bandwidth throttle set with @@NetworkSetBandwidthThrottleParams.
+//
+// The drive honors the schedule set with @@DownloadsScheduleSetParams:
+// while downloads aren't allowed, it idles, pausing any download in progress.
+//
 // @name Downloads.Drive
 // @category Downloads
 // @caller client
-type DownloadsDriveParams struct{}
+type DownloadsDriveParams struct {
+	// How many downloads to perform at the same time, defaults to 1
+	// @optional
+	Slots int64 `json:"slots,omitempty"`
+}
 
 func (p DownloadsDriveParams) Validate() error {
 	return nil
@@ -1814,6 +2522,76 @@ const (
 	NetworkStatusOffline NetworkStatus = "offline"
 )
 
+// Sent during @@DownloadsDriveParams when the drive goes idle
+// because of the download schedule, and when it resumes.
+//
+// @name Downloads.Drive.ScheduleStatus
+type DownloadsDriveScheduleStatusNotification struct {
+	// The current schedule status
+	Status ScheduleStatus `json:"status"`
+	// Why the drive is idle
+	// @optional
+	Reason ScheduleIdleReason `json:"reason,omitempty"`
+	// When the download window opens next, if the drive is
+	// idle because we're outside of it
+	// @optional
+	ResumesAt *time.Time `json:"resumesAt,omitempty"`
+}
+
+type ScheduleStatus string
+
+const (
+	// Downloads are allowed
+	ScheduleStatusActive ScheduleStatus = "active"
+	// Downloads are paused until the schedule allows them again
+	ScheduleStatusIdle ScheduleStatus = "idle"
+)
+
+type ScheduleIdleReason string
+
+const (
+	// We're outside of the download window
+	ScheduleIdleReasonOutsideWindow ScheduleIdleReason = "outside-window"
+	// The connection is metered, see @@NetworkSetMeteredParams
+	ScheduleIdleReasonMetered ScheduleIdleReason = "metered"
+)
+
+// Restricts when @@DownloadsDriveParams performs downloads.
+type DownloadSchedule struct {
+	// Only download during this window of the day. Downloads may
+	// happen at any time of the day if unset.
+	// @optional
+	Window *DownloadWindow `json:"window,omitempty"`
+	// Pause downloads while the connection is metered,
+	// see @@NetworkSetMeteredParams
+	PauseWhenMetered bool `json:"pauseWhenMetered"`
+}
+
+func (ds DownloadSchedule) Validate() error {
+	return validation.ValidateStruct(&ds,
+		validation.Field(&ds.Window),
+	)
+}
+
+// A daily window, in the local time of the machine butler runs on.
+// If End is before Start, the window spans midnight, so 23:00 to 06:00
+// allows downloads at night.
+type DownloadWindow struct {
+	// Start of the window, as "HH:MM"
+	Start string `json:"start"`
+	// End of the window, as "HH:MM"
+	End string `json:"end"`
+}
+
+var timeOfDayRegexp = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+func (dw DownloadWindow) Validate() error {
+	return validation.ValidateStruct(&dw,
+		validation.Field(&dw.Start, validation.Required, validation.Match(timeOfDayRegexp)),
+		validation.Field(&dw.End, validation.Required, validation.Match(timeOfDayRegexp)),
+	)
+}
+
 type DownloadReason string
 
 const (
@@ -1882,6 +2660,39 @@ func (p DownloadsDiscardParams) Validate() error {
 
 type DownloadsDiscardResult struct{}
 
+// Retrieve the download schedule, which is persisted in the database.
+//
+// @name Downloads.Schedule.Get
+// @category Downloads
+// @caller client
+type DownloadsScheduleGetParams struct{}
+
+func (p DownloadsScheduleGetParams) Validate() error {
+	return nil
+}
+
+type DownloadsScheduleGetResult struct {
+	Schedule *DownloadSchedule `json:"schedule"`
+}
+
+// Replace the download schedule. Drives in progress pick
+// it up within a second.
+//
+// @name Downloads.Schedule.Set
+// @category Downloads
+// @caller client
+type DownloadsScheduleSetParams struct {
+	Schedule *DownloadSchedule `json:"schedule"`
+}
+
+func (p DownloadsScheduleSetParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Schedule, validation.Required),
+	)
+}
+
+type DownloadsScheduleSetResult struct{}
+
 //----------------------------------------------------------------------
 // CheckUpdate
 //----------------------------------------------------------------------
@@ -2019,6 +2830,69 @@ func (p LaunchParams) Validate() error {
 type LaunchResult struct {
 }
 
+// A single launch of a cave, see @@LaunchHistoryParams
+type LaunchSession struct {
+	ID       string `json:"id"`
+	CaveID   string `json:"caveId"`
+	GameID   int64  `json:"gameId"`
+	UploadID int64  `json:"uploadId"`
+	BuildID  int64  `json:"buildId"`
+
+	// Launch strategy used: native, html, url or shell
+	Strategy string `json:"strategy"`
+	// Name of the manifest action used
+	// @optional
+	ActionName string `json:"actionName,omitempty"`
+	Sandbox    bool   `json:"sandbox"`
+
+	StartedAt *time.Time `json:"startedAt"`
+	// Not set if the session hasn't ended
+	// @optional
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+
+	// Only set for games butler waits on (native launches)
+	// @optional
+	ExitCode *int64 `json:"exitCode,omitempty"`
+	// True if the launch failed, or the game exited
+	// with an error shortly after starting
+	Crashed bool `json:"crashed"`
+	// @optional
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// The last few kilobytes of the game's standard output
+	// @optional
+	Stdout string `json:"stdout,omitempty"`
+	// The last few kilobytes of the game's standard error
+	// @optional
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// Retrieve the most recent launch sessions, across all caves.
+// The game's output is not included, use @@FetchCaveSessionsParams
+// for that.
+//
+// @name Launch.History
+// @category Launch
+// @caller client
+type LaunchHistoryParams struct {
+	// Maximum number of sessions to return, defaults to 50
+	// @optional
+	Limit int64 `json:"limit"`
+
+	// If true, only return sessions that crashed
+	// @optional
+	CrashedOnly bool `json:"crashedOnly,omitempty"`
+}
+
+func (p LaunchHistoryParams) Validate() error {
+	return nil
+}
+
+type LaunchHistoryResult struct {
+	// Most recent first
+	Sessions []*LaunchSession `json:"sessions"`
+}
+
 // Sent during @@LaunchParams, when the game is configured, prerequisites are installed
 // sandbox is set up (if enabled), and the game is actually running.
 //
@@ -2085,6 +2959,12 @@ type PickManifestActionResult struct {
 type ShellLaunchParams struct {
 	// Absolute path of item to open, e.g. `D:\\Games\\Itch\\garden\\README.txt`
 	ItemPath string `json:"itemPath"`
+
+	// Environment variables requested by the manifest action, if any
+	Env map[string]string `json:"env,omitempty"`
+
+	// Working directory requested by the manifest action, if any
+	WorkingDirectory string `json:"workingDirectory,omitempty"`
 }
 
 func (p ShellLaunchParams) Validate() error {
@@ -2135,6 +3015,12 @@ type HTMLLaunchResult struct {
 type URLLaunchParams struct {
 	// URL to open, e.g. `https://itch.io/community`
 	URL string `json:"url"`
+
+	// Environment variables requested by the manifest action, if any
+	Env map[string]string `json:"env,omitempty"`
+
+	// Working directory requested by the manifest action, if any
+	WorkingDirectory string `json:"workingDirectory,omitempty"`
 }
 
 func (p URLLaunchParams) Validate() error {
@@ -2440,6 +3326,12 @@ const (
 	// Nothing that can be launched was found
 	CodeNoLaunchCandidates Code = 5000
 
+	// The pre-launch hook of a manifest action failed
+	CodePreLaunchHookFailed Code = 5001
+
+	// The post-exit hook of a manifest action failed
+	CodePostExitHookFailed Code = 5002
+
 	// Java Runtime Environment is required to launch this title.
 	CodeJavaRuntimeNeeded Code = 6000
 
@@ -2469,6 +3361,14 @@ type Manifest struct {
 	// Prereqs describe libraries or frameworks that must be installed
 	// prior to launching a game
 	Prereqs []*Prereq `json:"prereqs,omitempty"`
+
+	// Saves are glob patterns matching the files a game keeps save data
+	// in, relative to the install folder, or to the user's home folder
+	// if they start with `~/` (the cave's private home, if it's been
+	// launched with one). Matching folders are saved whole. Patterns
+	// can't contain `..`, or match the whole home folder.
+	// See @@CavesSavesBackupParams.
+	Saves []string `json:"saves,omitempty"`
 }
 
 // An Action is a choice for the user to pick when launching a game.
@@ -2487,9 +3387,19 @@ type Action struct {
 	// command-line arguments
 	Args []string `json:"args,omitempty"`
 
+	// environment variables to set, values may contain `{{installDir}}`
+	Env map[string]string `json:"env,omitempty"`
+
+	// working directory, relative to the manifest or absolute, may
+	// contain `{{installDir}}`. Defaults to the folder of the launch target.
+	Cwd string `json:"cwd,omitempty"`
+
 	// sandbox opt-in
 	Sandbox bool `json:"sandbox,omitempty"`
 
+	// what the game can do when sandboxed
+	SandboxPolicy *SandboxPolicy `json:"sandboxPolicy,omitempty"`
+
 	// requested API scope
 	Scope string `json:"scope,omitempty"`
 
@@ -2501,6 +3411,12 @@ type Action struct {
 
 	// localized action name
 	Locales map[string]*ActionLocale `json:"locales,omitempty"`
+
+	// command to run in the install folder before launching
+	PreLaunch *ActionHook `json:"preLaunch,omitempty"`
+
+	// command to run in the install folder after the game exits
+	PostExit *ActionHook `json:"postExit,omitempty"`
 }
 
 type Prereq struct {
@@ -2513,6 +3429,45 @@ type ActionLocale struct {
 	Name string `json:"name"`
 }
 
+// A SandboxPolicy describes what a sandboxed game can do. It can be
+// declared in a manifest action, and overriden by the player
+// with @@CavesSetSandboxPolicyParams. Unset fields keep the
+// value from the manifest, or the default.
+//
+// A manifest can only take access away: paths it lists are only
+// accessible if the player's override lists them too.
+type SandboxPolicy struct {
+	// Allow network access (default: true)
+	Network *bool `json:"network,omitempty"`
+
+	// Allow access to GPU devices (default: true)
+	GPU *bool `json:"gpu,omitempty"`
+
+	// Allow playing and recording sound (default: true)
+	Audio *bool `json:"audio,omitempty"`
+
+	// Give the game its own home folder instead of the player's
+	// (default: true). It's kept in the install location. Only the
+	// player can turn it off, a manifest can't.
+	PrivateHome *bool `json:"privateHome,omitempty"`
+
+	// Other paths the game can read, may contain `{{installDir}}`
+	ReadOnlyPaths []string `json:"readOnlyPaths,omitempty"`
+
+	// Other paths the game can read and write, may contain `{{installDir}}`
+	ReadWritePaths []string `json:"readWritePaths,omitempty"`
+}
+
+// An ActionHook is a command run before or after an action, with
+// the same sandbox settings and environment.
+type ActionHook struct {
+	// file path (relative to manifest or absolute), may contain `{{EXT}}` or `{{installDir}}`
+	Path string `json:"path"`
+
+	// command-line arguments, may contain `{{installDir}}`
+	Args []string `json:"args,omitempty"`
+}
+
 // Dates
 
 func FromDateTime(s string) (time.Time, error) {
@@ -0,0 +1,136 @@
+package butlerd
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+)
+
+type ServeWebSocketParams struct {
+	Handler  jsonrpc2.Handler
+	Consumer *state.Consumer
+	Listener net.Listener
+	Secret   string
+	Log      bool
+
+	// Values of the `Origin` header browsers are allowed to connect from,
+	// for example `http://localhost:8080`. "*" allows any origin.
+	// Clients that don't send an `Origin` header (ie. that aren't
+	// browsers) are always allowed.
+	AllowedOrigins []string
+
+	ShutdownChan chan struct{}
+}
+
+// ServeWebSocket accepts WebSocket connections until the shutdown channel
+// is closed. Each WebSocket message carries a single JSON-RPC 2.0 object,
+// and clients must call Meta.Authenticate first, just like over TCP.
+func (s *Server) ServeWebSocket(ctx context.Context, params ServeWebSocketParams) error {
+	consumer := params.Consumer
+
+	// connections aren't tracked anymore once we're closing,
+	// so waiting for them doesn't race with new ones
+	var wg sync.WaitGroup
+	var closingMutex sync.Mutex
+	closing := false
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(req *http.Request) bool {
+			return isAllowedOrigin(req.Header.Get("Origin"), params.AllowedOrigins)
+		},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		closingMutex.Lock()
+		if closing {
+			closingMutex.Unlock()
+			http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		wg.Add(1)
+		closingMutex.Unlock()
+		defer wg.Done()
+
+		wsConn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			// Upgrade already replied with an HTTP error
+			consumer.Warnf("While upgrading to WebSocket: %+v", err)
+			return
+		}
+
+		gh := &gatedHandler{
+			secret: params.Secret,
+			inner:  params.Handler,
+		}
+
+		connCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream := wsjsonrpc2.NewObjectStream(wsConn)
+		conn := jsonrpc2.NewConn(connCtx, stream, gh, connOpts(params.Log)...)
+		select {
+		case <-conn.DisconnectNotify():
+		case <-params.ShutdownChan:
+			conn.Close()
+		case <-ctx.Done():
+			conn.Close()
+		}
+	})
+
+	httpServer := &http.Server{
+		Handler: handler,
+	}
+
+	serveErrs := make(chan error, 1)
+	go func() {
+		serveErrs <- httpServer.Serve(params.Listener)
+	}()
+
+	shutdown := func() {
+		closingMutex.Lock()
+		closing = true
+		closingMutex.Unlock()
+
+		log.Printf("Closing WebSocket listener...")
+		err := httpServer.Close()
+		if err != nil {
+			log.Printf("While closing WebSocket listener: %+v", err)
+		}
+
+		log.Printf("Waiting for WebSocket connections to close...")
+		wg.Wait()
+		log.Printf("All WebSocket connections closed")
+	}
+
+	select {
+	case err := <-serveErrs:
+		return errors.WithStack(err)
+	case <-params.ShutdownChan:
+		shutdown()
+		return nil
+	case <-ctx.Done():
+		shutdown()
+		return nil
+	}
+}
+
+func isAllowedOrigin(origin string, allowedOrigins []string) bool {
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
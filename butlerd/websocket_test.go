@@ -0,0 +1,110 @@
+package butlerd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAllowedOrigin(t *testing.T) {
+	allowed := []string{"http://localhost:8080"}
+
+	assert.True(t, isAllowedOrigin("", allowed), "not a browser")
+	assert.True(t, isAllowedOrigin("http://localhost:8080", allowed))
+	assert.False(t, isAllowedOrigin("http://localhost:8081", allowed))
+	assert.False(t, isAllowedOrigin("http://evil.example", allowed))
+	assert.False(t, isAllowedOrigin("http://evil.example", nil))
+	assert.True(t, isAllowedOrigin("http://evil.example", []string{"*"}))
+}
+
+type pingHandler struct{}
+
+func (h *pingHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	conn.Reply(ctx, req.ID, "pong")
+}
+
+type wsResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpc2.Error `json:"error"`
+}
+
+func TestServeWebSocket(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	wtest.Must(t, err)
+
+	shutdownChan := make(chan struct{})
+	served := make(chan error, 1)
+	go func() {
+		s := NewServer("s3cr3t")
+		served <- s.ServeWebSocket(context.Background(), ServeWebSocketParams{
+			Handler:        &pingHandler{},
+			Consumer:       &state.Consumer{},
+			Listener:       listener,
+			Secret:         "s3cr3t",
+			AllowedOrigins: []string{"http://localhost:8080"},
+			ShutdownChan:   shutdownChan,
+		})
+	}()
+
+	address := "ws://" + listener.Addr().String()
+
+	// browsers on other pages can't connect
+	header := http.Header{}
+	header.Set("Origin", "http://evil.example")
+	_, httpRes, err := websocket.DefaultDialer.Dial(address, header)
+	assert.Error(t, err)
+	if assert.NotNil(t, httpRes) {
+		assert.EqualValues(t, http.StatusForbidden, httpRes.StatusCode)
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(address, nil)
+	wtest.Must(t, err)
+	defer ws.Close()
+
+	call := func(id int64, method string, params interface{}) *wsResponse {
+		wtest.Must(t, ws.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"method":  method,
+			"params":  params,
+		}))
+		var res wsResponse
+		wtest.Must(t, ws.ReadJSON(&res))
+		assert.EqualValues(t, id, res.ID)
+		return &res
+	}
+
+	res := call(1, "Test.Ping", map[string]interface{}{})
+	if assert.NotNil(t, res.Error, "must authenticate first") {
+		assert.EqualValues(t, jsonrpc2.CodeInvalidRequest, res.Error.Code)
+	}
+
+	res = call(2, "Meta.Authenticate", MetaAuthenticateParams{Secret: "wrong"})
+	assert.NotNil(t, res.Error)
+
+	res = call(3, "Meta.Authenticate", MetaAuthenticateParams{Secret: "s3cr3t"})
+	assert.Nil(t, res.Error)
+
+	res = call(4, "Test.Ping", map[string]interface{}{})
+	if assert.Nil(t, res.Error) {
+		assert.EqualValues(t, `"pong"`, string(res.Result))
+	}
+
+	close(shutdownChan)
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeWebSocket didn't return after shutdown")
+	}
+}
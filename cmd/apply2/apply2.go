@@ -11,7 +11,10 @@ import (
 
 	"github.com/dchest/safefile"
 	"github.com/itchio/butler/cmd/sizeof"
+	"github.com/itchio/butler/cmd/storecmd"
 	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
 	"github.com/itchio/butler/mansion"
 	"github.com/itchio/headway/united"
 	"github.com/itchio/savior/filesource"
@@ -59,13 +62,56 @@ func Register(ctx *mansion.Context) {
 	})
 }
 
+// Params describes a single patch to apply
+type Params struct {
+	// Patch file (.pwr) to apply, local path or URL
+	Patch string
+	// Directory with old files
+	Old string
+	// Directory for patched files. If empty, Old is patched in-place
+	Dir string
+	// Directory for temporary files and checkpoints
+	StagingDir string
+	// Signature file (.pws) to verify build against after patching
+	Signature string
+	// Seconds between checkpoints
+	SaveInterval float64
+	// Stop after the first checkpoint is saved
+	StopEarly bool
+	// If set, patched files are linked from this store
+	Store *store.Store
+}
+
 func Do(ctx *mansion.Context, consumer *state.Consumer) error {
+	st, dbPool, err := storecmd.Open(ctx)
+	if err != nil {
+		return err
+	}
+	if dbPool != nil {
+		defer dbPool.Close()
+	}
+
+	return Apply(consumer, &Params{
+		Patch:        args.patch,
+		Old:          args.old,
+		Dir:          args.dir,
+		StagingDir:   args.stagingDir,
+		Signature:    args.signature,
+		SaveInterval: args.saveInterval,
+		StopEarly:    args.stopEarly || args.simulateRestart,
+		Store:        st,
+	})
+}
+
+// Apply patches a directory, resuming from a checkpoint in
+// the staging directory, if any.
+func Apply(consumer *state.Consumer, params *Params) error {
 	startTime := time.Now()
 
-	patch := args.patch
-	old := args.old
-	dir := args.dir
-	stagingDir := args.stagingDir
+	patch := params.Patch
+	old := params.Old
+	dir := params.Dir
+	stagingDir := params.StagingDir
 
 	if dir == "" {
 		consumer.Opf("Patching %s (in-place)", old)
@@ -73,6 +119,14 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 		consumer.Opf("Patching %s (fresh)", dir)
 	}
 
+	if params.Store != nil && dir == "" {
+		// patching writes into existing files
+		err := params.Store.Detach(consumer, old)
+		if err != nil {
+			return errors.WithMessage(err, "detaching files from store")
+		}
+	}
+
 	patchSource, err := filesource.Open(patch, option.WithConsumer(comm.NewStateConsumer()))
 	if err != nil {
 		return errors.WithMessage(err, "opening patch")
@@ -106,7 +160,7 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 	}
 
 	lastSaveTime := time.Now()
-	saveInterval := time.Duration(float64(time.Second) * args.saveInterval)
+	saveInterval := time.Duration(float64(time.Second) * params.SaveInterval)
 	consumer.Infof("Save interval: %s", saveInterval)
 
 	p.SetSaveConsumer(&patcherSaveConsumer{
@@ -134,7 +188,7 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 				return patcher.AfterSaveStop, errors.WithMessage(err, "committing checkpoint file")
 			}
 
-			if args.stopEarly || args.simulateRestart {
+			if params.StopEarly {
 				return patcher.AfterSaveStop, nil
 			}
 			return patcher.AfterSaveContinue, nil
@@ -175,8 +229,8 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 		return errors.WithMessage(err, "patching")
 	}
 
-	if args.stagingDir != "" {
-		stagingDirSize, err := sizeof.Do(args.stagingDir)
+	if stagingDir != "" {
+		stagingDirSize, err := sizeof.Do(stagingDir)
 		if err != nil {
 			return err
 		}
@@ -196,8 +250,8 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 		united.FormatBPS(out.Size, duration),
 		united.FormatDuration(duration))
 
-	if args.signature != "" {
-		sigSource, err := filesource.Open(args.signature)
+	if params.Signature != "" {
+		sigSource, err := filesource.Open(params.Signature)
 		if err != nil {
 			return err
 		}
@@ -236,6 +290,19 @@ func Do(ctx *mansion.Context, consumer *state.Consumer) error {
 		consumer.Statf("Phew, everything checks out!")
 	}
 
+	if params.Store != nil {
+		outputDir := dir
+		if outputDir == "" {
+			outputDir = old
+		}
+
+		ingestRes, err := params.Store.Ingest(consumer, outputDir, bfs.ContainerPaths(p.GetSourceContainer()))
+		if err != nil {
+			return errors.WithMessage(err, "linking files from store")
+		}
+		consumer.Statf("%d files linked from store, %s shared with other builds", ingestRes.Linked, united.FormatBytes(ingestRes.Shared))
+	}
+
 	return nil
 }
 
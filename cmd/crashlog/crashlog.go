@@ -0,0 +1,249 @@
+// Package crashlog bundles what butler knows about an installed game's
+// recent launches, so players can attach it to bug reports.
+package crashlog
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/daemon"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/endpoints/fetch"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/mansion"
+	"github.com/itchio/ox"
+	"github.com/pkg/errors"
+)
+
+var args = struct {
+	caveID   string
+	output   string
+	sessions int64
+}{}
+
+func Register(ctx *mansion.Context) {
+	cmd := ctx.App.Command("crashlog", "Bundle the launch logs and install receipt of an installed game, for bug reports")
+	cmd.Arg("cave", "ID of the cave (installed game)").Required().StringVar(&args.caveID)
+	cmd.Flag("output", "Path of the .zip file to write (defaults to a new file in the current folder)").Short('o').StringVar(&args.output)
+	cmd.Flag("sessions", "How many of the most recent launch sessions to include").Default("5").Int64Var(&args.sessions)
+	ctx.Register(cmd, do)
+}
+
+func do(ctx *mansion.Context) {
+	ctx.Must(Do(ctx, args.caveID, args.output, args.sessions))
+}
+
+// Result is what the crashlog command outputs in JSON mode
+type Result struct {
+	Path     string `json:"path"`
+	Sessions int    `json:"sessions"`
+}
+
+// Do writes a crash log bundle for a cave to output
+func Do(ctx *mansion.Context, caveID string, output string, maxSessions int64) error {
+	if ctx.DBPath == "" {
+		ctx.DBPath = butlerd.GuessDBPath("")
+		comm.Logf("Using database at %s (pass --dbpath to use another one)", ctx.DBPath)
+	}
+	if _, err := os.Stat(ctx.DBPath); err != nil {
+		return errors.Errorf("No database found at (%s)", ctx.DBPath)
+	}
+
+	dbPool, err := daemon.OpenDB(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer dbPool.Close()
+
+	conn := dbPool.Get(context.Background().Done())
+	defer dbPool.Put(conn)
+
+	cave := models.CaveByID(conn, caveID)
+	if cave == nil {
+		return errors.Errorf("Cave not found: (%s)", caveID)
+	}
+	cave.Preload(conn)
+
+	if output == "" {
+		output = fmt.Sprintf("crashlog-%s-%s.zip", cave.ID, time.Now().Format("20060102-150405"))
+	}
+
+	sessions := models.LaunchSessionsByCaveID(conn, cave.ID, maxSessions)
+
+	comm.Opf("Writing crash log for (%s) to (%s)", gameTitle(cave), output)
+	err = writeBundle(ctx, conn, cave, sessions, output)
+	if err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	comm.ResultOrPrint(&Result{
+		Path:     output,
+		Sessions: len(sessions),
+	}, func() {
+		comm.Statf("Bundled %d launch sessions, attach (%s) to your bug report", len(sessions), output)
+	})
+	return nil
+}
+
+func writeBundle(ctx *mansion.Context, conn *sqlite.Conn, cave *models.Cave, sessions []*models.LaunchSession, output string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	now := time.Now()
+
+	writeText := func(name string, contents string) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: now,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = io.WriteString(w, contents)
+		return errors.WithStack(err)
+	}
+
+	writeJSON := func(name string, v interface{}) error {
+		contents, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return writeText(name, string(contents))
+	}
+
+	installFolder := cave.GetInstallFolder(conn)
+	receipt, err := bfs.ReadReceipt(installFolder)
+	if err != nil {
+		comm.Warnf("Could not read receipt: %s", err.Error())
+	}
+
+	err = writeText("crashlog.txt", summary(ctx, cave, installFolder, receipt, sessions))
+	if err != nil {
+		return err
+	}
+
+	err = writeJSON("cave.json", fetch.FormatCave(conn, cave))
+	if err != nil {
+		return err
+	}
+
+	if receipt != nil {
+		err = writeJSON("receipt.json", receipt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, ls := range sessions {
+		prefix := fmt.Sprintf("sessions/%02d-%s", i+1, ls.ID)
+
+		err = writeJSON(prefix+".json", fetch.FormatLaunchSession(ls, false))
+		if err != nil {
+			return err
+		}
+		if ls.Stdout != "" {
+			err = writeText(prefix+"-stdout.log", ls.Stdout)
+			if err != nil {
+				return err
+			}
+		}
+		if ls.Stderr != "" {
+			err = writeText(prefix+"-stderr.log", ls.Stderr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+func summary(ctx *mansion.Context, cave *models.Cave, installFolder string, receipt *bfs.Receipt, sessions []*models.LaunchSession) string {
+	var lines []string
+	addf := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	addf("butler %s, on %s", ctx.VersionString, ox.CurrentRuntime())
+	addf("Generated at %s", time.Now().UTC().Format(time.RFC3339))
+	addf("")
+	addf("Game: %s (#%d)", gameTitle(cave), cave.GameID)
+	if cave.Upload != nil {
+		addf("Upload: %s (#%d)", cave.Upload.DisplayName, cave.UploadID)
+	}
+	if cave.Build != nil {
+		addf("Build: %s (#%d)", cave.Build.UserVersion, cave.BuildID)
+	}
+	addf("Cave: %s", cave.ID)
+	addf("Install folder: %s", installFolder)
+	if receipt == nil {
+		addf("No receipt found")
+	} else {
+		addf("Installed with %s, %d files in receipt", receipt.InstallerName, len(receipt.Files))
+	}
+	addf("")
+
+	if len(sessions) == 0 {
+		addf("No launch sessions recorded")
+	} else {
+		addf("Launch sessions (most recent first):")
+	}
+	for i, ls := range sessions {
+		addf("")
+		addf("%02d. %s", i+1, formatTime(ls.StartedAt))
+		addf("    Strategy: %s, action: %s, sandbox: %v", ls.Strategy, orNone(ls.ActionName), ls.Sandbox)
+		if ls.EndedAt != nil && ls.StartedAt != nil {
+			addf("    Ran for %s", ls.EndedAt.Sub(*ls.StartedAt).Round(time.Second))
+		} else {
+			addf("    Never ended (butler may have been closed during the session)")
+		}
+		if ls.ExitCode != nil {
+			addf("    Exit code: %d", *ls.ExitCode)
+		}
+		if ls.Crashed {
+			addf("    Crashed: %s", orNone(ls.ErrorMessage))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func gameTitle(cave *models.Cave) string {
+	if cave.Game != nil {
+		return cave.Game.Title
+	}
+	return fmt.Sprintf("game #%d", cave.GameID)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "(unknown time)"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
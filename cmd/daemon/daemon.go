@@ -2,6 +2,8 @@ package daemon
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
@@ -13,6 +15,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/database"
+	"github.com/itchio/butler/endpoints/tasks"
+	"github.com/itchio/butler/installer/lanshare"
 	"github.com/itchio/headway/state"
 	"github.com/sourcegraph/jsonrpc2"
 
@@ -24,6 +28,9 @@ import (
 var args = struct {
 	destinyPids []int64
 	transport   string
+	socketPath  string
+	trustPeer   bool
+	origins     []string
 	keepAlive   bool
 	log         bool
 }{}
@@ -31,7 +38,10 @@ var args = struct {
 func Register(ctx *mansion.Context) {
 	cmd := ctx.App.Command("daemon", "Start a butlerd instance").Hidden()
 	cmd.Flag("destiny-pid", "The daemon will shutdown whenever any of its destiny PIDs shuts down").Int64ListVar(&args.destinyPids)
-	cmd.Flag("transport", "Which transport to use").Default("tcp").EnumVar(&args.transport, "http", "tcp")
+	cmd.Flag("transport", "Which transport to use").Default("tcp").EnumVar(&args.transport, "http", "tcp", "unix", "websocket")
+	cmd.Flag("socket-path", "Path of the socket to listen on when using the unix transport (defaults to a file in the temporary directory)").StringVar(&args.socketPath)
+	cmd.Flag("trust-peer", "When using the unix transport, don't require Meta.Authenticate from clients running as the same user (Linux only)").BoolVar(&args.trustPeer)
+	cmd.Flag("allowed-origin", "When using the websocket transport, an origin browsers may connect from (can be specified multiple times, '*' allows all origins)").StringsVar(&args.origins)
 	cmd.Flag("keep-alive", "Accept multiple TCP connections, stay up until killed or a destiny PID shuts down").BoolVar(&args.keepAlive)
 	cmd.Flag("log", "Log all requests to stderr").BoolVar(&args.log)
 	ctx.Register(cmd, do)
@@ -66,9 +76,31 @@ func do(ctx *mansion.Context) {
 	}
 	secret := generateSecret()
 
-	err = os.MkdirAll(filepath.Dir(ctx.DBPath), 0755)
+	dbPool, err := OpenDB(ctx)
+	ctx.Must(err)
+	defer dbPool.Close()
+
+	lanCtx, cancelLAN := context.WithCancel(context.Background())
+	defer cancelLAN()
+	go lanshare.Serve(lanCtx, func(f func(conn *sqlite.Conn)) {
+		conn := dbPool.Get(lanCtx.Done())
+		if conn == nil {
+			// shutting down
+			return
+		}
+		defer dbPool.Put(conn)
+		f(conn)
+	}, comm.NewStateConsumer())
+
+	ctx.Must(Do(ctx, context.Background(), dbPool, secret))
+}
+
+// OpenDB opens (and creates, if needed) the sqlite database at ctx.DBPath,
+// and runs any pending migrations.
+func OpenDB(ctx *mansion.Context) (*sqlite.Pool, error) {
+	err := os.MkdirAll(filepath.Dir(ctx.DBPath), 0755)
 	if err != nil {
-		ctx.Must(errors.WithMessage(err, "creating DB directory if necessary"))
+		return nil, errors.WithMessage(err, "creating DB directory if necessary")
 	}
 
 	justCreated := false
@@ -80,9 +112,8 @@ func do(ctx *mansion.Context) {
 
 	dbPool, err := sqlite.Open(ctx.DBPath, 0, 100)
 	if err != nil {
-		ctx.Must(errors.WithMessage(err, "opening DB for the first time"))
+		return nil, errors.WithMessage(err, "opening DB for the first time")
 	}
-	defer dbPool.Close()
 
 	err = func() (retErr error) {
 		defer horror.RecoverInto(&retErr)
@@ -96,10 +127,11 @@ func do(ctx *mansion.Context) {
 		}, conn, justCreated)
 	}()
 	if err != nil {
-		ctx.Must(errors.WithMessage(err, "preparing DB"))
+		dbPool.Close()
+		return nil, errors.WithMessage(err, "preparing DB")
 	}
 
-	ctx.Must(Do(ctx, context.Background(), dbPool, secret))
+	return dbPool, nil
 }
 
 type handler struct {
@@ -123,6 +155,8 @@ func Do(mansionContext *mansion.Context, ctx context.Context, dbPool *sqlite.Poo
 	}
 	consumer := comm.NewStateConsumer()
 
+	go tasks.ScheduleIntegrityScans(ctx, h.router)
+
 	switch args.transport {
 	case "tcp":
 		listener, err := net.Listen("tcp", "127.0.0.1:")
@@ -150,9 +184,109 @@ func Do(mansionContext *mansion.Context, ctx context.Context, dbPool *sqlite.Poo
 		if err != nil {
 			return err
 		}
+	case "unix":
+		socketPath := args.socketPath
+		if socketPath == "" {
+			// in a folder only we can enter, so nobody can connect
+			// before the socket's permissions are set
+			socketDir, err := ioutil.TempDir("", "butlerd")
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer os.RemoveAll(socketDir)
+			socketPath = filepath.Join(socketDir, fmt.Sprintf("butlerd-%d.sock", os.Getpid()))
+		}
+
+		listener, err := listenUnix(socketPath)
+		if err != nil {
+			return err
+		}
+
+		comm.Object("butlerd/listen-notification", map[string]interface{}{
+			"secret": secret,
+			"unix": map[string]interface{}{
+				"path": socketPath,
+			},
+		})
+
+		err = s.ServeTCP(ctx, butlerd.ServeTCPParams{
+			Handler:   h,
+			Consumer:  consumer,
+			Listener:  listener,
+			Secret:    secret,
+			Log:       args.log,
+			KeepAlive: args.keepAlive,
+
+			TrustPeerCredentials: args.trustPeer,
+
+			ShutdownChan: h.router.ShutdownChan,
+		})
+		if err != nil {
+			return err
+		}
+	case "websocket":
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			return err
+		}
+
+		comm.Object("butlerd/listen-notification", map[string]interface{}{
+			"secret": secret,
+			"websocket": map[string]interface{}{
+				"address": fmt.Sprintf("ws://%s", listener.Addr().String()),
+			},
+		})
+
+		err = s.ServeWebSocket(ctx, butlerd.ServeWebSocketParams{
+			Handler:  h,
+			Consumer: consumer,
+			Listener: listener,
+			Secret:   secret,
+			Log:      args.log,
+
+			AllowedOrigins: args.origins,
+
+			ShutdownChan: h.router.ShutdownChan,
+		})
+		if err != nil {
+			return err
+		}
 	case "http":
 		comm.Dief("The HTTP transport is deprecated. Use TCP instead.")
 	}
 
 	return nil
 }
+
+// listenUnix listens on a unix socket that only the current user
+// can connect to, removing any stale socket left at that path.
+// Anything else at that path is left alone.
+func listenUnix(socketPath string) (net.Listener, error) {
+	stats, err := os.Lstat(socketPath)
+	if err == nil {
+		if stats.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("Refusing to listen on (%s): it exists and isn't a socket", socketPath)
+		}
+		err = os.Remove(socketPath)
+		if err != nil {
+			return nil, errors.WithMessage(err, "removing stale socket")
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	restoreUmask := restrictUmask()
+	listener, err := net.Listen("unix", socketPath)
+	restoreUmask()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = os.Chmod(socketPath, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, errors.WithMessage(err, "restricting socket permissions")
+	}
+
+	return listener, nil
+}
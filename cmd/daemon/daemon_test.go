@@ -0,0 +1,51 @@
+// +build !windows
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "butlerd-socket")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "butlerd.sock")
+	listener, err := listenUnix(socketPath)
+	wtest.Must(t, err)
+
+	stats, err := os.Lstat(socketPath)
+	wtest.Must(t, err)
+	assert.True(t, stats.Mode()&os.ModeSocket != 0)
+	assert.EqualValues(t, os.FileMode(0600), stats.Mode().Perm())
+
+	conn, err := net.Dial("unix", socketPath)
+	wtest.Must(t, err)
+	conn.Close()
+
+	// a socket left behind by a daemon that didn't shut down cleanly
+	if l, ok := listener.(*net.UnixListener); ok {
+		l.SetUnlinkOnClose(false)
+	}
+	listener.Close()
+	listener, err = listenUnix(socketPath)
+	wtest.Must(t, err)
+	listener.Close()
+
+	// anything else is not ours to remove
+	filePath := filepath.Join(dir, "notes.txt")
+	wtest.Must(t, ioutil.WriteFile(filePath, []byte("important"), 0644))
+	_, err = listenUnix(filePath)
+	assert.Error(t, err)
+	contents, err := ioutil.ReadFile(filePath)
+	wtest.Must(t, err)
+	assert.EqualValues(t, "important", string(contents))
+}
@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"context"
+	"net"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/mansion"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ConnectInProcess serves butlerd requests from the same process, without
+// listening on any port. clientHandler receives requests & notifications
+// sent by butlerd (dialogs, progress, logs, etc.)
+//
+// The connection doesn't need to be authenticated. It's closed when ctx
+// is done.
+func ConnectInProcess(ctx context.Context, mansionContext *mansion.Context, dbPool *sqlite.Pool, clientHandler jsonrpc2.Handler) *jsonrpc2.Conn {
+	h := &handler{
+		ctx:    mansionContext,
+		router: getRouter(dbPool, mansionContext),
+	}
+
+	serverSide, clientSide := net.Pipe()
+
+	serverStream := jsonrpc2.NewBufferedStream(serverSide, butlerd.LFObjectCodec{})
+	serverConn := jsonrpc2.NewConn(ctx, serverStream, jsonrpc2.AsyncHandler(h))
+
+	clientStream := jsonrpc2.NewBufferedStream(clientSide, butlerd.LFObjectCodec{})
+	clientConn := jsonrpc2.NewConn(ctx, clientStream, jsonrpc2.AsyncHandler(clientHandler))
+
+	go func() {
+		<-ctx.Done()
+		clientConn.Close()
+		serverConn.Close()
+	}()
+
+	return clientConn
+}
@@ -0,0 +1,14 @@
+//+build !windows
+
+package daemon
+
+import "syscall"
+
+// restrictUmask makes files created from now on only accessible
+// to the current user, until the returned func is called.
+func restrictUmask() func() {
+	old := syscall.Umask(0177)
+	return func() {
+		syscall.Umask(old)
+	}
+}
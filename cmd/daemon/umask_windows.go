@@ -0,0 +1,9 @@
+//+build windows
+
+package daemon
+
+// restrictUmask is a no-op on Windows, where sockets get the
+// permissions of the folder they're in.
+func restrictUmask() func() {
+	return func() {}
+}
@@ -7,15 +7,23 @@ import (
 
 	"github.com/itchio/boar"
 
+	"github.com/itchio/butler/cmd/storecmd"
 	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
 	"github.com/itchio/butler/mansion"
 	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/united"
+	"github.com/itchio/lake/tlc"
 	"github.com/pkg/errors"
 )
 
 var args = struct {
-	target *string
-	out    *string
+	target      *string
+	out         *string
+	update      *bool
+	buildID     *int64
+	userVersion *string
 }{}
 
 func Register(ctx *mansion.Context) {
@@ -24,16 +32,30 @@ func Register(ctx *mansion.Context) {
 
 	args.target = cmd.Arg("target", "Which user/project:channel to fetch from, for example 'leafo/x-moon:win-64'. Targets are of the form project:channel where project is username/game or game_id.").Required().String()
 	args.out = cmd.Arg("out", "Directory to fetch and extract build to").Required().String()
+	args.update = cmd.Flag("update", "If the directory isn't empty, update it to the latest build using patches, or by healing it").Bool()
+	args.buildID = cmd.Flag("build-id", "Fetch this build instead of the latest one").Int64()
+	args.userVersion = cmd.Flag("userversion", "Fetch the most recent build with this user version instead of the latest one").String()
 }
 
 func do(ctx *mansion.Context) {
-	ctx.Must(Do(ctx, *args.target, *args.out))
+	ctx.Must(Do(ctx, *args.target, *args.out, *args.update, BuildSelector{
+		BuildID:     *args.buildID,
+		UserVersion: *args.userVersion,
+	}))
 }
 
-func Do(ctx *mansion.Context, specStr string, outPath string) error {
+// Do downloads and extracts a build of a channel into outPath, or updates
+// the build already there. Unless a build is selected, the channel's latest
+// build is used.
+func Do(ctx *mansion.Context, specStr string, outPath string, update bool, selector BuildSelector) error {
 	consumer := comm.NewStateConsumer()
 
-	err := os.MkdirAll(outPath, os.FileMode(0755))
+	err := selector.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(outPath, os.FileMode(0755))
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -43,8 +65,8 @@ func Do(ctx *mansion.Context, specStr string, outPath string) error {
 		return errors.WithStack(err)
 	}
 
-	if len(outFiles) > 0 {
-		return fmt.Errorf("Destination directory %s exists and is not empty", outPath)
+	if len(outFiles) > 0 && !update {
+		return fmt.Errorf("Destination directory %s exists and is not empty (use --update to update it)", outPath)
 	}
 
 	spec, err := itchio.ParseSpec(specStr)
@@ -62,18 +84,79 @@ func Do(ctx *mansion.Context, specStr string, outPath string) error {
 		return err
 	}
 
-	comm.Opf("Getting last build of channel %s", spec.Channel)
+	st, dbPool, err := storecmd.Open(ctx)
+	if err != nil {
+		return err
+	}
+	if dbPool != nil {
+		defer dbPool.Close()
+	}
+
+	if selector.IsZero() {
+		comm.Opf("Getting last build of channel %s", spec.Channel)
+	} else {
+		comm.Opf("Looking for %s in channel %s", selector, spec.Channel)
+	}
 
 	channelResponse, err := client.GetChannel(ctx.DefaultCtx(), spec.Target, spec.Channel)
 	if err != nil {
 		return err
 	}
+	channel := channelResponse.Channel
 
-	if channelResponse.Channel.Head == nil {
+	if channel.Head == nil {
 		return fmt.Errorf("Channel %s doesn't have any builds yet", spec.Channel)
 	}
 
-	buildID := channelResponse.Channel.Head.ID
+	build := channel.Head
+	if !selector.IsZero() {
+		if channel.Upload == nil {
+			return fmt.Errorf("Channel %s doesn't have an upload yet", spec.Channel)
+		}
+		build, err = FindBuild(ctx.DefaultCtx(), client, channel.Upload.ID, itchio.GameCredentials{}, selector)
+		if err != nil {
+			return err
+		}
+		if build.State != itchio.BuildStateCompleted {
+			return fmt.Errorf("Build %d is %s, only completed builds can be fetched", build.ID, build.State)
+		}
+		comm.Statf("Found build %d (%s)", build.ID, buildVersion(build))
+	}
+	buildID := build.ID
+
+	if len(outFiles) > 0 {
+		if st != nil {
+			// patching and healing write into existing files
+			err = st.Detach(consumer, outPath)
+			if err != nil {
+				return errors.WithMessage(err, "detaching files from store")
+			}
+		}
+
+		container, err := doUpdate(ctx, client, outPath, build, st)
+		if err != nil {
+			return err
+		}
+		err = writeReceipt(outPath, channel.Upload, build, container)
+		if err != nil {
+			return err
+		}
+		return ingest(st, outPath, container)
+	}
+
+	if st != nil {
+		// healing an empty folder only downloads files the store doesn't have
+		comm.Opf("Fetching into %s", outPath)
+		container, err := heal(ctx, client, outPath, build, nil, st)
+		if err != nil {
+			return err
+		}
+		err = writeReceipt(outPath, channel.Upload, build, container)
+		if err != nil {
+			return err
+		}
+		return ingest(st, outPath, container)
+	}
 
 	buildFilesRes, err := client.ListBuildFiles(ctx.DefaultCtx(), buildID)
 	if err != nil {
@@ -82,7 +165,7 @@ func Do(ctx *mansion.Context, specStr string, outPath string) error {
 
 	archiveFile := itchio.FindBuildFileEx(itchio.BuildFileTypeArchive, itchio.BuildFileSubTypeDefault, buildFilesRes.Files)
 	if archiveFile == nil {
-		return fmt.Errorf("Channel %s's latest build is still processing", spec.Channel)
+		return fmt.Errorf("Build %d of channel %s is still processing", buildID, spec.Channel)
 	}
 
 	url := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
@@ -90,6 +173,12 @@ func Do(ctx *mansion.Context, specStr string, outPath string) error {
 		FileID:  archiveFile.ID,
 	})
 
+	// also tells us which files are part of the build, for the receipt
+	sigInfo, err := readBuildSignature(client, build, buildFilesRes.Files)
+	if err != nil {
+		return err
+	}
+
 	comm.Opf("Extracting into %s", outPath)
 
 	comm.StartProgress()
@@ -104,5 +193,46 @@ func Do(ctx *mansion.Context, specStr string, outPath string) error {
 	}
 	comm.Statf("Extracted %s", extractRes.Stats())
 
+	return writeReceipt(outPath, channel.Upload, build, sigInfo.Container)
+}
+
+// ingest links the files of a fetched build from the store, if any
+func ingest(st *store.Store, outPath string, container *tlc.Container) error {
+	if st == nil {
+		return nil
+	}
+
+	res, err := st.Ingest(comm.NewStateConsumer(), outPath, bfs.ContainerPaths(container))
+	if err != nil {
+		return errors.WithMessage(err, "linking files from store")
+	}
+	comm.Statf("%d files linked from store, %s shared with other builds", res.Linked, united.FormatBytes(res.Shared))
 	return nil
 }
+
+// writeReceipt records which build is in a folder, so that it
+// can later be updated with `butler fetch --update`. Only the
+// files of the build's container are listed, not whatever else
+// was in the folder, so they're never busted as ghosts.
+func writeReceipt(outPath string, upload *itchio.Upload, build *itchio.Build, container *tlc.Container) error {
+	receipt := &bfs.Receipt{
+		Upload:        upload,
+		Build:         build,
+		InstallerName: "archive",
+	}
+	for _, f := range container.Files {
+		receipt.Files = append(receipt.Files, f.Path)
+	}
+	for _, s := range container.Symlinks {
+		receipt.Files = append(receipt.Files, s.Path)
+	}
+
+	return receipt.WriteReceipt(outPath)
+}
+
+func buildVersion(build *itchio.Build) string {
+	if build.UserVersion != "" {
+		return build.UserVersion
+	}
+	return fmt.Sprintf("version %d", build.Version)
+}
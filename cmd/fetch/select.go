@@ -0,0 +1,98 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	itchio "github.com/itchio/go-itchio"
+	"github.com/pkg/errors"
+)
+
+// A BuildSelector picks a specific build of an upload, either by ID or
+// by user version, instead of its latest build.
+type BuildSelector struct {
+	BuildID     int64
+	UserVersion string
+}
+
+// IsZero returns true if no build is selected, ie. the latest build should be used
+func (bs BuildSelector) IsZero() bool {
+	return bs.BuildID == 0 && bs.UserVersion == ""
+}
+
+// Validate returns an error if both an ID and a user version were given
+func (bs BuildSelector) Validate() error {
+	if bs.BuildID != 0 && bs.UserVersion != "" {
+		return errors.New("Specify either a build ID or a user version, not both")
+	}
+	return nil
+}
+
+func (bs BuildSelector) String() string {
+	if bs.BuildID != 0 {
+		return fmt.Sprintf("build #%d", bs.BuildID)
+	}
+	return fmt.Sprintf("version %s", bs.UserVersion)
+}
+
+// Matches returns true if build is the one selected
+func (bs BuildSelector) Matches(build *itchio.Build) bool {
+	if bs.BuildID != 0 {
+		return build.ID == bs.BuildID
+	}
+	return build.UserVersion == bs.UserVersion
+}
+
+// Pick returns the index of the selected build in builds, or -1 if it's not
+// there. User versions aren't necessarily unique, so the most recent
+// matching build wins.
+func (bs BuildSelector) Pick(builds []*itchio.Build) int {
+	index := -1
+	for i, build := range builds {
+		if !bs.Matches(build) {
+			continue
+		}
+		if index == -1 || build.ID > builds[index].ID {
+			index = i
+		}
+	}
+	return index
+}
+
+// FindBuild looks for the selected build among the builds of an upload,
+// and returns it along with its files.
+func FindBuild(ctx context.Context, client *itchio.Client, uploadID int64, credentials itchio.GameCredentials, bs BuildSelector) (*itchio.Build, error) {
+	err := bs.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	buildsRes, err := client.ListUploadBuilds(ctx, itchio.ListUploadBuildsParams{
+		UploadID:    uploadID,
+		Credentials: credentials,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing builds of upload %d", uploadID)
+	}
+
+	builds := buildsRes.Builds
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].ID > builds[j].ID
+	})
+
+	index := bs.Pick(builds)
+	if index < 0 {
+		return nil, errors.Errorf("No %s found for upload %d", bs, uploadID)
+	}
+
+	// build listings don't include files
+	buildRes, err := client.GetBuild(ctx, itchio.GetBuildParams{
+		BuildID:     builds[index].ID,
+		Credentials: credentials,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting build %d", builds[index].ID)
+	}
+	return buildRes.Build, nil
+}
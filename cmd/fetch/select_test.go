@@ -0,0 +1,66 @@
+package fetch
+
+import (
+	"testing"
+
+	itchio "github.com/itchio/go-itchio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSelector(t *testing.T) {
+	builds := []*itchio.Build{
+		{ID: 300, UserVersion: "1.1"},
+		{ID: 100, UserVersion: "1.0"},
+		// re-pushed with the same version
+		{ID: 250, UserVersion: "1.0"},
+		{ID: 200, UserVersion: "1.0"},
+		{ID: 50},
+	}
+
+	cases := []struct {
+		name    string
+		bs      BuildSelector
+		invalid bool
+		matches []int64
+		picked  int64
+	}{
+		{"by ID", BuildSelector{BuildID: 100}, false, []int64{100}, 100},
+		{"by user version", BuildSelector{UserVersion: "1.1"}, false, []int64{300}, 300},
+		{"same user version, most recent wins", BuildSelector{UserVersion: "1.0"}, false, []int64{100, 250, 200}, 250},
+		{"ID that doesn't exist", BuildSelector{BuildID: 400}, false, nil, -1},
+		{"user version that doesn't exist", BuildSelector{UserVersion: "2.0"}, false, nil, -1},
+		{"both ID and user version", BuildSelector{BuildID: 100, UserVersion: "1.0"}, true, nil, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.False(t, tc.bs.IsZero())
+
+			err := tc.bs.Validate()
+			if tc.invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			var matches []int64
+			for _, b := range builds {
+				if tc.bs.Matches(b) {
+					matches = append(matches, b.ID)
+				}
+			}
+			assert.EqualValues(t, tc.matches, matches)
+
+			index := tc.bs.Pick(builds)
+			if tc.picked < 0 {
+				assert.EqualValues(t, -1, index)
+			} else if assert.True(t, index >= 0) {
+				assert.EqualValues(t, tc.picked, builds[index].ID)
+			}
+		})
+	}
+
+	latest := BuildSelector{}
+	assert.True(t, latest.IsZero())
+	assert.NoError(t, latest.Validate())
+}
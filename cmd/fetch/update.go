@@ -0,0 +1,245 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/itchio/butler/cmd/apply2"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/united"
+	"github.com/itchio/httpkit/eos"
+	"github.com/itchio/httpkit/eos/option"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/savior/seeksource"
+	"github.com/itchio/wharf/pwr"
+	"github.com/pkg/errors"
+)
+
+// doUpdate brings a folder to the given build. It uses the receipt
+// left by a previous fetch to find the build that's currently in there,
+// applies the chain of patches from that build to the target, and falls
+// back to healing if that's not possible. It returns the target build's
+// container, as listed in its signature.
+func doUpdate(ctx *mansion.Context, client *itchio.Client, outPath string, target *itchio.Build, st *store.Store) (*tlc.Container, error) {
+	receipt, err := bfs.ReadReceipt(outPath)
+	if err != nil {
+		comm.Warnf("Ignoring receipt: %s", err.Error())
+		receipt = nil
+	}
+
+	if receipt == nil || receipt.Build == nil {
+		comm.Opf("Don't know which build is in %s, healing it to build %d", outPath, target.ID)
+		return heal(ctx, client, outPath, target, receipt, st)
+	}
+
+	current := receipt.Build.ID
+	if current == target.ID {
+		comm.Statf("Already at build %d, nothing to do", current)
+		return targetContainer(ctx, client, target)
+	}
+
+	if current > target.ID {
+		comm.Opf("Downgrading from build %d to %d, healing", current, target.ID)
+		return heal(ctx, client, outPath, target, receipt, st)
+	}
+
+	err = applyUpgradePath(ctx, client, outPath, current, target)
+	if err != nil {
+		comm.Warnf("Could not patch from build %d to %d: %s", current, target.ID, err.Error())
+		comm.Opf("Healing instead...")
+		return heal(ctx, client, outPath, target, receipt, st)
+	}
+
+	return targetContainer(ctx, client, target)
+}
+
+// targetContainer returns the files, folders and symlinks of a build
+func targetContainer(ctx *mansion.Context, client *itchio.Client, target *itchio.Build) (*tlc.Container, error) {
+	buildFilesRes, err := client.ListBuildFiles(ctx.DefaultCtx(), target.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sigInfo, err := readBuildSignature(client, target, buildFilesRes.Files)
+	if err != nil {
+		return nil, err
+	}
+	return sigInfo.Container, nil
+}
+
+// readBuildSignature downloads and parses the signature of a build
+func readBuildSignature(client *itchio.Client, target *itchio.Build, files []*itchio.BuildFile) (*pwr.SignatureInfo, error) {
+	signatureFile := itchio.FindBuildFile(itchio.BuildFileTypeSignature, files)
+	if signatureFile == nil {
+		return nil, errors.Errorf("Build %d is still processing", target.ID)
+	}
+
+	signatureURL := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
+		BuildID: target.ID,
+		FileID:  signatureFile.ID,
+	})
+
+	signatureReader, err := eos.Open(signatureURL, option.WithConsumer(comm.NewStateConsumer()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer signatureReader.Close()
+
+	signatureSource := seeksource.FromFile(signatureReader)
+	_, err = signatureSource.Resume(nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sigInfo, err := pwr.ReadSignature(context.Background(), signatureSource)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return sigInfo, nil
+}
+
+func applyUpgradePath(ctx *mansion.Context, client *itchio.Client, outPath string, current int64, target *itchio.Build) error {
+	upgradeRes, err := client.GetBuildUpgradePath(ctx.DefaultCtx(), itchio.GetBuildUpgradePathParams{
+		CurrentBuildID: current,
+		TargetBuildID:  target.ID,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "finding upgrade path")
+	}
+
+	// skip the current build, we're not interested in it
+	builds := upgradeRes.UpgradePath.Builds
+	if len(builds) < 2 {
+		return errors.Errorf("upgrade path only has %d builds", len(builds))
+	}
+	builds = builds[1:]
+
+	var patchFiles []*itchio.BuildFile
+	var totalSize int64
+	for _, b := range builds {
+		patchFile := itchio.FindBuildFileEx(itchio.BuildFileTypePatch, itchio.BuildFileSubTypeOptimized, b.Files)
+		if patchFile == nil {
+			patchFile = itchio.FindBuildFileEx(itchio.BuildFileTypePatch, itchio.BuildFileSubTypeDefault, b.Files)
+		}
+		if patchFile == nil {
+			return errors.Errorf("build %d is missing a patch", b.ID)
+		}
+		patchFiles = append(patchFiles, patchFile)
+		totalSize += patchFile.Size
+	}
+
+	comm.Opf("Applying %d patches (%s) to go from build %d to %d", len(builds), united.FormatBytes(totalSize), current, target.ID)
+
+	stagingDir, err := ioutil.TempDir("", "butler-fetch-staging")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	consumer := comm.NewStateConsumer()
+	for i, b := range builds {
+		patchURL := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
+			BuildID: b.ID,
+			FileID:  patchFiles[i].ID,
+		})
+
+		comm.Opf("(%d/%d) Patching to build %d", i+1, len(builds), b.ID)
+		err := apply2.Apply(consumer, &apply2.Params{
+			Patch:        patchURL,
+			Old:          outPath,
+			StagingDir:   filepath.Join(stagingDir, fmt.Sprintf("patch-%d", b.ID)),
+			SaveInterval: 5,
+		})
+		if err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("applying patch of build %d", b.ID))
+		}
+	}
+
+	return nil
+}
+
+// heal checks the folder against the target build's signature, and
+// downloads anything that's missing or different from its archive.
+// If a store is given, missing files are linked from it first.
+// It returns the target build's container.
+func heal(ctx *mansion.Context, client *itchio.Client, outPath string, target *itchio.Build, receipt *bfs.Receipt, st *store.Store) (*tlc.Container, error) {
+	consumer := comm.NewStateConsumer()
+
+	buildFilesRes, err := client.ListBuildFiles(ctx.DefaultCtx(), target.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	archiveFile := itchio.FindBuildFileEx(itchio.BuildFileTypeArchive, itchio.BuildFileSubTypeDefault, buildFilesRes.Files)
+	if archiveFile == nil {
+		return nil, errors.Errorf("Build %d is still processing", target.ID)
+	}
+	archiveURL := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
+		BuildID: target.ID,
+		FileID:  archiveFile.ID,
+	})
+
+	sigInfo, err := readBuildSignature(client, target, buildFilesRes.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	if st != nil {
+		numFiles, numBytes, err := st.Seed(consumer, outPath, sigInfo)
+		if err != nil {
+			return nil, err
+		}
+		if numFiles > 0 {
+			comm.Statf("Linked %d files (%s) from store", numFiles, united.FormatBytes(numBytes))
+		}
+	}
+
+	vc := &pwr.ValidatorContext{
+		Consumer:   consumer,
+		NumWorkers: 1,
+		HealPath:   fmt.Sprintf("archive,%s", archiveURL),
+	}
+
+	comm.StartProgress()
+	err = vc.Validate(context.Background(), outPath, sigInfo)
+	comm.EndProgress()
+	if err != nil {
+		return nil, errors.WithMessage(err, "healing")
+	}
+
+	if vc.WoundsConsumer.HasWounds() {
+		if healer, ok := vc.WoundsConsumer.(pwr.Healer); ok {
+			comm.Statf("Healed %s of corrupted or missing data", united.FormatBytes(healer.TotalHealed()))
+		}
+	} else {
+		comm.Statf("All %s were already healthy", united.FormatBytes(sigInfo.Container.Size))
+	}
+
+	// without a receipt, we can't tell which files are ours,
+	// so ghost busting leaves everything alone
+	var newFiles []string
+	for _, f := range sigInfo.Container.Files {
+		newFiles = append(newFiles, f.Path)
+	}
+	for _, s := range sigInfo.Container.Symlinks {
+		newFiles = append(newFiles, s.Path)
+	}
+
+	err = bfs.BustGhosts(&bfs.BustGhostsParams{
+		Consumer: consumer,
+		Folder:   outPath,
+		NewFiles: newFiles,
+		Receipt:  receipt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sigInfo.Container, nil
+}
@@ -0,0 +1,243 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	v1Files = map[string]string{
+		"game.txt": "hello",
+		"old.txt":  "going away",
+	}
+	v2Files = map[string]string{
+		"game.txt": "hello world",
+		"new.txt":  "just arrived",
+	}
+)
+
+const (
+	v2SignatureID = 20
+	v2ArchiveID   = 21
+	v2PatchID     = 22
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	wtest.Must(t, os.MkdirAll(dir, 0755))
+	for name, contents := range files {
+		wtest.Must(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	names, err := ioutil.ReadDir(dir)
+	wtest.Must(t, err)
+
+	files := make(map[string]string)
+	for _, fi := range names {
+		if fi.IsDir() {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		wtest.Must(t, err)
+		files[fi.Name()] = string(contents)
+	}
+	return files
+}
+
+// fakeBuilds serves builds 1 and 2 of a game the way itch.io would:
+// build 2 has a signature, an archive, and (if withPatch is set)
+// a patch from build 1.
+type fakeBuilds struct {
+	signature []byte
+	archive   []byte
+	patch     []byte
+	withPatch bool
+}
+
+func newFakeBuilds(t *testing.T, dir string) *fakeBuilds {
+	consumer := &state.Consumer{}
+	v1Dir := filepath.Join(dir, "v1")
+	v2Dir := filepath.Join(dir, "v2")
+	writeFiles(t, v1Dir, v1Files)
+	writeFiles(t, v2Dir, v2Files)
+
+	v1Container, err := tlc.WalkAny(v1Dir, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+	v1Pool, err := pools.New(v1Container, v1Dir)
+	wtest.Must(t, err)
+	defer v1Pool.Close()
+	v1Hashes, err := pwr.ComputeSignature(context.Background(), v1Container, v1Pool, consumer)
+	wtest.Must(t, err)
+
+	v2Container, err := tlc.WalkAny(v2Dir, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+	v2Pool, err := pools.New(v2Container, v2Dir)
+	wtest.Must(t, err)
+	defer v2Pool.Close()
+
+	// diffing gives us the patch and the new build's signature
+	fb := &fakeBuilds{withPatch: true}
+	patchBuf := new(bytes.Buffer)
+	signatureBuf := new(bytes.Buffer)
+	dctx := &pwr.DiffContext{
+		SourceContainer: v2Container,
+		Pool:            v2Pool,
+		TargetContainer: v1Container,
+		TargetSignature: v1Hashes,
+		Consumer:        consumer,
+		Compression:     &pwr.CompressionSettings{Algorithm: pwr.CompressionAlgorithm_NONE},
+	}
+	wtest.Must(t, dctx.WritePatch(context.Background(), patchBuf, signatureBuf))
+	fb.patch = patchBuf.Bytes()
+	fb.signature = signatureBuf.Bytes()
+
+	archiveBuf := new(bytes.Buffer)
+	zw := zip.NewWriter(archiveBuf)
+	for name, contents := range v2Files {
+		w, err := zw.Create(name)
+		wtest.Must(t, err)
+		_, err = w.Write([]byte(contents))
+		wtest.Must(t, err)
+	}
+	wtest.Must(t, zw.Close())
+	fb.archive = archiveBuf.Bytes()
+
+	return fb
+}
+
+func (fb *fakeBuilds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sendJSON := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	serveFile := func(contents []byte) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(contents))
+	}
+
+	switch r.URL.Path {
+	case "/wharf/builds/2/files":
+		sendJSON(itchio.ListBuildFilesResponse{
+			Files: []*itchio.BuildFile{
+				{ID: v2SignatureID, Type: itchio.BuildFileTypeSignature, SubType: itchio.BuildFileSubTypeDefault, State: itchio.BuildFileStateUploaded},
+				{ID: v2ArchiveID, Type: itchio.BuildFileTypeArchive, SubType: itchio.BuildFileSubTypeDefault, State: itchio.BuildFileStateUploaded},
+			},
+		})
+	case "/builds/1/upgrade-paths/2":
+		target := &itchio.Build{ID: 2}
+		if fb.withPatch {
+			target.Files = []*itchio.BuildFile{
+				{ID: v2PatchID, Type: itchio.BuildFileTypePatch, SubType: itchio.BuildFileSubTypeDefault, State: itchio.BuildFileStateUploaded, Size: int64(len(fb.patch))},
+			}
+		}
+		sendJSON(itchio.GetBuildUpgradePathResponse{
+			UpgradePath: &itchio.UpgradePath{
+				Builds: []*itchio.Build{{ID: 1}, target},
+			},
+		})
+	case "/wharf/builds/2/files/20/download":
+		serveFile(fb.signature)
+	case "/wharf/builds/2/files/21/download":
+		serveFile(fb.archive)
+	case "/wharf/builds/2/files/22/download":
+		serveFile(fb.patch)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":["not found"]}`))
+	}
+}
+
+func TestDoUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetch-update")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	fb := newFakeBuilds(t, dir)
+	server := httptest.NewServer(fb)
+	defer server.Close()
+	client := itchio.ClientWithKey("key").SetServer(server.URL)
+	ctx := mansion.NewContext(kingpin.New("butler", "test"))
+	target := &itchio.Build{ID: 2}
+
+	// a folder with build 1 in it, as left by a previous fetch
+	prepare := func(name string, withReceipt bool) string {
+		outPath := filepath.Join(dir, name)
+		writeFiles(t, outPath, v1Files)
+		if withReceipt {
+			receipt := &bfs.Receipt{
+				Build: &itchio.Build{ID: 1},
+				Files: []string{"game.txt", "old.txt"},
+			}
+			wtest.Must(t, receipt.WriteReceipt(outPath))
+		}
+		return outPath
+	}
+
+	checkContainer := func(container *tlc.Container) {
+		if assert.NotNil(t, container) {
+			var paths []string
+			for _, f := range container.Files {
+				paths = append(paths, f.Path)
+			}
+			assert.ElementsMatch(t, []string{"game.txt", "new.txt"}, paths)
+		}
+	}
+
+	t.Logf("patching from build 1")
+	outPath := prepare("patched", true)
+	container, err := doUpdate(ctx, client, outPath, target, nil)
+	wtest.Must(t, err)
+	checkContainer(container)
+	assert.EqualValues(t, v2Files, readFiles(t, outPath))
+
+	t.Logf("upgrade path is missing a patch, healing instead")
+	fb.withPatch = false
+	err = applyUpgradePath(ctx, client, prepare("unpatchable", true), 1, target)
+	assert.Error(t, err)
+
+	outPath = prepare("healed", true)
+	container, err = doUpdate(ctx, client, outPath, target, nil)
+	wtest.Must(t, err)
+	checkContainer(container)
+	assert.EqualValues(t, v2Files, readFiles(t, outPath), "files of build 1 are gone")
+
+	t.Logf("no receipt, healing leaves unknown files alone")
+	outPath = prepare("no-receipt", false)
+	container, err = doUpdate(ctx, client, outPath, target, nil)
+	wtest.Must(t, err)
+	checkContainer(container)
+	files := readFiles(t, outPath)
+	assert.EqualValues(t, v2Files["game.txt"], files["game.txt"])
+	assert.EqualValues(t, v2Files["new.txt"], files["new.txt"])
+	assert.EqualValues(t, v1Files["old.txt"], files["old.txt"])
+
+	t.Logf("already at build 2")
+	outPath = filepath.Join(dir, "up-to-date")
+	writeFiles(t, outPath, v2Files)
+	wtest.Must(t, (&bfs.Receipt{Build: &itchio.Build{ID: 2}}).WriteReceipt(outPath))
+	container, err = doUpdate(ctx, client, outPath, target, nil)
+	wtest.Must(t, err)
+	checkContainer(container)
+	assert.EqualValues(t, v2Files, readFiles(t, outPath))
+}
@@ -0,0 +1,141 @@
+package headless
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	"github.com/itchio/headway/united"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+func doCaves(ctx *mansion.Context) {
+	ctx.Must(Caves(ctx))
+}
+
+// Caves prints a list of all installed games
+func Caves(ctx *mansion.Context) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	caves := []*butlerd.Cave{}
+	var cursor butlerd.Cursor
+	for {
+		var res butlerd.FetchCavesResult
+		err := s.rc.Call(messages.FetchCaves.Method(), butlerd.FetchCavesParams{
+			SortBy: "title",
+			Cursor: cursor,
+		}, &res)
+		if err != nil {
+			return errors.WithMessage(err, "fetching caves")
+		}
+		caves = append(caves, res.Items...)
+
+		if res.NextCursor == "" {
+			break
+		}
+		cursor = res.NextCursor
+	}
+
+	comm.ResultOrPrint(caves, func() {
+		if len(caves) == 0 {
+			comm.Logf("No games installed yet")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Cave", "Game", "Upload", "Size", "Install folder"})
+		for _, cave := range caves {
+			var game, upload, size, folder string
+			if cave.Game != nil {
+				game = cave.Game.Title
+			}
+			if cave.Upload != nil {
+				upload = fmt.Sprintf("#%d", cave.Upload.ID)
+				if cave.Build != nil {
+					upload += fmt.Sprintf(" (build #%d)", cave.Build.ID)
+				}
+			}
+			if cave.InstallInfo != nil {
+				size = united.FormatBytes(cave.InstallInfo.InstalledSize)
+				folder = cave.InstallInfo.InstallFolder
+			}
+			table.Append([]string{cave.ID, game, upload, size, folder})
+		}
+		table.Render()
+	})
+	return nil
+}
+
+func doVerify(ctx *mansion.Context) {
+	ctx.Must(Verify(ctx, verifyArgs.caveID, verifyArgs.repair))
+}
+
+// Verify checks the files of an installed game, and repairs it if asked to
+func Verify(ctx *mansion.Context, caveID string, repair bool) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// signatures are fetched from itch.io
+	_, err = s.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	comm.Opf("Verifying cave %s", caveID)
+	var res butlerd.CavesVerifyResult
+	err = s.rc.Call(messages.CavesVerify.Method(), butlerd.CavesVerifyParams{
+		CaveID: caveID,
+	}, &res)
+	if err != nil {
+		return errors.WithMessage(err, "verifying cave")
+	}
+
+	comm.ResultOrPrint(res, func() {
+		if res.Healthy {
+			if res.CheckedContents {
+				comm.Statf("All files are healthy")
+			} else {
+				comm.Statf("All files are there (contents can only be checked for games pushed with butler)")
+			}
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Path", "Problem", "Damaged"})
+		for _, w := range res.Wounds {
+			table.Append([]string{w.Path, string(w.Kind), united.FormatBytes(w.Size)})
+		}
+		table.Render()
+		comm.Statf("%d damaged entries, %s corrupted data", len(res.Wounds), united.FormatBytes(res.CorruptedBytes))
+	})
+
+	if res.Healthy {
+		return nil
+	}
+	if !repair {
+		comm.Logf("")
+		comm.Logf("Use `butler caves verify --repair %s` to fix it.", caveID)
+		return errors.New("Cave is damaged")
+	}
+
+	comm.Opf("Repairing cave %s", caveID)
+	err = s.rc.Call(messages.CavesRepair.Method(), butlerd.CavesRepairParams{
+		CaveID: caveID,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "repairing cave")
+	}
+	comm.Statf("Repaired cave %s", caveID)
+	return nil
+}
@@ -0,0 +1,231 @@
+package headless
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/headway/united"
+	"github.com/skratchdot/open-golang/open"
+)
+
+// registerDialogs answers the questions butlerd asks clients, either
+// by prompting on the terminal, or according to --assume-yes
+func registerDialogs(h *handler) {
+	h.Register(messages.PickUpload.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.PickUploadParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		var choices []string
+		for _, u := range params.Uploads {
+			name := u.DisplayName
+			if name == "" {
+				name = u.Filename
+			}
+			choices = append(choices, fmt.Sprintf("%s (#%d, %s)", name, u.ID, united.FormatBytes(u.Size)))
+		}
+
+		index := pick("Which upload should be installed?", choices)
+		return &butlerd.PickUploadResult{Index: int64(index)}, nil
+	})
+
+	h.Register(messages.InstallVersionSwitchPick.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.InstallVersionSwitchPickParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		var choices []string
+		for _, b := range params.Builds {
+			version := b.UserVersion
+			if version == "" {
+				version = fmt.Sprintf("version %d", b.Version)
+			}
+			choices = append(choices, fmt.Sprintf("Build #%d (%s)", b.ID, version))
+		}
+
+		index := pick("Which build should be installed?", choices)
+		return &butlerd.InstallVersionSwitchPickResult{Index: int64(index)}, nil
+	})
+
+	h.Register(messages.PickManifestAction.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.PickManifestActionParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		var choices []string
+		for _, a := range params.Actions {
+			choices = append(choices, fmt.Sprintf("%s (%s)", a.Name, a.Path))
+		}
+
+		index := pick("Which action should be launched?", choices)
+		return &butlerd.PickManifestActionResult{Index: index}, nil
+	})
+
+	h.Register(messages.AcceptLicense.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.AcceptLicenseParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		comm.Notice("License agreement", strings.Split(params.Text, "\n"))
+		return &butlerd.AcceptLicenseResult{Accept: comm.YesNo("Do you accept the terms of the license?")}, nil
+	})
+
+	h.Register(messages.AllowSandboxSetup.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.AllowSandboxSetupParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		return &butlerd.AllowSandboxSetupResult{Allow: comm.YesNo("The sandbox needs to be set up (requires administrator privileges). Proceed?")}, nil
+	})
+
+	h.Register(messages.PrereqsFailed.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.PrereqsFailedParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		comm.Warnf("Some prerequisites failed to install: %s", params.Error)
+		comm.Debugf("%s", params.ErrorStack)
+		return &butlerd.PrereqsFailedResult{Continue: comm.YesNo("Launch anyway?")}, nil
+	})
+
+	h.Register(messages.ShellLaunch.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.ShellLaunchParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		comm.Opf("Opening %s", params.ItemPath)
+		err = open.Start(params.ItemPath)
+		if err != nil {
+			return nil, err
+		}
+		return &butlerd.ShellLaunchResult{}, nil
+	})
+
+	h.Register(messages.URLLaunch.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.URLLaunchParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		comm.Opf("Opening %s", params.URL)
+		err = open.Start(params.URL)
+		if err != nil {
+			return nil, err
+		}
+		return &butlerd.URLLaunchResult{}, nil
+	})
+
+	h.Register(messages.HTMLLaunch.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+		var params butlerd.HTMLLaunchParams
+		err := decodeParams(rc, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		indexPath := filepath.Join(params.RootFolder, filepath.FromSlash(params.IndexPath))
+		comm.Opf("Opening %s in a web browser", indexPath)
+		err = open.Start(indexPath)
+		if err != nil {
+			return nil, err
+		}
+		return &butlerd.HTMLLaunchResult{}, nil
+	})
+}
+
+// registerNotifications relays logs and progress sent by butlerd
+func registerNotifications(h *handler) {
+	messages.Log.Register(h, func(rc *butlerd.RequestContext, params butlerd.LogNotification) {
+		switch params.Level {
+		case butlerd.LogLevelDebug:
+			comm.Debug(params.Message)
+		case butlerd.LogLevelWarning, butlerd.LogLevelError:
+			comm.Warn(params.Message)
+		default:
+			comm.Log(params.Message)
+		}
+	})
+
+	messages.TaskStarted.Register(h, func(rc *butlerd.RequestContext, params butlerd.TaskStartedNotification) {
+		comm.Opf("Starting %s task", params.Type)
+		comm.StartProgressWithTotalBytes(params.TotalSize)
+	})
+
+	messages.Progress.Register(h, func(rc *butlerd.RequestContext, params butlerd.ProgressNotification) {
+		comm.Progress(params.Progress)
+	})
+
+	messages.TaskSucceeded.Register(h, func(rc *butlerd.RequestContext, params butlerd.TaskSucceededNotification) {
+		comm.EndProgress()
+		comm.Statf("Task %s succeeded", params.Type)
+	})
+
+	messages.PrereqsStarted.Register(h, func(rc *butlerd.RequestContext, params butlerd.PrereqsStartedNotification) {
+		comm.Opf("Installing %d prerequisites", len(params.Tasks))
+	})
+
+	messages.PrereqsEnded.Register(h, func(rc *butlerd.RequestContext, params butlerd.PrereqsEndedNotification) {
+		comm.Statf("Done with prerequisites")
+	})
+
+	messages.DownloadsDriveStarted.Register(h, func(rc *butlerd.RequestContext, params butlerd.DownloadsDriveStartedNotification) {
+		comm.Opf("Downloading %s", params.Download.Game.Title)
+		comm.StartProgress()
+	})
+
+	messages.DownloadsDriveProgress.Register(h, func(rc *butlerd.RequestContext, params butlerd.DownloadsDriveProgressNotification) {
+		comm.Progress(params.Progress.Progress)
+	})
+
+	messages.LaunchRunning.Register(h, func(rc *butlerd.RequestContext, params butlerd.LaunchRunningNotification) {
+		comm.Opf("Game is running")
+	})
+
+	messages.LaunchExited.Register(h, func(rc *butlerd.RequestContext, params butlerd.LaunchExitedNotification) {
+		comm.Statf("Game has exited")
+	})
+}
+
+// pick asks the user to choose from a list, and returns
+// the index of the choice, or -1 if they didn't pick anything
+func pick(question string, choices []string) int {
+	for i, choice := range choices {
+		comm.Logf("  %d. %s", i+1, choice)
+	}
+
+	if comm.AssumeYesEnabled() {
+		comm.Logf("%s 1 (--assume-yes)", question)
+		return 0
+	}
+
+	comm.Logf("%s [1-%d]", question, len(choices))
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Scan()
+	answer, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+	if err != nil || answer < 1 || answer > int64(len(choices)) {
+		comm.Logf("No valid choice given, cancelling")
+		return -1
+	}
+	return int(answer - 1)
+}
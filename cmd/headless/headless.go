@@ -0,0 +1,116 @@
+package headless
+
+import (
+	"github.com/itchio/butler/mansion"
+)
+
+var installArgs = struct {
+	game        string
+	location    string
+	uploadID    int64
+	buildID     int64
+	userVersion string
+}{}
+
+var installFileArgs = struct {
+	path             string
+	location         string
+	caveID           string
+	uploadID         int64
+	buildID          int64
+	ignoreInstallers bool
+}{}
+
+var switchVersionArgs = struct {
+	caveID      string
+	buildID     int64
+	userVersion string
+}{}
+
+var uninstallArgs = struct {
+	caveID          string
+	hard            bool
+	keepAngels      bool
+	skipSavesBackup bool
+	dryRun          bool
+}{}
+
+var verifyArgs = struct {
+	caveID string
+	repair bool
+}{}
+
+var launchArgs = struct {
+	caveID       string
+	prereqsDir   string
+	forcePrereqs bool
+	sandbox      bool
+}{}
+
+// Register adds commands that run butlerd operations in-process, against
+// the local database, without requiring a butlerd client.
+func Register(ctx *mansion.Context) {
+	{
+		cmd := ctx.App.Command("install", "Install a game from itch.io, without the itch app")
+		cmd.Arg("game", "ID or URL of the game to install, for example '123456' or 'https://leafo.itch.io/x-moon'").Required().StringVar(&installArgs.game)
+		cmd.Flag("location", "Folder to install into. Defaults to the first install location known to the database").StringVar(&installArgs.location)
+		cmd.Flag("upload-id", "Which upload to install, instead of picking a compatible one").Int64Var(&installArgs.uploadID)
+		cmd.Flag("build-id", "Install this build of the upload, instead of its latest one").Int64Var(&installArgs.buildID)
+		cmd.Flag("userversion", "Install the most recent build of the upload with this user version").StringVar(&installArgs.userVersion)
+		ctx.Register(cmd, doInstall)
+	}
+
+	{
+		cmd := ctx.App.Command("install-file", "Install a game from a local copy of one of its uploads, without downloading it")
+		cmd.Arg("path", "Upload archive, or folder it was extracted to").Required().ExistingFileOrDirVar(&installFileArgs.path)
+		cmd.Flag("location", "Folder to install into. Defaults to the first install location known to the database").StringVar(&installFileArgs.location)
+		cmd.Flag("cave", "Install over this cave instead of creating a new one").StringVar(&installFileArgs.caveID)
+		cmd.Flag("upload-id", "Which upload it is. If not given, it's recognized by hash, or by name and size").Int64Var(&installFileArgs.uploadID)
+		cmd.Flag("build-id", "Which build of the upload it is. Defaults to its latest known build").Int64Var(&installFileArgs.buildID)
+		cmd.Flag("ignore-installers", "Don't run installers, just extract them").BoolVar(&installFileArgs.ignoreInstallers)
+		ctx.Register(cmd, doInstallFile)
+	}
+
+	{
+		cmd := ctx.App.Command("switch-version", "Install another build of an installed game, for example to go back to a previous version")
+		cmd.Arg("cave", "ID of the cave to switch (see 'butler caves')").Required().StringVar(&switchVersionArgs.caveID)
+		cmd.Flag("build-id", "Build to switch to. If neither this nor --userversion are given, builds are listed to pick from").Int64Var(&switchVersionArgs.buildID)
+		cmd.Flag("userversion", "Switch to the most recent build with this user version").StringVar(&switchVersionArgs.userVersion)
+		ctx.Register(cmd, doSwitchVersion)
+	}
+
+	{
+		cmd := ctx.App.Command("uninstall", "Uninstall a game previously installed with 'butler install' or the itch app")
+		cmd.Arg("cave", "ID of the cave to uninstall (see 'butler caves')").Required().StringVar(&uninstallArgs.caveID)
+		cmd.Flag("hard", "Don't run any uninstallers, just remove the install folder").BoolVar(&uninstallArgs.hard)
+		cmd.Flag("keep-angels", "Leave files that weren't installed, like saves, in the install folder").BoolVar(&uninstallArgs.keepAngels)
+		cmd.Flag("skip-saves-backup", "Uninstall even if save files can't be backed up first").BoolVar(&uninstallArgs.skipSavesBackup)
+		cmd.Flag("dry-run", "Only print what would be removed").BoolVar(&uninstallArgs.dryRun)
+		ctx.Register(cmd, doUninstall)
+	}
+
+	{
+		cmd := ctx.App.Command("launch", "Launch an installed game")
+		cmd.Arg("cave", "ID of the cave to launch (see 'butler caves')").Required().StringVar(&launchArgs.caveID)
+		cmd.Flag("prereqs-dir", "Folder to store prerequisites installers in. Defaults to a folder next to the database").StringVar(&launchArgs.prereqsDir)
+		cmd.Flag("force-prereqs", "Install all prerequisites, even if they're marked as installed").BoolVar(&launchArgs.forcePrereqs)
+		cmd.Flag("sandbox", "Run the game in the itch.io sandbox, even if the manifest doesn't opt in").BoolVar(&launchArgs.sandbox)
+		ctx.Register(cmd, doLaunch)
+	}
+
+	{
+		caves := ctx.App.Command("caves", "List and check installed games")
+
+		{
+			cmd := caves.Command("list", "List installed games").Default()
+			ctx.Register(cmd, doCaves)
+		}
+
+		{
+			cmd := caves.Command("verify", "Check the files of an installed game, and optionally repair it")
+			cmd.Arg("cave", "ID of the cave to verify (see 'butler caves')").Required().StringVar(&verifyArgs.caveID)
+			cmd.Flag("repair", "Fix missing or corrupted files, by fetching them again").BoolVar(&verifyArgs.repair)
+			ctx.Register(cmd, doVerify)
+		}
+	}
+}
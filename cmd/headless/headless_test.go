@@ -0,0 +1,92 @@
+package headless
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func withAssumeYes(f func()) {
+	comm.Configure(true, true, false, false, false, true, false)
+	defer comm.Configure(false, false, false, false, false, false, false)
+	f()
+}
+
+// request calls one of the handlers butlerd would call
+func request(t *testing.T, h *handler, method string, params interface{}) (interface{}, error) {
+	rh, ok := h.handlers[method]
+	if !ok {
+		t.Fatalf("no handler for %s", method)
+	}
+
+	rc := &butlerd.RequestContext{Consumer: h.consumer}
+	if params != nil {
+		payload, err := json.Marshal(params)
+		wtest.Must(t, err)
+		raw := json.RawMessage(payload)
+		rc.Params = &raw
+	}
+	return rh(rc)
+}
+
+func TestPickAssumeYes(t *testing.T) {
+	withAssumeYes(func() {
+		assert.EqualValues(t, 0, pick("Which one?", []string{"this one", "that one"}))
+	})
+}
+
+func TestDialogs(t *testing.T) {
+	h := newHandler(&state.Consumer{})
+	registerDialogs(h)
+
+	withAssumeYes(func() {
+		res, err := request(t, h, messages.PickUpload.Method(), butlerd.PickUploadParams{
+			Uploads: []*itchio.Upload{
+				{ID: 10, Filename: "garden-linux.zip"},
+				{ID: 11, Filename: "garden-windows.zip"},
+			},
+		})
+		wtest.Must(t, err)
+		assert.EqualValues(t, 0, res.(*butlerd.PickUploadResult).Index)
+
+		res, err = request(t, h, messages.InstallVersionSwitchPick.Method(), butlerd.InstallVersionSwitchPickParams{
+			Builds: []*itchio.Build{{ID: 101}, {ID: 100, UserVersion: "1.0"}},
+		})
+		wtest.Must(t, err)
+		assert.EqualValues(t, 0, res.(*butlerd.InstallVersionSwitchPickResult).Index)
+
+		res, err = request(t, h, messages.AcceptLicense.Method(), butlerd.AcceptLicenseParams{Text: "Be nice"})
+		wtest.Must(t, err)
+		assert.True(t, res.(*butlerd.AcceptLicenseResult).Accept)
+	})
+
+	_, err := request(t, h, messages.PickUpload.Method(), nil)
+	assert.Error(t, err)
+}
+
+func TestSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "headless")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := mansion.NewContext(kingpin.New("butler", "test"))
+	ctx.DBPath = filepath.Join(dir, "butler.db")
+
+	// talks to the in-process daemon, which has no games yet
+	wtest.Must(t, Caves(ctx))
+
+	err = Uninstall(ctx, butlerd.UninstallPerformParams{CaveID: "nope"})
+	assert.Error(t, err)
+}
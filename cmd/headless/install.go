@@ -0,0 +1,240 @@
+package headless
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/fetch"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/pkg/errors"
+)
+
+func doInstall(ctx *mansion.Context) {
+	ctx.Must(Install(ctx, installArgs.game, installArgs.location, installArgs.uploadID, fetch.BuildSelector{
+		BuildID:     installArgs.buildID,
+		UserVersion: installArgs.userVersion,
+	}))
+}
+
+// Install queues and performs an install of a game, the same way
+// the itch app would. If a build is selected, it's installed instead
+// of the upload's latest build.
+func Install(ctx *mansion.Context, gameSpec string, location string, uploadID int64, selector fetch.BuildSelector) error {
+	err := selector.Validate()
+	if err != nil {
+		return err
+	}
+
+	gameID, err := resolveGameID(ctx, gameSpec)
+	if err != nil {
+		return errors.WithMessage(err, "finding game")
+	}
+
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	rc := s.rc
+
+	profile, err := s.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	// owned keys & developed games determine which credentials
+	// are used to download the game
+	err = rc.Call(messages.FetchProfileOwnedKeys.Method(), butlerd.FetchProfileOwnedKeysParams{
+		ProfileID: profile.ID,
+		Fresh:     true,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "fetching owned keys")
+	}
+
+	err = rc.Call(messages.FetchProfileGames.Method(), butlerd.FetchProfileGamesParams{
+		ProfileID: profile.ID,
+		Fresh:     true,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "fetching developed games")
+	}
+
+	var gameRes butlerd.FetchGameResult
+	err = rc.Call(messages.FetchGame.Method(), butlerd.FetchGameParams{
+		GameID: gameID,
+		Fresh:  true,
+	}, &gameRes)
+	if err != nil {
+		return errors.WithMessage(err, "fetching game")
+	}
+	game := gameRes.Game
+
+	var upload *itchio.Upload
+	if uploadID != 0 || !selector.IsZero() {
+		var uploadsRes butlerd.FetchGameUploadsResult
+		err := rc.Call(messages.FetchGameUploads.Method(), butlerd.FetchGameUploadsParams{
+			GameID: gameID,
+			Fresh:  true,
+		}, &uploadsRes)
+		if err != nil {
+			return errors.WithMessage(err, "fetching uploads")
+		}
+
+		if uploadID != 0 {
+			for _, u := range uploadsRes.Uploads {
+				if u.ID == uploadID {
+					upload = u
+					break
+				}
+			}
+			if upload == nil {
+				return errors.Errorf("Upload #%d not found for %s", uploadID, game.Title)
+			}
+		} else {
+			// only wharf-enabled uploads have builds
+			for _, u := range uploadsRes.Uploads {
+				if u.Build == nil {
+					continue
+				}
+				if upload != nil {
+					return errors.Errorf("Several uploads of %s have builds, pick one with --upload-id", game.Title)
+				}
+				upload = u
+			}
+			if upload == nil {
+				return errors.Errorf("No uploads of %s have builds", game.Title)
+			}
+		}
+	}
+
+	var build *itchio.Build
+	if !selector.IsZero() {
+		client, credentials := s.gameClient(ctx, gameID)
+		build, err = fetch.FindBuild(ctx.DefaultCtx(), client, upload.ID, credentials, selector)
+		if err != nil {
+			return err
+		}
+		comm.Logf("Picked build #%d for upload #%d", build.ID, upload.ID)
+	}
+
+	installLocationID, err := s.installLocationID(location)
+	if err != nil {
+		return err
+	}
+
+	comm.Opf("Queuing install for %s", game.Title)
+	var queueRes butlerd.InstallQueueResult
+	err = rc.Call(messages.InstallQueue.Method(), butlerd.InstallQueueParams{
+		Game:              game,
+		Upload:            upload,
+		Build:             build,
+		InstallLocationID: installLocationID,
+	}, &queueRes)
+	if err != nil {
+		return errors.WithMessage(err, "queuing install")
+	}
+
+	comm.Opf("Installing to %s", queueRes.InstallFolder)
+	err = rc.Call(messages.InstallPerform.Method(), butlerd.InstallPerformParams{
+		ID:            queueRes.ID,
+		StagingFolder: queueRes.StagingFolder,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "performing install")
+	}
+
+	comm.ResultOrPrint(queueRes, func() {
+		comm.Statf("Installed %s as cave %s", game.Title, queueRes.CaveID)
+		comm.Logf("")
+		comm.Logf("Use `butler launch %s` to launch it.", queueRes.CaveID)
+	})
+	return nil
+}
+
+// installLocationID returns the ID of the install location with the given
+// path, adding it if necessary. If path is empty, the first install location
+// is used.
+func (s *session) installLocationID(path string) (string, error) {
+	var listRes butlerd.InstallLocationsListResult
+	err := s.rc.Call(messages.InstallLocationsList.Method(), butlerd.InstallLocationsListParams{}, &listRes)
+	if err != nil {
+		return "", errors.WithMessage(err, "listing install locations")
+	}
+
+	if path == "" {
+		if len(listRes.InstallLocations) == 0 {
+			return "", errors.New("No install locations yet, specify one with --location")
+		}
+		return listRes.InstallLocations[0].ID, nil
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	for _, il := range listRes.InstallLocations {
+		if filepath.Clean(il.Path) == path {
+			return il.ID, nil
+		}
+	}
+
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var addRes butlerd.InstallLocationsAddResult
+	err = s.rc.Call(messages.InstallLocationsAdd.Method(), butlerd.InstallLocationsAddParams{
+		Path: path,
+	}, &addRes)
+	if err != nil {
+		return "", errors.WithMessage(err, "adding install location")
+	}
+	comm.Logf("Added install location %s", path)
+	return addRes.InstallLocation.ID, nil
+}
+
+// resolveGameID accepts either a numeric game ID, or the URL of a game page
+func resolveGameID(ctx *mansion.Context, gameSpec string) (int64, error) {
+	if gameID, err := strconv.ParseInt(gameSpec, 10, 64); err == nil {
+		return gameID, nil
+	}
+
+	if !strings.HasPrefix(gameSpec, "http://") && !strings.HasPrefix(gameSpec, "https://") {
+		return 0, errors.Errorf("Invalid game (%s): expected a game ID or URL", gameSpec)
+	}
+
+	// every game page has a small JSON companion with its ID
+	dataURL := strings.TrimSuffix(gameSpec, "/") + "/data.json"
+	res, err := ctx.HTTPClient.Get(dataURL)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("While fetching %s, got HTTP %d", dataURL, res.StatusCode)
+	}
+
+	var data struct {
+		ID int64 `json:"id"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&data)
+	if err != nil {
+		return 0, errors.WithMessage(err, "decoding game data")
+	}
+	if data.ID == 0 {
+		return 0, errors.Errorf("No game found at %s", gameSpec)
+	}
+	return data.ID, nil
+}
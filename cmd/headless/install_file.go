@@ -0,0 +1,60 @@
+package headless
+
+import (
+	"fmt"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	"github.com/pkg/errors"
+)
+
+func doInstallFile(ctx *mansion.Context) {
+	ctx.Must(InstallFile(ctx, installFileArgs.path, installFileArgs.location, butlerd.InstallFromFileParams{
+		CaveID:           installFileArgs.caveID,
+		UploadID:         installFileArgs.uploadID,
+		BuildID:          installFileArgs.buildID,
+		IgnoreInstallers: installFileArgs.ignoreInstallers,
+	}))
+}
+
+// InstallFile installs a game from a local file or folder, without
+// downloading anything. The upload it's from must be known to the
+// database, from a previous install or fetch.
+func InstallFile(ctx *mansion.Context, path string, location string, params butlerd.InstallFromFileParams) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	params.Path = path
+	if params.CaveID == "" {
+		params.InstallLocationID, err = s.installLocationID(location)
+		if err != nil {
+			return err
+		}
+	}
+
+	comm.Opf("Installing from %s", path)
+	var res butlerd.InstallFromFileResult
+	err = s.rc.Call(messages.InstallFromFile.Method(), params, &res)
+	if err != nil {
+		return errors.WithMessage(err, "installing from file")
+	}
+
+	comm.ResultOrPrint(res, func() {
+		how := fmt.Sprintf("matched by %s", res.MatchedBy)
+		if res.MatchedBy == butlerd.SourceMatchFilename {
+			how = "guessed from its file name and size"
+		}
+		if res.Verified {
+			how += fmt.Sprintf(", checked against build %d", res.Build.ID)
+		}
+		comm.Statf("Installed %s as cave %s (%s)", res.Game.Title, res.CaveID, how)
+		comm.Logf("")
+		comm.Logf("Use `butler launch %s` to launch it.", res.CaveID)
+	})
+	return nil
+}
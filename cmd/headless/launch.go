@@ -0,0 +1,41 @@
+package headless
+
+import (
+	"path/filepath"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	"github.com/pkg/errors"
+)
+
+func doLaunch(ctx *mansion.Context) {
+	ctx.Must(Launch(ctx, launchArgs.caveID, launchArgs.prereqsDir, launchArgs.forcePrereqs, launchArgs.sandbox))
+}
+
+// Launch runs an installed cave and waits for it to exit
+func Launch(ctx *mansion.Context, caveID string, prereqsDir string, forcePrereqs bool, sandbox bool) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if prereqsDir == "" {
+		prereqsDir = filepath.Join(filepath.Dir(ctx.DBPath), "prereqs")
+	}
+
+	comm.Opf("Launching cave %s", caveID)
+	err = s.rc.Call(messages.Launch.Method(), butlerd.LaunchParams{
+		CaveID:       caveID,
+		PrereqsDir:   prereqsDir,
+		ForcePrereqs: forcePrereqs,
+		Sandbox:      sandbox,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "launching")
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/daemon"
+	"github.com/itchio/butler/cmd/operate"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// A session is a connection to an in-process butlerd router
+type session struct {
+	rc      *butlerd.RequestContext
+	handler *handler
+	dbPool  *sqlite.Pool
+	cancel  context.CancelFunc
+}
+
+func newSession(ctx *mansion.Context) (*session, error) {
+	if ctx.DBPath == "" {
+		ctx.DBPath = butlerd.GuessDBPath("")
+		comm.Logf("Using database at %s (pass --dbpath to use another one)", ctx.DBPath)
+	}
+
+	dbPool, err := daemon.OpenDB(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	h := newHandler(comm.NewStateConsumer())
+	registerDialogs(h)
+	registerNotifications(h)
+
+	sessionCtx, cancel := context.WithCancel(context.Background())
+	conn := daemon.ConnectInProcess(sessionCtx, ctx, dbPool, h)
+
+	s := &session{
+		rc: &butlerd.RequestContext{
+			Ctx:      sessionCtx,
+			Conn:     &butlerd.JsonRPC2Conn{Conn: conn},
+			Consumer: h.consumer,
+		},
+		handler: h,
+		dbPool:  dbPool,
+		cancel:  cancel,
+	}
+	return s, nil
+}
+
+func (s *session) Close() {
+	s.cancel()
+	s.dbPool.Close()
+}
+
+// login makes sure the credentials saved by `butler login` are
+// also known to the database, as a profile.
+func (s *session) login(ctx *mansion.Context) (*butlerd.Profile, error) {
+	client, err := ctx.AuthenticateViaOauth()
+	if err != nil {
+		return nil, errors.WithMessage(err, "authenticating")
+	}
+
+	var res butlerd.ProfileLoginWithAPIKeyResult
+	err = s.rc.Call(messages.ProfileLoginWithAPIKey.Method(), butlerd.ProfileLoginWithAPIKeyParams{
+		APIKey: client.Key,
+	}, &res)
+	if err != nil {
+		return nil, errors.WithMessage(err, "logging into profile")
+	}
+	return res.Profile, nil
+}
+
+// gameClient returns an API client and credentials able to download
+// the given game, according to the owned keys and games in the database.
+func (s *session) gameClient(ctx *mansion.Context, gameID int64) (*itchio.Client, itchio.GameCredentials) {
+	conn := s.dbPool.Get(s.rc.Ctx.Done())
+	defer s.dbPool.Put(conn)
+
+	access := operate.AccessForGameID(conn, gameID)
+	return ctx.NewClient(access.APIKey), access.Credentials
+}
+
+//
+
+// handler answers requests and notifications sent by butlerd. It implements
+// the router interface expected by the messages package.
+type handler struct {
+	handlers             map[string]butlerd.RequestHandler
+	notificationHandlers map[string]butlerd.NotificationHandler
+	consumer             *state.Consumer
+}
+
+var _ jsonrpc2.Handler = (*handler)(nil)
+
+func newHandler(consumer *state.Consumer) *handler {
+	return &handler{
+		handlers:             make(map[string]butlerd.RequestHandler),
+		notificationHandlers: make(map[string]butlerd.NotificationHandler),
+		consumer:             consumer,
+	}
+}
+
+func (h *handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	rc := &butlerd.RequestContext{
+		Ctx:      ctx,
+		Conn:     &butlerd.JsonRPC2Conn{Conn: conn},
+		Params:   req.Params,
+		Consumer: h.consumer,
+	}
+
+	if req.Notif {
+		if nh, ok := h.notificationHandlers[req.Method]; ok {
+			nh(rc)
+		}
+		return
+	}
+
+	var err error
+	if rh, ok := h.handlers[req.Method]; ok {
+		var res interface{}
+		res, err = rh(rc)
+		if err == nil {
+			err = conn.Reply(ctx, req.ID, res)
+		} else {
+			err = conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: fmt.Sprintf("%+v", err),
+			})
+		}
+	} else {
+		err = conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("Method '%s' not found", req.Method),
+		})
+	}
+	if err != nil {
+		comm.Warnf("Failed to reply to %s: %+v", req.Method, err)
+	}
+}
+
+func (h *handler) Register(method string, rh butlerd.RequestHandler) {
+	h.handlers[method] = rh
+}
+
+func (h *handler) RegisterNotification(method string, nh butlerd.NotificationHandler) {
+	h.notificationHandlers[method] = nh
+}
+
+// decodeParams reads the params of a request butlerd sent us
+func decodeParams(rc *butlerd.RequestContext, params interface{}) error {
+	if rc.Params == nil {
+		return &butlerd.RpcError{Code: jsonrpc2.CodeInvalidParams, Message: "missing params"}
+	}
+	err := json.Unmarshal(*rc.Params, params)
+	if err != nil {
+		return &butlerd.RpcError{Code: jsonrpc2.CodeParseError, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package headless
+
+import (
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/fetch"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	"github.com/pkg/errors"
+)
+
+func doSwitchVersion(ctx *mansion.Context) {
+	ctx.Must(SwitchVersion(ctx, switchVersionArgs.caveID, fetch.BuildSelector{
+		BuildID:     switchVersionArgs.buildID,
+		UserVersion: switchVersionArgs.userVersion,
+	}))
+}
+
+// SwitchVersion installs another build of a cave's upload. If no build is
+// selected, the user picks one from a list.
+func SwitchVersion(ctx *mansion.Context, caveID string, selector fetch.BuildSelector) error {
+	err := selector.Validate()
+	if err != nil {
+		return err
+	}
+
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	_, err = s.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !selector.IsZero() {
+		s.handler.Register(messages.InstallVersionSwitchPick.Method(), func(rc *butlerd.RequestContext) (interface{}, error) {
+			var params butlerd.InstallVersionSwitchPickParams
+			err := decodeParams(rc, &params)
+			if err != nil {
+				return nil, err
+			}
+
+			index := selector.Pick(params.Builds)
+			if index < 0 {
+				comm.Logf("No %s found among %d builds of upload #%d", selector, len(params.Builds), params.Upload.ID)
+			}
+			return &butlerd.InstallVersionSwitchPickResult{Index: int64(index)}, nil
+		})
+	}
+
+	comm.Opf("Looking for versions of cave %s", caveID)
+	err = s.rc.Call(messages.InstallVersionSwitchQueue.Method(), butlerd.InstallVersionSwitchQueueParams{
+		CaveID: caveID,
+	}, nil)
+	if err != nil {
+		return errors.WithMessage(err, "queuing version switch")
+	}
+
+	err = s.driveDownloads(caveID)
+	if err != nil {
+		return err
+	}
+
+	comm.Statf("Switched cave %s to another version", caveID)
+	return nil
+}
+
+// driveDownloads performs queued downloads until the one for
+// the given cave has finished or errored.
+func (s *session) driveDownloads(caveID string) error {
+	done := make(chan error, 1)
+	messages.DownloadsDriveFinished.Register(s.handler, func(rc *butlerd.RequestContext, params butlerd.DownloadsDriveFinishedNotification) {
+		comm.EndProgress()
+		if params.Download.CaveID == caveID {
+			done <- nil
+		}
+	})
+	messages.DownloadsDriveErrored.Register(s.handler, func(rc *butlerd.RequestContext, params butlerd.DownloadsDriveErroredNotification) {
+		comm.EndProgress()
+		if params.Download.CaveID == caveID {
+			msg := "unknown error"
+			if params.Download.ErrorMessage != nil {
+				msg = *params.Download.ErrorMessage
+			}
+			done <- errors.Errorf("Download failed: %s", msg)
+		}
+	})
+
+	driveDone := make(chan error, 1)
+	go func() {
+		err := s.rc.Call(messages.DownloadsDrive.Method(), butlerd.DownloadsDriveParams{}, nil)
+		driveDone <- err
+	}()
+
+	select {
+	case err := <-done:
+		cancelErr := s.rc.Call(messages.DownloadsDriveCancel.Method(), butlerd.DownloadsDriveCancelParams{}, nil)
+		if cancelErr != nil {
+			comm.Warnf("Could not stop driving downloads: %s", cancelErr.Error())
+		} else {
+			<-driveDone
+		}
+		return err
+	case err := <-driveDone:
+		if err != nil {
+			return errors.WithMessage(err, "driving downloads")
+		}
+		return errors.New("Stopped driving downloads before the version switch was done")
+	}
+}
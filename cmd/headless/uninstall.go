@@ -0,0 +1,81 @@
+package headless
+
+import (
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	"github.com/itchio/headway/united"
+	"github.com/pkg/errors"
+)
+
+func doUninstall(ctx *mansion.Context) {
+	params := butlerd.UninstallPerformParams{
+		CaveID:          uninstallArgs.caveID,
+		Hard:            uninstallArgs.hard,
+		KeepAngels:      uninstallArgs.keepAngels,
+		SkipSavesBackup: uninstallArgs.skipSavesBackup,
+	}
+	if uninstallArgs.dryRun {
+		ctx.Must(UninstallPlan(ctx, params))
+		return
+	}
+	ctx.Must(Uninstall(ctx, params))
+}
+
+// Uninstall removes an installed cave and its install folder
+func Uninstall(ctx *mansion.Context, params butlerd.UninstallPerformParams) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	comm.Opf("Uninstalling cave %s", params.CaveID)
+	err = s.rc.Call(messages.UninstallPerform.Method(), params, nil)
+	if err != nil {
+		return errors.WithMessage(err, "performing uninstall")
+	}
+
+	comm.Statf("Uninstalled cave %s", params.CaveID)
+	return nil
+}
+
+// UninstallPlan prints what uninstalling a cave would remove
+func UninstallPlan(ctx *mansion.Context, params butlerd.UninstallPerformParams) error {
+	s, err := newSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var res butlerd.UninstallPlanResult
+	err = s.rc.Call(messages.UninstallPlan.Method(), butlerd.UninstallPlanParams{
+		CaveID:     params.CaveID,
+		Hard:       params.Hard,
+		KeepAngels: params.KeepAngels,
+	}, &res)
+	if err != nil {
+		return errors.WithMessage(err, "planning uninstall")
+	}
+
+	comm.ResultOrPrint(res, func() {
+		comm.Opf("Uninstalling cave %s would:", params.CaveID)
+		if res.ExternalUninstaller {
+			comm.Logf("  - run the %s uninstaller", res.Installer)
+		}
+		comm.Logf("  - remove %d installed files and %d folders from (%s)", len(res.Files), len(res.Dirs), res.InstallFolder)
+		if len(res.Angels) > 0 {
+			verb := "remove"
+			if params.KeepAngels && !params.Hard {
+				verb = "keep"
+			}
+			comm.Logf("  - %s %d files that weren't installed (%s):", verb, len(res.Angels), united.FormatBytes(res.AngelsSize))
+			for _, angel := range res.Angels {
+				comm.Logf("      %s", angel)
+			}
+		}
+		comm.Statf("%s would be freed", united.FormatBytes(res.ReclaimedBytes))
+	})
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/itchio/headway/united"
 	"github.com/itchio/headway/counter"
+	"github.com/itchio/headway/state"
 
 	"github.com/itchio/arkive/zip"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/itchio/butler/filtering"
 	"github.com/itchio/butler/mansion"
 
+	"github.com/itchio/lake"
 	"github.com/itchio/lake/tlc"
 	"github.com/itchio/lake/pools/fspool"
 	"github.com/itchio/lake/pools/zipwriterpool"
@@ -49,12 +51,40 @@ func do(ctx *mansion.Context) error {
 	consumer.Statf("Found %s", container)
 
 	src := fspool.New(container, args.dir)
+	defer src.Close()
 
 	w, err := os.Create(args.out)
 	if err != nil {
 		return err
 	}
+	defer w.Close()
 
+	consumer.Opf("Compressing...")
+	comm.StartProgressWithTotalBytes(container.Size)
+	startTime := time.Now()
+
+	err = Compress(consumer, container, src, w)
+	if err != nil {
+		return err
+	}
+	comm.EndProgress()
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(startTime)
+	consumer.Statf("Compressed @ %s (%s total)",
+		united.FormatBPS(container.Size, duration),
+		united.FormatDuration(duration),
+	)
+	return nil
+}
+
+// Compress writes all files of container, read from src, as a .zip
+// archive to w.
+func Compress(consumer *state.Consumer, container *tlc.Container, src lake.Pool, w io.Writer) error {
 	zw := zip.NewWriter(w)
 
 	dst := zipwriterpool.New(container, zw)
@@ -77,6 +107,10 @@ func do(ctx *mansion.Context) error {
 		defer fdst.Close()
 
 		cw := counter.NewWriterCallback(func(done int64) {
+			if container.Size == 0 {
+				// only empty files, nothing to report
+				return
+			}
 			p := float64(totalBytes+done) / float64(container.Size)
 			consumer.Progress(p)
 		}, fdst)
@@ -91,28 +125,13 @@ func do(ctx *mansion.Context) error {
 		return nil
 	}
 
-	consumer.Opf("Compressing...")
-	comm.StartProgressWithTotalBytes(container.Size)
-	startTime := time.Now()
-
 	numFiles := len(container.Files)
 	for i := 0; i < numFiles; i++ {
-		err = doFile(int64(i))
+		err := doFile(int64(i))
 		if err != nil {
 			return err
 		}
 	}
-	comm.EndProgress()
-
-	err = dst.Close()
-	if err != nil {
-		return err
-	}
 
-	duration := time.Since(startTime)
-	consumer.Statf("Compressed @ %s (%s total)",
-		united.FormatBPS(container.Size, duration),
-		united.FormatDuration(duration),
-	)
-	return nil
+	return dst.Close()
 }
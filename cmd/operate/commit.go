@@ -1,6 +1,8 @@
 package operate
 
 import (
+	"os"
+
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/butlerd/messages"
 	"github.com/itchio/butler/installer"
@@ -57,6 +59,12 @@ func commitInstall(oc *OperationContext, params *CommitInstallParams) error {
 		return errors.WithStack(err)
 	}
 
+	// whatever was kept to roll back is for another build now
+	err = os.RemoveAll(RollbackFolder(params.InstallFolder))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	cave := oc.cave
 	if cave != nil {
 		// TODO: pass runtime in params?
@@ -73,6 +81,7 @@ func commitInstall(oc *OperationContext, params *CommitInstallParams) error {
 		cave.Build = params.Build
 		cave.UpdateInstallTime()
 		oc.rc.WithConn(cave.SaveWithAssocs)
+		recordCaveFileStats(oc.rc, cave.ID, params.InstallFolder, res.Files)
 	}
 
 	return nil
@@ -14,6 +14,7 @@ import (
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/cmd/wipe"
 	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/lanshare"
 	"github.com/itchio/headway/state"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
@@ -34,6 +35,10 @@ type OperationContext struct {
 	loaded map[string]struct{}
 
 	pidFilePath string
+
+	// peers found by lanPeers, by build ID, so the network
+	// is only browsed once per operation.
+	lanPeersByBuild map[int64][]*lanshare.Peer
 }
 
 type PidFileContents struct {
@@ -0,0 +1,159 @@
+package operate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/operate/loopbackconn"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+)
+
+// operateFixture is a database with an install location in a
+// temporary folder, and a request context to run operations with
+type operateFixture struct {
+	dir      string
+	dbPool   *sqlite.Pool
+	rc       *butlerd.RequestContext
+	location *models.InstallLocation
+
+	mutex         sync.Mutex
+	notifications []string
+}
+
+func newOperateFixture(t *testing.T) *operateFixture {
+	dir, err := ioutil.TempDir("", "operate-test")
+	wtest.Must(t, err)
+
+	dbPool := dbtest.OpenPool(t, dir, 2)
+
+	of := &operateFixture{
+		dir:    dir,
+		dbPool: dbPool,
+		location: &models.InstallLocation{
+			ID:   "location",
+			Path: filepath.Join(dir, "location"),
+		},
+	}
+
+	consumer := &state.Consumer{}
+	conn := loopbackconn.New(consumer)
+	for _, method := range []string{
+		messages.TaskStarted.Method(),
+		messages.TaskSucceeded.Method(),
+	} {
+		conn.OnNotification(method, func(ctx context.Context, method string, params interface{}) error {
+			of.mutex.Lock()
+			defer of.mutex.Unlock()
+			of.notifications = append(of.notifications, method)
+			return nil
+		})
+	}
+	of.rc = butlerd.NewRequestContext(context.Background(), consumer, conn, dbPool)
+
+	of.rc.WithConn(func(conn *sqlite.Conn) {
+		models.MustSave(conn, of.location)
+	})
+	return of
+}
+
+func (of *operateFixture) Close() {
+	of.dbPool.Close()
+	os.RemoveAll(of.dir)
+}
+
+func (of *operateFixture) notified() []string {
+	of.mutex.Lock()
+	defer of.mutex.Unlock()
+	return append([]string(nil), of.notifications...)
+}
+
+// addCave installs files to a new cave, as an archive install of build
+// (if it's not nil), and returns it the way endpoints get it
+func (of *operateFixture) addCave(t *testing.T, caveID string, build *itchio.Build, files map[string]string) *models.Cave {
+	installFolder := of.location.GetInstallFolder(caveID)
+	writeFiles(t, installFolder, files)
+
+	game := &itchio.Game{ID: 1, Title: "Garden"}
+	upload := &itchio.Upload{ID: 10, Filename: "garden.zip"}
+	receipt := &bfs.Receipt{
+		InstallerName: "archive",
+		Game:          game,
+		Upload:        upload,
+		Build:         build,
+	}
+	for path := range files {
+		receipt.Files = append(receipt.Files, path)
+	}
+	sort.Strings(receipt.Files)
+	wtest.Must(t, receipt.WriteReceipt(installFolder))
+
+	of.rc.WithConn(func(conn *sqlite.Conn) {
+		models.MustSave(conn, game)
+		models.MustSave(conn, upload)
+		cave := &models.Cave{
+			ID:                caveID,
+			GameID:            game.ID,
+			UploadID:          upload.ID,
+			InstallLocationID: of.location.ID,
+			InstallFolderName: caveID,
+		}
+		if build != nil {
+			models.MustSave(conn, build)
+			cave.BuildID = build.ID
+		}
+		models.MustSave(conn, cave)
+	})
+	return ValidateCave(of.rc, caveID)
+}
+
+// operationContext returns an operation context staging
+// in a temporary folder, as if installing to cave
+func (of *operateFixture) operationContext(cave *models.Cave) (*OperationContext, *MetaSubcontext, *InstallSubcontext) {
+	oc := &OperationContext{
+		cave:        cave,
+		rc:          of.rc,
+		ctx:         of.rc.Ctx,
+		consumer:    of.rc.Consumer,
+		stageFolder: of.location.GetStagingFolder(cave.ID),
+		root:        make(map[string]interface{}),
+		loaded:      make(map[string]struct{}),
+	}
+	meta := &MetaSubcontext{
+		Data: &InstallParams{
+			CaveID:        cave.ID,
+			InstallFolder: of.location.GetInstallFolder(cave.ID),
+			Game:          cave.Game,
+			Upload:        cave.Upload,
+			Build:         cave.Build,
+		},
+	}
+	isub := &InstallSubcontext{
+		Data: &InstallSubcontextState{},
+	}
+	return oc, meta, isub
+}
+
+func readFiles(t *testing.T, folder string) map[string]string {
+	res := make(map[string]string)
+	container, err := bfs.Walk(folder)
+	wtest.Must(t, err)
+	for _, f := range container.Files {
+		contents, err := ioutil.ReadFile(filepath.Join(folder, filepath.FromSlash(f.Path)))
+		wtest.Must(t, err)
+		res[f.Path] = string(contents)
+	}
+	return res
+}
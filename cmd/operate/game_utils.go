@@ -2,6 +2,7 @@ package operate
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -283,3 +284,34 @@ func FindBuildFile(files []*itchio.BuildFile, fileType itchio.BuildFileType, sub
 	}
 	return nil
 }
+
+// GetCaveSandboxPolicy returns the player's override of a
+// cave's sandbox policy, or nil if they haven't set one.
+func GetCaveSandboxPolicy(cave *models.Cave) (*butlerd.SandboxPolicy, error) {
+	if cave.SandboxPolicy == "" {
+		return nil, nil
+	}
+
+	var policy butlerd.SandboxPolicy
+	err := json.Unmarshal([]byte(cave.SandboxPolicy), &policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshalling sandbox policy")
+	}
+	return &policy, nil
+}
+
+// SetCaveSandboxPolicy sets (or clears, if policy is nil) the player's
+// override of a cave's sandbox policy. It doesn't save the cave.
+func SetCaveSandboxPolicy(cave *models.Cave, policy *butlerd.SandboxPolicy) error {
+	if policy == nil {
+		cave.SandboxPolicy = ""
+		return nil
+	}
+
+	contents, err := json.Marshal(policy)
+	if err != nil {
+		return errors.Wrap(err, "marshalling sandbox policy")
+	}
+	cave.SandboxPolicy = models.JSON(contents)
+	return nil
+}
@@ -1,6 +1,7 @@
 package operate
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/itchio/httpkit/eos"
 	"github.com/itchio/httpkit/eos/option"
 
+	"github.com/itchio/headway/state"
 	"github.com/itchio/headway/united"
 
 	"github.com/itchio/lake/tlc"
@@ -39,6 +41,11 @@ func heal(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, r
 		Build:  params.Build,
 	})
 
+	err := detachFromStore(oc, params.InstallFolder)
+	if err != nil {
+		return err
+	}
+
 	client := oc.rc.Client(params.Access.APIKey)
 
 	signatureURL := MakeSourceURL(client, consumer, istate.DownloadSessionID, params, "signature")
@@ -52,39 +59,21 @@ func heal(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, r
 		HealPath:   healSpec,
 	}
 
-	signatureFile, err := eos.Open(signatureURL, option.WithConsumer(consumer))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	defer signatureFile.Close()
-
-	stat, err := signatureFile.Stat()
+	sigInfo, err := fetchSignature(oc.ctx, consumer, signatureURL)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
 
-	consumer.Infof("Fetching + parsing %s signature...",
-		united.FormatBytes(stat.Size()),
-	)
-
-	signatureSource := seeksource.FromFile(signatureFile)
-
-	timeBeforeSig := time.Now()
-
-	_, err = signatureSource.Resume(nil)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	sigInfo, err := pwr.ReadSignature(oc.ctx, signatureSource)
-	if err != nil {
-		return errors.WithStack(err)
+	if st := installStore(oc, params); st != nil {
+		numFiles, numBytes, err := st.Seed(consumer, params.InstallFolder, sigInfo)
+		if err != nil {
+			consumer.Warnf("Could not link files from store: %+v", err)
+		} else if numFiles > 0 {
+			consumer.Infof("✓ Linked %d files (%s) from store", numFiles, united.FormatBytes(numBytes))
+		}
 	}
 
-	consumer.Infof("✓ Fetched signature in %s, dealing with %s container",
-		time.Since(timeBeforeSig),
-		united.FormatBytes(sigInfo.Container.Size),
-	)
+	seedFromPeers(oc, params, sigInfo)
 
 	consumer.Infof("Healing container...")
 
@@ -130,6 +119,8 @@ func heal(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, r
 
 	res := resultForContainer(sigInfo.Container)
 
+	linkFromStore(oc, params, res.Files)
+
 	consumer.Infof("Busting ghosts...")
 
 	err = bfs.BustGhosts(&bfs.BustGhostsParams{
@@ -171,3 +162,41 @@ func resultForContainer(c *tlc.Container) *installer.InstallResult {
 
 	return res
 }
+
+// fetchSignature downloads and parses the signature of a build
+func fetchSignature(ctx context.Context, consumer *state.Consumer, signatureURL string) (*pwr.SignatureInfo, error) {
+	signatureFile, err := eos.Open(signatureURL, option.WithConsumer(consumer))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer signatureFile.Close()
+
+	stat, err := signatureFile.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("Fetching + parsing %s signature...",
+		united.FormatBytes(stat.Size()),
+	)
+
+	signatureSource := seeksource.FromFile(signatureFile)
+
+	timeBeforeSig := time.Now()
+
+	_, err = signatureSource.Resume(nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sigInfo, err := pwr.ReadSignature(ctx, signatureSource)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("✓ Fetched signature in %s, dealing with %s container",
+		time.Since(timeBeforeSig),
+		united.FormatBytes(sigInfo.Container.Size),
+	)
+	return sigInfo, nil
+}
@@ -129,7 +129,7 @@ func doInstallPerform(oc *OperationContext, meta *MetaSubcontext) error {
 	}
 
 	{
-		if !istate.RefreshedGame {
+		if !istate.RefreshedGame && params.LocalSource == "" {
 			client := rc.Client(params.Access.APIKey)
 			istate.RefreshedGame = true
 			oc.Save(isub)
@@ -165,6 +165,17 @@ func doInstallPerform(oc *OperationContext, meta *MetaSubcontext) error {
 			rc.WithConn(func(conn *sqlite.Conn) {
 				cave = models.CaveByID(conn, params.CaveID)
 			})
+			if cave != nil && !istate.BackedUpSaves && isUpgrade(cave, params) {
+				_, err := BackupSaves(rc, cave, params.Game, butlerd.SaveSnapshotReasonUpgrade)
+				if err != nil {
+					consumer.Warnf("Could not back up saves: %+v", err)
+				}
+				istate.BackedUpSaves = true
+				err = oc.Save(isub)
+				if err != nil {
+					return err
+				}
+			}
 			if cave == nil {
 				cave = &models.Cave{
 					ID:                params.CaveID,
@@ -195,6 +206,10 @@ func doInstallPerform(oc *OperationContext, meta *MetaSubcontext) error {
 			return heal(oc, meta, isub, prepareRes.ReceiptIn)
 		}
 
+		if prepareRes.Strategy == InstallPerformStrategyLocalFolder {
+			return installFromFolder(oc, meta, prepareRes.ReceiptIn)
+		}
+
 		stats, err := prepareRes.File.Stat()
 		if err != nil {
 			return errors.WithStack(err)
@@ -218,6 +233,7 @@ func doInstallPerform(oc *OperationContext, meta *MetaSubcontext) error {
 			InstallFolderPath: params.InstallFolder,
 
 			ReceiptIn: prepareRes.ReceiptIn,
+			Store:     installStore(oc, params),
 
 			Context: oc.ctx,
 		}
@@ -388,16 +404,36 @@ func doInstallPerform(oc *OperationContext, meta *MetaSubcontext) error {
 			}
 		}
 
+		build := params.Build
+		if params.LocalSource != "" {
+			build, err = verifyLocalSource(oc, params)
+			if err != nil {
+				return err
+			}
+		}
+
 		return commitInstall(oc, &CommitInstallParams{
 			InstallFolder: params.InstallFolder,
 
 			InstallerName: string(finalInstallerInfo.Type),
 			Game:          params.Game,
 			Upload:        params.Upload,
-			Build:         params.Build,
+			Build:         build,
 
 			InstallResult: finalInstallResult,
 		})
 
 	})
 }
+
+// isUpgrade returns true if an install replaces what's installed
+// in a cave with another upload or build.
+func isUpgrade(cave *models.Cave, params *InstallParams) bool {
+	if params.Upload == nil {
+		return false
+	}
+	if cave.UploadID != params.Upload.ID {
+		return true
+	}
+	return params.Build != nil && cave.BuildID != params.Build.ID
+}
@@ -2,6 +2,7 @@ package operate
 
 import (
 	"io"
+	"os"
 
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/installer"
@@ -20,6 +21,8 @@ const (
 	InstallPerformStrategyInstall InstallPerformStrategy = 1
 	InstallPerformStrategyHeal    InstallPerformStrategy = 2
 	InstallPerformStrategyUpgrade InstallPerformStrategy = 3
+	// Copy an already-extracted local folder
+	InstallPerformStrategyLocalFolder InstallPerformStrategy = 4
 )
 
 type InstallPrepareResult struct {
@@ -53,7 +56,9 @@ func InstallPrepare(oc *OperationContext, meta *MetaSubcontext, isub *InstallSub
 
 	istate := isub.Data
 
-	if istate.DownloadSessionID == "" {
+	if params.LocalSource != "" {
+		consumer.Infof("→ Installing from local source (%s)", params.LocalSource)
+	} else if istate.DownloadSessionID == "" {
 		res, err := client.NewDownloadSession(rc.Ctx, itchio.NewDownloadSessionParams{
 			GameID:      params.Game.ID,
 			Credentials: params.Access.Credentials,
@@ -82,7 +87,7 @@ func InstallPrepare(oc *OperationContext, meta *MetaSubcontext, isub *InstallSub
 	consumer.Infof("→ To be installed:")
 	LogUpload(consumer, params.Upload, params.Build)
 
-	if receiptIn != nil && receiptIn.Upload != nil && receiptIn.Upload.ID == params.Upload.ID {
+	if params.LocalSource == "" && receiptIn != nil && receiptIn.Upload != nil && receiptIn.Upload.ID == params.Upload.ID {
 		consumer.Infof("Installing over same upload")
 		if receiptIn.Build != nil && params.Build != nil {
 			oldID := receiptIn.Build.ID
@@ -177,11 +182,32 @@ func InstallPrepare(oc *OperationContext, meta *MetaSubcontext, isub *InstallSub
 		}
 	}
 
-	installSourceFileType := ""
-	if params.IgnoreInstallers {
-		installSourceFileType = "archive"
+	if params.LocalSource == "" && receiptIn == nil && allowDownloads && len(lanPeers(oc, params)) > 0 {
+		// healing an empty folder is a fresh install, and it's
+		// how we fetch files from peers
+		consumer.Infof("Installing from LAN peers, by healing")
+		res.Strategy = InstallPerformStrategyHeal
+		return task(res)
+	}
+
+	var installSourceURL string
+	if params.LocalSource != "" {
+		stats, err := os.Stat(params.LocalSource)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if stats.IsDir() {
+			res.Strategy = InstallPerformStrategyLocalFolder
+			return task(res)
+		}
+		installSourceURL = params.LocalSource
+	} else {
+		installSourceFileType := ""
+		if params.IgnoreInstallers {
+			installSourceFileType = "archive"
+		}
+		installSourceURL = MakeSourceURL(client, consumer, istate.DownloadSessionID, params, installSourceFileType)
 	}
-	installSourceURL := MakeSourceURL(client, consumer, istate.DownloadSessionID, params, installSourceFileType)
 
 	file, err := eos.Open(installSourceURL, option.WithConsumer(consumer))
 	if err != nil {
@@ -190,7 +216,7 @@ func InstallPrepare(oc *OperationContext, meta *MetaSubcontext, isub *InstallSub
 	res.File = file
 	defer file.Close()
 
-	if params.Upload.Storage == itchio.UploadStorageExternal {
+	if params.LocalSource == "" && params.Upload.Storage == itchio.UploadStorageExternal {
 		consumer.Warnf("Dealing with an external upload (from %s), all bets are off.", params.Upload.Host)
 
 		if IsBadExternalHost(params.Upload.Host) {
@@ -15,6 +15,7 @@ type InstallSubcontextState struct {
 	UpgradePathIndex    int                      `json:"upgradePathIndex,omitempty"`
 	UsingHealFallback   bool                     `json:"usingHealFallback,omitempty"`
 	RefreshedGame       bool                     `json:"refreshedGame,omitempty"`
+	BackedUpSaves       bool                     `json:"backedUpSaves,omitempty"`
 }
 
 type InstallSubcontext struct {
@@ -0,0 +1,309 @@
+package operate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/butler/manager/runlock"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/pwr"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+// StatCaveFiles returns the current size and modification time of
+// files of an install folder. Missing files are skipped.
+func StatCaveFiles(caveID string, installFolder string, files []string) []*models.CaveFileStat {
+	var stats []*models.CaveFileStat
+	for _, path := range files {
+		fi, err := os.Lstat(filepath.Join(installFolder, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		stats = append(stats, &models.CaveFileStat{
+			CaveID:  caveID,
+			Path:    path,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime().UnixNano(),
+		})
+	}
+	return stats
+}
+
+// ScanCave checks the files listed in a cave's receipt against their last
+// known size and modification time, and hashes the ones that changed to
+// check them against the build's signature. Files found intact are
+// remembered, so they're not hashed again next time.
+//
+// Files with no known stats (all of them, for caves installed before
+// butler kept track) are hashed as well: what's on disk is only trusted
+// once it matched the signature. Without a signature, there's nothing to
+// check them against, so they're taken as they are.
+//
+// The result is saved and returned. It's nil if the cave has no list of
+// installed files. If the cave is being installed, launched, etc., the
+// scan waits for that to be done.
+func ScanCave(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave) (*butlerd.CaveIntegrity, error) {
+	return scanCave(ctx, rc, cave, true)
+}
+
+// ErrCaveBusy is returned by ScanIdleCave when something else is
+// using the cave.
+var ErrCaveBusy = errors.New("cave is busy")
+
+// ScanIdleCave is like ScanCave, except it returns ErrCaveBusy right away
+// instead of waiting if the cave is being installed, launched, etc.
+func ScanIdleCave(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave) (*butlerd.CaveIntegrity, error) {
+	return scanCave(ctx, rc, cave, false)
+}
+
+func scanCave(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave, wait bool) (*butlerd.CaveIntegrity, error) {
+	consumer := rc.Consumer
+
+	var installFolder string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+	})
+
+	// don't leave an empty folder behind by locking it
+	_, err := os.Stat(installFolder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			consumer.Debugf("Install folder of cave %s is gone, not scanning it", cave.ID)
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	rlock := runlock.New(consumer, installFolder)
+	if wait {
+		err = rlock.Lock(ctx, "scan")
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else {
+		locked, err := rlock.TryLock("scan")
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !locked {
+			return nil, ErrCaveBusy
+		}
+	}
+	defer rlock.Unlock()
+
+	var known []*models.CaveFileStat
+	rc.WithConn(func(conn *sqlite.Conn) {
+		known = models.CaveFileStats(conn, cave.ID)
+	})
+
+	receipt, err := bfs.ReadReceipt(installFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !receipt.HasFiles() {
+		consumer.Debugf("No list of installed files for cave %s, not scanning it", cave.ID)
+		return nil, nil
+	}
+
+	var fetchSig func() (*pwr.SignatureInfo, error)
+	if cave.Build != nil {
+		fetchSig = func() (*pwr.SignatureInfo, error) {
+			return fetchCaveSignature(ctx, rc, cave)
+		}
+	}
+	scan, err := scanCaveFiles(ctx, consumer, cave.ID, installFolder, receipt.Files, known, fetchSig)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	res := &butlerd.CaveIntegrity{
+		CaveID:          cave.ID,
+		CheckedAt:       &now,
+		Healthy:         len(scan.wounds) == 0,
+		CheckedContents: scan.checkedContents,
+		Wounds:          scan.wounds,
+	}
+
+	woundsJSON, err := json.Marshal(res.Wounds)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rc.WithConn(func(conn *sqlite.Conn) {
+		if len(scan.changed) > 0 {
+			models.MustSave(conn, scan.changed)
+		}
+		ci := &models.CaveIntegrity{
+			CaveID:          cave.ID,
+			CheckedAt:       res.CheckedAt,
+			Healthy:         res.Healthy,
+			CheckedContents: res.CheckedContents,
+			Wounds:          models.JSON(woundsJSON),
+		}
+		ci.Save(conn)
+	})
+
+	return res, nil
+}
+
+type caveScan struct {
+	wounds []*butlerd.CaveWound
+	// stats of intact files that changed, or weren't known
+	changed []*models.CaveFileStat
+	// whether files were checked against a signature
+	checkedContents bool
+}
+
+// scanCaveFiles does the work of ScanCave, given the files of a cave and
+// their known stats. fetchSig is nil if the cave has no build, and it's
+// only called if some files need checking.
+func scanCaveFiles(ctx context.Context, consumer *state.Consumer, caveID string, installFolder string, files []string, known []*models.CaveFileStat, fetchSig func() (*pwr.SignatureInfo, error)) (*caveScan, error) {
+	knownByPath := make(map[string]*models.CaveFileStat)
+	for _, fs := range known {
+		knownByPath[fs.Path] = fs
+	}
+
+	scan := &caveScan{}
+	var suspicious []*models.CaveFileStat
+	present := make(map[string]bool)
+	for _, fs := range StatCaveFiles(caveID, installFolder, files) {
+		present[fs.Path] = true
+		k := knownByPath[fs.Path]
+		if sameStat(k, fs) {
+			continue
+		}
+		suspicious = append(suspicious, fs)
+	}
+
+	for _, path := range files {
+		if present[path] {
+			continue
+		}
+		w := &butlerd.CaveWound{
+			Path: path,
+			Kind: butlerd.CaveWoundKindMissing,
+		}
+		if k := knownByPath[path]; k != nil {
+			w.Size = k.Size
+		}
+		scan.wounds = append(scan.wounds, w)
+	}
+
+	var sigInfo *pwr.SignatureInfo
+	if len(suspicious) > 0 && fetchSig != nil {
+		consumer.Infof("%d files of cave %s changed, checking them against signature", len(suspicious), caveID)
+		var err error
+		sigInfo, err = fetchSig()
+		if err != nil {
+			consumer.Warnf("Could not fetch signature, only checking sizes: %+v", err)
+		}
+	}
+
+	if sigInfo != nil {
+		scan.checkedContents = true
+		keys := store.SignatureKeys(sigInfo)
+		modes := make(map[string]os.FileMode)
+		for _, f := range sigInfo.Container.Files {
+			modes[f.Path] = os.FileMode(f.Mode)
+		}
+
+		for _, fs := range suspicious {
+			if err := ctx.Err(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+			key, ok := keys[fs.Path]
+			if !ok {
+				// not a file as far as the signature is concerned
+				scan.changed = append(scan.changed, fs)
+				continue
+			}
+
+			actualKey, err := store.FileKey(filepath.Join(installFolder, filepath.FromSlash(fs.Path)), fs.Size, modes[fs.Path])
+			if err != nil {
+				return nil, err
+			}
+			if actualKey == key {
+				scan.changed = append(scan.changed, fs)
+				continue
+			}
+			scan.wounds = append(scan.wounds, &butlerd.CaveWound{
+				Path: fs.Path,
+				Kind: butlerd.CaveWoundKindCorrupted,
+				Size: fs.Size,
+			})
+		}
+	} else {
+		for _, fs := range suspicious {
+			k := knownByPath[fs.Path]
+			if k != nil && k.Size != fs.Size {
+				scan.wounds = append(scan.wounds, &butlerd.CaveWound{
+					Path: fs.Path,
+					Kind: butlerd.CaveWoundKindCorrupted,
+					Size: fs.Size,
+				})
+			} else if fetchSig == nil {
+				// there's nothing else to check it against
+				scan.changed = append(scan.changed, fs)
+			}
+		}
+	}
+
+	return scan, nil
+}
+
+func sameStat(a *models.CaveFileStat, b *models.CaveFileStat) bool {
+	return a != nil && b != nil && a.Size == b.Size && a.ModTime == b.ModTime
+}
+
+// FormatCaveIntegrity returns the result of a scan, as saved by ScanCave
+func FormatCaveIntegrity(ci *models.CaveIntegrity) (*butlerd.CaveIntegrity, error) {
+	res := &butlerd.CaveIntegrity{
+		CaveID:          ci.CaveID,
+		CheckedAt:       ci.CheckedAt,
+		Healthy:         ci.Healthy,
+		CheckedContents: ci.CheckedContents,
+	}
+	if ci.Wounds != "" {
+		err := json.Unmarshal([]byte(ci.Wounds), &res.Wounds)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshalling wounds")
+		}
+	}
+	return res, nil
+}
+
+// recordCaveFileStats remembers the stats of freshly installed files,
+// and forgets previous scan results.
+func recordCaveFileStats(rc *butlerd.RequestContext, caveID string, installFolder string, files []string) {
+	stats := StatCaveFiles(caveID, installFolder, files)
+	rc.WithConn(func(conn *sqlite.Conn) {
+		models.SetCaveFileStats(conn, caveID, stats)
+		models.MustDelete(conn, &models.CaveIntegrity{}, builder.Eq{"cave_id": caveID})
+	})
+}
+
+func fetchCaveSignature(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave) (*pwr.SignatureInfo, error) {
+	var access *GameAccess
+	rc.WithConn(func(conn *sqlite.Conn) {
+		access = AccessForGameID(conn, cave.GameID)
+	})
+
+	client := rc.Client(access.APIKey)
+	signatureURL := MakeSourceURL(client, rc.Consumer, "", &InstallParams{
+		Upload: cave.Upload,
+		Build:  cave.Build,
+		Access: access,
+	}, "signature")
+	return fetchSignature(ctx, rc.Consumer, signatureURL)
+}
@@ -0,0 +1,186 @@
+package operate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/manager/runlock"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools/fspool"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func statPaths(stats []*models.CaveFileStat) []string {
+	var paths []string
+	for _, fs := range stats {
+		paths = append(paths, fs.Path)
+	}
+	return paths
+}
+
+func woundsByPath(wounds []*butlerd.CaveWound) map[string]butlerd.CaveWoundKind {
+	res := make(map[string]butlerd.CaveWoundKind)
+	for _, w := range wounds {
+		res[w.Path] = w.Kind
+	}
+	return res
+}
+
+func TestScanCaveFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan-cave")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	consumer := &state.Consumer{}
+	ctx := context.Background()
+	files := map[string]string{
+		"game.bin":        "the game",
+		"data/level1.dat": "the first level",
+		"data/level2.dat": "the second level",
+	}
+	paths := []string{"game.bin", "data/level1.dat", "data/level2.dat"}
+
+	build := filepath.Join(dir, "build")
+	writeFiles(t, build, files)
+	container, err := tlc.WalkDir(build, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+	pool := fspool.New(container, build)
+	hashes, err := pwr.ComputeSignature(ctx, container, pool, consumer)
+	pool.Close()
+	wtest.Must(t, err)
+	sigInfo := &pwr.SignatureInfo{Container: container, Hashes: hashes}
+
+	sigFetches := 0
+	fetchSig := func() (*pwr.SignatureInfo, error) {
+		sigFetches++
+		return sigInfo, nil
+	}
+
+	// installed before file stats were kept, and modded since
+	cave := filepath.Join(dir, "cave")
+	writeFiles(t, cave, files)
+	writeFiles(t, cave, map[string]string{"data/level1.dat": "the first level, modded"})
+
+	// nothing is known, so everything is checked
+	scan, err := scanCaveFiles(ctx, consumer, "cave", cave, paths, nil, fetchSig)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, sigFetches)
+	assert.True(t, scan.checkedContents)
+	assert.EqualValues(t, map[string]butlerd.CaveWoundKind{
+		"data/level1.dat": butlerd.CaveWoundKindCorrupted,
+	}, woundsByPath(scan.wounds))
+	assert.ElementsMatch(t, []string{"game.bin", "data/level2.dat"}, statPaths(scan.changed))
+	known := scan.changed
+
+	// files that didn't change aren't hashed or saved again
+	scan, err = scanCaveFiles(ctx, consumer, "cave", cave, []string{"game.bin", "data/level2.dat"}, known, fetchSig)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, sigFetches)
+	assert.Empty(t, scan.wounds)
+	assert.Empty(t, scan.changed)
+
+	// touched files are checked again, and their new stats saved
+	later := time.Now().Add(time.Hour)
+	wtest.Must(t, os.Chtimes(filepath.Join(cave, "game.bin"), later, later))
+	wtest.Must(t, os.Remove(filepath.Join(cave, "data", "level2.dat")))
+	scan, err = scanCaveFiles(ctx, consumer, "cave", cave, []string{"game.bin", "data/level2.dat"}, known, fetchSig)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 2, sigFetches)
+	assert.EqualValues(t, map[string]butlerd.CaveWoundKind{
+		"data/level2.dat": butlerd.CaveWoundKindMissing,
+	}, woundsByPath(scan.wounds))
+	if assert.Len(t, scan.changed, 1) {
+		assert.EqualValues(t, "game.bin", scan.changed[0].Path)
+		assert.EqualValues(t, later.UnixNano(), scan.changed[0].ModTime)
+	}
+
+	// without the signature, unknown files aren't taken as intact
+	noSig := func() (*pwr.SignatureInfo, error) {
+		return nil, errors.New("offline")
+	}
+	scan, err = scanCaveFiles(ctx, consumer, "cave", cave, paths, nil, noSig)
+	wtest.Must(t, err)
+	assert.False(t, scan.checkedContents)
+	assert.Empty(t, scan.changed)
+}
+
+func TestScanCaveFilesWithoutBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan-cave")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	consumer := &state.Consumer{}
+	ctx := context.Background()
+	writeFiles(t, dir, map[string]string{
+		"game.exe": "the game",
+		"data.pak": "the data",
+	})
+	paths := []string{"game.exe", "data.pak"}
+
+	// there's nothing to check them against, so they're taken as they are
+	scan, err := scanCaveFiles(ctx, consumer, "cave", dir, paths, nil, nil)
+	wtest.Must(t, err)
+	assert.False(t, scan.checkedContents)
+	assert.Empty(t, scan.wounds)
+	assert.Len(t, scan.changed, 2)
+
+	// but size changes are noticed
+	writeFiles(t, dir, map[string]string{"data.pak": "the data, truncat"})
+	scan, err = scanCaveFiles(ctx, consumer, "cave", dir, paths, scan.changed, nil)
+	wtest.Must(t, err)
+	assert.EqualValues(t, map[string]butlerd.CaveWoundKind{
+		"data.pak": butlerd.CaveWoundKindCorrupted,
+	}, woundsByPath(scan.wounds))
+}
+
+func TestScanAndVerifyLockCave(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", nil, map[string]string{
+		"game.exe": "the game",
+	})
+	installFolder := of.location.GetInstallFolder(cave.ID)
+
+	// the game is running, for example
+	launch := runlock.New(of.rc.Consumer, installFolder)
+	wtest.Must(t, launch.Lock(context.Background(), "launch"))
+
+	_, err := ScanIdleCave(of.rc.Ctx, of.rc, cave)
+	assert.EqualValues(t, ErrCaveBusy, errors.Cause(err))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = ScanCave(ctx, of.rc, cave)
+	assert.Error(t, err, "waits for the lock")
+	_, err = VerifyCave(ctx, of.rc, cave)
+	assert.Error(t, err, "waits for the lock")
+
+	wtest.Must(t, launch.Unlock())
+
+	integrity, err := ScanIdleCave(of.rc.Ctx, of.rc, cave)
+	wtest.Must(t, err)
+	if assert.NotNil(t, integrity) {
+		assert.True(t, integrity.Healthy)
+	}
+
+	res, err := VerifyCave(of.rc.Ctx, of.rc, cave)
+	wtest.Must(t, err)
+	assert.True(t, res.Healthy)
+
+	// and they let go of it
+	locked, err := launch.TryLock("launch")
+	wtest.Must(t, err)
+	assert.True(t, locked)
+	wtest.Must(t, launch.Unlock())
+}
@@ -0,0 +1,76 @@
+package operate
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/lanshare"
+	"github.com/itchio/headway/united"
+	"github.com/itchio/wharf/pwr"
+)
+
+// lanPeers returns peers on the local network that have the build being
+// installed, if the install location has LAN sharing enabled.
+func lanPeers(oc *OperationContext, params *InstallParams) []*lanshare.Peer {
+	if params.Build == nil || params.InstallLocationID == "" {
+		return nil
+	}
+
+	var enabled bool
+	oc.rc.WithConn(func(conn *sqlite.Conn) {
+		il := models.InstallLocationByID(conn, params.InstallLocationID)
+		enabled = il != nil && il.LANSharing
+	})
+	if !enabled {
+		return nil
+	}
+
+	if peers, ok := oc.lanPeersByBuild[params.Build.ID]; ok {
+		return peers
+	}
+
+	consumer := oc.Consumer()
+	peers, err := lanshare.FindPeers(oc.ctx, lanshareBuildRef(params))
+	if err != nil {
+		consumer.Warnf("Could not look for LAN peers: %+v", err)
+		return nil
+	}
+	if len(peers) > 0 {
+		consumer.Infof("Found %d LAN peers with build %d", len(peers), params.Build.ID)
+	}
+
+	if oc.lanPeersByBuild == nil {
+		oc.lanPeersByBuild = make(map[int64][]*lanshare.Peer)
+	}
+	oc.lanPeersByBuild[params.Build.ID] = peers
+	return peers
+}
+
+func lanshareBuildRef(params *InstallParams) lanshare.BuildRef {
+	return lanshare.BuildRef{
+		GameID:   params.Game.ID,
+		UploadID: params.Upload.ID,
+		BuildID:  params.Build.ID,
+	}
+}
+
+// seedFromPeers fetches files missing from the install folder from LAN
+// peers, if any. Healing fetches whatever they didn't have from the CDN,
+// so errors are only logged.
+func seedFromPeers(oc *OperationContext, params *InstallParams, sigInfo *pwr.SignatureInfo) {
+	peers := lanPeers(oc, params)
+	if len(peers) == 0 {
+		return
+	}
+
+	consumer := oc.Consumer()
+	consumer.Infof("Fetching files from LAN peers...")
+	oc.rc.StartProgress()
+	numFiles, numBytes, err := lanshare.Seed(oc.ctx, consumer, peers, lanshareBuildRef(params), params.InstallFolder, sigInfo)
+	oc.rc.EndProgress()
+	if err != nil {
+		consumer.Warnf("Could not fetch files from LAN peers: %+v", err)
+	}
+	if numFiles > 0 {
+		consumer.Infof("✓ Fetched %d files (%s) from LAN peers", numFiles, united.FormatBytes(numBytes))
+	}
+}
@@ -0,0 +1,204 @@
+package operate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/installer/bfs"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools/fspool"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/pkg/errors"
+)
+
+// HashLocalSource returns the hex-encoded SHA-256 of a local install
+// source, and its size. For a folder, it's a digest of the paths, sizes
+// and hashes of all its files, in order, so the same build extracted
+// anywhere hashes the same.
+func HashLocalSource(path string) (string, int64, error) {
+	stats, err := os.Stat(path)
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+
+	if !stats.IsDir() {
+		sum, err := hashFile(path)
+		if err != nil {
+			return "", 0, err
+		}
+		return hex.EncodeToString(sum), stats.Size(), nil
+	}
+
+	container, err := bfs.Walk(path)
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+
+	files := append([]*tlc.File(nil), container.Files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	h := sha256.New()
+	for _, f := range files {
+		sum, err := hashFile(filepath.Join(path, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return "", 0, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%x\n", f.Path, f.Size, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), container.Size, nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return h.Sum(nil), nil
+}
+
+// installFromFolder copies an already-extracted upload into the install
+// folder. It's recorded as an "archive" install, which is what
+// extracting the upload would have been.
+func installFromFolder(oc *OperationContext, meta *MetaSubcontext, receiptIn *bfs.Receipt) error {
+	params := meta.Data
+	consumer := oc.Consumer()
+
+	err := detachFromStore(oc, params.InstallFolder)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	consumer.Opf("Walking (%s)...", params.LocalSource)
+	container, err := bfs.Walk(params.LocalSource)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	consumer.Statf("Found %s", container.Stats())
+
+	err = container.Prepare(params.InstallFolder)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = messages.TaskStarted.Notify(oc.rc, butlerd.TaskStartedNotification{
+		Reason:    butlerd.TaskReasonInstall,
+		Type:      butlerd.TaskTypeInstall,
+		Game:      params.Game,
+		Upload:    params.Upload,
+		Build:     params.Build,
+		TotalSize: container.Size,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	consumer.Infof("Copying to (%s)", params.InstallFolder)
+	err = func() error {
+		inPool := fspool.New(container, params.LocalSource)
+		defer inPool.Close()
+
+		outPool := fspool.New(container, params.InstallFolder)
+		defer outPool.Close()
+
+		oc.rc.StartProgress()
+		defer oc.rc.EndProgress()
+		return pwr.CopyContainer(container, outPool, inPool, consumer)
+	}()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	res := resultForContainer(container)
+
+	linkFromStore(oc, params, res.Files)
+
+	consumer.Infof("Busting ghosts...")
+	err = bfs.BustGhosts(&bfs.BustGhostsParams{
+		Folder:   params.InstallFolder,
+		NewFiles: res.Files,
+		Receipt:  receiptIn,
+
+		Consumer: consumer,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	build, err := verifyLocalSource(oc, params)
+	if err != nil {
+		return err
+	}
+
+	return commitInstall(oc, &CommitInstallParams{
+		InstallFolder: params.InstallFolder,
+
+		InstallerName: "archive",
+		Game:          params.Game,
+		Upload:        params.Upload,
+		Build:         build,
+
+		InstallResult: res,
+	})
+}
+
+// verifyLocalSource checks what was installed from a local source against
+// the signature of the build it was matched to, and returns the build to
+// record. Files that don't match are an error: a cave must not claim a
+// build it doesn't have, or upgrades would patch the wrong files. If the
+// signature can't be fetched (when offline for example), the install is
+// recorded without a build, and the next update is a full install.
+func verifyLocalSource(oc *OperationContext, params *InstallParams) (*itchio.Build, error) {
+	if params.Build == nil {
+		return nil, nil
+	}
+	consumer := oc.Consumer()
+
+	client := oc.rc.Client(params.Access.APIKey)
+	signatureURL := MakeSourceURL(client, consumer, "", params, "signature")
+	sigInfo, err := fetchSignature(oc.ctx, consumer, signatureURL)
+	if err != nil {
+		consumer.Warnf("Could not fetch signature of build %d, not recording it: %v", params.Build.ID, err)
+		return nil, nil
+	}
+
+	consumer.Infof("Checking installed files against build %d...", params.Build.ID)
+	oc.rc.StartProgress()
+	err = checkAgainstSignature(oc.ctx, consumer, params.InstallFolder, sigInfo)
+	oc.rc.EndProgress()
+	if err != nil {
+		return nil, errors.Errorf("Local source is not build %d, specify the build it is: %v", params.Build.ID, err)
+	}
+
+	consumer.Infof("✓ Local source matches build %d", params.Build.ID)
+	return params.Build, nil
+}
+
+// checkAgainstSignature returns an error if any file of sigInfo is
+// missing from folder or has different contents
+func checkAgainstSignature(ctx context.Context, consumer *state.Consumer, folder string, sigInfo *pwr.SignatureInfo) error {
+	vc := &pwr.ValidatorContext{
+		Consumer:   consumer,
+		NumWorkers: 1,
+		FailFast:   true,
+	}
+	return vc.Validate(ctx, folder, sigInfo)
+}
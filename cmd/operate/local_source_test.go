@@ -0,0 +1,94 @@
+package operate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools/fspool"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, folder string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(folder, filepath.FromSlash(name))
+		wtest.Must(t, os.MkdirAll(filepath.Dir(path), 0755))
+		wtest.Must(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+func TestHashLocalSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local-source")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"game.bin":        "the game",
+		"data/level1.dat": "the first level",
+	}
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	writeFiles(t, a, files)
+	writeFiles(t, b, files)
+
+	hashA, sizeA, err := HashLocalSource(a)
+	wtest.Must(t, err)
+	hashB, _, err := HashLocalSource(b)
+	wtest.Must(t, err)
+	assert.EqualValues(t, hashA, hashB, "same files hash the same wherever they are")
+	assert.EqualValues(t, len("the game")+len("the first level"), sizeA)
+
+	writeFiles(t, b, map[string]string{"data/level1.dat": "the first level, modded"})
+	hashB, _, err = HashLocalSource(b)
+	wtest.Must(t, err)
+	assert.NotEqual(t, hashA, hashB)
+
+	hash, size, err := HashLocalSource(filepath.Join(a, "game.bin"))
+	wtest.Must(t, err)
+	sum := sha256.Sum256([]byte("the game"))
+	assert.EqualValues(t, hex.EncodeToString(sum[:]), hash)
+	assert.EqualValues(t, len("the game"), size)
+}
+
+func TestCheckAgainstSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local-source")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	consumer := &state.Consumer{}
+	files := map[string]string{
+		"game.bin":        "the game",
+		"data/level1.dat": "the first level",
+	}
+
+	build := filepath.Join(dir, "build")
+	writeFiles(t, build, files)
+	container, err := tlc.WalkDir(build, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+	pool := fspool.New(container, build)
+	hashes, err := pwr.ComputeSignature(context.Background(), container, pool, consumer)
+	pool.Close()
+	wtest.Must(t, err)
+	sigInfo := &pwr.SignatureInfo{Container: container, Hashes: hashes}
+
+	installed := filepath.Join(dir, "installed")
+	writeFiles(t, installed, files)
+	// files the game wrote don't matter
+	writeFiles(t, installed, map[string]string{"save.dat": "progress"})
+	assert.NoError(t, checkAgainstSignature(context.Background(), consumer, installed, sigInfo))
+
+	// another build of the game
+	writeFiles(t, installed, map[string]string{"data/level1.dat": "the first level, v2"})
+	assert.Error(t, checkAgainstSignature(context.Background(), consumer, installed, sigInfo))
+
+	wtest.Must(t, os.Remove(filepath.Join(installed, "data", "level1.dat")))
+	assert.Error(t, checkAgainstSignature(context.Background(), consumer, installed, sigInfo))
+}
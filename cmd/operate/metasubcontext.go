@@ -25,6 +25,13 @@ type InstallParams struct {
 
 	IgnoreInstallers bool `json:"ignoreInstallers,omitempty"`
 
+	// Path of a local file or folder to install from,
+	// instead of downloading the upload
+	LocalSource string `json:"localSource,omitempty"`
+
+	// Keep a copy of files overwritten by an upgrade, for rollbacks
+	KeepRollback bool `json:"keepRollback,omitempty"`
+
 	Access *GameAccess `json:"credentials"`
 }
 
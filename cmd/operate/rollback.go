@@ -0,0 +1,401 @@
+package operate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/wipe"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/manager"
+	"github.com/itchio/butler/manager/runlock"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/ox"
+	"github.com/itchio/wharf/pwr/bowl"
+	"github.com/pkg/errors"
+)
+
+// A rollbackManifest describes what's needed to go back to the
+// build that was installed before an upgrade.
+//
+// Only files the upgrade overwrote or deleted are kept, in the
+// `files` folder next to it. Files of the previous build that aren't
+// in there were left untouched.
+type rollbackManifest struct {
+	// Receipt of the install folder before the upgrade
+	Previous *bfs.Receipt `json:"previous"`
+	// Build the upgrade ended on, zero while it's not done
+	ToBuildID int64 `json:"toBuildId"`
+	// Files of the previous build that were kept
+	Files []string `json:"files"`
+}
+
+// RollbackFolder returns where an install folder's rollback data
+// is kept, once an upgrade is done.
+func RollbackFolder(installFolder string) string {
+	return filepath.Join(installFolder, ".itch", "rollback")
+}
+
+func rollbackManifestPath(folder string) string {
+	return filepath.Join(folder, "rollback.json")
+}
+
+func readRollbackManifest(folder string) (*rollbackManifest, error) {
+	contents, err := ioutil.ReadFile(rollbackManifestPath(folder))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	var rm rollbackManifest
+	err = json.Unmarshal(contents, &rm)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding rollback manifest")
+	}
+	return &rm, nil
+}
+
+func (rm *rollbackManifest) write(folder string) error {
+	contents, err := json.Marshal(rm)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = os.MkdirAll(folder, 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = ioutil.WriteFile(rollbackManifestPath(folder), contents, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// stagedRollbackFolder is where rollback data is gathered while
+// patches are being applied.
+func stagedRollbackFolder(oc *OperationContext) string {
+	return filepath.Join(oc.StageFolder(), "rollback")
+}
+
+// prepareRollback starts gathering rollback data for an upgrade, if it
+// was asked for. It returns false if there won't be any.
+func prepareRollback(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, receiptIn *bfs.Receipt) (bool, error) {
+	if !meta.Data.KeepRollback {
+		return false, nil
+	}
+	consumer := oc.Consumer()
+	folder := stagedRollbackFolder(oc)
+
+	rm, err := readRollbackManifest(folder)
+	if err != nil {
+		return false, err
+	}
+	if rm != nil {
+		// resuming
+		return true, nil
+	}
+
+	if isub.Data.UpgradePathIndex > 0 {
+		consumer.Warnf("Upgrade already started without rollback data, not keeping any")
+		return false, nil
+	}
+	if !receiptIn.HasFiles() || receiptIn.Build == nil {
+		consumer.Warnf("No list of installed files, can't keep rollback data")
+		return false, nil
+	}
+
+	rm = &rollbackManifest{
+		Previous: receiptIn,
+	}
+	err = rm.write(folder)
+	if err != nil {
+		return false, errors.WithMessage(err, "writing rollback manifest")
+	}
+	return true, nil
+}
+
+// keepForRollback copies the files of the install folder that a patch
+// is about to overwrite or delete, unless they were already kept
+// because of a previous patch, or weren't part of the previous build.
+//
+// It must be called after the patch is applied, but before its
+// bowl is committed. If it can't tell which files the patch touches,
+// rollback data is dropped, and the upgrade goes on without it.
+func keepForRollback(oc *OperationContext, installFolder string, targetContainer *tlc.Container, sourceContainer *tlc.Container, b bowl.Bowl) error {
+	consumer := oc.Consumer()
+	folder := stagedRollbackFolder(oc)
+
+	rm, err := readRollbackManifest(folder)
+	if err != nil {
+		return err
+	}
+	if rm == nil {
+		// dropped while applying an earlier patch
+		return nil
+	}
+
+	skip := make(map[string]bool)
+	for _, path := range rm.Files {
+		skip[path] = true
+	}
+	previous := make(map[string]bool)
+	for _, path := range rm.Previous.Files {
+		previous[path] = true
+	}
+
+	c, err := b.Save()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	bc, ok := c.Data.(*bowl.OverlayBowlCheckpoint)
+	if !ok {
+		consumer.Warnf("Can't tell which files a %T touches, not keeping rollback data", c.Data)
+		return errors.WithStack(os.RemoveAll(folder))
+	}
+	for _, t := range bc.Transpositions {
+		targetPath := targetContainer.Files[t.TargetIndex].Path
+		if targetPath == sourceContainer.Files[t.SourceIndex].Path {
+			// left in place
+			skip[targetPath] = true
+		}
+	}
+
+	symlinkDests := make(map[string]string)
+	for _, s := range sourceContainer.Symlinks {
+		symlinkDests[s.Path] = s.Dest
+	}
+	for _, s := range targetContainer.Symlinks {
+		if dest, ok := symlinkDests[s.Path]; ok && dest == s.Dest {
+			skip[s.Path] = true
+		}
+	}
+
+	var paths []string
+	for _, f := range targetContainer.Files {
+		paths = append(paths, f.Path)
+	}
+	for _, s := range targetContainer.Symlinks {
+		paths = append(paths, s.Path)
+	}
+
+	kept := 0
+	for _, path := range paths {
+		if skip[path] || !previous[path] {
+			continue
+		}
+
+		src := filepath.Join(installFolder, filepath.FromSlash(path))
+		dst := filepath.Join(folder, "files", filepath.FromSlash(path))
+		err := copyEntry(src, dst)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				// nothing to restore then
+				continue
+			}
+			return errors.WithMessage(err, "keeping file for rollback")
+		}
+		rm.Files = append(rm.Files, path)
+		kept++
+	}
+
+	consumer.Infof("Kept %d files for rollback", kept)
+	return rm.write(folder)
+}
+
+// commitRollback makes the rollback data gathered during an upgrade
+// available to RollbackCave, replacing any older one.
+func commitRollback(oc *OperationContext, installFolder string, buildID int64) error {
+	folder := stagedRollbackFolder(oc)
+
+	rm, err := readRollbackManifest(folder)
+	if err != nil {
+		return err
+	}
+	if rm == nil {
+		return nil
+	}
+
+	rm.ToBuildID = buildID
+	err = rm.write(folder)
+	if err != nil {
+		return err
+	}
+
+	dest := RollbackFolder(installFolder)
+	err = os.RemoveAll(dest)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = os.Rename(folder, dest)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	oc.Consumer().Infof("%d files kept to roll back to build %d", len(rm.Files), rm.Previous.Build.ID)
+	return nil
+}
+
+// RollbackCave restores the build a cave had before its last upgrade,
+// if the upgrade kept rollback data.
+func RollbackCave(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave) (*bfs.Receipt, error) {
+	consumer := rc.Consumer
+
+	var installFolder string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+	})
+
+	rlock := runlock.New(consumer, installFolder)
+	err := rlock.Lock(ctx, "rollback")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rlock.Unlock()
+
+	folder := RollbackFolder(installFolder)
+	rm, err := readRollbackManifest(folder)
+	if err != nil {
+		return nil, err
+	}
+	if rm == nil || rm.ToBuildID == 0 {
+		return nil, errors.Errorf("Cave %s has nothing to roll back to", cave.ID)
+	}
+
+	receipt, err := bfs.ReadReceipt(installFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if receipt == nil || receipt.Build == nil || receipt.Build.ID != rm.ToBuildID {
+		return nil, errors.Errorf("Rollback data for cave %s is for build %d, which is not installed anymore", cave.ID, rm.ToBuildID)
+	}
+
+	previous := rm.Previous
+	consumer.Infof("Rolling back from build %d to build %d", receipt.Build.ID, previous.Build.ID)
+
+	err = messages.TaskStarted.Notify(rc, butlerd.TaskStartedNotification{
+		Reason: butlerd.TaskReasonRollback,
+		Type:   butlerd.TaskTypeRollback,
+		Game:   cave.Game,
+		Upload: previous.Upload,
+		Build:  previous.Build,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("Removing files added since...")
+	err = bfs.BustGhosts(&bfs.BustGhostsParams{
+		Folder:   installFolder,
+		NewFiles: previous.Files,
+		Receipt:  receipt,
+
+		Consumer: consumer,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("Restoring %d files...", len(rm.Files))
+	for _, path := range rm.Files {
+		src := filepath.Join(folder, "files", filepath.FromSlash(path))
+		dst := filepath.Join(installFolder, filepath.FromSlash(path))
+		err := copyEntry(src, dst)
+		if err != nil {
+			return nil, errors.WithMessage(err, "restoring file")
+		}
+	}
+
+	err = previous.WriteReceipt(installFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	verdict, err := manager.Configure(consumer, installFolder, ox.CurrentRuntime())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cave.SetVerdict(verdict)
+	cave.InstalledSize = verdict.TotalSize
+	cave.Upload = previous.Upload
+	cave.Build = previous.Build
+	rc.WithConn(cave.SaveWithAssocs)
+	recordCaveFileStats(rc, cave.ID, installFolder, previous.Files)
+
+	err = wipe.Do(consumer, folder)
+	if err != nil {
+		consumer.Warnf("Could not remove rollback data: %+v", err)
+	}
+
+	err = messages.TaskSucceeded.Notify(rc, butlerd.TaskSucceededNotification{
+		Type: butlerd.TaskTypeRollback,
+		InstallResult: &butlerd.InstallResult{
+			Game:   cave.Game,
+			Upload: previous.Upload,
+			Build:  previous.Build,
+		},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("Rolled back to build %d", previous.Build.ID)
+	return previous, nil
+}
+
+// copyEntry copies a regular file or a symlink, replacing whatever is at
+// dst. Whatever was there is removed first, rather than written to, in
+// case it's linked from the store.
+func copyEntry(src string, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = os.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		dest, err := os.Readlink(src)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(os.Symlink(dest, dst))
+	}
+
+	r, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(w.Close())
+}
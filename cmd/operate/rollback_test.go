@@ -0,0 +1,209 @@
+package operate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr/bowl"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkpointBowl is a bowl a patch was applied to,
+// as far as keepForRollback is concerned
+type checkpointBowl struct {
+	bowl.Bowl
+	checkpoint *bowl.BowlCheckpoint
+}
+
+func (cb *checkpointBowl) Save() (*bowl.BowlCheckpoint, error) {
+	return cb.checkpoint, nil
+}
+
+var rollbackBuild1 = map[string]string{
+	"game.bin":        "the game",
+	"data/level1.dat": "the first level",
+	"data/old.dat":    "removed in build 2",
+}
+
+var rollbackBuild2 = map[string]string{
+	"game.bin":        "the game",
+	"data/level1.dat": "the first level, fixed",
+	"data/new.dat":    "added in build 2",
+}
+
+// upgradeContainers returns the containers of a patch from
+// build 1 to build 2, and the transpositions it'd do
+func upgradeContainers(t *testing.T, dir string) (*tlc.Container, *tlc.Container, []bowl.Transposition) {
+	build1 := filepath.Join(dir, "build1")
+	writeFiles(t, build1, rollbackBuild1)
+	target, err := tlc.WalkDir(build1, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+
+	build2 := filepath.Join(dir, "build2")
+	writeFiles(t, build2, rollbackBuild2)
+	source, err := tlc.WalkDir(build2, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+
+	// game.bin didn't change, so it's left in place
+	var transpositions []bowl.Transposition
+	for ti, tf := range target.Files {
+		for si, sf := range source.Files {
+			if tf.Path == "game.bin" && sf.Path == "game.bin" {
+				transpositions = append(transpositions, bowl.Transposition{
+					TargetIndex: int64(ti),
+					SourceIndex: int64(si),
+				})
+			}
+		}
+	}
+	return target, source, transpositions
+}
+
+// applyUpgrade does what patching and committing the bowl would
+func applyUpgrade(t *testing.T, installFolder string) {
+	wtest.Must(t, os.Remove(filepath.Join(installFolder, "data", "old.dat")))
+	writeFiles(t, installFolder, rollbackBuild2)
+
+	receipt, err := bfs.ReadReceipt(installFolder)
+	wtest.Must(t, err)
+	receipt.Build = &itchio.Build{ID: 2}
+	receipt.Files = []string{"data/level1.dat", "data/new.dat", "game.bin"}
+	wtest.Must(t, receipt.WriteReceipt(installFolder))
+}
+
+func TestRollback(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", &itchio.Build{ID: 1}, rollbackBuild1)
+	oc, meta, isub := of.operationContext(cave)
+	installFolder := meta.Data.InstallFolder
+	meta.Data.KeepRollback = true
+
+	receiptIn, err := bfs.ReadReceipt(installFolder)
+	wtest.Must(t, err)
+	keep, err := prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.True(t, keep)
+
+	target, source, transpositions := upgradeContainers(t, of.dir)
+	b := &checkpointBowl{checkpoint: &bowl.BowlCheckpoint{
+		Data: &bowl.OverlayBowlCheckpoint{Transpositions: transpositions},
+	}}
+	wtest.Must(t, keepForRollback(oc, installFolder, target, source, b))
+
+	// only what the patch overwrites or deletes is kept
+	rm, err := readRollbackManifest(stagedRollbackFolder(oc))
+	wtest.Must(t, err)
+	assert.ElementsMatch(t, []string{"data/level1.dat", "data/old.dat"}, rm.Files)
+
+	applyUpgrade(t, installFolder)
+	wtest.Must(t, commitRollback(oc, installFolder, 2))
+	_, err = os.Stat(stagedRollbackFolder(oc))
+	assert.True(t, os.IsNotExist(err))
+	rm, err = readRollbackManifest(RollbackFolder(installFolder))
+	wtest.Must(t, err)
+	assert.EqualValues(t, 2, rm.ToBuildID)
+
+	receipt, err := RollbackCave(of.rc.Ctx, of.rc, ValidateCave(of.rc, cave.ID))
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, receipt.Build.ID)
+	assert.EqualValues(t, rollbackBuild1, readFiles(t, installFolder))
+
+	receipt, err = bfs.ReadReceipt(installFolder)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, receipt.Build.ID)
+	of.rc.WithConn(func(conn *sqlite.Conn) {
+		assert.EqualValues(t, 1, models.CaveByID(conn, cave.ID).BuildID)
+	})
+	assert.EqualValues(t, []string{
+		messages.TaskStarted.Method(),
+		messages.TaskSucceeded.Method(),
+	}, of.notified())
+
+	// rollback data is used up
+	_, err = os.Stat(RollbackFolder(installFolder))
+	assert.True(t, os.IsNotExist(err))
+	_, err = RollbackCave(of.rc.Ctx, of.rc, ValidateCave(of.rc, cave.ID))
+	assert.Error(t, err)
+}
+
+func TestRollbackUnknownBowl(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", &itchio.Build{ID: 1}, rollbackBuild1)
+	oc, meta, isub := of.operationContext(cave)
+	installFolder := meta.Data.InstallFolder
+	meta.Data.KeepRollback = true
+
+	receiptIn, err := bfs.ReadReceipt(installFolder)
+	wtest.Must(t, err)
+	keep, err := prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.True(t, keep)
+
+	// can't tell what it touched, so rollback data is dropped
+	target, source, _ := upgradeContainers(t, of.dir)
+	b := &checkpointBowl{checkpoint: &bowl.BowlCheckpoint{}}
+	wtest.Must(t, keepForRollback(oc, installFolder, target, source, b))
+	_, err = os.Stat(stagedRollbackFolder(oc))
+	assert.True(t, os.IsNotExist(err))
+
+	// and the upgrade goes on without it
+	wtest.Must(t, keepForRollback(oc, installFolder, target, source, b))
+	applyUpgrade(t, installFolder)
+	wtest.Must(t, commitRollback(oc, installFolder, 2))
+
+	_, err = RollbackCave(of.rc.Ctx, of.rc, ValidateCave(of.rc, cave.ID))
+	assert.Error(t, err)
+	assert.EqualValues(t, rollbackBuild2, readFiles(t, installFolder))
+}
+
+func TestPrepareRollback(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", nil, rollbackBuild1)
+	oc, meta, isub := of.operationContext(cave)
+
+	receiptIn, err := bfs.ReadReceipt(meta.Data.InstallFolder)
+	wtest.Must(t, err)
+
+	// not asked for
+	keep, err := prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.False(t, keep)
+
+	// no build to go back to
+	meta.Data.KeepRollback = true
+	keep, err = prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.False(t, keep)
+
+	// some patches were applied without keeping anything
+	receiptIn.Build = &itchio.Build{ID: 1}
+	isub.Data.UpgradePathIndex = 1
+	keep, err = prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.False(t, keep)
+
+	isub.Data.UpgradePathIndex = 0
+	keep, err = prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.True(t, keep)
+
+	// resuming
+	isub.Data.UpgradePathIndex = 1
+	keep, err = prepareRollback(oc, meta, isub, receiptIn)
+	wtest.Must(t, err)
+	assert.True(t, keep)
+}
@@ -0,0 +1,163 @@
+package operate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/itchio/ox"
+)
+
+// A saveRoot is a folder games commonly keep save data in,
+// in a subfolder named after them.
+type saveRoot struct {
+	Path string
+	// Also look one level deeper, for engines that use
+	// a company folder, like Unity.
+	Nested bool
+}
+
+// saveRoots returns well-known save locations for a platform
+func saveRoots(platform ox.Platform, home string) []saveRoot {
+	envOr := func(name string, fallback string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return fallback
+	}
+
+	switch platform {
+	case ox.PlatformWindows:
+		appData := envOr("APPDATA", filepath.Join(home, "AppData", "Roaming"))
+		localAppData := envOr("LOCALAPPDATA", filepath.Join(home, "AppData", "Local"))
+		return []saveRoot{
+			{Path: appData},
+			{Path: filepath.Join(appData, "LOVE")},
+			{Path: filepath.Join(appData, "Godot", "app_userdata")},
+			{Path: localAppData},
+			{Path: filepath.Join(home, "AppData", "LocalLow"), Nested: true},
+			{Path: filepath.Join(home, "Documents", "My Games")},
+			{Path: filepath.Join(home, "Documents")},
+			{Path: filepath.Join(home, "Saved Games")},
+		}
+	case ox.PlatformOSX:
+		appSupport := filepath.Join(home, "Library", "Application Support")
+		return []saveRoot{
+			{Path: appSupport, Nested: true},
+			{Path: filepath.Join(appSupport, "LOVE")},
+			{Path: filepath.Join(appSupport, "Godot", "app_userdata")},
+			{Path: filepath.Join(home, "Documents")},
+		}
+	case ox.PlatformLinux:
+		dataHome := envOr("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+		configHome := envOr("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+		return []saveRoot{
+			{Path: dataHome},
+			{Path: filepath.Join(dataHome, "love")},
+			{Path: filepath.Join(dataHome, "godot", "app_userdata")},
+			{Path: configHome},
+			{Path: filepath.Join(configHome, "unity3d"), Nested: true},
+		}
+	}
+	return nil
+}
+
+// findKnownSaveFolders returns folders of well-known save locations
+// that are named after a game.
+func findKnownSaveFolders(platform ox.Platform, home string, gameTitle string) []string {
+	name := normalizeSaveName(gameTitle)
+	if len(name) < 4 {
+		// too likely to match something else
+		return nil
+	}
+
+	var res []string
+	for _, root := range saveRoots(platform, home) {
+		for _, child := range listDirs(root.Path) {
+			if normalizeSaveName(filepath.Base(child)) == name {
+				res = append(res, child)
+				continue
+			}
+			if root.Nested {
+				for _, grandchild := range listDirs(child) {
+					if normalizeSaveName(filepath.Base(grandchild)) == name {
+						res = append(res, grandchild)
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+func listDirs(folder string) []string {
+	f, err := os.Open(folder)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	entries, err := f.Readdir(-1)
+	if err != nil {
+		return nil
+	}
+
+	var res []string
+	for _, fi := range entries {
+		if fi.IsDir() {
+			res = append(res, filepath.Join(folder, fi.Name()))
+		}
+	}
+	return res
+}
+
+// normalizeSaveName lowercases s and strips anything that's not a
+// letter or digit, so "Most Erratic: The Game" matches "MostErraticTheGame"
+func normalizeSaveName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+var saveDirNames = map[string]bool{
+	"save":      true,
+	"saves":     true,
+	"saved":     true,
+	"savegame":  true,
+	"savegames": true,
+	"savedata":  true,
+	"savefiles": true,
+	"profile":   true,
+	"profiles":  true,
+	"userdata":  true,
+	"slots":     true,
+}
+
+var saveExts = map[string]bool{
+	".sav":      true,
+	".save":     true,
+	".savegame": true,
+	".sl2":      true,
+	".slot":     true,
+}
+
+// looksLikeSave returns true if a file created by a game (an angel)
+// is probably save data rather than logs, caches, or mods.
+func looksLikeSave(path string) bool {
+	path = strings.ToLower(path)
+	if saveExts[filepath.Ext(path)] {
+		return true
+	}
+
+	parts := strings.Split(path, "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if saveDirNames[dir] {
+			return true
+		}
+	}
+	return strings.HasPrefix(parts[len(parts)-1], "save")
+}
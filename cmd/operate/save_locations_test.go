@@ -0,0 +1,82 @@
+package operate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooksLikeSave(t *testing.T) {
+	for _, path := range []string{
+		"slot1.sav",
+		"data/Progress.SAVE",
+		"saves/slot1.dat",
+		"UserData/profile.json",
+		"savegame.dat",
+	} {
+		assert.True(t, looksLikeSave(path), path)
+	}
+	for _, path := range []string{
+		"log.txt",
+		"cache/shaders.bin",
+		"mods/better-trees/tree.png",
+		"data/slaves.dat",
+	} {
+		assert.False(t, looksLikeSave(path), path)
+	}
+}
+
+func TestNormalizeSaveName(t *testing.T) {
+	assert.EqualValues(t, "mosterraticthegame", normalizeSaveName("Most Erratic: The Game"))
+	assert.EqualValues(t, "mosterraticthegame", normalizeSaveName("MostErraticTheGame"))
+	assert.EqualValues(t, "überspiel2", normalizeSaveName("Über-Spiel 2"))
+	assert.EqualValues(t, "", normalizeSaveName("!!!"))
+}
+
+func TestFindKnownSaveFolders(t *testing.T) {
+	home, err := ioutil.TempDir("", "save-locations")
+	wtest.Must(t, err)
+	defer os.RemoveAll(home)
+
+	for _, name := range []string{"XDG_DATA_HOME", "XDG_CONFIG_HOME"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, "")
+	}
+
+	mkdirs := func(paths ...string) {
+		for _, p := range paths {
+			wtest.Must(t, os.MkdirAll(filepath.Join(home, filepath.FromSlash(p)), 0755))
+		}
+	}
+	mkdirs(
+		".local/share/Most Erratic",
+		".local/share/godot/app_userdata/MostErratic",
+		".config/unity3d/Erratic Games/most-erratic",
+		".config/Other Game",
+		// not nested, so not looked at
+		".local/share/Erratic Games/MostErratic",
+		// in a macOS location
+		"Documents/MostErratic",
+	)
+
+	linux := findKnownSaveFolders(ox.PlatformLinux, home, "Most Erratic")
+	assert.ElementsMatch(t, []string{
+		filepath.Join(home, ".local", "share", "Most Erratic"),
+		filepath.Join(home, ".local", "share", "godot", "app_userdata", "MostErratic"),
+		filepath.Join(home, ".config", "unity3d", "Erratic Games", "most-erratic"),
+	}, linux)
+
+	osx := findKnownSaveFolders(ox.PlatformOSX, home, "Most Erratic")
+	assert.ElementsMatch(t, []string{
+		filepath.Join(home, "Documents", "MostErratic"),
+	}, osx)
+
+	// too short to be told apart from other folders
+	mkdirs(".local/share/Go")
+	assert.Empty(t, findKnownSaveFolders(ox.PlatformLinux, home, "GO!"))
+}
@@ -0,0 +1,544 @@
+package operate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/arkive/zip"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/mkzip"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/endpoints/launch/manifest"
+	"github.com/itchio/butler/installer/bfs"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/hades"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/united"
+	"github.com/itchio/lake"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/ox"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+// files found by heuristics that are bigger than this are
+// more likely to be caches than saves
+const maxGuessedSaveSize = 64 * 1024 * 1024
+
+// files matched by the manifest that are bigger than this are more
+// likely matched by a pattern that's too broad than saves
+const maxManifestSaveSize = 512 * 1024 * 1024
+
+// how many snapshots not asked for by the user are kept, per game
+const maxAutomaticSaveSnapshots = 10
+
+const saveSnapshotTimeFormat = "20060102-150405.000"
+
+// Entries of snapshot archives are prefixed by which folder they're
+// relative to, so they can be restored to a moved install folder, or
+// to another machine.
+const (
+	saveArchiveInstallPrefix     = "install/"
+	saveArchiveHomePrefix        = "home/"
+	saveArchiveSandboxHomePrefix = "sandbox-home/"
+)
+
+// folders that never have save files in them
+var notSaveDirNames = map[string]bool{
+	// receipts, rollback data, etc.
+	".itch": true,
+	// caches of games launched with a private home
+	".cache": true,
+}
+
+// FindSaves returns the save files of a game installed in installFolder.
+// sandboxHome is the cave's private home, it's only looked at if the
+// cave has been launched with one. See butlerd.CavesSavesListParams.
+func FindSaves(consumer *state.Consumer, game *itchio.Game, installFolder string, sandboxHome string) ([]*butlerd.SaveFile, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := os.Stat(sandboxHome); sandboxHome == "" || err != nil {
+		sandboxHome = ""
+	}
+
+	var res []*butlerd.SaveFile
+	seen := make(map[string]bool)
+	add := func(path string, source butlerd.SaveSource, maxSize int64) {
+		filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if fi.IsDir() && notSaveDirNames[fi.Name()] {
+				return filepath.SkipDir
+			}
+			if !fi.Mode().IsRegular() || seen[p] {
+				return nil
+			}
+			if maxSize > 0 && fi.Size() > maxSize {
+				consumer.Debugf("Not saving (%s), it's %s", p, united.FormatBytes(fi.Size()))
+				return nil
+			}
+			seen[p] = true
+			res = append(res, &butlerd.SaveFile{
+				Path:   p,
+				Source: source,
+				Size:   fi.Size(),
+			})
+			return nil
+		})
+	}
+
+	m, err := manifest.Read(installFolder)
+	if err != nil {
+		consumer.Warnf("Could not read manifest: %+v", err)
+	}
+	if m != nil {
+		for _, pattern := range m.Saves {
+			err := manifest.CheckSavePattern(pattern)
+			if err != nil {
+				consumer.Warnf("Ignoring save pattern (%s): %v", pattern, err)
+				continue
+			}
+
+			var fullPatterns []string
+			if strings.HasPrefix(pattern, "~/") {
+				rel := filepath.FromSlash(pattern[2:])
+				if sandboxHome != "" {
+					fullPatterns = append(fullPatterns, filepath.Join(sandboxHome, rel))
+				}
+				// it may have been launched without a private home too
+				fullPatterns = append(fullPatterns, filepath.Join(home, rel))
+			} else {
+				fullPatterns = append(fullPatterns, filepath.Join(installFolder, filepath.FromSlash(pattern)))
+			}
+
+			for _, fullPattern := range fullPatterns {
+				matches, err := filepath.Glob(fullPattern)
+				if err != nil {
+					consumer.Warnf("Invalid save pattern (%s): %v", pattern, err)
+					continue
+				}
+				for _, match := range matches {
+					add(match, butlerd.SaveSourceManifest, maxManifestSaveSize)
+				}
+			}
+		}
+	}
+
+	receipt, err := bfs.ReadReceipt(installFolder)
+	if err != nil {
+		consumer.Warnf("Could not read receipt: %+v", err)
+	}
+	if receipt.HasFiles() {
+		container, err := bfs.Walk(installFolder)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		angels := bfs.Difference(receipt.Files, bfs.ContainerPaths(container))
+		for _, angel := range angels {
+			if looksLikeSave(angel) {
+				add(filepath.Join(installFolder, filepath.FromSlash(angel)), butlerd.SaveSourceAngel, maxGuessedSaveSize)
+			}
+		}
+	}
+
+	if sandboxHome != "" {
+		// only the game writes there
+		add(sandboxHome, butlerd.SaveSourceSandboxHome, maxGuessedSaveSize)
+	}
+
+	if game != nil {
+		for _, folder := range findKnownSaveFolders(ox.CurrentRuntime().Platform, home, game.Title) {
+			if isInside(installFolder, folder) || isInside(folder, installFolder) {
+				continue
+			}
+			add(folder, butlerd.SaveSourceKnownLocation, maxGuessedSaveSize)
+		}
+	}
+
+	return res, nil
+}
+
+// BackupSaves takes a snapshot of the save files of a cave, and returns
+// it, or nil if it has no save files.
+func BackupSaves(rc *butlerd.RequestContext, cave *models.Cave, game *itchio.Game, reason butlerd.SaveSnapshotReason) (*butlerd.SaveSnapshot, error) {
+	return backupSaves(rc, cave, game, reason, "")
+}
+
+func backupSaves(rc *butlerd.RequestContext, cave *models.Cave, game *itchio.Game, reason butlerd.SaveSnapshotReason, keepID string) (*butlerd.SaveSnapshot, error) {
+	consumer := rc.Consumer
+
+	var installFolder string
+	var sandboxHome string
+	var savesFolder string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+		il := cave.GetInstallLocation(conn)
+		sandboxHome = il.GetSandboxHomeFolder(cave.ID)
+		savesFolder = il.GetSavesFolder(cave.GameID)
+	})
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	files, err := FindSaves(consumer, game, installFolder, sandboxHome)
+	if err != nil {
+		return nil, err
+	}
+
+	container := &tlc.Container{}
+	var paths []string
+	for _, f := range files {
+		var name string
+		if rel, ok := relativeSlashPath(installFolder, f.Path); ok {
+			name = saveArchiveInstallPrefix + rel
+		} else if rel, ok := relativeSlashPath(sandboxHome, f.Path); ok {
+			// checked before the home folder, it may be in there
+			name = saveArchiveSandboxHomePrefix + rel
+		} else if rel, ok := relativeSlashPath(home, f.Path); ok {
+			name = saveArchiveHomePrefix + rel
+		} else {
+			consumer.Warnf("Not saving (%s), it's outside of the install and home folders", f.Path)
+			continue
+		}
+
+		container.Files = append(container.Files, &tlc.File{
+			Path: name,
+			Mode: 0644,
+			Size: f.Size,
+		})
+		container.Size += f.Size
+		paths = append(paths, f.Path)
+	}
+
+	if len(paths) == 0 {
+		consumer.Infof("No save files found for cave %s", cave.ID)
+		return nil, nil
+	}
+
+	err = os.MkdirAll(savesFolder, 0755)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now().UTC()
+	id := fmt.Sprintf("%s-%s", now.Format(saveSnapshotTimeFormat), reason)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(saveSnapshotPath(savesFolder, id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%s-%d", now.Format(saveSnapshotTimeFormat), reason, i)
+	}
+	snapshotPath := saveSnapshotPath(savesFolder, id)
+
+	consumer.Infof("Backing up %d save files (%s) to (%s)", len(paths), united.FormatBytes(container.Size), snapshotPath)
+	err = func() error {
+		tmpPath := snapshotPath + ".tmp"
+		defer os.Remove(tmpPath)
+
+		w, err := os.Create(tmpPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer w.Close()
+
+		src := &savesPool{paths: paths}
+		defer src.Close()
+
+		err = mkzip.Compress(consumer, container, src, w)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		err = w.Close()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(os.Rename(tmpPath, snapshotPath))
+	}()
+	if err != nil {
+		return nil, errors.WithMessage(err, "writing save snapshot")
+	}
+
+	pruneSaveSnapshots(rc, cave.GameID, keepID)
+
+	stats, err := os.Stat(snapshotPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return formatSaveSnapshot(snapshotPath, stats), nil
+}
+
+// RestoreSaves extracts a snapshot of a game's saves, the most recent one
+// if snapshotID is empty. Current save files are backed up first.
+func RestoreSaves(rc *butlerd.RequestContext, cave *models.Cave, game *itchio.Game, snapshotID string) (*butlerd.SaveSnapshot, error) {
+	consumer := rc.Consumer
+
+	snapshots := ListSaveSnapshots(rc, cave.GameID)
+	var snapshot *butlerd.SaveSnapshot
+	for _, s := range snapshots {
+		if snapshotID == "" || s.ID == snapshotID {
+			snapshot = s
+			break
+		}
+	}
+	if snapshot == nil {
+		if snapshotID == "" {
+			return nil, errors.Errorf("No save snapshots for game %d", cave.GameID)
+		}
+		return nil, errors.Errorf("Save snapshot %s not found for game %d", snapshotID, cave.GameID)
+	}
+
+	_, err := backupSaves(rc, cave, game, butlerd.SaveSnapshotReasonRestore, snapshot.ID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "backing up current saves")
+	}
+
+	var installFolder string
+	var sandboxHome string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+		sandboxHome = cave.GetInstallLocation(conn).GetSandboxHomeFolder(cave.ID)
+	})
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	zr, err := zip.OpenReader(snapshot.Path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer zr.Close()
+
+	consumer.Infof("Restoring saves from (%s)", snapshot.Path)
+	restored := 0
+	for _, zf := range zr.File {
+		if strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+
+		var base, rel string
+		switch {
+		case strings.HasPrefix(zf.Name, saveArchiveInstallPrefix):
+			base, rel = installFolder, strings.TrimPrefix(zf.Name, saveArchiveInstallPrefix)
+		case strings.HasPrefix(zf.Name, saveArchiveSandboxHomePrefix):
+			base, rel = sandboxHome, strings.TrimPrefix(zf.Name, saveArchiveSandboxHomePrefix)
+		case strings.HasPrefix(zf.Name, saveArchiveHomePrefix):
+			base, rel = home, strings.TrimPrefix(zf.Name, saveArchiveHomePrefix)
+		default:
+			consumer.Warnf("Skipping unknown entry (%s)", zf.Name)
+			continue
+		}
+
+		dest := filepath.Join(base, filepath.FromSlash(rel))
+		if !isInside(base, dest) {
+			consumer.Warnf("Skipping entry (%s), it points outside of (%s)", zf.Name, base)
+			continue
+		}
+
+		err := extractSaveFile(zf, dest)
+		if err != nil {
+			return nil, errors.WithMessage(err, "restoring save file")
+		}
+		restored++
+	}
+
+	consumer.Infof("Restored %d save files", restored)
+	return snapshot, nil
+}
+
+// ListSaveSnapshots returns snapshots of a game's saves from all
+// install locations, most recent first.
+func ListSaveSnapshots(rc *butlerd.RequestContext, gameID int64) []*butlerd.SaveSnapshot {
+	var locations []*models.InstallLocation
+	rc.WithConn(func(conn *sqlite.Conn) {
+		models.MustSelect(conn, &locations, builder.NewCond(), hades.Search{})
+	})
+
+	var res []*butlerd.SaveSnapshot
+	for _, il := range locations {
+		folder := il.GetSavesFolder(gameID)
+		f, err := os.Open(folder)
+		if err != nil {
+			continue
+		}
+		entries, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, fi := range entries {
+			if fi.IsDir() || filepath.Ext(fi.Name()) != ".zip" {
+				continue
+			}
+			if s := formatSaveSnapshot(filepath.Join(folder, fi.Name()), fi); s != nil {
+				res = append(res, s)
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(*res[j].CreatedAt) {
+			return res[i].CreatedAt.After(*res[j].CreatedAt)
+		}
+		return res[i].ID > res[j].ID
+	})
+	return res
+}
+
+// pruneSaveSnapshots removes the oldest automatic snapshots of a game,
+// except for keepID.
+func pruneSaveSnapshots(rc *butlerd.RequestContext, gameID int64, keepID string) {
+	automatic := 0
+	for _, s := range ListSaveSnapshots(rc, gameID) {
+		if s.Reason == butlerd.SaveSnapshotReasonManual || s.ID == keepID {
+			continue
+		}
+		automatic++
+		if automatic <= maxAutomaticSaveSnapshots {
+			continue
+		}
+
+		rc.Consumer.Infof("Removing old save snapshot (%s)", s.Path)
+		err := os.Remove(s.Path)
+		if err != nil {
+			rc.Consumer.Warnf("Could not remove old save snapshot: %v", err)
+		}
+	}
+}
+
+func saveSnapshotPath(savesFolder string, id string) string {
+	return filepath.Join(savesFolder, id+".zip")
+}
+
+// formatSaveSnapshot returns nil if path isn't named like a snapshot
+func formatSaveSnapshot(path string, stats os.FileInfo) *butlerd.SaveSnapshot {
+	id := strings.TrimSuffix(filepath.Base(path), ".zip")
+	if len(id) < len(saveSnapshotTimeFormat)+2 {
+		return nil
+	}
+
+	createdAt, err := time.Parse(saveSnapshotTimeFormat, id[:len(saveSnapshotTimeFormat)])
+	if err != nil {
+		return nil
+	}
+	reason := strings.SplitN(id[len(saveSnapshotTimeFormat)+1:], "-", 2)[0]
+
+	return &butlerd.SaveSnapshot{
+		ID:        id,
+		Reason:    butlerd.SaveSnapshotReason(reason),
+		CreatedAt: &createdAt,
+		Path:      path,
+		Size:      stats.Size(),
+	}
+}
+
+func extractSaveFile(zf *zip.File, dest string) error {
+	r, err := zf.Open()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// it may be linked from the store, which mustn't be written through
+	err = os.Remove(dest)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	w, err := os.Create(dest)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(w.Close())
+}
+
+// relativeSlashPath returns path relative to base, slash-separated,
+// if it's inside of it
+func relativeSlashPath(base string, path string) (string, bool) {
+	if !isInside(base, path) {
+		return "", false
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// isInside returns true if path is base, or inside of it
+func isInside(base string, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// savesPool reads files from absolute paths, for mkzip.Compress
+type savesPool struct {
+	paths  []string
+	reader *os.File
+}
+
+var _ lake.Pool = (*savesPool)(nil)
+
+func (sp *savesPool) GetSize(fileIndex int64) int64 {
+	stats, err := os.Stat(sp.paths[fileIndex])
+	if err != nil {
+		return 0
+	}
+	return stats.Size()
+}
+
+func (sp *savesPool) GetReader(fileIndex int64) (io.Reader, error) {
+	return sp.GetReadSeeker(fileIndex)
+}
+
+func (sp *savesPool) GetReadSeeker(fileIndex int64) (io.ReadSeeker, error) {
+	err := sp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(sp.paths[fileIndex])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sp.reader = f
+	return f, nil
+}
+
+func (sp *savesPool) Close() error {
+	if sp.reader == nil {
+		return nil
+	}
+	err := sp.reader.Close()
+	sp.reader = nil
+	return errors.WithStack(err)
+}
@@ -0,0 +1,214 @@
+package operate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchio/butler/butlerd"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// withHome points the user's home folder to a temporary folder
+func withHome(t *testing.T, f func(home string)) {
+	home, err := ioutil.TempDir("", "saves-home")
+	wtest.Must(t, err)
+	defer os.RemoveAll(home)
+
+	for _, name := range []string{"HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, "")
+	}
+	os.Setenv("HOME", home)
+
+	f(home)
+}
+
+var savesBuild = map[string]string{
+	"game.bin":   "the game",
+	".itch.toml": "saves = [\"~/garden/*.sav\", \"~/\", \"../*.sav\"]\n",
+}
+
+// addSaves writes save files of a cave everywhere FindSaves looks
+func (of *operateFixture) addSaves(t *testing.T, caveID string, home string) {
+	writeFiles(t, of.location.GetInstallFolder(caveID), map[string]string{
+		"saves/slot1.sav": "slot 1",
+		"log.txt":         "not a save",
+	})
+	writeFiles(t, of.location.GetSandboxHomeFolder(caveID), map[string]string{
+		"garden/slot2.sav":            "slot 2",
+		".config/garden/settings.ini": "fullscreen=1",
+		".cache/garden/shaders.bin":   "not a save",
+	})
+	writeFiles(t, home, map[string]string{
+		"garden/slot3.sav": "slot 3",
+		"notes.txt":        "not the game's",
+	})
+	writeFiles(t, of.location.Path, map[string]string{
+		"slot4.sav": "not the game's",
+	})
+}
+
+func TestFindSaves(t *testing.T) {
+	withHome(t, func(home string) {
+		of := newOperateFixture(t)
+		defer of.Close()
+
+		cave := of.addCave(t, "cave", nil, savesBuild)
+		of.addSaves(t, cave.ID, home)
+		installFolder := of.location.GetInstallFolder(cave.ID)
+		sandboxHome := of.location.GetSandboxHomeFolder(cave.ID)
+
+		sources := func(files []*butlerd.SaveFile) map[string]butlerd.SaveSource {
+			res := make(map[string]butlerd.SaveSource)
+			for _, f := range files {
+				res[f.Path] = f.Source
+			}
+			return res
+		}
+
+		files, err := FindSaves(of.rc.Consumer, cave.Game, installFolder, sandboxHome)
+		wtest.Must(t, err)
+		assert.EqualValues(t, map[string]butlerd.SaveSource{
+			filepath.Join(installFolder, "saves", "slot1.sav"):              butlerd.SaveSourceAngel,
+			filepath.Join(sandboxHome, "garden", "slot2.sav"):               butlerd.SaveSourceManifest,
+			filepath.Join(sandboxHome, ".config", "garden", "settings.ini"): butlerd.SaveSourceSandboxHome,
+			filepath.Join(home, "garden", "slot3.sav"):                      butlerd.SaveSourceManifest,
+		}, sources(files))
+
+		// never launched with a private home
+		wtest.Must(t, os.RemoveAll(sandboxHome))
+		files, err = FindSaves(of.rc.Consumer, cave.Game, installFolder, sandboxHome)
+		wtest.Must(t, err)
+		assert.EqualValues(t, map[string]butlerd.SaveSource{
+			filepath.Join(installFolder, "saves", "slot1.sav"): butlerd.SaveSourceAngel,
+			filepath.Join(home, "garden", "slot3.sav"):         butlerd.SaveSourceManifest,
+		}, sources(files))
+	})
+}
+
+func TestBackupRestoreSaves(t *testing.T) {
+	withHome(t, func(home string) {
+		of := newOperateFixture(t)
+		defer of.Close()
+
+		cave := of.addCave(t, "cave", nil, savesBuild)
+		of.addSaves(t, cave.ID, home)
+		installFolder := of.location.GetInstallFolder(cave.ID)
+		sandboxHome := of.location.GetSandboxHomeFolder(cave.ID)
+
+		snapshot, err := BackupSaves(of.rc, cave, cave.Game, butlerd.SaveSnapshotReasonManual)
+		wtest.Must(t, err)
+		assert.EqualValues(t, butlerd.SaveSnapshotReasonManual, snapshot.Reason)
+
+		// played some more, then reinstalled with files linked from the store
+		blob := filepath.Join(of.dir, "store", "blob")
+		writeFiles(t, filepath.Dir(blob), map[string]string{"blob": "slot 1, later"})
+		slot1 := filepath.Join(installFolder, "saves", "slot1.sav")
+		wtest.Must(t, os.Remove(slot1))
+		wtest.Must(t, os.Link(blob, slot1))
+		wtest.Must(t, os.Remove(filepath.Join(sandboxHome, ".config", "garden", "settings.ini")))
+		writeFiles(t, home, map[string]string{"garden/slot3.sav": "slot 3, later"})
+
+		restored, err := RestoreSaves(of.rc, cave, cave.Game, snapshot.ID)
+		wtest.Must(t, err)
+		assert.EqualValues(t, snapshot.ID, restored.ID)
+
+		assert.EqualValues(t, map[string]string{
+			"game.bin":        "the game",
+			".itch.toml":      savesBuild[".itch.toml"],
+			"saves/slot1.sav": "slot 1",
+			"log.txt":         "not a save",
+		}, readFiles(t, installFolder))
+		assert.EqualValues(t, map[string]string{
+			"garden/slot2.sav":            "slot 2",
+			".config/garden/settings.ini": "fullscreen=1",
+			".cache/garden/shaders.bin":   "not a save",
+		}, readFiles(t, sandboxHome))
+		contents, err := ioutil.ReadFile(filepath.Join(home, "garden", "slot3.sav"))
+		wtest.Must(t, err)
+		assert.EqualValues(t, "slot 3", string(contents))
+
+		contents, err = ioutil.ReadFile(blob)
+		wtest.Must(t, err)
+		assert.EqualValues(t, "slot 1, later", string(contents), "the store isn't written through")
+
+		// what was there before restoring was backed up
+		snapshots := ListSaveSnapshots(of.rc, cave.GameID)
+		if assert.Len(t, snapshots, 2) {
+			assert.EqualValues(t, butlerd.SaveSnapshotReasonRestore, snapshots[0].Reason)
+		}
+	})
+}
+
+func TestFormatSaveSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "save-snapshots")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	format := func(name string) *butlerd.SaveSnapshot {
+		path := filepath.Join(dir, name)
+		wtest.Must(t, ioutil.WriteFile(path, []byte("zip"), 0644))
+		stats, err := os.Stat(path)
+		wtest.Must(t, err)
+		return formatSaveSnapshot(path, stats)
+	}
+
+	s := format("20261018-093000.125-upgrade.zip")
+	if assert.NotNil(t, s) {
+		assert.EqualValues(t, "20261018-093000.125-upgrade", s.ID)
+		assert.EqualValues(t, butlerd.SaveSnapshotReasonUpgrade, s.Reason)
+		assert.EqualValues(t, time.Date(2026, 10, 18, 9, 30, 0, 125000000, time.UTC), *s.CreatedAt)
+		assert.EqualValues(t, 3, s.Size)
+	}
+
+	// taken in the same millisecond as another
+	s = format("20261018-093000.125-restore-2.zip")
+	if assert.NotNil(t, s) {
+		assert.EqualValues(t, "20261018-093000.125-restore-2", s.ID)
+		assert.EqualValues(t, butlerd.SaveSnapshotReasonRestore, s.Reason)
+	}
+
+	assert.Nil(t, format("notes.zip"))
+	assert.Nil(t, format("20261018-093000.125.zip"))
+	assert.Nil(t, format("20261318-093000.125-manual.zip"))
+}
+
+func TestPruneSaveSnapshots(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	game := &itchio.Game{ID: 1}
+	savesFolder := of.location.GetSavesFolder(game.ID)
+	start := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	id := func(i int, reason butlerd.SaveSnapshotReason) string {
+		return start.Add(time.Duration(i)*time.Minute).Format(saveSnapshotTimeFormat) + "-" + string(reason)
+	}
+
+	files := make(map[string]string)
+	for i := 0; i < 14; i++ {
+		files[id(i, butlerd.SaveSnapshotReasonUpgrade)+".zip"] = "zip"
+	}
+	files[id(-1, butlerd.SaveSnapshotReasonManual)+".zip"] = "zip"
+	writeFiles(t, savesFolder, files)
+
+	keepID := id(0, butlerd.SaveSnapshotReasonUpgrade)
+	pruneSaveSnapshots(of.rc, game.ID, keepID)
+
+	var ids []string
+	for _, s := range ListSaveSnapshots(of.rc, game.ID) {
+		ids = append(ids, s.ID)
+	}
+	// the 10 most recent automatic ones, the manual one,
+	// and the one that was asked to be kept
+	expected := []string{}
+	for i := 13; i >= 4; i-- {
+		expected = append(expected, id(i, butlerd.SaveSnapshotReasonUpgrade))
+	}
+	expected = append(expected, keepID, id(-1, butlerd.SaveSnapshotReasonManual))
+	assert.EqualValues(t, expected, ids)
+}
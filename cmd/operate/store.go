@@ -0,0 +1,72 @@
+package operate
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/headway/united"
+	"github.com/pkg/errors"
+)
+
+// openStore returns the dedupe store butlerd was started
+// with, or nil if it wasn't started with one.
+func openStore(oc *OperationContext) *store.Store {
+	if oc.rc.StoreDir == "" {
+		return nil
+	}
+	return store.New(oc.rc.StoreDir, oc.rc.WithConn)
+}
+
+// installStore returns the dedupe store an install should link files
+// from, or nil if there's none, or if its install location doesn't
+// link files from the store. Linked files may be read-only, which
+// some games don't expect, so locations have to opt in.
+func installStore(oc *OperationContext, params *InstallParams) *store.Store {
+	if params.InstallLocationID == "" {
+		return nil
+	}
+
+	var enabled bool
+	oc.rc.WithConn(func(conn *sqlite.Conn) {
+		il := models.InstallLocationByID(conn, params.InstallLocationID)
+		enabled = il != nil && il.StoreLinks
+	})
+	if !enabled {
+		return nil
+	}
+	return openStore(oc)
+}
+
+// detachFromStore must be called before modifying files of an install
+// folder in place, see store.Detach. Files stay linked if the install
+// location stops linking files from the store, so it's always done.
+func detachFromStore(oc *OperationContext, installFolder string) error {
+	st := openStore(oc)
+	if st == nil {
+		return nil
+	}
+
+	err := st.Detach(oc.Consumer(), installFolder)
+	if err != nil {
+		return errors.WithMessage(err, "detaching files from store")
+	}
+	return nil
+}
+
+// linkFromStore replaces the given files of an install folder, which
+// must be files of the installed build, with links to the store. The
+// install is fine without it, so errors are only logged.
+func linkFromStore(oc *OperationContext, params *InstallParams, files []string) {
+	st := installStore(oc, params)
+	if st == nil {
+		return
+	}
+
+	consumer := oc.Consumer()
+	res, err := st.Ingest(consumer, params.InstallFolder, files)
+	if err != nil {
+		consumer.Warnf("Could not link files from store: %+v", err)
+		return
+	}
+	consumer.Infof("%d files linked from store, %s shared with other builds", res.Linked, united.FormatBytes(res.Shared))
+}
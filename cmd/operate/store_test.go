@@ -0,0 +1,69 @@
+package operate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/hades"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+)
+
+func TestStoreLinksOptIn(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	data := strings.Repeat("level data ", 4*1024)
+	cave := of.addCave(t, "cave", nil, map[string]string{"data.pak": data})
+	oc, meta, _ := of.operationContext(cave)
+	params := meta.Data
+	params.InstallLocationID = of.location.ID
+	dataPath := filepath.Join(params.InstallFolder, "data.pak")
+
+	numLinks := func() int {
+		var refs []*models.StoreRef
+		of.rc.WithConn(func(conn *sqlite.Conn) {
+			refs = models.AllStoreRefs(conn)
+		})
+		return len(refs)
+	}
+	setStoreLinks := func(enabled bool) {
+		of.rc.WithConn(func(conn *sqlite.Conn) {
+			models.MustUpdate(conn, &models.InstallLocation{},
+				hades.Where(builder.Eq{"id": of.location.ID}),
+				builder.Eq{"store_links": enabled},
+			)
+		})
+	}
+
+	t.Logf("no store")
+	setStoreLinks(true)
+	assert.Nil(t, installStore(oc, params))
+
+	t.Logf("location hasn't opted in")
+	of.rc.StoreDir = filepath.Join(of.dir, "store")
+	setStoreLinks(false)
+	assert.Nil(t, installStore(oc, params))
+	linkFromStore(oc, params, []string{"data.pak"})
+	assert.EqualValues(t, 0, numLinks())
+
+	t.Logf("location opted in")
+	setStoreLinks(true)
+	assert.NotNil(t, installStore(oc, params))
+	linkFromStore(oc, params, []string{"data.pak"})
+	assert.EqualValues(t, 1, numLinks())
+
+	t.Logf("opted out again, files are still detached before patching")
+	setStoreLinks(false)
+	wtest.Must(t, detachFromStore(oc, params.InstallFolder))
+	assert.EqualValues(t, 0, numLinks())
+
+	stats, err := os.Stat(dataPath)
+	wtest.Must(t, err)
+	assert.True(t, stats.Mode()&0200 != 0, "detached files are writable")
+}
@@ -2,6 +2,8 @@ package operate
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/butlerd/messages"
@@ -9,6 +11,7 @@ import (
 	"github.com/itchio/butler/database/models"
 	"github.com/itchio/butler/installer"
 	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/headway/state"
 	"github.com/pkg/errors"
 )
 
@@ -18,35 +21,34 @@ func UninstallPerform(ctx context.Context, rc *butlerd.RequestContext, params bu
 	defer rc.PutConn(conn)
 
 	cave := ValidateCave(rc, params.CaveID)
+	installFolder := cave.GetInstallFolder(conn)
 
+	var err error
+	if params.SkipSavesBackup {
+		consumer.Infof("Not backing up saves, as asked")
+	} else {
+		_, err = BackupSaves(rc, cave, cave.Game, butlerd.SaveSnapshotReasonUninstall)
+		if err != nil {
+			return errors.WithMessage(err, "backing up saves")
+		}
+	}
+
+	var receipt *bfs.Receipt
 	if params.Hard {
 		consumer.Opf("Performing hard uninstall for (%s)", cave.ID)
 	} else {
 		consumer.Opf("Performing graceful uninstall for (%s)", cave.ID)
-		installFolder := cave.GetInstallFolder(conn)
-
-		var installerType = installer.InstallerTypeUnknown
 
-		receipt, err := bfs.ReadReceipt(installFolder)
+		receipt, err = bfs.ReadReceipt(installFolder)
 		if err != nil {
 			consumer.Warnf("Could not read receipt: %s", err.Error())
 		}
 
-		if receipt != nil && receipt.InstallerName != "" {
-			installerType = (installer.InstallerType)(receipt.InstallerName)
-		}
-
+		installerType := uninstallerType(consumer, receipt)
 		consumer.Infof("Will use installer (%s)", installerType)
 		manager := installer.GetManager(string(installerType))
 		if manager == nil {
-			// TODO: detect common uninstallers?
-			consumer.Warnf("No manager for installer (%s)", installerType)
-			consumer.Infof("Falling back to archive")
-
-			manager = installer.GetManager("archive")
-			if manager == nil {
-				return errors.New("archive install manager not found, can't uninstall")
-			}
+			return errors.Errorf("%s install manager not found, can't uninstall", installerType)
 		}
 
 		managerUninstallParams := installer.UninstallParams{
@@ -86,6 +88,20 @@ func UninstallPerform(ctx context.Context, rc *butlerd.RequestContext, params bu
 	consumer.Infof("Clearing out downloads...")
 	models.DiscardDownloadsByCaveID(conn, cave.ID)
 
+	homeFolder := cave.GetInstallLocation(conn).GetSandboxHomeFolder(cave.ID)
+	if uninstallKeepsAngels(params.KeepAngels, params.Hard, receipt) {
+		if _, err := os.Stat(homeFolder); err == nil {
+			consumer.Infof("Keeping sandbox home (%s)", homeFolder)
+		}
+
+		consumer.Infof("Removing installed files...")
+		err := removeInstalledFiles(consumer, installFolder, receipt)
+		if err != nil {
+			consumer.Warnf("While removing installed files: %+v", err)
+		}
+		return nil
+	}
+
 	func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -94,9 +110,61 @@ func UninstallPerform(ctx context.Context, rc *butlerd.RequestContext, params bu
 		}()
 		consumer.Infof("Wiping install folder...")
 
-		installFolder := cave.GetInstallFolder(conn)
 		models.Must(wipe.Do(consumer, installFolder))
+		if _, err := os.Stat(homeFolder); err == nil {
+			consumer.Infof("Wiping sandbox home...")
+			models.Must(wipe.Do(consumer, homeFolder))
+		}
 	}()
 
 	return nil
 }
+
+// uninstallerType returns the installer whose manager uninstalls
+// what's described by a receipt.
+func uninstallerType(consumer *state.Consumer, receipt *bfs.Receipt) installer.InstallerType {
+	installerType := installer.InstallerTypeUnknown
+	if receipt != nil && receipt.InstallerName != "" {
+		installerType = (installer.InstallerType)(receipt.InstallerName)
+	}
+
+	if installer.GetManager(string(installerType)) == nil {
+		// TODO: detect common uninstallers?
+		consumer.Warnf("No manager for installer (%s)", installerType)
+		consumer.Infof("Falling back to archive")
+		installerType = installer.InstallerTypeArchive
+	}
+	return installerType
+}
+
+// hasExternalUninstaller returns true for installers whose
+// uninstall manager runs something else to remove files.
+func hasExternalUninstaller(installerType installer.InstallerType) bool {
+	switch installerType {
+	case installer.InstallerTypeMSI, installer.InstallerTypeNsis, installer.InstallerTypeInno:
+		return true
+	}
+	return false
+}
+
+// removeInstalledFiles removes the files listed in a receipt, and any
+// folder left empty, leaving angels where they are.
+func removeInstalledFiles(consumer *state.Consumer, installFolder string, receipt *bfs.Receipt) error {
+	err := bfs.BustGhosts(&bfs.BustGhostsParams{
+		Folder:   installFolder,
+		Receipt:  receipt,
+		Consumer: consumer,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = wipe.Do(consumer, filepath.Join(installFolder, ".itch"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// only works if there's no angels
+	os.Remove(installFolder)
+	return nil
+}
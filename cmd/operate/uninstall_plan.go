@@ -0,0 +1,162 @@
+package operate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/sizeof"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/pkg/errors"
+)
+
+// UninstallPlan returns what UninstallPerform would remove,
+// given the same parameters.
+func UninstallPlan(rc *butlerd.RequestContext, params butlerd.UninstallPlanParams) (*butlerd.UninstallPlanResult, error) {
+	consumer := rc.Consumer
+
+	cave := ValidateCave(rc, params.CaveID)
+	var installFolder string
+	var homeFolder string
+	var linked map[string]bool
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+		homeFolder = cave.GetInstallLocation(conn).GetSandboxHomeFolder(cave.ID)
+		linked = linkedFromStore(conn, installFolder)
+	})
+
+	res := &butlerd.UninstallPlanResult{
+		InstallFolder: installFolder,
+		Files:         []string{},
+		Dirs:          []string{},
+		Angels:        []string{},
+	}
+
+	var receipt *bfs.Receipt
+	if !params.Hard {
+		var err error
+		receipt, err = bfs.ReadReceipt(installFolder)
+		if err != nil {
+			consumer.Warnf("Could not read receipt: %s", err.Error())
+		}
+
+		installerType := uninstallerType(consumer, receipt)
+		res.Installer = string(installerType)
+		res.ExternalUninstaller = hasExternalUninstaller(installerType)
+	}
+
+	var homeSize int64
+	if _, err := os.Stat(homeFolder); err == nil {
+		res.SandboxHome = homeFolder
+		homeSize, err = sizeof.Do(homeFolder)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res.AngelsSize += homeSize
+	}
+
+	if _, err := os.Stat(installFolder); err != nil {
+		if os.IsNotExist(err) {
+			consumer.Infof("Install folder (%s) is already gone", installFolder)
+			if !uninstallKeepsAngels(params.KeepAngels, params.Hard, receipt) {
+				res.ReclaimedBytes += homeSize
+			}
+			return res, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	container, err := bfs.Walk(installFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sizes := make(map[string]int64)
+	for _, f := range container.Files {
+		sizes[f.Path] = f.Size
+	}
+	paths := bfs.ContainerPaths(container)
+
+	installed := make(map[string]bool)
+	if receipt.HasFiles() {
+		for _, path := range receipt.Files {
+			installed[path] = true
+		}
+	} else {
+		// we can't tell, and it's all getting wiped anyway
+		for _, path := range paths {
+			installed[path] = true
+		}
+	}
+
+	for _, path := range paths {
+		if installed[path] {
+			res.Files = append(res.Files, path)
+			if !linked[path] {
+				res.ReclaimedBytes += sizes[path]
+			}
+		} else {
+			res.Angels = append(res.Angels, path)
+			res.AngelsSize += sizes[path]
+		}
+	}
+
+	keepAngels := uninstallKeepsAngels(params.KeepAngels, params.Hard, receipt)
+	if !keepAngels {
+		res.ReclaimedBytes += res.AngelsSize
+	}
+
+	for _, d := range container.Dirs {
+		if d.Path == "." {
+			continue
+		}
+		if keepAngels && hasAngelsIn(d.Path, res.Angels) {
+			continue
+		}
+		res.Dirs = append(res.Dirs, d.Path)
+	}
+
+	// receipts, rollback data, etc.
+	dotItchSize, err := sizeof.Do(filepath.Join(installFolder, ".itch"))
+	if err == nil {
+		res.ReclaimedBytes += dotItchSize
+	}
+
+	return res, nil
+}
+
+// uninstallKeepsAngels returns true if an uninstall leaves angels
+// (and the cave's sandbox home) where they are
+func uninstallKeepsAngels(keepAngels bool, hard bool, receipt *bfs.Receipt) bool {
+	return keepAngels && !hard && receipt.HasFiles()
+}
+
+// linkedFromStore returns files of an install folder that are linked
+// from the store, as slash-separated paths relative to it.
+func linkedFromStore(conn *sqlite.Conn, installFolder string) map[string]bool {
+	linked := make(map[string]bool)
+	folder, err := filepath.Abs(installFolder)
+	if err != nil {
+		return linked
+	}
+	prefix := folder + string(filepath.Separator)
+	for _, ref := range models.StoreRefsInFolder(conn, prefix) {
+		if strings.HasPrefix(ref.Path, prefix) {
+			linked[filepath.ToSlash(strings.TrimPrefix(ref.Path, prefix))] = true
+		}
+	}
+	return linked
+}
+
+func hasAngelsIn(dir string, angels []string) bool {
+	prefix := dir + "/"
+	for _, angel := range angels {
+		if strings.HasPrefix(angel, prefix) {
+			return true
+		}
+	}
+	return false
+}
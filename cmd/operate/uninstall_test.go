@@ -0,0 +1,136 @@
+package operate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/sizeof"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/archive"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var uninstallBuild = map[string]string{
+	"game.bin":        "the game",
+	"data/level1.dat": "the first level",
+}
+
+// addAngels writes files the game would have, to a cave's
+// install folder and to its sandbox home
+func (of *operateFixture) addAngels(t *testing.T, caveID string) {
+	writeFiles(t, of.location.GetInstallFolder(caveID), map[string]string{
+		"saves/slot1.sav": "progress",
+	})
+	writeFiles(t, of.location.GetSandboxHomeFolder(caveID), map[string]string{
+		".config/garden/settings.ini": "fullscreen=1",
+	})
+}
+
+func TestUninstallPlan(t *testing.T) {
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", &itchio.Build{ID: 1}, uninstallBuild)
+	of.addAngels(t, cave.ID)
+	installFolder := of.location.GetInstallFolder(cave.ID)
+
+	// its data stays in the store
+	of.rc.WithConn(func(conn *sqlite.Conn) {
+		models.MustSave(conn, &models.StoreRef{
+			Path: filepath.Join(installFolder, "game.bin"),
+			Hash: "hash",
+		})
+	})
+
+	res, err := UninstallPlan(of.rc, butlerd.UninstallPlanParams{CaveID: cave.ID, KeepAngels: true})
+	wtest.Must(t, err)
+	assert.ElementsMatch(t, []string{"game.bin", "data/level1.dat"}, res.Files)
+	assert.ElementsMatch(t, []string{"data"}, res.Dirs)
+	assert.ElementsMatch(t, []string{"saves/slot1.sav"}, res.Angels)
+	assert.EqualValues(t, of.location.GetSandboxHomeFolder(cave.ID), res.SandboxHome)
+	homeSize, err := sizeof.Do(res.SandboxHome)
+	wtest.Must(t, err)
+	assert.EqualValues(t, int64(len("progress"))+homeSize, res.AngelsSize)
+
+	dotItch := res.ReclaimedBytes - int64(len("the first level"))
+	assert.True(t, dotItch > 0, "receipt is reclaimed")
+
+	res, err = UninstallPlan(of.rc, butlerd.UninstallPlanParams{CaveID: cave.ID})
+	wtest.Must(t, err)
+	assert.ElementsMatch(t, []string{"data", "saves"}, res.Dirs)
+	assert.EqualValues(t, int64(len("the first level"))+res.AngelsSize+dotItch, res.ReclaimedBytes)
+}
+
+func TestUninstallPerform(t *testing.T) {
+	withHome(t, func(home string) {
+		testUninstallPerform(t)
+	})
+}
+
+func testUninstallPerform(t *testing.T) {
+	archive.Register()
+
+	of := newOperateFixture(t)
+	defer of.Close()
+
+	cave := of.addCave(t, "cave", &itchio.Build{ID: 1}, uninstallBuild)
+	of.addAngels(t, cave.ID)
+	installFolder := of.location.GetInstallFolder(cave.ID)
+	homeFolder := of.location.GetSandboxHomeFolder(cave.ID)
+
+	wtest.Must(t, UninstallPerform(of.rc.Ctx, of.rc, butlerd.UninstallPerformParams{
+		CaveID:     cave.ID,
+		KeepAngels: true,
+	}))
+	assert.EqualValues(t, map[string]string{
+		"saves/slot1.sav": "progress",
+	}, readFiles(t, installFolder))
+	assert.EqualValues(t, map[string]string{
+		".config/garden/settings.ini": "fullscreen=1",
+	}, readFiles(t, homeFolder))
+
+	cave = of.addCave(t, "other", &itchio.Build{ID: 1}, uninstallBuild)
+	of.addAngels(t, cave.ID)
+
+	wtest.Must(t, UninstallPerform(of.rc.Ctx, of.rc, butlerd.UninstallPerformParams{
+		CaveID: cave.ID,
+	}))
+	_, err := os.Stat(of.location.GetInstallFolder(cave.ID))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(of.location.GetSandboxHomeFolder(cave.ID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUninstallPerformBackupFails(t *testing.T) {
+	withHome(t, func(home string) {
+		archive.Register()
+
+		of := newOperateFixture(t)
+		defer of.Close()
+
+		cave := of.addCave(t, "cave", &itchio.Build{ID: 1}, uninstallBuild)
+		of.addAngels(t, cave.ID)
+		installFolder := of.location.GetInstallFolder(cave.ID)
+
+		// snapshots can't be written there
+		writeFiles(t, of.location.Path, map[string]string{"saves": "not a folder"})
+
+		err := UninstallPerform(of.rc.Ctx, of.rc, butlerd.UninstallPerformParams{CaveID: cave.ID})
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(installFolder, "saves", "slot1.sav"))
+		assert.NoError(t, err, "saves aren't lost")
+		assert.NotNil(t, ValidateCave(of.rc, cave.ID))
+
+		wtest.Must(t, UninstallPerform(of.rc.Ctx, of.rc, butlerd.UninstallPerformParams{
+			CaveID:          cave.ID,
+			SkipSavesBackup: true,
+		}))
+		_, err = os.Stat(installFolder)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
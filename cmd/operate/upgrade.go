@@ -37,6 +37,16 @@ func upgrade(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext
 
 	consumer.Infof("Applying %d patches (%d already done)", remainingPatches, donePatches)
 
+	err := detachFromStore(oc, meta.Data.InstallFolder)
+	if err != nil {
+		return err
+	}
+
+	keepRollback, err := prepareRollback(oc, meta, isub, receiptIn)
+	if err != nil {
+		return err
+	}
+
 	var roughPatchCosts []float64
 	var totalPatchCost float64
 	for _, b := range istate.UpgradePath.Builds {
@@ -81,7 +91,7 @@ func upgrade(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext
 			start:    donePatchCost / totalPatchCost,
 			end:      (donePatchCost + cost) / totalPatchCost,
 		}
-		err := applyPatch(oc, meta, isub, receiptIn, i, sp)
+		err := applyPatch(oc, meta, isub, receiptIn, keepRollback, i, sp)
 		if err != nil {
 			return errors.WithMessage(err, fmt.Sprintf("while applying patch %d/%d (build %d)", i, totalPatches, build.ID))
 		}
@@ -91,10 +101,24 @@ func upgrade(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext
 	}
 	oc.rc.EndProgress()
 
+	if keepRollback {
+		err := commitRollback(oc, meta.Data.InstallFolder, istate.UpgradePath.Builds[totalPatches-1].ID)
+		if err != nil {
+			consumer.Warnf("Could not keep rollback data: %+v", err)
+		}
+	}
+
+	// the last patch wrote the receipt of the build we're now at
+	receipt, err := bfs.ReadReceipt(meta.Data.InstallFolder)
+	if err != nil {
+		consumer.Warnf("Could not read receipt, not linking files from store: %+v", err)
+	} else if receipt.HasFiles() {
+		linkFromStore(oc, meta.Data, receipt.Files)
+	}
 	return nil
 }
 
-func applyPatch(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, receiptIn *bfs.Receipt, upgradePathIndex int, progressTarget SlicedProgress) error {
+func applyPatch(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcontext, receiptIn *bfs.Receipt, keepRollback bool, upgradePathIndex int, progressTarget SlicedProgress) error {
 	rc := oc.rc
 	consumer := oc.Consumer()
 	params := meta.Data
@@ -227,6 +251,13 @@ func applyPatch(oc *OperationContext, meta *MetaSubcontext, isub *InstallSubcont
 
 	os.RemoveAll(checkpointPath)
 
+	if keepRollback {
+		err = keepForRollback(oc, params.InstallFolder, p.GetTargetContainer(), p.GetSourceContainer(), bowl)
+		if err != nil {
+			return errors.WithMessage(err, "while keeping files for rollback")
+		}
+	}
+
 	err = bowl.Commit()
 	if err != nil {
 		return errors.WithMessage(err, "while committing patch")
@@ -0,0 +1,189 @@
+package operate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/manager/runlock"
+	"github.com/itchio/headway/united"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/savior/seeksource"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wire"
+	"github.com/pkg/errors"
+)
+
+// VerifyCave checks the files of an installed cave against its build's
+// signature or, if it's not wharf-enabled, against its receipt. If the cave
+// is being installed, launched, etc., it waits for that to be done.
+func VerifyCave(ctx context.Context, rc *butlerd.RequestContext, cave *models.Cave) (*butlerd.CavesVerifyResult, error) {
+	consumer := rc.Consumer
+
+	var installFolder string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+	})
+
+	rlock := runlock.New(consumer, installFolder)
+	err := rlock.Lock(ctx, "verify")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rlock.Unlock()
+
+	if cave.Build == nil {
+		consumer.Infof("Not a wharf-enabled upload, checking files against receipt")
+		return verifyAgainstReceipt(installFolder)
+	}
+
+	sigInfo, err := fetchCaveSignature(ctx, rc, cave)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "butler-verify")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	woundsPath := filepath.Join(tmpDir, "wounds.pww")
+
+	vc := &pwr.ValidatorContext{
+		Consumer:   consumer,
+		WoundsPath: woundsPath,
+	}
+
+	consumer.Infof("Verifying %s in (%s)", united.FormatBytes(sigInfo.Container.Size), installFolder)
+	rc.StartProgress()
+	err = vc.Validate(ctx, installFolder, sigInfo)
+	rc.EndProgress()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &butlerd.CavesVerifyResult{
+		CheckedContents: true,
+		Healthy:         true,
+	}
+	if !vc.WoundsConsumer.HasWounds() {
+		consumer.Infof("✓ All files are healthy")
+		return res, nil
+	}
+
+	res.Healthy = false
+	res.CorruptedBytes = vc.WoundsConsumer.TotalCorrupted()
+	res.Wounds, err = readWounds(woundsPath, installFolder)
+	if err != nil {
+		return nil, err
+	}
+	consumer.Warnf("%d damaged entries, %s corrupted data", len(res.Wounds), united.FormatBytes(res.CorruptedBytes))
+	return res, nil
+}
+
+// readWounds reads a wounds file written by a validator, and returns
+// one wound per damaged entry.
+func readWounds(woundsPath string, installFolder string) ([]*butlerd.CaveWound, error) {
+	f, err := os.Open(woundsPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	source := seeksource.FromFile(f)
+	_, err = source.Resume(nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rctx := wire.NewReadContext(source)
+	err = rctx.ExpectMagic(pwr.WoundsMagic)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = rctx.ReadMessage(&pwr.WoundsHeader{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	container := &tlc.Container{}
+	err = rctx.ReadMessage(container)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var wounds []*butlerd.CaveWound
+	woundsByPath := make(map[string]*butlerd.CaveWound)
+	for {
+		wound := &pwr.Wound{}
+		err = rctx.ReadMessage(wound)
+		if err != nil {
+			if errors.Cause(err) == io.EOF {
+				break
+			}
+			return nil, errors.WithStack(err)
+		}
+
+		var path string
+		switch wound.Kind {
+		case pwr.WoundKind_FILE, pwr.WoundKind_CLOSED_FILE:
+			path = container.Files[wound.Index].Path
+		case pwr.WoundKind_SYMLINK:
+			path = container.Symlinks[wound.Index].Path
+		case pwr.WoundKind_DIR:
+			path = container.Dirs[wound.Index].Path
+		default:
+			continue
+		}
+
+		cw, ok := woundsByPath[path]
+		if !ok {
+			cw = &butlerd.CaveWound{
+				Path: path,
+				Kind: butlerd.CaveWoundKindCorrupted,
+			}
+			_, err := os.Lstat(filepath.Join(installFolder, filepath.FromSlash(path)))
+			if err != nil {
+				cw.Kind = butlerd.CaveWoundKindMissing
+			}
+			woundsByPath[path] = cw
+			wounds = append(wounds, cw)
+		}
+		cw.Size += wound.Size()
+	}
+	return wounds, nil
+}
+
+// verifyAgainstReceipt checks that all files listed in
+// the receipt of an install folder are there.
+func verifyAgainstReceipt(installFolder string) (*butlerd.CavesVerifyResult, error) {
+	receipt, err := bfs.ReadReceipt(installFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !receipt.HasFiles() {
+		return nil, errors.Errorf("No list of installed files for (%s), can't verify it", installFolder)
+	}
+
+	res := &butlerd.CavesVerifyResult{
+		Healthy: true,
+	}
+	for _, path := range receipt.Files {
+		_, err := os.Lstat(filepath.Join(installFolder, filepath.FromSlash(path)))
+		if err != nil {
+			res.Healthy = false
+			res.Wounds = append(res.Wounds, &butlerd.CaveWound{
+				Path: path,
+				Kind: butlerd.CaveWoundKindMissing,
+			})
+		}
+	}
+	return res, nil
+}
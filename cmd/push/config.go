@@ -0,0 +1,332 @@
+package push
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/filtering"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/united"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// config describes several channels to push in one go,
+// for example:
+//
+//	target = "leafo/x-moon"
+//	userversion-file = "VERSION"
+//	ignore = ["*.pdb"]
+//
+//	[channels.windows]
+//	dir = "build/windows"
+//
+//	[channels.linux]
+//	dir = "build/linux"
+//	ignore = ["*.debug", "assets/raw"]
+//
+// Settings left out of a channel are taken from the top level, and
+// settings left out of the top level are taken from the command line.
+type config struct {
+	// Project to push to, for example 'leafo/x-moon' or a game ID
+	Target string `toml:"target" yaml:"target"`
+	// Version number shared by all channels
+	UserVersion string `toml:"userversion" yaml:"userversion"`
+	// File to read the version number shared by all channels from
+	UserVersionFile string `toml:"userversion-file" yaml:"userversion-file"`
+	// Glob patterns of files and folders to ignore, in addition to the
+	// default ones. Patterns with a slash are matched against paths relative
+	// to the channel's dir, others against names at any depth.
+	Ignore []string `toml:"ignore" yaml:"ignore"`
+
+	FixPermissions *bool `toml:"fix-permissions" yaml:"fix-permissions"`
+	Dereference    *bool `toml:"dereference" yaml:"dereference"`
+	IfChanged      *bool `toml:"if-changed" yaml:"if-changed"`
+	AutoWrap       *bool `toml:"auto-wrap" yaml:"auto-wrap"`
+
+	// Channels to push, by name
+	Channels map[string]*channelConfig `toml:"channels" yaml:"channels"`
+}
+
+type channelConfig struct {
+	// Directory (or archive) to push to this channel
+	Dir string `toml:"dir" yaml:"dir"`
+	// Overrides the top-level target
+	Target string `toml:"target" yaml:"target"`
+	// Glob patterns of files and folders to ignore, in addition to the top-level ones
+	Ignore []string `toml:"ignore" yaml:"ignore"`
+
+	FixPermissions *bool `toml:"fix-permissions" yaml:"fix-permissions"`
+	Dereference    *bool `toml:"dereference" yaml:"dereference"`
+	IfChanged      *bool `toml:"if-changed" yaml:"if-changed"`
+	AutoWrap       *bool `toml:"auto-wrap" yaml:"auto-wrap"`
+}
+
+// readConfig parses a push config file, as YAML if its extension
+// is .yml or .yaml, and as TOML otherwise.
+func readConfig(configPath string) (*config, error) {
+	buf, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	c := &config{}
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(buf, c)
+	default:
+		var md toml.MetaData
+		md, err = toml.Decode(string(buf), c)
+		if err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				err = errors.Errorf("unknown key %s", undecoded[0])
+			}
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", configPath)
+	}
+
+	if len(c.Channels) == 0 {
+		return nil, errors.Errorf("%s doesn't list any channels", configPath)
+	}
+
+	for _, pattern := range c.Ignore {
+		err := filtering.CheckPattern(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", configPath)
+		}
+	}
+	for name, cc := range c.Channels {
+		if cc == nil {
+			continue
+		}
+		for _, pattern := range cc.Ignore {
+			err := filtering.CheckPattern(pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s, channel %s", configPath, name)
+			}
+		}
+	}
+	return c, nil
+}
+
+func pickBool(fallback bool, overrides ...*bool) bool {
+	res := fallback
+	for _, o := range overrides {
+		if o != nil {
+			res = *o
+		}
+	}
+	return res
+}
+
+// channelParams returns the push parameters for every channel listed
+// in the config, sorted by channel name.
+func (c *config) channelParams(baseDir string, stateDir string, userVersion string) ([]*pushParams, error) {
+	var names []string
+	for name := range c.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res []*pushParams
+	for _, name := range names {
+		cc := c.Channels[name]
+		if cc == nil || cc.Dir == "" {
+			return nil, errors.Errorf("channel %s: missing dir", name)
+		}
+
+		target := cc.Target
+		if target == "" {
+			target = c.Target
+		}
+		if target == "" {
+			return nil, errors.Errorf("channel %s: missing target", name)
+		}
+
+		spec, err := itchio.ParseSpec(fmt.Sprintf("%s:%s", target, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "channel %s", name)
+		}
+		err = spec.EnsureChannel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "channel %s", name)
+		}
+
+		buildPath := cc.Dir
+		if !filepath.IsAbs(buildPath) {
+			buildPath = filepath.Join(baseDir, buildPath)
+		}
+
+		var ignore []string
+		ignore = append(ignore, c.Ignore...)
+		ignore = append(ignore, cc.Ignore...)
+
+		res = append(res, &pushParams{
+			buildPath:   buildPath,
+			spec:        spec,
+			userVersion: userVersion,
+			fixPerms:    pickBool(args.fixPerms, c.FixPermissions, cc.FixPermissions),
+			dereference: pickBool(args.dereference, c.Dereference, cc.Dereference),
+			ifChanged:   pickBool(args.ifChanged, c.IfChanged, cc.IfChanged),
+			wrap:        pickBool(args.autoWrap, c.AutoWrap, cc.AutoWrap),
+			ignore:      ignore,
+			stateDir:    stateDir,
+			label:       name,
+		})
+	}
+	return res, nil
+}
+
+// ChannelSummary is what happened to one of the channels
+// pushed with DoConfig
+type ChannelSummary struct {
+	Channel   string `json:"channel"`
+	Target    string `json:"target"`
+	BuildID   int64  `json:"buildId,omitempty"`
+	PatchSize int64  `json:"patchSize,omitempty"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DoConfig pushes all the channels listed in a push config file. Channels
+// are walked, diffed and uploaded concurrently: a failure on one channel
+// doesn't stop the others.
+func DoConfig(ctx *mansion.Context, configPath string) error {
+	consumer := comm.NewStateConsumer()
+
+	c, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	baseDir := filepath.Dir(configPath)
+
+	userVersion := args.userVersion
+	if userVersion == "" {
+		userVersion = c.UserVersion
+	}
+	if userVersion == "" {
+		userVersionFile := args.userVersionFile
+		if userVersionFile == "" && c.UserVersionFile != "" {
+			userVersionFile = c.UserVersionFile
+			if !filepath.IsAbs(userVersionFile) {
+				userVersionFile = filepath.Join(baseDir, userVersionFile)
+			}
+		}
+		if userVersionFile != "" {
+			userVersion, err = readUserVersionFile(userVersionFile)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	stateDir := args.stateDir
+	if stateDir == "" {
+		stateDir = filepath.Join(baseDir, stateDirName)
+	}
+
+	params, err := c.channelParams(baseDir, stateDir, userVersion)
+	if err != nil {
+		return errors.Wrapf(err, "in %s", configPath)
+	}
+
+	// start walking all channels while waiting on auth flow
+	walks := make([]*pendingWalk, len(params))
+	for i, p := range params {
+		walks[i] = p.startWalk(consumer)
+	}
+
+	if args.dryRun {
+		client, err := dryRunClient(ctx)
+		if err != nil {
+			return errors.Wrap(err, "authenticating")
+		}
+
+		// one after the other, so the reports don't get mixed up
+		for i, p := range params {
+			comm.Logf("")
+			err := dryRun(ctx, client, consumer, p, walks[i])
+			if err != nil {
+				return errors.Wrapf(err, "channel %s", p.label)
+			}
+		}
+		return nil
+	}
+
+	client, err := ctx.AuthenticateViaOauth()
+	if err != nil {
+		return errors.Wrap(err, "authenticating")
+	}
+
+	comm.Opf("Pushing %d channels...", len(params))
+
+	summaries := make([]ChannelSummary, len(params))
+	var wg sync.WaitGroup
+	for i, p := range params {
+		wg.Add(1)
+		go func(i int, p *pushParams) {
+			defer wg.Done()
+
+			s := &summaries[i]
+			s.Channel = p.spec.Channel
+			s.Target = p.spec.Target
+
+			res, err := pushBuild(ctx, client, consumer, p, walks[i])
+			if err != nil {
+				comm.Logf("[%s] Push failed: %s", p.label, err.Error())
+				s.Error = err.Error()
+				return
+			}
+			s.BuildID = res.buildID
+			s.PatchSize = res.patchSize
+			s.Skipped = res.skipped
+		}(i, p)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, s := range summaries {
+		if s.Error != "" {
+			failed = append(failed, s.Channel)
+		}
+	}
+
+	comm.ResultOrPrint(summaries, func() {
+		comm.Logf("")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Channel", "Build", "Patch", "Status"})
+		for _, s := range summaries {
+			var build, patch, status string
+			switch {
+			case s.Error != "":
+				status = "failed"
+			case s.Skipped:
+				status = "no changes"
+			default:
+				build = fmt.Sprintf("%d", s.BuildID)
+				patch = united.FormatBytes(s.PatchSize)
+				status = "processing"
+			}
+			table.Append([]string{s.Target + ":" + s.Channel, build, patch, status})
+		}
+		table.Render()
+		comm.Logf("")
+		comm.Logf("Use `butler status` on any of these for more information.")
+	})
+
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d channels failed to push: %s", len(failed), len(params), strings.Join(failed, ", "))
+	}
+	return nil
+}
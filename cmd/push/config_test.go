@@ -0,0 +1,298 @@
+package push
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T, dir string, name string, contents string) string {
+	configPath := filepath.Join(dir, name)
+	err := ioutil.WriteFile(configPath, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		file     string
+		contents string
+		err      string
+		channels []string
+	}{
+		{
+			name: "toml",
+			file: "push.toml",
+			contents: `
+target = "leafo/x-moon"
+ignore = ["*.pdb"]
+
+[channels.windows]
+dir = "build/windows"
+
+[channels.linux]
+dir = "build/linux"
+`,
+			channels: []string{"linux", "windows"},
+		},
+		{
+			name: "yaml",
+			file: "push.yml",
+			contents: `
+target: leafo/x-moon
+channels:
+  mac:
+    dir: build/mac
+`,
+			channels: []string{"mac"},
+		},
+		{
+			name:     "toml syntax error",
+			file:     "push.toml",
+			contents: "target = ",
+			err:      "parsing",
+		},
+		{
+			name: "unknown toml key",
+			file: "push.toml",
+			contents: `
+taget = "leafo/x-moon"
+
+[channels.windows]
+dir = "build/windows"
+`,
+			err: "unknown key taget",
+		},
+		{
+			name: "unknown yaml key",
+			file: "push.yaml",
+			contents: `
+target: leafo/x-moon
+chanels:
+  mac:
+    dir: build/mac
+`,
+			err: "parsing",
+		},
+		{
+			name: "wrong type",
+			file: "push.toml",
+			contents: `
+target = "leafo/x-moon"
+dereference = "yes"
+
+[channels.windows]
+dir = "build/windows"
+`,
+			err: "parsing",
+		},
+		{
+			name: "malformed ignore pattern",
+			file: "push.toml",
+			contents: `
+target = "leafo/x-moon"
+ignore = ["[*.pdb"]
+
+[channels.windows]
+dir = "build/windows"
+`,
+			err: "invalid pattern",
+		},
+		{
+			name: "absolute ignore pattern in channel",
+			file: "push.yml",
+			contents: `
+target: leafo/x-moon
+channels:
+  mac:
+    dir: build/mac
+    ignore: ["/tmp"]
+`,
+			err: "channel mac",
+		},
+		{
+			name:     "no channels",
+			file:     "push.toml",
+			contents: `target = "leafo/x-moon"`,
+			err:      "doesn't list any channels",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := readConfig(writeTestConfig(t, dir, tc.file, tc.contents))
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			var channels []string
+			for name := range c.Channels {
+				channels = append(channels, name)
+			}
+			assert.ElementsMatch(t, tc.channels, channels)
+		})
+	}
+}
+
+func TestReadConfigMissing(t *testing.T) {
+	_, err := readConfig(filepath.Join(os.TempDir(), "does-not-exist", "push.toml"))
+	assert.Error(t, err)
+}
+
+func TestPickBool(t *testing.T) {
+	yes, no := true, false
+
+	cases := []struct {
+		name      string
+		fallback  bool
+		overrides []*bool
+		expected  bool
+	}{
+		{"flag only", true, nil, true},
+		{"unset overrides", true, []*bool{nil, nil}, true},
+		{"top level wins over flag", true, []*bool{&no, nil}, false},
+		{"channel wins over top level", false, []*bool{&no, &yes}, true},
+		{"channel wins over flag", true, []*bool{nil, &no}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.EqualValues(t, tc.expected, pickBool(tc.fallback, tc.overrides...))
+		})
+	}
+}
+
+func TestChannelParams(t *testing.T) {
+	savedArgs := args
+	defer func() { args = savedArgs }()
+
+	yes, no := true, false
+	baseDir := filepath.Join(os.TempDir(), "project")
+
+	cases := []struct {
+		name        string
+		dereference bool
+		c           *config
+		err         string
+		check       func(t *testing.T, params []*pushParams)
+	}{
+		{
+			name: "inherits from top level",
+			c: &config{
+				Target:      "leafo/x-moon",
+				Ignore:      []string{"*.pdb"},
+				Dereference: &yes,
+				Channels: map[string]*channelConfig{
+					"windows": {Dir: "build/windows", Ignore: []string{"*.log"}},
+					"linux":   {Dir: "/abs/linux", Target: "leafo/other", Dereference: &no},
+				},
+			},
+			check: func(t *testing.T, params []*pushParams) {
+				assert.Len(t, params, 2)
+
+				// sorted by channel name
+				linux, windows := params[0], params[1]
+				assert.EqualValues(t, "linux", linux.label)
+				assert.EqualValues(t, "leafo/other", linux.spec.Target)
+				assert.EqualValues(t, "/abs/linux", linux.buildPath)
+				assert.False(t, linux.dereference)
+				assert.EqualValues(t, []string{"*.pdb"}, linux.ignore)
+
+				assert.EqualValues(t, "windows", windows.label)
+				assert.EqualValues(t, "leafo/x-moon", windows.spec.Target)
+				assert.EqualValues(t, "windows", windows.spec.Channel)
+				assert.EqualValues(t, filepath.Join(baseDir, "build/windows"), windows.buildPath)
+				assert.True(t, windows.dereference)
+				assert.EqualValues(t, []string{"*.pdb", "*.log"}, windows.ignore)
+				assert.EqualValues(t, "1.0", windows.userVersion)
+			},
+		},
+		{
+			name:        "flags apply when config is silent",
+			dereference: true,
+			c: &config{
+				Target: "leafo/x-moon",
+				Channels: map[string]*channelConfig{
+					"windows": {Dir: "build/windows"},
+				},
+			},
+			check: func(t *testing.T, params []*pushParams) {
+				assert.True(t, params[0].dereference)
+			},
+		},
+		{
+			name:        "config wins over flags",
+			dereference: true,
+			c: &config{
+				Target:      "leafo/x-moon",
+				Dereference: &no,
+				Channels: map[string]*channelConfig{
+					"windows": {Dir: "build/windows"},
+				},
+			},
+			check: func(t *testing.T, params []*pushParams) {
+				assert.False(t, params[0].dereference)
+			},
+		},
+		{
+			name: "missing dir",
+			c: &config{
+				Target: "leafo/x-moon",
+				Channels: map[string]*channelConfig{
+					"windows": {},
+				},
+			},
+			err: "channel windows: missing dir",
+		},
+		{
+			name: "missing target",
+			c: &config{
+				Channels: map[string]*channelConfig{
+					"windows": {Dir: "build/windows"},
+				},
+			},
+			err: "channel windows: missing target",
+		},
+		{
+			name: "invalid target",
+			c: &config{
+				Target: "leafo/x-moon:beta",
+				Channels: map[string]*channelConfig{
+					"windows": {Dir: "build/windows"},
+				},
+			},
+			err: "channel windows: invalid spec",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			args.dereference = tc.dereference
+			params, err := tc.c.channelParams(baseDir, "state", "1.0")
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			tc.check(t, params)
+		})
+	}
+}
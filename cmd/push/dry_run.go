@@ -0,0 +1,150 @@
+package push
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/itchio/butler/cmd/probe"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/counter"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/pwr"
+	"github.com/pkg/errors"
+)
+
+// dryRunClient returns a client to look up the latest build with, if
+// credentials were saved. Dry runs don't require logging in, so that
+// they can be used offline to preview what would get pushed.
+func dryRunClient(ctx *mansion.Context) (*itchio.Client, error) {
+	if !ctx.HasSavedCredentials() {
+		return nil, nil
+	}
+	return ctx.AuthenticateViaOauth()
+}
+
+// dryRun diffs the source against the latest build of the channel, and
+// shows what pushing it would cost, without creating a build. If client
+// is nil, or the server can't be reached, it diffs against an empty build.
+func dryRun(ctx *mansion.Context, client *itchio.Client, consumer *state.Consumer, p *pushParams, walk *pendingWalk) error {
+	spec := p.spec
+
+	targetSignature, err := dryRunTarget(ctx, client, consumer, p)
+	if err != nil {
+		return err
+	}
+
+	walkies, err := walk.Wait()
+	if err != nil {
+		return err
+	}
+	walkies.container.Print(func(line string) {
+		comm.Logf(line)
+	})
+	p.statf("Would push %s", walkies.container)
+
+	p.opf("Diffing to find out how large the patch would be...")
+
+	// the patch is kept around so it can be probed
+	patchFile, err := ioutil.TempFile("", "butler-dry-run-*.pwr")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(patchFile.Name())
+	defer patchFile.Close()
+
+	showProgress := p.label == ""
+	stateConsumer := &state.Consumer{}
+	if showProgress {
+		stateConsumer.OnProgress = comm.Progress
+		comm.StartProgress()
+	}
+
+	res, err := dryRunPatch(walkies, targetSignature, stateConsumer, patchFile)
+	if showProgress {
+		comm.EndProgress()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = patchFile.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	res.printStats()
+
+	comm.Logf("")
+	err = probe.Do(ctx, patchFile.Name())
+	if err != nil {
+		return errors.Wrap(err, "analyzing patch")
+	}
+
+	comm.Logf("")
+	p.statf("Dry run, nothing was pushed to %s:%s", spec.Target, spec.Channel)
+	return nil
+}
+
+// dryRunPatch writes the patch from targetSignature to the walked
+// source to patchWriter, and returns what pushing it would cost.
+func dryRunPatch(walkies walkResult, targetSignature *pwr.SignatureInfo, consumer *state.Consumer, patchWriter io.Writer) (*pushResult, error) {
+	patchCounter := counter.NewWriter(patchWriter)
+	signatureCounter := counter.NewWriter(ioutil.Discard)
+
+	dctx := newDiffContext(walkies, targetSignature, consumer)
+	err := dctx.WritePatch(context.Background(), patchCounter, signatureCounter)
+	if err != nil {
+		return nil, errors.Wrap(err, "computing patch")
+	}
+
+	return &pushResult{
+		sourceSize:  walkies.container.Size,
+		patchSize:   patchCounter.Count(),
+		freshBytes:  dctx.FreshBytes,
+		reusedBytes: dctx.ReusedBytes,
+	}, nil
+}
+
+// dryRunTarget returns the signature of the latest build of the channel,
+// or an empty signature if there's none, or it can't be known.
+func dryRunTarget(ctx *mansion.Context, client *itchio.Client, consumer *state.Consumer, p *pushParams) (*pwr.SignatureInfo, error) {
+	spec := p.spec
+
+	if client == nil {
+		p.opf("Dry run: not logged in, comparing against an empty build (patch size is the full size)")
+		return emptySignature(), nil
+	}
+
+	chanInfo, err := client.GetChannel(ctx.DefaultCtx(), spec.Target, spec.Channel)
+	if err != nil {
+		if apiErr, ok := itchio.AsAPIError(err); ok {
+			if apiErr.StatusCode == http.StatusNotFound {
+				p.opf("Dry run: %s:%s has no builds yet, this would be the first one", spec.Target, spec.Channel)
+				return emptySignature(), nil
+			}
+			return nil, errors.Wrapf(err, "looking up channel %s:%s", spec.Target, spec.Channel)
+		}
+
+		// most likely offline, the file list is still useful
+		comm.Warnf("Could not look up channel %s:%s: %s", spec.Target, spec.Channel, err.Error())
+		p.opf("Dry run: comparing against an empty build (patch size is the full size)")
+		return emptySignature(), nil
+	}
+
+	if chanInfo.Channel == nil || chanInfo.Channel.Head == nil {
+		p.opf("Dry run: %s:%s has no builds yet, this would be the first one", spec.Target, spec.Channel)
+		return emptySignature(), nil
+	}
+
+	headID := chanInfo.Channel.Head.ID
+	p.opf("Dry run: comparing against build %d of %s:%s", headID, spec.Target, spec.Channel)
+	targetSignature, err := getSignature(ctx, client, consumer, headID)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting latest build signature")
+	}
+	return targetSignature, nil
+}
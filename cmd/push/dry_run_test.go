@@ -0,0 +1,103 @@
+package push
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/mansion"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/tlc"
+	_ "github.com/itchio/wharf/compressors/cbrotli"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestDryRunPatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dry-run")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	// random data doesn't compress, so the patch size says
+	// how much of it would be uploaded
+	rng := rand.New(rand.NewSource(0xf00d))
+	for _, name := range []string{"game.bin", "data.pak"} {
+		data := make([]byte, 256*1024)
+		rng.Read(data)
+		wtest.Must(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+
+	consumer := &state.Consumer{}
+	walkies, err := startWalk(dir, false, &tlc.WalkOpts{}, nil).Wait()
+	wtest.Must(t, err)
+	defer walkies.pool.Close()
+	size := walkies.container.Size
+
+	// first build of the channel
+	res, err := dryRunPatch(walkies, emptySignature(), consumer, ioutil.Discard)
+	wtest.Must(t, err)
+	assert.EqualValues(t, size, res.sourceSize)
+	assert.EqualValues(t, size, res.freshBytes)
+	assert.EqualValues(t, 0, res.reusedBytes)
+	assert.True(t, res.patchSize > size/2, "patch has all the data")
+
+	// nothing changed since the latest build
+	hashes, err := pwr.ComputeSignature(context.Background(), walkies.container, walkies.pool, consumer)
+	wtest.Must(t, err)
+	latest := &pwr.SignatureInfo{Container: walkies.container, Hashes: hashes}
+
+	res, err = dryRunPatch(walkies, latest, consumer, ioutil.Discard)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 0, res.freshBytes)
+	assert.EqualValues(t, size, res.reusedBytes)
+	assert.True(t, res.patchSize < 4*1024, "patch only has references to the latest build")
+}
+
+func TestDryRunTarget(t *testing.T) {
+	ctx := mansion.NewContext(kingpin.New("butler", "test"))
+	p := &pushParams{spec: &itchio.Spec{Target: "amos/garden", Channel: "linux"}}
+	consumer := &state.Consumer{}
+
+	var status int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.EqualValues(t, "/wharf/channels/linux", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := itchio.ClientWithKey("key").SetServer(server.URL)
+
+	isEmpty := func(sig *pwr.SignatureInfo) bool {
+		return len(sig.Container.Files) == 0 && len(sig.Hashes) == 0
+	}
+
+	// not logged in
+	sig, err := dryRunTarget(ctx, nil, consumer, p)
+	wtest.Must(t, err)
+	assert.True(t, isEmpty(sig))
+
+	status, body = http.StatusNotFound, `{"errors":["channel not found"]}`
+	sig, err = dryRunTarget(ctx, client, consumer, p)
+	wtest.Must(t, err)
+	assert.True(t, isEmpty(sig))
+
+	status, body = http.StatusOK, `{"channel":{"name":"linux"}}`
+	sig, err = dryRunTarget(ctx, client, consumer, p)
+	wtest.Must(t, err)
+	assert.True(t, isEmpty(sig))
+
+	// not ours to push to
+	status, body = http.StatusForbidden, `{"errors":["invalid game"]}`
+	_, err = dryRunTarget(ctx, client, consumer, p)
+	assert.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,12 +20,11 @@ import (
 	"github.com/itchio/butler/mansion"
 
 	"github.com/itchio/headway/counter"
-	"github.com/itchio/headway/united"
 	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/united"
 
 	"github.com/itchio/savior/seeksource"
 
-	"github.com/itchio/lake"
 	"github.com/itchio/lake/tlc"
 
 	"github.com/itchio/wharf/pwr"
@@ -49,6 +49,8 @@ var singleFileWarningExtensionList = []string{
 var args = struct {
 	src             string
 	target          string
+	config          string
+	stateDir        string
 	userVersion     string
 	userVersionFile string
 	fixPerms        bool
@@ -60,14 +62,16 @@ var args = struct {
 
 func Register(ctx *mansion.Context) {
 	cmd := ctx.App.Command("push", "Upload a new build to itch.io. See `butler help push`.")
-	cmd.Arg("src", "Directory to upload. May also be a zip archive (slower)").Required().StringVar(&args.src)
-	cmd.Arg("target", "Where to push, for example 'leafo/x-moon:win-64'. Targets are of the form project:channel, where project is username/game or game_id.").Required().StringVar(&args.target)
+	cmd.Arg("src", "Directory to upload. May also be a zip archive (slower)").StringVar(&args.src)
+	cmd.Arg("target", "Where to push, for example 'leafo/x-moon:win-64'. Targets are of the form project:channel, where project is username/game or game_id.").StringVar(&args.target)
+	cmd.Flag("config", "A TOML or YAML file listing several channels to push at once, instead of src and target").StringVar(&args.config)
 	cmd.Flag("userversion", "A user-supplied version number that you can later query builds by").StringVar(&args.userVersion)
 	cmd.Flag("userversion-file", "A file containing a user-supplied version number that you can later query builds by").StringVar(&args.userVersionFile)
 	cmd.Flag("fix-permissions", "Detect Mac & Linux executables and adjust their permissions automatically").Default("true").BoolVar(&args.fixPerms)
 	cmd.Flag("dereference", "Dereference symlinks").Default("false").BoolVar(&args.dereference)
 	cmd.Flag("if-changed", "Don't push anything if it would be an empty patch").Default("false").BoolVar(&args.ifChanged)
 	cmd.Flag("dry-run", "Don't push anything, just show what would be pushed").Default("false").BoolVar(&args.dryRun)
+	cmd.Flag("state-dir", "Where to save push progress, so an interrupted push can be resumed. Defaults to a .butler-push folder next to src").StringVar(&args.stateDir)
 	cmd.Flag("auto-wrap", "Apply workaround for https://github.com/itchio/itch/issues/2147").Default("true").BoolVar(&args.autoWrap)
 	ctx.Register(cmd, do)
 }
@@ -75,53 +79,122 @@ func Register(ctx *mansion.Context) {
 func do(ctx *mansion.Context) {
 	go ctx.DoVersionCheck()
 
+	if args.config != "" {
+		if args.src != "" || args.target != "" {
+			ctx.Must(errors.New("--config can't be used with src and target arguments"))
+		}
+		ctx.Must(DoConfig(ctx, args.config))
+		return
+	}
+
+	if args.src == "" || args.target == "" {
+		ctx.Must(errors.New("push needs both src and target arguments (or --config)"))
+	}
+
 	// if userVersionFile specified, read from the given file
-	// TODO: do utf-16 decoding here
 	userVersion := args.userVersion
 	if userVersion == "" && args.userVersionFile != "" {
-		buf, err := ioutil.ReadFile(args.userVersionFile)
+		var err error
+		userVersion, err = readUserVersionFile(args.userVersionFile)
 		ctx.Must(err)
-
-		userVersion = strings.TrimSpace(string(buf))
-		if strings.ContainsAny(userVersion, "\r\n") {
-			ctx.Must(fmt.Errorf("%s contains line breaks, refusing to use as userversion", args.userVersionFile))
-		}
 	}
 
 	ctx.Must(Do(ctx, args.src, args.target, userVersion, args.fixPerms, args.dereference, args.ifChanged, args.autoWrap))
 }
 
-func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion string, fixPerms bool, dereference bool, ifChanged bool, wrap bool) error {
-	consumer := comm.NewStateConsumer()
+// TODO: do utf-16 decoding here
+func readUserVersionFile(path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	userVersion := strings.TrimSpace(string(buf))
+	if strings.ContainsAny(userVersion, "\r\n") {
+		return "", fmt.Errorf("%s contains line breaks, refusing to use as userversion", path)
+	}
+	return userVersion, nil
+}
+
+// pushParams describes a single build to push
+type pushParams struct {
+	buildPath   string
+	spec        *itchio.Spec
+	userVersion string
+	fixPerms    bool
+	dereference bool
+	ifChanged   bool
+	wrap        bool
+	ignore      []string
+	// stateDir is where push progress is saved, see statePath
+	stateDir string
+
+	// label is set when several channels are pushed at once:
+	// it prefixes all messages, and disables the progress bar.
+	label string
+}
+
+// pushResult describes what happened to a single pushed build
+type pushResult struct {
+	buildID     int64
+	skipped     bool
+	sourceSize  int64
+	patchSize   int64
+	freshBytes  int64
+	reusedBytes int64
+}
+
+func (p *pushParams) opf(format string, args ...interface{}) {
+	if p.label != "" {
+		format = "[" + p.label + "] " + format
+	}
+	comm.Opf(format, args...)
+}
+
+func (p *pushParams) statf(format string, args ...interface{}) {
+	if p.label != "" {
+		format = "[" + p.label + "] " + format
+	}
+	comm.Statf(format, args...)
+}
+
+// startWalk starts walking the build folder in the background
+func (p *pushParams) startWalk(consumer *state.Consumer) *pendingWalk {
+	// the state folder may be inside the build folder, for example
+	// when pushing "." from a config file: it must never be pushed.
+	ignore := []string{stateDirName}
+	if p.stateDir != "" && isInsideDir(p.stateDir, p.buildPath) {
+		ignore = append(ignore, filepath.Base(p.stateDir))
+	}
+	ignore = append(ignore, p.ignore...)
 
-	// start walking source container while waiting on auth flow
-	sourceContainerChan := make(chan walkResult)
-	walkErrs := make(chan error)
 	walkOpts := &tlc.WalkOpts{
-		Filter:      filtering.FilterPaths,
-		Dereference: dereference,
+		Filter:      filtering.FilterPathsWith(ignore),
+		Dereference: p.dereference,
 	}
-	if wrap {
-		walkOpts.AutoWrap(&buildPath, consumer)
+	if p.wrap {
+		walkOpts.AutoWrap(&p.buildPath, consumer)
 	}
 
-	go doWalk(buildPath, sourceContainerChan, walkErrs, fixPerms, walkOpts)
+	return startWalk(p.buildPath, p.fixPerms, walkOpts, ignore)
+}
 
-	if args.dryRun {
-		comm.Opf("Dry run, listing files we would push...")
-		select {
-		case walkErr := <-walkErrs:
-			return errors.Wrap(walkErr, "walking directory to push")
-		case walkies := <-sourceContainerChan:
-			log := func(line string) {
-				comm.Logf(line)
-			}
-			walkies.container.Print(log)
-			comm.Statf("Would push %s", walkies.container)
-		}
-		return nil
+func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion string, fixPerms bool, dereference bool, ifChanged bool, wrap bool) error {
+	consumer := comm.NewStateConsumer()
+
+	p := &pushParams{
+		buildPath:   buildPath,
+		userVersion: userVersion,
+		fixPerms:    fixPerms,
+		dereference: dereference,
+		ifChanged:   ifChanged,
+		wrap:        wrap,
+		stateDir:    args.stateDir,
 	}
 
+	// start walking source container while waiting on auth flow
+	walk := p.startWalk(consumer)
+
 	spec, err := itchio.ParseSpec(specStr)
 	if err != nil {
 		return errors.Wrapf(err, "parsing push target '%s'", specStr)
@@ -131,116 +204,165 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 	if err != nil {
 		return err
 	}
+	p.spec = spec
+
+	if args.dryRun {
+		client, err := dryRunClient(ctx)
+		if err != nil {
+			return errors.Wrap(err, "authenticating")
+		}
+		return dryRun(ctx, client, consumer, p, walk)
+	}
 
 	client, err := ctx.AuthenticateViaOauth()
 	if err != nil {
 		return errors.Wrap(err, "authenticating")
 	}
 
-	getSignature := func(ID int64) (*pwr.SignatureInfo, error) {
-		buildFiles, err := client.ListBuildFiles(ctx.DefaultCtx(), ID)
-		if err != nil {
-			return nil, errors.Wrap(err, "listing build files")
-		}
-
-		signatureFile := itchio.FindBuildFile(itchio.BuildFileTypeSignature, buildFiles.Files)
-		if signatureFile == nil {
-			comm.Dief("Could not find signature for parent build %d, aborting", ID)
-		}
-
-		signatureURL := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
-			BuildID: ID,
-			FileID:  signatureFile.ID,
-		})
-
-		signatureReader, err := eos.Open(signatureURL, option.WithConsumer(consumer))
-		if err != nil {
-			return nil, errors.Wrap(err, "opening signature")
-		}
-		defer signatureReader.Close()
+	res, err := pushBuild(ctx, client, consumer, p, walk)
+	if err != nil {
+		return err
+	}
 
-		signatureSource := seeksource.FromFile(signatureReader)
+	if res.skipped {
+		return nil
+	}
 
-		_, err = signatureSource.Resume(nil)
-		if err != nil {
-			return nil, errors.Wrap(err, "opening signature")
-		}
+	res.printStats()
+	comm.Opf("Build is now processing, should be up in a bit.")
+	comm.Logf("")
+	comm.Logf("Use the `butler status %s` for more information.", specStr)
+	comm.Logf("")
 
-		signature, err := pwr.ReadSignature(context.Background(), signatureSource)
-		if err != nil {
-			return nil, errors.Wrap(err, "reading signature")
-		}
+	return nil
+}
 
-		return signature, nil
-	}
+// pushBuild creates a build on the server, diffs the walked folder against the
+// previous build and uploads the resulting patch and signature.
+func pushBuild(ctx *mansion.Context, client *itchio.Client, consumer *state.Consumer, p *pushParams, walk *pendingWalk) (*pushResult, error) {
+	spec := p.spec
+	showProgress := p.label == ""
 
-	if ifChanged {
+	if p.ifChanged {
 		chanInfo, err := client.GetChannel(ctx.DefaultCtx(), spec.Target, spec.Channel)
 		if err == nil && chanInfo != nil && chanInfo.Channel != nil && chanInfo.Channel.Head != nil {
-			comm.Opf("Comparing against previous build...")
-			sig, err := getSignature(chanInfo.Channel.Head.ID)
+			p.opf("Comparing against previous build...")
+			sig, err := getSignature(ctx, client, consumer, chanInfo.Channel.Head.ID)
 			if err != nil {
-				return errors.Wrap(err, "getting previous build signature")
+				return nil, errors.Wrap(err, "getting previous build signature")
 			}
 
-			err = pwr.AssertValid(buildPath, sig)
+			err = pwr.AssertValid(p.buildPath, sig)
 			if err == nil {
-				comm.Statf("No changes and --if-changed used, not pushing anything")
-				return nil
+				p.statf("No changes and --if-changed used, not pushing anything")
+				return &pushResult{skipped: true}, nil
 			}
 
 			if _, ok := err.(*pwr.ErrHasWound); ok {
 				// cool, that's what we expected
 			} else {
-				return errors.Wrap(err, "checking for differences")
+				return nil, errors.Wrap(err, "checking for differences")
 			}
 		} else {
-			comm.Opf("No previous build to compare against, pushing unconditionally")
+			p.opf("No previous build to compare against, pushing unconditionally")
 		}
 	}
 
-	newBuildRes, err := client.CreateBuild(ctx.DefaultCtx(), itchio.CreateBuildParams{
-		Target:      spec.Target,
-		Channel:     spec.Channel,
-		UserVersion: userVersion,
-	})
+	statePath := p.statePath()
+	var st *pushState
+	var walkies walkResult
+	walked := false
+
+	prevState, err := readPushState(statePath)
 	if err != nil {
-		return errors.Wrap(err, "creating build on remote server")
-	}
+		comm.Warnf("Ignoring saved push state: %s", err.Error())
+	} else if prevState != nil && prevState.Target == spec.Target && prevState.Channel == spec.Channel {
+		// we need to know what we're pushing to decide whether we can resume
+		walkies, err = walk.Wait()
+		if err != nil {
+			return nil, err
+		}
+		walked = true
 
-	buildID := newBuildRes.Build.ID
-	parentID := newBuildRes.Build.ParentBuild.ID
+		if prevState.Fingerprint == p.fingerprint(walkies.container, prevState.ParentID) {
+			p.opf("Resuming interrupted push of build %d", prevState.BuildID)
+			st = prevState
+		} else {
+			p.opf("Files changed since the interrupted push of build %d, starting over", prevState.BuildID)
+		}
+	}
 
-	var targetSignature *pwr.SignatureInfo
+	var patchWriter, signatureWriter uploadWriter
 
-	if parentID == 0 {
-		comm.Opf("For channel `%s`: pushing first build", spec.Channel)
-		targetSignature = &pwr.SignatureInfo{
-			Container: &tlc.Container{},
-			Hashes:    make([]wsync.BlockHash, 0),
+	if st != nil {
+		patchUpload, err := newResumedUpload(st.PatchUploadURL, consumer)
+		if err == nil {
+			var signatureUpload *resumedUpload
+			signatureUpload, err = newResumedUpload(st.SignatureUploadURL, consumer)
+			if err == nil {
+				comm.Debugf("Patch has %d bytes uploaded, signature has %d", patchUpload.committed, signatureUpload.committed)
+				patchWriter = patchUpload
+				signatureWriter = signatureUpload
+			}
 		}
-	} else {
-		comm.Opf("For channel `%s`: last build is %d, downloading its signature", spec.Channel, parentID)
-		var err error
-		targetSignature, err = getSignature(parentID)
+
 		if err != nil {
-			return errors.Wrap(err, "searching for parent build signature")
+			p.opf("Can't resume build %d (%s), starting over", st.BuildID, err.Error())
+			st = nil
 		}
 	}
 
-	bothFiles, err := createBothFiles(ctx, client, buildID)
-	if err != nil {
-		return errors.Wrap(err, "creating remote patch and signature files")
+	if st == nil {
+		newBuildRes, err := client.CreateBuild(ctx.DefaultCtx(), itchio.CreateBuildParams{
+			Target:      spec.Target,
+			Channel:     spec.Channel,
+			UserVersion: p.userVersion,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "creating build on remote server")
+		}
+
+		bothFiles, err := createBothFiles(ctx, client, newBuildRes.Build.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating remote patch and signature files")
+		}
+
+		st = &pushState{
+			Target:             spec.Target,
+			Channel:            spec.Channel,
+			BuildID:            newBuildRes.Build.ID,
+			ParentID:           newBuildRes.Build.ParentBuild.ID,
+			PatchFileID:        bothFiles.patchRes.File.ID,
+			PatchUploadURL:     bothFiles.patchRes.File.UploadURL,
+			SignatureFileID:    bothFiles.signatureRes.File.ID,
+			SignatureUploadURL: bothFiles.signatureRes.File.UploadURL,
+		}
+
+		freshPatchWriter := uploader.NewResumableUpload(st.PatchUploadURL)
+		freshPatchWriter.SetConsumer(consumer)
+		patchWriter = freshPatchWriter
+
+		freshSignatureWriter := uploader.NewResumableUpload(st.SignatureUploadURL)
+		freshSignatureWriter.SetConsumer(consumer)
+		signatureWriter = freshSignatureWriter
 	}
 
-	newPatchRes := bothFiles.patchRes
-	newSignatureRes := bothFiles.signatureRes
+	buildID := st.BuildID
+	parentID := st.ParentID
 
-	patchWriter := uploader.NewResumableUpload(newPatchRes.File.UploadURL)
-	patchWriter.SetConsumer(consumer)
+	var targetSignature *pwr.SignatureInfo
 
-	signatureWriter := uploader.NewResumableUpload(newSignatureRes.File.UploadURL)
-	signatureWriter.SetConsumer(consumer)
+	if parentID == 0 {
+		p.opf("For channel `%s`: pushing first build", spec.Channel)
+		targetSignature = emptySignature()
+	} else {
+		p.opf("For channel `%s`: last build is %d, downloading its signature", spec.Channel, parentID)
+		var err error
+		targetSignature, err = getSignature(ctx, client, consumer, parentID)
+		if err != nil {
+			return nil, errors.Wrap(err, "searching for parent build signature")
+		}
+	}
 
 	comm.Debugf("Launching patch & signature channels")
 
@@ -252,22 +374,26 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 	// note that we could actually start diffing before all the file
 	// creation & upload setup is done
 
-	var sourceContainer *tlc.Container
-	var sourcePool lake.Pool
+	if !walked {
+		comm.Debugf("Waiting for source container")
+		walkies, err = walk.Wait()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sourceContainer := walkies.container
 
-	comm.Debugf("Waiting for source container")
-	select {
-	case walkErr := <-walkErrs:
-		return errors.Wrap(walkErr, "walking directory to push")
-	case walkies := <-sourceContainerChan:
-		sourceContainer = walkies.container
-		sourcePool = walkies.pool
-		break
+	if st.Fingerprint == "" {
+		st.Fingerprint = p.fingerprint(sourceContainer, parentID)
+		err = st.save(statePath)
+		if err != nil {
+			comm.Warnf("Could not save push state, this push won't be resumable: %s", err.Error())
+		}
 	}
 
 	showSingleFileWarningIfNecessary(sourceContainer)
 
-	comm.Opf("Pushing %s", sourceContainer)
+	p.opf("Pushing %s", sourceContainer)
 
 	comm.Debugf("Building diff context")
 	var readBytes int64
@@ -277,7 +403,12 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 	var patchUploadedBytes int64
 
 	stopTicking := make(chan struct{})
+	tickerDone := make(chan struct{})
 	updateProgress := func() {
+		if !showProgress {
+			return
+		}
+
 		// input bytes that aren't in output, for example:
 		//  - bytes that have been compressed away
 		//  - bytes that were in old build and were simply reused
@@ -305,17 +436,29 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 
 	patchWriter.SetProgressListener(func(count int64) {
 		patchUploadedBytes = count
+		st.setPatchOffset(count)
 		updateProgress()
 	})
+	signatureWriter.SetProgressListener(func(count int64) {
+		st.setSignatureOffset(count)
+	})
 
 	go func() {
+		defer close(tickerDone)
 		ticker := time.NewTicker(time.Second * time.Duration(2))
+		defer ticker.Stop()
+		warnedSave := false
 		for {
 			select {
 			case <-ticker.C:
 				bytesPerSec = float64(patchUploadedBytes-lastUploadedBytes) / 2.0
 				lastUploadedBytes = patchUploadedBytes
 				updateProgress()
+				err := st.save(statePath)
+				if err != nil && !warnedSave {
+					comm.Warnf("Could not save push state: %s", err.Error())
+					warnedSave = true
+				}
 			case <-stopTicking:
 				return
 			}
@@ -329,31 +472,21 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 		},
 	}
 
-	dctx := &pwr.DiffContext{
-		Compression: &pwr.CompressionSettings{
-			Algorithm: pwr.CompressionAlgorithm_BROTLI,
-			Quality:   1,
-		},
+	dctx := newDiffContext(walkies, targetSignature, stateConsumer)
 
-		SourceContainer: sourceContainer,
-		Pool:            sourcePool,
-
-		TargetContainer: targetSignature.Container,
-		TargetSignature: targetSignature.Hashes,
-
-		Consumer: stateConsumer,
+	if showProgress {
+		comm.StartProgress()
+		comm.ProgressScale(0.0)
 	}
-
-	comm.StartProgress()
-	comm.ProgressScale(0.0)
 	err = dctx.WritePatch(context.Background(), patchCounter, signatureCounter)
 	if err != nil {
-		return errors.Wrap(err, "computing and writing patch")
+		close(stopTicking)
+		return nil, errors.Wrap(err, "computing and writing patch")
 	}
 
 	// close both files concurrently
 	{
-		errs := make(chan error)
+		errs := make(chan error, 2)
 
 		go func() {
 			errs <- patchWriter.Close()
@@ -366,20 +499,23 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 		for i := 0; i < 2; i++ {
 			err := <-errs
 			if err != nil {
-				return errors.WithStack(err)
+				close(stopTicking)
+				return nil, errors.WithStack(err)
 			}
 		}
 	}
 
 	close(stopTicking)
-	comm.ProgressLabel("finalizing build")
+	if showProgress {
+		comm.ProgressLabel("finalizing build")
+	}
 
 	// finalize both files concurrently
 	{
-		errs := make(chan error)
+		errs := make(chan error, 2)
 
 		doFinalize := func(fileID int64, fileSize int64, done chan error) {
-			_, err = client.FinalizeBuildFile(ctx.DefaultCtx(), itchio.FinalizeBuildFileParams{
+			_, err := client.FinalizeBuildFile(ctx.DefaultCtx(), itchio.FinalizeBuildFileParams{
 				BuildID: buildID,
 				FileID:  fileID,
 				Size:    fileSize,
@@ -387,45 +523,120 @@ func Do(ctx *mansion.Context, buildPath string, specStr string, userVersion stri
 			done <- err
 		}
 
-		go doFinalize(newPatchRes.File.ID, patchCounter.Count(), errs)
-		go doFinalize(newSignatureRes.File.ID, signatureCounter.Count(), errs)
+		go doFinalize(st.PatchFileID, patchCounter.Count(), errs)
+		go doFinalize(st.SignatureFileID, signatureCounter.Count(), errs)
 
 		// 2 doFinalize
 		for i := 0; i < 2; i++ {
 			err := <-errs
 			if err != nil {
-				return errors.WithStack(err)
+				return nil, errors.WithStack(err)
 			}
 		}
 	}
 
-	comm.EndProgress()
+	if showProgress {
+		comm.EndProgress()
+	}
 
-	{
-		prettyPatchSize := united.FormatBytes(patchCounter.Count())
-		percReused := 100.0 * float64(dctx.ReusedBytes) / float64(dctx.FreshBytes+dctx.ReusedBytes)
-		relToNew := 100.0 * float64(patchCounter.Count()) / float64(sourceContainer.Size)
-		prettyFreshSize := united.FormatBytes(dctx.FreshBytes)
-		savings := 100.0 - relToNew
-
-		if dctx.ReusedBytes > 0 {
-			comm.Statf("Re-used %.2f%% of old, added %s fresh data", percReused, prettyFreshSize)
-		} else {
-			comm.Statf("Added %s fresh data", prettyFreshSize)
-		}
+	<-tickerDone
+	err = removePushState(statePath)
+	if err != nil {
+		comm.Warnf("Could not remove push state: %s", err.Error())
+	}
 
-		if savings > 0 && !math.IsNaN(savings) {
-			comm.Statf("%s patch (%.2f%% savings)", prettyPatchSize, 100.0-relToNew)
-		} else {
-			comm.Statf("%s patch (no savings)", prettyPatchSize)
-		}
+	return &pushResult{
+		buildID:     buildID,
+		sourceSize:  sourceContainer.Size,
+		patchSize:   patchCounter.Count(),
+		freshBytes:  dctx.FreshBytes,
+		reusedBytes: dctx.ReusedBytes,
+	}, nil
+}
+
+// getSignature downloads and parses the signature of a build
+func getSignature(ctx *mansion.Context, client *itchio.Client, consumer *state.Consumer, ID int64) (*pwr.SignatureInfo, error) {
+	buildFiles, err := client.ListBuildFiles(ctx.DefaultCtx(), ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing build files")
 	}
-	comm.Opf("Build is now processing, should be up in a bit.")
-	comm.Logf("")
-	comm.Logf("Use the `butler status %s` for more information.", specStr)
-	comm.Logf("")
 
-	return nil
+	signatureFile := itchio.FindBuildFile(itchio.BuildFileTypeSignature, buildFiles.Files)
+	if signatureFile == nil {
+		return nil, errors.Errorf("Could not find signature for parent build %d, aborting", ID)
+	}
+
+	signatureURL := client.MakeBuildFileDownloadURL(itchio.MakeBuildFileDownloadURLParams{
+		BuildID: ID,
+		FileID:  signatureFile.ID,
+	})
+
+	signatureReader, err := eos.Open(signatureURL, option.WithConsumer(consumer))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening signature")
+	}
+	defer signatureReader.Close()
+
+	signatureSource := seeksource.FromFile(signatureReader)
+
+	_, err = signatureSource.Resume(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening signature")
+	}
+
+	signature, err := pwr.ReadSignature(context.Background(), signatureSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signature")
+	}
+
+	return signature, nil
+}
+
+// emptySignature is what we diff against when pushing
+// the first build of a channel
+func emptySignature() *pwr.SignatureInfo {
+	return &pwr.SignatureInfo{
+		Container: &tlc.Container{},
+		Hashes:    make([]wsync.BlockHash, 0),
+	}
+}
+
+func newDiffContext(walkies walkResult, targetSignature *pwr.SignatureInfo, consumer *state.Consumer) *pwr.DiffContext {
+	return &pwr.DiffContext{
+		Compression: &pwr.CompressionSettings{
+			Algorithm: pwr.CompressionAlgorithm_BROTLI,
+			Quality:   1,
+		},
+
+		SourceContainer: walkies.container,
+		Pool:            walkies.pool,
+
+		TargetContainer: targetSignature.Container,
+		TargetSignature: targetSignature.Hashes,
+
+		Consumer: consumer,
+	}
+}
+
+// printStats shows how much of the previous build was reused
+func (res *pushResult) printStats() {
+	prettyPatchSize := united.FormatBytes(res.patchSize)
+	percReused := 100.0 * float64(res.reusedBytes) / float64(res.freshBytes+res.reusedBytes)
+	relToNew := 100.0 * float64(res.patchSize) / float64(res.sourceSize)
+	prettyFreshSize := united.FormatBytes(res.freshBytes)
+	savings := 100.0 - relToNew
+
+	if res.reusedBytes > 0 {
+		comm.Statf("Re-used %.2f%% of old, added %s fresh data", percReused, prettyFreshSize)
+	} else {
+		comm.Statf("Added %s fresh data", prettyFreshSize)
+	}
+
+	if savings > 0 && !math.IsNaN(savings) {
+		comm.Statf("%s patch (%.2f%% savings)", prettyPatchSize, 100.0-relToNew)
+	} else {
+		comm.Statf("%s patch (no savings)", prettyPatchSize)
+	}
 }
 
 func min(a, b float64) float64 {
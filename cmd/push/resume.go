@@ -0,0 +1,194 @@
+package push
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dchest/safefile"
+	"github.com/itchio/lake/tlc"
+	"github.com/pkg/errors"
+)
+
+// stateDirName is the sidecar folder where push progress is
+// saved, unless --state-dir is specified.
+const stateDirName = ".butler-push"
+
+// pushState is saved while pushing a build, so that if butler
+// is interrupted, running the same push again resumes it instead
+// of creating another build.
+type pushState struct {
+	Target  string `json:"target"`
+	Channel string `json:"channel"`
+
+	// Identifies the source files and push settings: if it changes,
+	// the patch would be different and we can't resume.
+	Fingerprint string `json:"fingerprint"`
+
+	BuildID  int64 `json:"buildId"`
+	ParentID int64 `json:"parentId"`
+
+	PatchFileID        int64  `json:"patchFileId"`
+	PatchUploadURL     string `json:"patchUploadUrl"`
+	SignatureFileID    int64  `json:"signatureFileId"`
+	SignatureUploadURL string `json:"signatureUploadUrl"`
+
+	// Bytes of the patch and signature streamed so far. These
+	// are informative, the server is asked what it has on resume.
+	PatchOffset     int64 `json:"patchOffset"`
+	SignatureOffset int64 `json:"signatureOffset"`
+
+	// offsets are updated by upload progress listeners while
+	// the state is periodically saved
+	mu sync.Mutex
+}
+
+// statePath returns where the state for this push is saved
+func (p *pushParams) statePath() string {
+	stateDir := p.stateDir
+	if stateDir == "" {
+		absPath, err := filepath.Abs(p.buildPath)
+		if err != nil {
+			absPath = p.buildPath
+		}
+		stateDir = filepath.Join(filepath.Dir(absPath), stateDirName)
+	}
+
+	target := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(p.spec.Target)
+	return filepath.Join(stateDir, fmt.Sprintf("%s_%s.json", target, p.spec.Channel))
+}
+
+// readPushState returns a nil state if there is no push to resume
+func readPushState(statePath string) (*pushState, error) {
+	buf, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	st := &pushState{}
+	err = json.Unmarshal(buf, st)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", statePath)
+	}
+	return st, nil
+}
+
+func (st *pushState) setPatchOffset(offset int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.PatchOffset = offset
+}
+
+func (st *pushState) setSignatureOffset(offset int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.SignatureOffset = offset
+}
+
+func (st *pushState) save(statePath string) error {
+	err := os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	st.mu.Lock()
+	buf, err := json.MarshalIndent(st, "", "  ")
+	st.mu.Unlock()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := safefile.Create(statePath, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(buf)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(f.Commit())
+}
+
+// removePushState forgets about a push, and removes the
+// state folder if it's empty
+func removePushState(statePath string) error {
+	err := os.Remove(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	// only succeeds if it's empty, which is what we want
+	os.Remove(filepath.Dir(statePath))
+	return nil
+}
+
+// fingerprint hashes everything that influences the contents of the
+// patch and signature: the build, the version, and the source files,
+// including their modification times.
+func (p *pushParams) fingerprint(container *tlc.Container, parentID int64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%s\n", p.spec.Target, p.spec.Channel)
+	fmt.Fprintf(h, "userversion %q\n", p.userVersion)
+	fmt.Fprintf(h, "parent %d\n", parentID)
+
+	stat := func(path string) {
+		stats, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(h, "  (missing)\n")
+			return
+		}
+		fmt.Fprintf(h, "  %d %d\n", stats.Size(), stats.ModTime().UnixNano())
+	}
+
+	stats, err := os.Stat(p.buildPath)
+	isDir := err == nil && stats.IsDir()
+	if !isDir {
+		// archives are walked without extracting, so the archive
+		// itself is what we check
+		stat(p.buildPath)
+	}
+
+	for _, d := range container.Dirs {
+		fmt.Fprintf(h, "dir %s %o\n", d.Path, d.Mode)
+	}
+	for _, f := range container.Files {
+		fmt.Fprintf(h, "file %s %o %d\n", f.Path, f.Mode, f.Size)
+		if isDir {
+			stat(filepath.Join(p.buildPath, filepath.FromSlash(f.Path)))
+		}
+	}
+	for _, s := range container.Symlinks {
+		fmt.Fprintf(h, "symlink %s %o %s\n", s.Path, s.Mode, s.Dest)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// isInsideDir returns true if path is dir or one of its descendants
+func isInsideDir(path string, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
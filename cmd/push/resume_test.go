@@ -0,0 +1,57 @@
+package push
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/comm"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateDirNotPushed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "game.exe"), []byte("MZ"), 0755)
+	assert.NoError(t, err)
+
+	// like a config file next to the build, with dir = "."
+	p := &pushParams{
+		buildPath: dir,
+		spec:      &itchio.Spec{Target: "leafo/x-moon", Channel: "windows"},
+		stateDir:  filepath.Join(dir, stateDirName),
+	}
+
+	walk := func() string {
+		walkies, err := p.startWalk(comm.NewStateConsumer()).Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range walkies.container.Files {
+			assert.EqualValues(t, "game.exe", f.Path)
+		}
+		return p.fingerprint(walkies.container, 0)
+	}
+
+	before := walk()
+
+	st := &pushState{Target: "leafo/x-moon", Channel: "windows", PatchOffset: 1024}
+	assert.NoError(t, st.save(p.statePath()))
+
+	assert.EqualValues(t, before, walk(), "saving state must not change the fingerprint")
+}
+
+func TestIsInsideDir(t *testing.T) {
+	assert.True(t, isInsideDir("build/.butler-push", "build"))
+	assert.True(t, isInsideDir("build", "build"))
+	assert.True(t, isInsideDir(".butler-push", "."))
+	assert.False(t, isInsideDir(".butler-push", "build"))
+	assert.False(t, isInsideDir("../state", "."))
+	assert.True(t, isInsideDir("..state", "."))
+}
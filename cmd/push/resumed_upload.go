@@ -0,0 +1,267 @@
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/httpkit/retrycontext"
+	"github.com/itchio/httpkit/timeout"
+	"github.com/itchio/httpkit/uploader"
+	"github.com/pkg/errors"
+)
+
+const (
+	// non-last chunks sent to GCS must be a multiple of this
+	gcsChunkSize = 256 * 1024
+	// how many chunks to send in a single request
+	resumedChunkGroup = 64
+)
+
+// errUploadGone is returned when an upload session can't be resumed,
+// either because it expired or because it was cancelled.
+var errUploadGone = errors.New("upload session is gone")
+
+// uploadWriter is implemented by both fresh and resumed uploads
+type uploadWriter interface {
+	Write(buf []byte) (int, error)
+	Close() error
+	SetProgressListener(progressListener uploader.ProgressListenerFunc)
+}
+
+// resumedUpload continues a GCS resumable upload session started by a
+// previous butler process. httpkit's uploader always starts from byte 0,
+// so this drops whatever the server already has and uploads the rest.
+//
+// It's only correct if the data written to it is exactly the same as the
+// data written during the previous attempt.
+type resumedUpload struct {
+	uploadURL        string
+	httpClient       *http.Client
+	consumer         *state.Consumer
+	progressListener uploader.ProgressListenerFunc
+
+	// bytes the server had stored when we started
+	committed int64
+	// the server already has the whole file
+	complete bool
+
+	// bytes passed to Write so far
+	received int64
+	// server offset of the first byte in buf
+	offset int64
+	buf    bytes.Buffer
+}
+
+var _ uploadWriter = (*resumedUpload)(nil)
+
+func newUploadHTTPClient() *http.Client {
+	return timeout.NewDefaultClient()
+}
+
+// newResumedUpload asks the server how much of an upload it already has.
+func newResumedUpload(uploadURL string, consumer *state.Consumer) (*resumedUpload, error) {
+	ru := &resumedUpload{
+		uploadURL:  uploadURL,
+		httpClient: newUploadHTTPClient(),
+		consumer:   consumer,
+	}
+
+	committed, complete, err := ru.queryStatus()
+	if err != nil {
+		return nil, err
+	}
+	ru.committed = committed
+	ru.complete = complete
+	ru.offset = committed
+	return ru, nil
+}
+
+func (ru *resumedUpload) SetProgressListener(progressListener uploader.ProgressListenerFunc) {
+	ru.progressListener = progressListener
+}
+
+// Write implements io.Writer.
+func (ru *resumedUpload) Write(data []byte) (int, error) {
+	n := len(data)
+
+	if ru.complete {
+		ru.received += int64(n)
+		return n, nil
+	}
+
+	// skip over what the server already has
+	if skip := ru.committed - ru.received; skip > 0 {
+		if skip > int64(len(data)) {
+			skip = int64(len(data))
+		}
+		data = data[skip:]
+		ru.received += skip
+	}
+
+	ru.buf.Write(data)
+	ru.received += int64(len(data))
+
+	groupSize := gcsChunkSize * resumedChunkGroup
+	for ru.buf.Len() >= groupSize {
+		err := ru.put(ru.buf.Next(groupSize), false)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// Close implements io.Closer.
+func (ru *resumedUpload) Close() error {
+	if ru.complete {
+		return nil
+	}
+	if ru.received < ru.committed {
+		return errors.Errorf("resumed upload is shorter than what was already uploaded (%d < %d)", ru.received, ru.committed)
+	}
+
+	err := ru.put(ru.buf.Bytes(), true)
+	if err != nil {
+		return err
+	}
+	ru.complete = true
+	return nil
+}
+
+func (ru *resumedUpload) put(data []byte, last bool) error {
+	total := ru.offset + int64(len(data))
+
+	retryCtx := retrycontext.New(retrycontext.Settings{
+		MaxTries: 15,
+		Consumer: ru.consumer,
+	})
+
+	for retryCtx.ShouldTry() {
+		committed, done, err := ru.tryPut(data, last, total)
+		if err != nil {
+			retryCtx.Retry(err)
+
+			// find out what made it
+			committed, done, err = ru.queryStatus()
+			if err != nil {
+				continue
+			}
+		}
+
+		if done {
+			if !last {
+				return errors.Errorf("upload was completed early, at %d bytes", committed)
+			}
+			ru.offset = total
+			ru.notifyProgress()
+			return nil
+		}
+
+		if committed < ru.offset || committed > total {
+			return errors.Errorf("server committed unexpected range (0-%d, expected %d-%d)", committed, ru.offset, total)
+		}
+		data = data[committed-ru.offset:]
+		ru.offset = committed
+		ru.notifyProgress()
+
+		if !last && len(data) == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Too many errors, stopping upload")
+}
+
+func (ru *resumedUpload) notifyProgress() {
+	if ru.progressListener != nil {
+		ru.progressListener(ru.offset)
+	}
+}
+
+// tryPut sends a single request, and returns how many bytes the server
+// has committed overall, and whether the upload is complete.
+func (ru *resumedUpload) tryPut(data []byte, last bool, total int64) (int64, bool, error) {
+	req, err := http.NewRequest("PUT", ru.uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	totalString := "*"
+	if last {
+		totalString = strconv.FormatInt(total, 10)
+	}
+
+	if len(data) == 0 {
+		req.Header.Set("content-range", fmt.Sprintf("bytes */%s", totalString))
+	} else {
+		end := ru.offset + int64(len(data)) - 1
+		req.Header.Set("content-range", fmt.Sprintf("bytes %d-%d/%s", ru.offset, end, totalString))
+	}
+	req.ContentLength = int64(len(data))
+
+	res, err := ru.httpClient.Do(req)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	res.Body.Close()
+
+	return interpretUploadStatus(res)
+}
+
+// queryStatus returns how many bytes the server has committed, and
+// whether the upload is complete.
+func (ru *resumedUpload) queryStatus() (int64, bool, error) {
+	req, err := http.NewRequest("PUT", ru.uploadURL, nil)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	req.Header.Set("content-range", "bytes */*")
+	req.ContentLength = 0
+
+	res, err := ru.httpClient.Do(req)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	res.Body.Close()
+
+	return interpretUploadStatus(res)
+}
+
+func interpretUploadStatus(res *http.Response) (int64, bool, error) {
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+		// "Resume Incomplete": the Range header tells us what's stored
+		rangeHeader := res.Header.Get("Range")
+		if rangeHeader == "" {
+			return 0, false, nil
+		}
+		committed, err := parseCommittedRange(rangeHeader)
+		return committed, false, err
+	case http.StatusNotFound, http.StatusGone:
+		return 0, false, errUploadGone
+	default:
+		return 0, false, errors.Errorf("upload server replied with HTTP %s", res.Status)
+	}
+}
+
+// parseCommittedRange parses headers like "bytes=0-1023" and returns
+// the number of bytes stored (1024 in that example)
+func parseCommittedRange(rangeHeader string) (int64, error) {
+	tokens := strings.SplitN(strings.TrimPrefix(rangeHeader, "bytes="), "-", 2)
+	if len(tokens) != 2 || tokens[0] != "0" {
+		return 0, errors.Errorf("invalid range header %q", rangeHeader)
+	}
+
+	end, err := strconv.ParseInt(tokens[1], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid range header %q", rangeHeader)
+	}
+	return end + 1, nil
+}
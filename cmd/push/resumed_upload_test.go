@@ -0,0 +1,114 @@
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGCS implements just enough of the GCS resumable upload protocol:
+// it stores chunks in order, and only ever commits whole 256KiB chunks
+// unless the total size is known.
+type fakeGCS struct {
+	mu       sync.Mutex
+	data     []byte
+	complete bool
+}
+
+func (fg *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	contentRange := strings.TrimPrefix(r.Header.Get("content-range"), "bytes ")
+	tokens := strings.SplitN(contentRange, "/", 2)
+	rangeString, totalString := tokens[0], tokens[1]
+
+	if rangeString != "*" {
+		var start, end int64
+		fmt.Sscanf(rangeString, "%d-%d", &start, &end)
+		if start != int64(len(fg.data)) || end-start+1 != int64(len(body)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fg.data = append(fg.data, body...)
+	}
+
+	if totalString != "*" {
+		total, _ := strconv.ParseInt(totalString, 10, 64)
+		if total == int64(len(fg.data)) {
+			fg.complete = true
+		}
+	}
+
+	if fg.complete {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(fg.data) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(fg.data)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func Test_ResumedUpload(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := make([]byte, 40*gcsChunkSize+1234)
+	rand.New(rand.NewSource(0xfeedface)).Read(payload)
+
+	for _, committedChunks := range []int{0, 3, 40} {
+		fg := &fakeGCS{}
+		fg.data = append([]byte{}, payload[:committedChunks*gcsChunkSize]...)
+		server := httptest.NewServer(fg)
+
+		ru, err := newResumedUpload(server.URL, nil)
+		assert.NoError(err)
+		assert.EqualValues(committedChunks*gcsChunkSize, ru.committed)
+
+		// write in odd-sized pieces, like the patch writer would
+		for offset := 0; offset < len(payload); offset += 77777 {
+			end := offset + 77777
+			if end > len(payload) {
+				end = len(payload)
+			}
+			_, err := ru.Write(payload[offset:end])
+			assert.NoError(err)
+		}
+		assert.NoError(ru.Close())
+
+		assert.True(fg.complete)
+		assert.True(bytes.Equal(payload, fg.data))
+		server.Close()
+	}
+
+	{
+		fg := &fakeGCS{data: payload, complete: true}
+		server := httptest.NewServer(fg)
+
+		ru, err := newResumedUpload(server.URL, nil)
+		assert.NoError(err)
+		assert.True(ru.complete)
+		_, err = ru.Write(payload)
+		assert.NoError(err)
+		assert.NoError(ru.Close())
+		server.Close()
+	}
+
+	{
+		server := httptest.NewServer(http.NotFoundHandler())
+		_, err := newResumedUpload(server.URL, nil)
+		assert.Equal(errUploadGone, err)
+		server.Close()
+	}
+}
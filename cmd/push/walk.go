@@ -1,6 +1,7 @@
 package push
 
 import (
+	"github.com/itchio/butler/filtering"
 	"github.com/itchio/lake"
 	"github.com/itchio/lake/pools"
 	"github.com/itchio/lake/tlc"
@@ -13,12 +14,39 @@ type walkResult struct {
 	pool      lake.Pool
 }
 
-func doWalk(path string, out chan walkResult, errs chan error, fixPerms bool, walkOpts *tlc.WalkOpts) {
+// pendingWalk is a walk started in the background, so that
+// it can happen while we're busy talking to the server
+type pendingWalk struct {
+	out  chan walkResult
+	errs chan error
+}
+
+func startWalk(path string, fixPerms bool, walkOpts *tlc.WalkOpts, ignore []string) *pendingWalk {
+	pw := &pendingWalk{
+		out:  make(chan walkResult, 1),
+		errs: make(chan error, 1),
+	}
+	go doWalk(path, pw.out, pw.errs, fixPerms, walkOpts, ignore)
+	return pw
+}
+
+// Wait blocks until the walk is done
+func (pw *pendingWalk) Wait() (walkResult, error) {
+	select {
+	case walkErr := <-pw.errs:
+		return walkResult{}, errors.Wrap(walkErr, "walking directory to push")
+	case walkies := <-pw.out:
+		return walkies, nil
+	}
+}
+
+func doWalk(path string, out chan walkResult, errs chan error, fixPerms bool, walkOpts *tlc.WalkOpts, ignore []string) {
 	container, err := tlc.WalkAny(path, walkOpts)
 	if err != nil {
 		errs <- errors.WithStack(err)
 		return
 	}
+	filtering.PruneContainer(container, walkOpts.WrappedDir, ignore)
 
 	pool, err := pools.New(container, path)
 	if err != nil {
@@ -0,0 +1,53 @@
+package push
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/comm"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"game.exe",
+		"game.pdb",
+		"assets/hero.png",
+		"assets/raw/hero.psd",
+		"mods/assets/raw/mod.psd",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(name), 0644))
+	}
+
+	p := &pushParams{
+		buildPath: dir,
+		spec:      &itchio.Spec{Target: "leafo/x-moon", Channel: "windows"},
+		ignore:    []string{"*.pdb", "assets/raw"},
+	}
+	walkies, err := p.startWalk(comm.NewStateConsumer()).Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer walkies.pool.Close()
+
+	var paths []string
+	var size int64
+	for _, f := range walkies.container.Files {
+		assert.EqualValues(t, size, f.Offset)
+		size += f.Size
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{"game.exe", "assets/hero.png", "mods/assets/raw/mod.psd"}, paths)
+	assert.EqualValues(t, size, walkies.container.Size)
+}
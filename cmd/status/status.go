@@ -4,18 +4,39 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
+	"github.com/itchio/butler/cmd/fetch"
 	"github.com/itchio/butler/comm"
 	"github.com/itchio/butler/mansion"
 	itchio "github.com/itchio/go-itchio"
 	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/united"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 )
 
+// Exit codes for `butler status --wait`
+const (
+	// ExitLive means all builds we waited for are live
+	ExitLive = 0
+	// ExitFailed means at least one build failed processing
+	ExitFailed = 2
+	// ExitTimeout means builds were still processing when --timeout expired
+	ExitTimeout = 3
+)
+
+// how often to check on pending builds when waiting
+const waitInterval = 5 * time.Second
+
 var args = struct {
 	target       *string
 	showAllFiles *bool
+	history      *int
+	wait         *bool
+	timeout      *time.Duration
+	buildID      *int64
+	userVersion  *string
 }{}
 
 func Register(ctx *mansion.Context) {
@@ -24,33 +45,110 @@ func Register(ctx *mansion.Context) {
 
 	args.target = cmd.Arg("target", "Which user/project to show the status of, for example 'leafo/x-moon'").Required().String()
 	args.showAllFiles = cmd.Flag("show-all-files", "Show status of all files, not just archive").Bool()
+	args.history = cmd.Flag("history", "Also show the last N builds of each channel").Default("0").Int()
+	args.wait = cmd.Flag("wait", "Wait for pending builds to finish processing. Exits with 0 if they're live, 2 if one failed, 3 on timeout").Bool()
+	args.timeout = cmd.Flag("timeout", "How long to wait for with --wait, for example '30m' (default: forever)").Duration()
+	args.buildID = cmd.Flag("build-id", "Also show this build of the channel, with its files").Int64()
+	args.userVersion = cmd.Flag("userversion", "Also show the most recent build of the channel with this user version, with its files").String()
 }
 
 func do(ctx *mansion.Context) {
 	go ctx.DoVersionCheck()
-	ctx.Must(Do(ctx, *args.target, *args.showAllFiles))
+
+	selector := fetch.BuildSelector{
+		BuildID:     *args.buildID,
+		UserVersion: *args.userVersion,
+	}
+	exitCode, err := Do(ctx, *args.target, *args.showAllFiles, *args.history, selector, *args.wait, *args.timeout)
+	ctx.Must(err)
+	if exitCode != ExitLive {
+		os.Exit(exitCode)
+	}
 }
 
-func Do(ctx *mansion.Context, specStr string, showAllFiles bool) error {
+// Status is what `butler status` prints in JSON mode
+type Status struct {
+	Target   string           `json:"target"`
+	Channels []*ChannelStatus `json:"channels"`
+}
+
+// ChannelStatus describes a channel, its builds, and their files
+type ChannelStatus struct {
+	Name     string          `json:"name"`
+	UploadID int64           `json:"uploadId"`
+	Head     *itchio.Build   `json:"head,omitempty"`
+	Pending  *itchio.Build   `json:"pending,omitempty"`
+	History  []*itchio.Build `json:"history,omitempty"`
+	// Build picked with --build-id or --userversion, if any
+	Selected *itchio.Build `json:"selected,omitempty"`
+}
+
+// Do shows the status of a target's channels. If wait is true, it first waits for
+// pending builds to finish processing, and returns one of the Exit codes.
+func Do(ctx *mansion.Context, specStr string, showAllFiles bool, history int, selector fetch.BuildSelector, wait bool, timeout time.Duration) (int, error) {
 	spec, err := itchio.ParseSpec(specStr)
 	if err != nil {
-		return errors.Wrapf(err, "parsing spec %s", spec)
+		return 0, errors.Wrapf(err, "parsing spec %s", specStr)
+	}
+
+	if !selector.IsZero() {
+		err = selector.Validate()
+		if err != nil {
+			return 0, err
+		}
+
+		// builds are looked up per-upload, ie. per-channel
+		err = spec.EnsureChannel()
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	client, err := ctx.AuthenticateViaOauth()
 	if err != nil {
-		return errors.Wrap(err, "authenticating")
+		return 0, errors.Wrap(err, "authenticating")
 	}
 
-	listChannelsResp, err := client.ListChannels(ctx.DefaultCtx(), spec.Target)
+	// files are always included in JSON mode
+	withFiles := showAllFiles || comm.JsonEnabled()
+
+	exitCode := ExitLive
+	if wait {
+		exitCode, err = waitForBuilds(ctx, client, spec, timeout)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	st, err := getStatus(ctx, client, spec, withFiles, history)
 	if err != nil {
-		return errors.Wrap(err, "listing channels")
+		return 0, err
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Channel", "Upload", "Build", "Version"})
+	if !selector.IsZero() {
+		for _, cs := range st.Channels {
+			if cs.UploadID == 0 {
+				continue
+			}
+			cs.Selected, err = fetch.FindBuild(ctx.DefaultCtx(), client, cs.UploadID, itchio.GameCredentials{}, selector)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
 
-	found := false
+	comm.ResultOrPrint(st, func() {
+		printStatus(st, spec, showAllFiles)
+	})
+
+	return exitCode, nil
+}
+
+func getStatus(ctx *mansion.Context, client *itchio.Client, spec *itchio.Spec, withFiles bool, history int) (*Status, error) {
+	listChannelsResp, err := client.ListChannels(ctx.DefaultCtx(), spec.Target)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing channels")
+	}
 
 	sortedChannelNames := []string{}
 	for name := range listChannelsResp.Channels {
@@ -58,34 +156,203 @@ func Do(ctx *mansion.Context, specStr string, showAllFiles bool) error {
 	}
 	sort.Strings(sortedChannelNames)
 
+	st := &Status{
+		Target:   spec.Target,
+		Channels: []*ChannelStatus{},
+	}
+
+	fillFiles := func(build *itchio.Build) error {
+		if build == nil || !withFiles || len(build.Files) > 0 {
+			return nil
+		}
+
+		res, err := client.ListBuildFiles(ctx.DefaultCtx(), build.ID)
+		if err != nil {
+			return errors.Wrapf(err, "listing files of build %d", build.ID)
+		}
+		build.Files = res.Files
+		return nil
+	}
+
 	for _, channelName := range sortedChannelNames {
 		ch := listChannelsResp.Channels[channelName]
 		if spec.Channel != "" && ch.Name != spec.Channel {
 			continue
 		}
-		found = true
 
-		if ch.Head != nil {
-			line := []string{ch.Name, fmt.Sprintf("#%d", ch.Upload.ID), buildState(ch.Head), versionState(ch.Head)}
-			table.Append(line)
-		} else {
-			line := []string{ch.Name, fmt.Sprintf("#%d", ch.Upload.ID), "No builds yet"}
-			table.Append(line)
+		cs := &ChannelStatus{
+			Name:    ch.Name,
+			Head:    ch.Head,
+			Pending: ch.Pending,
+		}
+		if ch.Upload != nil {
+			cs.UploadID = ch.Upload.ID
+		}
+
+		if history > 0 && cs.UploadID != 0 {
+			buildsRes, err := client.ListUploadBuilds(ctx.DefaultCtx(), itchio.ListUploadBuildsParams{
+				UploadID: cs.UploadID,
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "listing builds of channel %s", ch.Name)
+			}
+
+			builds := buildsRes.Builds
+			sort.Slice(builds, func(i, j int) bool {
+				return builds[i].ID > builds[j].ID
+			})
+			if len(builds) > history {
+				builds = builds[:history]
+			}
+			cs.History = builds
 		}
 
-		if ch.Pending != nil {
-			line := []string{"", "", buildState(ch.Pending), versionState(ch.Pending)}
-			table.Append(line)
+		for _, build := range append([]*itchio.Build{cs.Head, cs.Pending}, cs.History...) {
+			err := fillFiles(build)
+			if err != nil {
+				return nil, err
+			}
 		}
+
+		st.Channels = append(st.Channels, cs)
+	}
+
+	return st, nil
+}
+
+// waitForBuilds polls channels until none of them have a pending build
+func waitForBuilds(ctx *mansion.Context, client *itchio.Client, spec *itchio.Spec, timeout time.Duration) (int, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
 	}
 
-	if found {
-		table.Render()
-	} else {
+	// for each channel, the latest build we've seen pending, and
+	// need to know the outcome of
+	waitedFor := make(map[string]int64)
+	lastStates := make(map[int64]itchio.BuildState)
+
+	for {
+		st, err := getStatus(ctx, client, spec, false, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		numPending := 0
+		for _, cs := range st.Channels {
+			build := currentPending(cs)
+			if build == nil || build.ID < waitedFor[cs.Name] {
+				continue
+			}
+
+			waitedFor[cs.Name] = build.ID
+			if lastStates[build.ID] != build.State {
+				lastStates[build.ID] = build.State
+				comm.Logf("%s: build #%d is %s", cs.Name, build.ID, build.State)
+			}
+
+			// failed builds can stay pending until the next push
+			if build.State != itchio.BuildStateFailed {
+				numPending++
+			}
+		}
+
+		if numPending == 0 {
+			break
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			comm.Logf("Timed out after %s, %d builds still pending", timeout, numPending)
+			return ExitTimeout, nil
+		}
+
+		time.Sleep(waitInterval)
+	}
+
+	var channelNames []string
+	for channelName := range waitedFor {
+		channelNames = append(channelNames, channelName)
+	}
+	sort.Strings(channelNames)
+
+	exitCode := ExitLive
+	for _, channelName := range channelNames {
+		buildID := waitedFor[channelName]
+		res, err := client.GetBuild(ctx.DefaultCtx(), itchio.GetBuildParams{
+			BuildID: buildID,
+		})
+		if err != nil {
+			return 0, errors.Wrapf(err, "getting build %d", buildID)
+		}
+
+		switch res.Build.State {
+		case itchio.BuildStateCompleted:
+			comm.Statf("%s: build #%d is live", channelName, buildID)
+		default:
+			comm.Logf("%s: build #%d ended up %s", channelName, buildID, res.Build.State)
+			exitCode = ExitFailed
+		}
+	}
+	return exitCode, nil
+}
+
+// currentPending returns the pending build of a channel, unless
+// it's older than the live one, like a build that failed before
+// another one was pushed and processed.
+func currentPending(cs *ChannelStatus) *itchio.Build {
+	if cs.Pending == nil {
+		return nil
+	}
+	if cs.Head != nil && cs.Head.ID > cs.Pending.ID {
+		return nil
+	}
+	return cs.Pending
+}
+
+func printStatus(st *Status, spec *itchio.Spec, showAllFiles bool) {
+	if len(st.Channels) == 0 {
 		comm.Logf("No channel %s found for %s", spec.Channel, spec.Target)
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Channel", "Upload", "Build", "Version"})
+	table.SetAutoWrapText(false)
+
+	appendBuild := func(line []string, build *itchio.Build, withFiles bool) {
+		table.Append(line)
+		if withFiles {
+			for _, f := range build.Files {
+				table.Append([]string{"", "", fileState(f), ""})
+			}
+		}
 	}
 
-	return nil
+	for _, cs := range st.Channels {
+		upload := fmt.Sprintf("#%d", cs.UploadID)
+		if cs.Head != nil {
+			appendBuild([]string{cs.Name, upload, buildState(cs.Head), versionState(cs.Head)}, cs.Head, showAllFiles)
+		} else {
+			table.Append([]string{cs.Name, upload, "No builds yet"})
+		}
+
+		if cs.Pending != nil {
+			appendBuild([]string{"", "", buildState(cs.Pending), versionState(cs.Pending)}, cs.Pending, showAllFiles)
+		}
+
+		for _, build := range cs.History {
+			if (cs.Head != nil && build.ID == cs.Head.ID) || (cs.Pending != nil && build.ID == cs.Pending.ID) {
+				continue
+			}
+			appendBuild([]string{"", "", buildState(build), versionState(build)}, build, showAllFiles)
+		}
+
+		if cs.Selected != nil {
+			appendBuild([]string{"", "", "→ " + buildState(cs.Selected), versionState(cs.Selected)}, cs.Selected, true)
+		}
+	}
+
+	table.Render()
 }
 
 func buildState(build *itchio.Build) string {
@@ -108,6 +375,18 @@ func buildState(build *itchio.Build) string {
 	return s
 }
 
+func fileState(f *itchio.BuildFile) string {
+	s := fmt.Sprintf("    %s", f.Type)
+	if f.SubType != "" && f.SubType != itchio.BuildFileSubTypeDefault {
+		s += fmt.Sprintf(" (%s)", f.SubType)
+	}
+	s += fmt.Sprintf(": %s", f.State)
+	if f.Size > 0 {
+		s += fmt.Sprintf(", %s", united.FormatBytes(f.Size))
+	}
+	return s
+}
+
 func versionState(build *itchio.Build) string {
 	switch build.State {
 	case itchio.BuildStateCompleted:
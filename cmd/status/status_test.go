@@ -0,0 +1,19 @@
+package status
+
+import (
+	"testing"
+
+	itchio "github.com/itchio/go-itchio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentPending(t *testing.T) {
+	older := &itchio.Build{ID: 10, State: itchio.BuildStateFailed}
+	live := &itchio.Build{ID: 11, State: itchio.BuildStateCompleted}
+	newer := &itchio.Build{ID: 12, State: itchio.BuildStateProcessing}
+
+	assert.Nil(t, currentPending(&ChannelStatus{Head: live}))
+	assert.Nil(t, currentPending(&ChannelStatus{Head: live, Pending: older}), "stale failed builds are ignored")
+	assert.EqualValues(t, newer, currentPending(&ChannelStatus{Head: live, Pending: newer}))
+	assert.EqualValues(t, older, currentPending(&ChannelStatus{Pending: older}))
+}
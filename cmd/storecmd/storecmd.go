@@ -0,0 +1,81 @@
+// Package storecmd implements commands to manage the dedupe store
+// (see installer/store), and lets other commands open it.
+package storecmd
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/daemon"
+	"github.com/itchio/butler/comm"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/butler/mansion"
+	"github.com/itchio/headway/united"
+	"github.com/pkg/errors"
+)
+
+var gcArgs = struct {
+	dryRun bool
+}{}
+
+func Register(ctx *mansion.Context) {
+	parentCmd := ctx.App.Command("store", "Manage the store of files shared between builds (see --store-dir)")
+
+	{
+		cmd := parentCmd.Command("gc", "Remove files from the store that no fetched or installed build uses anymore")
+		cmd.Flag("dry-run", "Only show what would be removed").BoolVar(&gcArgs.dryRun)
+		ctx.Register(cmd, doGC)
+	}
+}
+
+func doGC(ctx *mansion.Context) {
+	ctx.Must(GC(ctx, gcArgs.dryRun))
+}
+
+// GC removes unused files from the store
+func GC(ctx *mansion.Context, dryRun bool) error {
+	if ctx.StoreDir == "" {
+		return errors.New("Missing store folder: use --store-dir path/to/store")
+	}
+
+	st, dbPool, err := Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	comm.Opf("Looking for unused files in %s", st.Dir)
+	res, err := st.GC(comm.NewStateConsumer(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	comm.ResultOrPrint(res, func() {
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		comm.Statf("%s %d files (%s), kept %d, forgot %d stale links",
+			verb, res.RemovedBlobs, united.FormatBytes(res.FreedBytes), res.KeptBlobs, res.StaleRefs)
+	})
+	return nil
+}
+
+// Open returns the store in ctx.StoreDir, or nil if it's not set. The
+// store's index is kept in the butlerd database, which callers must
+// close when they're done.
+func Open(ctx *mansion.Context) (*store.Store, *sqlite.Pool, error) {
+	if ctx.StoreDir == "" {
+		return nil, nil, nil
+	}
+
+	if ctx.DBPath == "" {
+		ctx.DBPath = butlerd.GuessDBPath("")
+		comm.Logf("Using database at %s (pass --dbpath to use another one)", ctx.DBPath)
+	}
+
+	dbPool, err := daemon.OpenDB(ctx)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return store.OpenWithPool(ctx.StoreDir, dbPool), dbPool, nil
+}
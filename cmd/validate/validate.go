@@ -3,7 +3,9 @@ package validate
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/itchio/ox"
@@ -12,10 +14,6 @@ import (
 
 	"github.com/itchio/dash"
 
-	"github.com/mitchellh/mapstructure"
-
-	"github.com/BurntSushi/toml"
-
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/comm"
 	"github.com/itchio/butler/endpoints/launch"
@@ -46,6 +44,11 @@ func Register(ctx *mansion.Context) {
 	ctx.Register(cmd, doValidate)
 }
 
+type lintResult struct {
+	Path        string                 `json:"path"`
+	Diagnostics []*manifest.Diagnostic `json:"diagnostics"`
+}
+
 func doValidate(ctx *mansion.Context) {
 	ctx.Must(Validate(comm.NewStateConsumer()))
 }
@@ -151,55 +154,67 @@ func Validate(consumer *state.Consumer) error {
 
 	consumer.Opf("Validating %s manifest at (%s)", united.FormatBytes(stats.Size()), manifestPath)
 
-	var intermediate map[string]interface{}
-	_, err = toml.DecodeFile(manifestPath, &intermediate)
+	data, err := ioutil.ReadFile(manifestPath)
 	if err != nil {
-		consumer.Errorf("Parse error:")
-		return errors.Wrap(err, "parsing manifest")
+		return errors.Wrap(err, "reading manifest")
 	}
 
-	jsonIntermediate, err := json.MarshalIndent(intermediate, "", "  ")
-	if err != nil {
-		return errors.Wrap(err, "marshalling manifest as json")
+	var reg *redist.RedistRegistry
+	lintParams := manifest.LintParams{
+		Runtime: runtime,
+		Registry: func() (*redist.RedistRegistry, error) {
+			regFile, err := eos.Open("https://broth.itch.ovh/itch-redists/info/LATEST/unpacked", option.WithConsumer(consumer))
+			if err != nil {
+				return nil, errors.Wrap(err, "opening prereqs registry")
+			}
+			defer regFile.Close()
+
+			reg = &redist.RedistRegistry{}
+			err = json.NewDecoder(regFile).Decode(reg)
+			if err != nil {
+				return nil, errors.Wrap(err, "decoding prereqs registry")
+			}
+			return reg, nil
+		},
+	}
+	if hasDir {
+		lintParams.Dir = dir
+		lintParams.IsNative = func(action *butlerd.Action) bool {
+			sr, err := launch.DetermineStrategy(consumer, runtime, dir, action)
+			if err != nil {
+				// missing paths are reported separately
+				return true
+			}
+			return sr.Strategy == launch.LaunchStrategyNative
+		}
 	}
-	consumer.Debugf("Intermediate:\n%s", string(jsonIntermediate))
 
-	appManifest := &butlerd.Manifest{}
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Result:      appManifest,
-		ErrorUnused: true,
-	})
+	lintRes, err := manifest.Lint(data, lintParams)
 	if err != nil {
-		consumer.Errorf("Internal error:")
-		return errors.Wrap(err, "decoding manifest from json form")
+		return errors.Wrap(err, "linting manifest")
 	}
+	errorCount += lintRes.Errors()
 
-	err = decoder.Decode(intermediate)
-	if err != nil {
-		warnOnly := false
-		if mse, ok := err.(*mapstructure.Error); ok {
-			warnOnly = true
-			for _, e := range mse.Errors {
-				if strings.Contains(e, "has invalid keys") {
-					// cool!
-				} else {
-					warnOnly = false
-					break
-				}
+	if comm.JsonEnabled() {
+		comm.Result(&lintResult{
+			Path:        manifestPath,
+			Diagnostics: lintRes.Diagnostics,
+		})
+	} else {
+		consumer.Infof("")
+		if len(lintRes.Diagnostics) > 0 {
+			consumer.Statf("Found %d problems:", len(lintRes.Diagnostics))
+			for _, d := range lintRes.Diagnostics {
+				consumer.Infof("%s:%s", manifestPath, d)
 			}
-		}
-
-		if warnOnly {
-			showWarning("%s", err.Error())
 		} else {
-			consumer.Errorf("Decoding error:")
-			return errors.Wrap(err, "decoding manifest")
+			consumer.Statf("No problems found in manifest")
 		}
 	}
 
-	_, err = toml.DecodeFile(manifestPath, appManifest)
-	if err != nil {
-		return errors.Wrap(err, "parsing toml manifest")
+	appManifest := lintRes.Manifest
+	if appManifest == nil {
+		return fmt.Errorf("Found %d errors.", errorCount)
 	}
 
 	jsonManifest, err := json.MarshalIndent(appManifest, "", "  ")
@@ -223,8 +238,6 @@ func Validate(consumer *state.Consumer) error {
 					consumer.Infof("    Only for macOS")
 				case ox.PlatformWindows:
 					consumer.Infof("    Only for Windows")
-				default:
-					showError("Unknown platform specified: (%s)", action.Platform)
 				}
 			}
 			if action.Scope != "" {
@@ -233,16 +246,38 @@ func Validate(consumer *state.Consumer) error {
 			if action.Sandbox {
 				consumer.Infof("    Sandbox opt-in")
 			}
+			if action.SandboxPolicy != nil {
+				policy := launch.ResolveSandboxPolicy(runtime, dir, action.SandboxPolicy, nil)
+				consumer.Infof("    Sandbox policy: %s", policy)
+			}
 			if action.Console {
 				consumer.Infof("    Console")
 			}
 			if len(action.Args) > 0 {
 				consumer.Infof("    Passes arguments: %s", strings.Join(action.Args, " ::: "))
 			}
+			if len(action.Env) > 0 {
+				var envKeys []string
+				for k := range action.Env {
+					envKeys = append(envKeys, k)
+				}
+				sort.Strings(envKeys)
+				consumer.Infof("    Sets environment variables: %s", strings.Join(envKeys, ", "))
+			}
+			if action.Cwd != "" {
+				consumer.Infof("    Starts in (%s)", action.Cwd)
+			}
+			if action.PreLaunch != nil {
+				consumer.Infof("    Runs (%s) before launching", action.PreLaunch.Path)
+			}
+			if action.PostExit != nil {
+				consumer.Infof("    Runs (%s) after exiting", action.PostExit.Path)
+			}
 			if hasDir {
 				sr, err := launch.DetermineStrategy(consumer, runtime, dir, action)
 				if err != nil {
-					showError(err.Error())
+					// already reported by the linter
+					consumer.Warnf("    %s", err.Error())
 				} else {
 					printStrategyResult(sr)
 				}
@@ -261,21 +296,14 @@ func Validate(consumer *state.Consumer) error {
 		consumer.Statf("Validating %d prereqs...", len(appManifest.Prereqs))
 		consumer.Infof("")
 
-		regFile, err := eos.Open("https://broth.itch.ovh/itch-redists/info/LATEST/unpacked", option.WithConsumer(consumer))
-		if err != nil {
-			return errors.Wrap(err, "opening prereqs registry")
-		}
-
-		reg := &redist.RedistRegistry{}
-		err = json.NewDecoder(regFile).Decode(reg)
-		if err != nil {
-			return errors.Wrap(err, "decoding prereqs registry")
+		if reg == nil {
+			reg = &redist.RedistRegistry{}
 		}
 
 		for _, p := range appManifest.Prereqs {
 			entry := reg.Entries[p.Name]
 			if entry == nil {
-				showError("Unknown prerequisite listed: %s", p.Name)
+				// already reported by the linter
 				continue
 			}
 			consumer.Infof("  → %s (%s)", entry.FullName, p.Name)
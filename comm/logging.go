@@ -59,6 +59,12 @@ func JsonEnabled() bool {
 	return settings.json
 }
 
+// AssumeYesEnabled returns true if questions shouldn't be
+// asked, and the default answer used instead.
+func AssumeYesEnabled() bool {
+	return settings.assumeYes
+}
+
 // YesNo asks the user whether to proceed or not
 // won't work in json mode for now.
 func YesNo(question string) bool {
@@ -7,6 +7,7 @@ import (
 	"github.com/itchio/butler/cmd/clean"
 	"github.com/itchio/butler/cmd/configure"
 	"github.com/itchio/butler/cmd/cp"
+	"github.com/itchio/butler/cmd/crashlog"
 	"github.com/itchio/butler/cmd/daemon"
 	"github.com/itchio/butler/cmd/diff"
 	"github.com/itchio/butler/cmd/ditto"
@@ -17,6 +18,7 @@ import (
 	"github.com/itchio/butler/cmd/extract"
 	"github.com/itchio/butler/cmd/fetch"
 	"github.com/itchio/butler/cmd/file"
+	"github.com/itchio/butler/cmd/headless"
 	"github.com/itchio/butler/cmd/heal"
 	"github.com/itchio/butler/cmd/login"
 	"github.com/itchio/butler/cmd/logout"
@@ -35,6 +37,7 @@ import (
 	"github.com/itchio/butler/cmd/singlediff"
 	"github.com/itchio/butler/cmd/sizeof"
 	"github.com/itchio/butler/cmd/status"
+	"github.com/itchio/butler/cmd/storecmd"
 	"github.com/itchio/butler/cmd/unsz"
 	"github.com/itchio/butler/cmd/untar"
 	"github.com/itchio/butler/cmd/unzip"
@@ -62,6 +65,10 @@ func registerCommands(ctx *mansion.Context) {
 	fetch.Register(ctx)
 	status.Register(ctx)
 
+	headless.Register(ctx)
+	storecmd.Register(ctx)
+	crashlog.Register(ctx)
+
 	file.Register(ctx)
 	ls.Register(ctx)
 
@@ -0,0 +1,49 @@
+// Package dbtest provides butler databases for tests
+package dbtest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+)
+
+// OpenPool creates a database with all of butler's tables in dir,
+// which is created if needed, and returns a pool of size connections
+// to it. Closing the pool is up to the caller.
+func OpenPool(t *testing.T, dir string, size int) *sqlite.Pool {
+	wtest.Must(t, os.MkdirAll(dir, 0755))
+
+	dbPool, err := sqlite.Open(filepath.Join(dir, "butler.db"), 0, size)
+	wtest.Must(t, err)
+
+	conn := dbPool.Get(context.Background().Done())
+	err = database.Prepare(&state.Consumer{}, conn, true)
+	dbPool.Put(conn)
+	if err != nil {
+		dbPool.Close()
+		wtest.Must(t, err)
+	}
+	return dbPool
+}
+
+// WithConn calls f with a connection to a fresh database,
+// which is removed once f returns.
+func WithConn(t *testing.T, f func(conn *sqlite.Conn)) {
+	dir, err := ioutil.TempDir("", "butler-dbtest")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	dbPool := OpenPool(t, dir, 1)
+	defer dbPool.Close()
+
+	conn := dbPool.Get(context.Background().Done())
+	defer dbPool.Put(conn)
+	f(conn)
+}
@@ -26,4 +26,12 @@ var AllModels = []interface{}{
 	&FetchInfo{},
 	&GameUpload{},
 	&CaveHistoricalPlayTime{},
+	&StoreBlob{},
+	&StoreRef{},
+	&LaunchSession{},
+	&DownloadSchedule{},
+	&SourceHash{},
+	&IntegrityScanSchedule{},
+	&CaveIntegrity{},
+	&CaveFileStat{},
 }
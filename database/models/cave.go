@@ -35,6 +35,10 @@ type Cave struct {
 	Verdict       JSON  `json:"verdict"`
 	InstalledSize int64 `json:"installedSize"`
 
+	// Player's override of the manifest's sandbox policy,
+	// see operate.GetCaveSandboxPolicy
+	SandboxPolicy JSON `json:"sandboxPolicy"`
+
 	InstallLocationID string           `json:"installLocationId"`
 	InstallLocation   *InstallLocation `json:"installLocation"`
 
@@ -142,4 +146,7 @@ func (c *Cave) SaveWithAssocs(conn *sqlite.Conn) {
 
 func (c *Cave) Delete(conn *sqlite.Conn) {
 	MustDelete(conn, &Cave{}, builder.Eq{"id": c.ID})
+	MustDelete(conn, &LaunchSession{}, builder.Eq{"cave_id": c.ID})
+	MustDelete(conn, &CaveIntegrity{}, builder.Eq{"cave_id": c.ID})
+	MustDelete(conn, &CaveFileStat{}, builder.Eq{"cave_id": c.ID})
 }
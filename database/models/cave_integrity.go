@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/hades"
+	"xorm.io/builder"
+)
+
+// DefaultIntegrityScanInterval is how often caves are scanned,
+// unless the player picked another interval.
+const DefaultIntegrityScanInterval = 7 * 24 * time.Hour
+
+// IntegrityScanSchedule configures background integrity scans, which
+// are off until the player turns them on. There's only ever one, with
+// ID IntegrityScanScheduleID.
+type IntegrityScanSchedule struct {
+	ID string `hades:"primary_key"`
+
+	Enabled bool
+	// How long to wait between scans of a cave. Zero means
+	// DefaultIntegrityScanInterval.
+	IntervalHours int64
+}
+
+const IntegrityScanScheduleID = "default"
+
+func GetIntegrityScanSchedule(conn *sqlite.Conn) *IntegrityScanSchedule {
+	iss := &IntegrityScanSchedule{
+		ID: IntegrityScanScheduleID,
+	}
+	MustSelectOne(conn, iss, builder.Eq{"id": IntegrityScanScheduleID})
+	return iss
+}
+
+func (iss *IntegrityScanSchedule) Interval() time.Duration {
+	if iss.IntervalHours <= 0 {
+		return DefaultIntegrityScanInterval
+	}
+	return time.Duration(iss.IntervalHours) * time.Hour
+}
+
+func (iss *IntegrityScanSchedule) Save(conn *sqlite.Conn) {
+	iss.ID = IntegrityScanScheduleID
+	MustSave(conn, iss)
+}
+
+// CaveIntegrity is the result of the last integrity scan of a cave
+type CaveIntegrity struct {
+	CaveID string `json:"caveId" hades:"primary_key"`
+
+	CheckedAt *time.Time `json:"checkedAt"`
+	Healthy   bool       `json:"healthy"`
+	// Whether suspicious files could be checked against a signature
+	CheckedContents bool `json:"checkedContents"`
+	// A list of butlerd.CaveWound
+	Wounds JSON `json:"wounds"`
+}
+
+func CaveIntegrityByCaveID(conn *sqlite.Conn, caveID string) *CaveIntegrity {
+	var ci CaveIntegrity
+	if MustSelectOne(conn, &ci, builder.Eq{"cave_id": caveID}) {
+		return &ci
+	}
+	return nil
+}
+
+func (ci *CaveIntegrity) Save(conn *sqlite.Conn) {
+	MustSave(conn, ci)
+}
+
+// CaveFileStat is the size and modification time of a file of a cave,
+// when it was last known to be intact. Integrity scans only hash files
+// that don't match it anymore.
+type CaveFileStat struct {
+	CaveID string `json:"caveId" hades:"primary_key"`
+	// Relative to the install folder, with forward slashes
+	Path string `json:"path" hades:"primary_key"`
+
+	Size int64 `json:"size"`
+	// In nanoseconds since the epoch
+	ModTime int64 `json:"modTime"`
+}
+
+func CaveFileStats(conn *sqlite.Conn, caveID string) []*CaveFileStat {
+	var stats []*CaveFileStat
+	MustSelect(conn, &stats, builder.Eq{"cave_id": caveID}, hades.Search{})
+	return stats
+}
+
+// SetCaveFileStats replaces the known stats of a cave's files
+func SetCaveFileStats(conn *sqlite.Conn, caveID string, stats []*CaveFileStat) {
+	MustDelete(conn, &CaveFileStat{}, builder.Eq{"cave_id": caveID})
+	if len(stats) > 0 {
+		MustSave(conn, stats)
+	}
+}
@@ -45,6 +45,53 @@ type Download struct {
 
 	Discarded bool `json:"discarded"`
 	Fresh     bool `json:"fresh"`
+
+	// Set while a butlerd instance drives the download, see AcquireDownloadLease
+	LeaseOwner     string     `json:"leaseOwner"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt"`
+}
+
+// DownloadLeaseDuration is how long a lease on a download
+// lasts if its owner doesn't renew it.
+const DownloadLeaseDuration = 30 * time.Second
+
+// DownloadLeaseAvailable matches downloads that owner holds the lease on,
+// or that nobody holds an unexpired lease on.
+func DownloadLeaseAvailable(owner string) builder.Cond {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	return builder.Expr("(coalesce(lease_owner, '') IN ('', ?) OR julianday(lease_expires_at) < julianday(?))", owner, now)
+}
+
+// AcquireDownloadLease takes, or renews, the lease on a download for owner.
+// It returns false if someone else holds an unexpired lease, so that two
+// butlerd instances sharing a database don't drive the same download.
+func AcquireDownloadLease(conn *sqlite.Conn, downloadID string, owner string) bool {
+	expiresAt := time.Now().UTC().Add(DownloadLeaseDuration)
+	MustUpdate(conn, &Download{},
+		hades.Where(builder.And(
+			builder.Eq{"id": downloadID},
+			DownloadLeaseAvailable(owner),
+		)),
+		builder.Eq{
+			"lease_owner":      owner,
+			"lease_expires_at": expiresAt.Format(time.RFC3339Nano),
+		},
+	)
+	return conn.Changes() > 0
+}
+
+// ReleaseDownloadLease gives up owner's lease on a download, if it holds it
+func ReleaseDownloadLease(conn *sqlite.Conn, downloadID string, owner string) {
+	MustUpdate(conn, &Download{},
+		hades.Where(builder.Eq{
+			"id":          downloadID,
+			"lease_owner": owner,
+		}),
+		builder.Eq{
+			"lease_owner":      "",
+			"lease_expires_at": time.Now().UTC().Format(time.RFC3339Nano),
+		},
+	)
 }
 
 func AllDownloads(conn *sqlite.Conn) []*Download {
@@ -98,6 +145,50 @@ func (d *Download) Save(conn *sqlite.Conn) {
 	MustSave(conn, d)
 }
 
+// Prioritize moves a download to the front of the queue
+func (d *Download) Prioritize(conn *sqlite.Conn) {
+	d.Position = DownloadMinPosition(conn) - 1
+	d.update(conn, builder.Eq{"position": d.Position})
+}
+
+// Discard marks a download as discarded, drives clean it up
+func (d *Download) Discard(conn *sqlite.Conn) {
+	d.Discarded = true
+	d.update(conn, builder.Eq{"discarded": true})
+}
+
+// SaveOutcome saves when a download finished, and its error if it failed.
+// Both are cleared when it's retried.
+func (d *Download) SaveOutcome(conn *sqlite.Conn) {
+	var finishedAt, errorCode, errorString, errorMessage interface{}
+	if d.FinishedAt != nil {
+		finishedAt = d.FinishedAt.Format(time.RFC3339Nano)
+	}
+	if d.ErrorCode != nil {
+		errorCode = *d.ErrorCode
+	}
+	if d.Error != nil {
+		errorString = *d.Error
+	}
+	if d.ErrorMessage != nil {
+		errorMessage = *d.ErrorMessage
+	}
+
+	d.update(conn, builder.Eq{
+		"finished_at":   finishedAt,
+		"error_code":    errorCode,
+		"error":         errorString,
+		"error_message": errorMessage,
+	})
+}
+
+// update only writes some columns of a download. Saving the whole row
+// would overwrite changes made since it was read, like lease renewals
+// by the butlerd instance driving it.
+func (d *Download) update(conn *sqlite.Conn, values builder.Eq) {
+	MustUpdate(conn, &Download{}, hades.Where(builder.Eq{"id": d.ID}), values)
+}
+
 func DiscardDownloadsByCaveID(conn *sqlite.Conn, caveID string) {
 	MustUpdate(conn, &Download{},
 		hades.Where(builder.Eq{"cave_id": caveID}),
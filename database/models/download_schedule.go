@@ -0,0 +1,37 @@
+package models
+
+import (
+	"crawshaw.io/sqlite"
+	"xorm.io/builder"
+)
+
+// DownloadSchedule restricts when Downloads.Drive performs downloads.
+// There's only ever one, with ID DownloadScheduleID.
+type DownloadSchedule struct {
+	ID string `hades:"primary_key"`
+
+	// Daily window in local time, as "HH:MM". Both empty if
+	// downloads may happen at any time of the day.
+	WindowStart string
+	WindowEnd   string
+
+	// Don't download while the connection is metered
+	PauseWhenMetered bool
+}
+
+const DownloadScheduleID = "default"
+
+// GetDownloadSchedule returns the download schedule, which
+// doesn't restrict anything if it was never set.
+func GetDownloadSchedule(conn *sqlite.Conn) *DownloadSchedule {
+	ds := &DownloadSchedule{
+		ID: DownloadScheduleID,
+	}
+	MustSelectOne(conn, ds, builder.Eq{"id": DownloadScheduleID})
+	return ds
+}
+
+func (ds *DownloadSchedule) Save(conn *sqlite.Conn) {
+	ds.ID = DownloadScheduleID
+	MustSave(conn, ds)
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"path/filepath"
+	"strconv"
 
 	"crawshaw.io/sqlite"
 	"xorm.io/builder"
@@ -13,6 +14,15 @@ type InstallLocation struct {
 
 	Path string `json:"path"`
 
+	// If set, builds installed here are shared with other butlerd
+	// instances on the local network, and installs here look for
+	// them there first. See package lanshare.
+	LANSharing bool `json:"lanSharing"`
+
+	// If set, and butlerd has a store, files of builds installed here
+	// are linked from it. See package store.
+	StoreLinks bool `json:"storeLinks"`
+
 	Caves []*Cave `json:"caves"`
 }
 
@@ -32,6 +42,19 @@ func (il *InstallLocation) GetStagingFolder(installID string) string {
 	return filepath.Join(il.Path, "downloads", installID)
 }
 
+// GetSavesFolder returns where snapshots of a game's saves are kept.
+// They're per game rather than per cave, so they outlive uninstalls.
+func (il *InstallLocation) GetSavesFolder(gameID int64) string {
+	return filepath.Join(il.Path, "saves", strconv.FormatInt(gameID, 10))
+}
+
+// GetSandboxHomeFolder returns the private home folder of a cave
+// launched in a sandbox, when its policy asks for one. It's kept out
+// of the install folder so it survives reinstalls and repairs.
+func (il *InstallLocation) GetSandboxHomeFolder(caveID string) string {
+	return filepath.Join(il.Path, "homes", caveID)
+}
+
 func (il *InstallLocation) GetCaves(conn *sqlite.Conn) []*Cave {
 	MustPreload(conn, il,
 		hades.Assoc("Caves"),
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/hades"
+	"xorm.io/builder"
+)
+
+// MaxLaunchSessionsPerCave is how many launch sessions are kept
+// for each cave, older ones are pruned.
+const MaxLaunchSessionsPerCave = 50
+
+// MaxLaunchSessionOutput is how many bytes of standard output
+// and standard error are kept for each launch session.
+const MaxLaunchSessionOutput = 32 * 1024
+
+// LaunchSession records a single launch of a cave, so players
+// have something to show when they report a crash.
+type LaunchSession struct {
+	// An UUID
+	ID string `json:"id" hades:"primary_key"`
+
+	CaveID   string `json:"caveId"`
+	GameID   int64  `json:"gameId"`
+	UploadID int64  `json:"uploadId"`
+	BuildID  int64  `json:"buildId"`
+
+	// Launch strategy used (native, html, url, shell)
+	Strategy string `json:"strategy"`
+	// Name of the manifest action used, if any
+	ActionName string `json:"actionName"`
+	Sandbox    bool   `json:"sandbox"`
+
+	StartedAt *time.Time `json:"startedAt"`
+	// Nil if the session hasn't ended (or if butler died before it did)
+	EndedAt *time.Time `json:"endedAt"`
+
+	// Only set by launchers that wait for the game to exit
+	ExitCode *int64 `json:"exitCode"`
+	Crashed  bool   `json:"crashed"`
+	// Short error message, if the launch failed
+	ErrorMessage string `json:"errorMessage"`
+
+	// Last bytes of the game's output, see MaxLaunchSessionOutput
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+func LaunchSessionByID(conn *sqlite.Conn, id string) *LaunchSession {
+	var ls LaunchSession
+	if MustSelectOne(conn, &ls, builder.Eq{"id": id}) {
+		return &ls
+	}
+	return nil
+}
+
+// LaunchSessions returns launch sessions matching cond, most recent first.
+// A limit of 0 means no limit.
+func LaunchSessions(conn *sqlite.Conn, cond builder.Cond, limit int64) []*LaunchSession {
+	var sessions []*LaunchSession
+	search := hades.Search{}.OrderBy("started_at DESC")
+	if limit > 0 {
+		search = search.Limit(limit)
+	}
+	MustSelect(conn, &sessions, cond, search)
+	return sessions
+}
+
+func LaunchSessionsByCaveID(conn *sqlite.Conn, caveID string, limit int64) []*LaunchSession {
+	return LaunchSessions(conn, builder.Eq{"cave_id": caveID}, limit)
+}
+
+func (ls *LaunchSession) Save(conn *sqlite.Conn) {
+	MustSave(conn, ls)
+}
+
+// PruneLaunchSessions deletes a cave's oldest launch sessions,
+// so that only MaxLaunchSessionsPerCave are left.
+func PruneLaunchSessions(conn *sqlite.Conn, caveID string) {
+	sessions := LaunchSessionsByCaveID(conn, caveID, 0)
+	if len(sessions) <= MaxLaunchSessionsPerCave {
+		return
+	}
+
+	var ids []interface{}
+	for _, ls := range sessions[MaxLaunchSessionsPerCave:] {
+		ids = append(ids, ls.ID)
+	}
+	MustDelete(conn, &LaunchSession{}, builder.In("id", ids...))
+}
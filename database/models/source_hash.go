@@ -0,0 +1,32 @@
+package models
+
+import (
+	"crawshaw.io/sqlite"
+	"xorm.io/builder"
+)
+
+// SourceHash remembers which upload (and build) a local install source
+// came from, so Install.FromFile can recognize it later by its hash.
+type SourceHash struct {
+	// Hex-encoded SHA-256 of the file, or of the files
+	// of a folder, see operate.HashLocalSource
+	Hash string `json:"hash" hades:"primary_key"`
+	Size int64  `json:"size"`
+
+	GameID   int64 `json:"gameId"`
+	UploadID int64 `json:"uploadId"`
+	// Zero if the upload isn't wharf-enabled
+	BuildID int64 `json:"buildId"`
+}
+
+func SourceHashByHash(conn *sqlite.Conn, hash string) *SourceHash {
+	var sh SourceHash
+	if MustSelectOne(conn, &sh, builder.Eq{"hash": hash}) {
+		return &sh
+	}
+	return nil
+}
+
+func (sh *SourceHash) Save(conn *sqlite.Conn) {
+	MustSave(conn, sh)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/hades"
+	"xorm.io/builder"
+)
+
+// StoreBlob is a file kept in the local dedupe store, see
+// the installer/store package.
+type StoreBlob struct {
+	// Key of the file, derived from its wharf block hashes
+	Hash string `hades:"primary_key"`
+	Size int64
+
+	CreatedAt *time.Time
+}
+
+// StoreRef records that a file of an install folder is a link
+// to a blob of the store.
+type StoreRef struct {
+	// Absolute path of the linked file
+	Path string `hades:"primary_key"`
+	Hash string
+
+	// Size and modification time of the file when it was linked,
+	// for links that don't share an inode with the blob (reflinks)
+	Size    int64
+	ModTime int64
+}
+
+// StoreRefsInFolder returns refs whose path starts with folder. Since
+// '_' is a wildcard for LIKE, callers should check the paths too.
+func StoreRefsInFolder(conn *sqlite.Conn, folder string) []*StoreRef {
+	var refs []*StoreRef
+	MustSelect(conn, &refs, builder.Like{"path", folder + "%"}, hades.Search{})
+	return refs
+}
+
+func AllStoreRefs(conn *sqlite.Conn) []*StoreRef {
+	var refs []*StoreRef
+	MustSelect(conn, &refs, builder.NewCond(), hades.Search{})
+	return refs
+}
+
+func AllStoreBlobs(conn *sqlite.Conn) []*StoreBlob {
+	var blobs []*StoreBlob
+	MustSelect(conn, &blobs, builder.NewCond(), hades.Search{})
+	return blobs
+}
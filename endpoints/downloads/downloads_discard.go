@@ -18,8 +18,7 @@ func DownloadsDiscard(rc *butlerd.RequestContext, params butlerd.DownloadsDiscar
 			consumer.Statf("Discarded download for %s", operate.GameToString(download.Game))
 
 			// TODO: check whether it's dangerous to discard or not (if cave will be left morphing)
-			download.Discarded = true
-			download.Save(conn)
+			download.Discard(conn)
 		}
 	})
 
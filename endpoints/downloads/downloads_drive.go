@@ -6,8 +6,11 @@ import (
 	"io/ioutil"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/itchio/wharf/werrors"
 
 	"github.com/itchio/httpkit/neterr"
@@ -43,9 +46,11 @@ type tempLockfileErr interface {
 func DownloadsDrive(rc *butlerd.RequestContext, params butlerd.DownloadsDriveParams) (*butlerd.DownloadsDriveResult, error) {
 	consumer := rc.Consumer
 
-	// TODO: implement downloads drive lock via the database.
-
-	consumer.Infof("Now driving downloads...")
+	slots := int(params.Slots)
+	if slots < 1 {
+		slots = 1
+	}
+	consumer.Infof("Now driving downloads (%d slots)...", slots)
 
 	parentCtx := rc.Ctx
 	ctx, cancelFunc := context.WithCancel(parentCtx)
@@ -57,6 +62,19 @@ func DownloadsDrive(rc *butlerd.RequestContext, params butlerd.DownloadsDrivePar
 		Online: true,
 	}
 
+	d := &driver{
+		rc:      rc,
+		ctx:     ctx,
+		slots:   slots,
+		owner:   uuid.New().String(),
+		running: make(map[string]context.CancelFunc),
+		wakeup:  make(chan struct{}, 1),
+
+		schedule: butlerd.DownloadsDriveScheduleStatusNotification{
+			Status: butlerd.ScheduleStatusActive,
+		},
+	}
+
 poll:
 	for {
 		select {
@@ -68,30 +86,204 @@ poll:
 			// let's keep going
 		}
 
-		err := cleanDiscarded(rc)
+		err := d.cleanDiscarded()
 		if err != nil {
 			consumer.Warnf("%+v", errors.WithMessage(err, "while cleaning discarded:"))
 		}
 
-		err = performOne(ctx, rc)
-		if err != nil {
-			if err == butlerd.CodeNetworkDisconnected {
-				err = waitForInternet(rc, status)
-				if err != nil {
-					consumer.Warnf("%+v", errors.WithMessage(err, "while waiting for internet:"))
-				}
-			} else {
-				consumer.Warnf("%+v", errors.WithMessage(err, "while performing download:"))
+		if d.wentOffline() {
+			err = waitForInternet(rc, status)
+			if err != nil {
+				consumer.Warnf("%+v", errors.WithMessage(err, "while waiting for internet:"))
 			}
 		}
 
-		time.Sleep(1 * time.Second)
+		if d.checkSchedule() {
+			d.fillSlots()
+		}
+
+		select {
+		case <-d.wakeup:
+		case <-ctx.Done():
+		case <-time.After(1 * time.Second):
+		}
 	}
 
+	d.wg.Wait()
+
 	res := &butlerd.DownloadsDriveResult{}
 	return res, nil
 }
 
+// driver performs up to `slots` downloads at the same time,
+// picking them by order of priority.
+type driver struct {
+	rc    *butlerd.RequestContext
+	ctx   context.Context
+	slots int
+
+	// identifies this drive in download leases
+	owner string
+
+	mutex sync.Mutex
+	// cancel funcs of running downloads, by download ID
+	running map[string]context.CancelFunc
+	offline bool
+
+	// last status sent, see checkSchedule
+	schedule butlerd.DownloadsDriveScheduleStatusNotification
+	// last problem with the schedule we warned about
+	scheduleWarning string
+
+	wakeup chan struct{}
+	wg     sync.WaitGroup
+}
+
+// nextDownloads returns the downloads that should be performed right now:
+// the first pending ones, by position, that nobody else has a lease on.
+func (d *driver) nextDownloads(conn *sqlite.Conn) []*models.Download {
+	var downloads []*models.Download
+	models.MustSelect(conn, &downloads,
+		builder.And(
+			builder.IsNull{"finished_at"},
+			builder.Not{builder.Expr("discarded")},
+			models.DownloadLeaseAvailable(d.owner),
+		),
+		hades.Search{}.OrderBy("position ASC").Limit(int64(d.slots)),
+	)
+	return downloads
+}
+
+func (d *driver) fillSlots() {
+	d.mutex.Lock()
+	free := d.slots - len(d.running)
+	d.mutex.Unlock()
+	if free <= 0 {
+		return
+	}
+
+	var started []*models.Download
+	d.rc.WithConn(func(conn *sqlite.Conn) {
+		for _, download := range d.nextDownloads(conn) {
+			if len(started) >= free {
+				break
+			}
+
+			d.mutex.Lock()
+			_, running := d.running[download.ID]
+			d.mutex.Unlock()
+			if running {
+				continue
+			}
+
+			if !models.AcquireDownloadLease(conn, download.ID, d.owner) {
+				continue
+			}
+
+			download.Preload(conn)
+			started = append(started, download)
+		}
+	})
+
+	for _, download := range started {
+		ctx, cancelFunc := context.WithCancel(d.ctx)
+		d.mutex.Lock()
+		d.running[download.ID] = cancelFunc
+		d.mutex.Unlock()
+
+		d.wg.Add(1)
+		go d.work(ctx, download)
+	}
+}
+
+func (d *driver) work(ctx context.Context, download *models.Download) {
+	defer d.wg.Done()
+
+	rc := d.rc.Fork(ctx)
+	err := d.performOne(rc, download)
+
+	rc.WithConn(func(conn *sqlite.Conn) {
+		models.ReleaseDownloadLease(conn, download.ID, d.owner)
+	})
+
+	d.mutex.Lock()
+	if cancelFunc, ok := d.running[download.ID]; ok {
+		cancelFunc()
+		delete(d.running, download.ID)
+	}
+	if err != nil {
+		if err == butlerd.CodeNetworkDisconnected {
+			d.offline = true
+		} else {
+			rc.Consumer.Warnf("%+v", errors.WithMessage(err, "while performing download:"))
+		}
+	}
+	d.mutex.Unlock()
+
+	// a slot just freed up
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// wentOffline returns true if a download failed because we lost
+// our internet connection since the last call.
+func (d *driver) wentOffline() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	offline := d.offline
+	d.offline = false
+	return offline
+}
+
+// checkSchedule returns true if the download schedule allows downloading
+// right now. If it doesn't, running downloads are paused: they'll resume
+// from their staging folder later. Schedule status changes are notified.
+func (d *driver) checkSchedule() bool {
+	rc := d.rc
+	consumer := rc.Consumer
+
+	var schedule *models.DownloadSchedule
+	rc.WithConn(func(conn *sqlite.Conn) {
+		schedule = models.GetDownloadSchedule(conn)
+	})
+	status, err := scheduleStatus(schedule, time.Now(), isMetered())
+	if err != nil {
+		if err.Error() != d.scheduleWarning {
+			d.scheduleWarning = err.Error()
+			consumer.Warnf("Ignoring download schedule window: %s", err.Error())
+		}
+	} else {
+		d.scheduleWarning = ""
+	}
+
+	if status.Status != d.schedule.Status || status.Reason != d.schedule.Reason {
+		d.schedule = status
+		switch status.Reason {
+		case butlerd.ScheduleIdleReasonOutsideWindow:
+			consumer.Opf("Outside of the download window, idling until %s", status.ResumesAt.Format("15:04"))
+		case butlerd.ScheduleIdleReasonMetered:
+			consumer.Opf("Connection is metered, idling until it isn't")
+		default:
+			consumer.Statf("Download schedule allows downloading again, resuming")
+		}
+		messages.DownloadsDriveScheduleStatus.Notify(rc, status)
+	}
+
+	if status.Status == butlerd.ScheduleStatusActive {
+		return true
+	}
+
+	d.mutex.Lock()
+	for _, cancelFunc := range d.running {
+		cancelFunc()
+	}
+	d.mutex.Unlock()
+	return false
+}
+
 func waitForInternet(rc *butlerd.RequestContext, status *Status) error {
 	consumer := rc.Consumer
 
@@ -131,15 +323,28 @@ func waitForInternet(rc *butlerd.RequestContext, status *Status) error {
 	return nil
 }
 
-func cleanDiscarded(rc *butlerd.RequestContext) error {
+// cleanDiscarded wipes discarded downloads, once they've stopped
+func (d *driver) cleanDiscarded() error {
+	rc := d.rc
 	consumer := rc.Consumer
 
 	var discardedDownloads []*models.Download
 	rc.WithConn(func(conn *sqlite.Conn) {
-		models.MustSelect(conn, &discardedDownloads, builder.Expr("discarded"), hades.Search{})
+		models.MustSelect(conn, &discardedDownloads, builder.And(
+			builder.Expr("discarded"),
+			models.DownloadLeaseAvailable(d.owner),
+		), hades.Search{})
 		models.PreloadDownloads(conn, discardedDownloads)
 	})
 	for _, download := range discardedDownloads {
+		d.mutex.Lock()
+		_, running := d.running[download.ID]
+		d.mutex.Unlock()
+		if running {
+			// its worker will notice soon enough
+			continue
+		}
+
 		consumer.Opf("Cleaning up download for %s", operate.GameToString(download.Game))
 
 		if download.StagingFolder == "" {
@@ -175,32 +380,13 @@ func cleanDiscarded(rc *butlerd.RequestContext) error {
 	return nil
 }
 
-func performOne(parentCtx context.Context, rc *butlerd.RequestContext) error {
+// performOne performs a download, with a request context of its own.
+// The caller must hold its lease.
+func (d *driver) performOne(rc *butlerd.RequestContext, download *models.Download) error {
 	consumer := rc.Consumer
+	consumer.Infof("Performing download for %s", operate.GameToString(download.Game))
 
-	var pendingDownloads []*models.Download
-	var download *models.Download
-	rc.WithConn(func(conn *sqlite.Conn) {
-		models.MustSelect(conn, &pendingDownloads,
-			builder.And(
-				builder.IsNull{"finished_at"},
-				builder.Not{builder.Expr("discarded")},
-			),
-			hades.Search{}.OrderBy("position ASC"),
-		)
-		if len(pendingDownloads) == 0 {
-			return
-		}
-
-		download = pendingDownloads[0]
-		download.Preload(conn)
-	})
-	if download == nil {
-		return nil
-	}
-	consumer.Infof("%d pending downloads, performing for %s", len(pendingDownloads), operate.GameToString(download.Game))
-
-	ctx, cancelFunc := context.WithCancel(parentCtx)
+	ctx, cancelFunc := context.WithCancel(rc.Ctx)
 	defer cancelFunc()
 
 	wasDiscarded := func() bool {
@@ -224,34 +410,37 @@ func performOne(parentCtx context.Context, rc *butlerd.RequestContext) error {
 
 		// has something else been prioritized?
 		{
-			var priorityDownloadID string
+			var deprioritized bool
 			rc.WithConn(func(conn *sqlite.Conn) {
-				models.MustExecWithSearch(conn,
-					builder.Select("id").From("downloads").Where(
-						builder.And(
-							builder.IsNull{"finished_at"},
-							builder.Not{builder.Expr("discarded")},
-						),
-					),
-					hades.Search{}.Limit(1),
-					func(stmt *sqlite.Stmt) error {
-						priorityDownloadID = stmt.ColumnText(0)
-						return nil
-					},
-				)
+				deprioritized = true
+				for _, next := range d.nextDownloads(conn) {
+					if next.ID == download.ID {
+						deprioritized = false
+					}
+				}
 			})
-			if priorityDownloadID != download.ID {
-				consumer.Infof("%s deprioritized (for %s), bailing out!", download.ID, priorityDownloadID)
+			if deprioritized {
+				consumer.Infof("%s deprioritized, bailing out!", download.ID)
 				return true
 			}
 		}
 		return false
 	}
+	renewLease := func() bool {
+		var renewed bool
+		rc.WithConn(func(conn *sqlite.Conn) {
+			renewed = models.AcquireDownloadLease(conn, download.ID, d.owner)
+		})
+		if !renewed {
+			consumer.Warnf("Lost lease on download %s, bailing out!", download.ID)
+		}
+		return renewed
+	}
 	goGadgetoDiscardWatcher := func() {
 		for {
 			select {
 			case <-time.After(5 * time.Second):
-				if wasDiscarded() {
+				if !renewLease() || wasDiscarded() {
 					cancelFunc()
 				}
 			case <-ctx.Done():
@@ -382,7 +571,7 @@ func performOne(parentCtx context.Context, rc *butlerd.RequestContext) error {
 
 		finishedAt := time.Now().UTC()
 		download.FinishedAt = &finishedAt
-		rc.WithConn(download.Save)
+		rc.WithConn(download.SaveOutcome)
 
 		messages.DownloadsDriveErrored.Notify(rc, butlerd.DownloadsDriveErroredNotification{
 			Download: formatDownload(download),
@@ -394,7 +583,7 @@ func performOne(parentCtx context.Context, rc *butlerd.RequestContext) error {
 	consumer.Infof("Download finished!")
 	finishedAt := time.Now().UTC()
 	download.FinishedAt = &finishedAt
-	rc.WithConn(download.Save)
+	rc.WithConn(download.SaveOutcome)
 
 	messages.DownloadsDriveFinished.Notify(rc, butlerd.DownloadsDriveFinishedNotification{
 		Download: formatDownload(download),
@@ -0,0 +1,117 @@
+package downloads
+
+import (
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/hades"
+	"github.com/stretchr/testify/assert"
+	"xorm.io/builder"
+)
+
+func downloadIDs(downloads []*models.Download) []string {
+	var ids []string
+	for _, d := range downloads {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+func TestDownloadLeases(t *testing.T) {
+	dbtest.WithConn(t, func(conn *sqlite.Conn) {
+		for i, id := range []string{"first", "second", "third"} {
+			models.MustSave(conn, &models.Download{ID: id, Position: int64(i)})
+		}
+
+		// two butlerd instances sharing a database
+		alice := &driver{owner: "alice", slots: 2}
+		bob := &driver{owner: "bob", slots: 2}
+
+		assert.EqualValues(t, []string{"first", "second"}, downloadIDs(alice.nextDownloads(conn)))
+		assert.True(t, models.AcquireDownloadLease(conn, "first", alice.owner))
+		assert.True(t, models.AcquireDownloadLease(conn, "second", alice.owner))
+
+		// bob only sees what alice isn't driving
+		assert.False(t, models.AcquireDownloadLease(conn, "first", bob.owner))
+		assert.EqualValues(t, []string{"third"}, downloadIDs(bob.nextDownloads(conn)))
+		assert.True(t, models.AcquireDownloadLease(conn, "third", bob.owner))
+
+		// renewing your own lease works
+		assert.True(t, models.AcquireDownloadLease(conn, "first", alice.owner))
+		assert.EqualValues(t, []string{"first", "second"}, downloadIDs(alice.nextDownloads(conn)))
+
+		// released leases are up for grabs
+		models.ReleaseDownloadLease(conn, "second", alice.owner)
+		assert.EqualValues(t, []string{"second", "third"}, downloadIDs(bob.nextDownloads(conn)))
+
+		// releasing someone else's lease does nothing
+		models.ReleaseDownloadLease(conn, "first", bob.owner)
+		assert.False(t, models.AcquireDownloadLease(conn, "first", bob.owner))
+
+		// so do expired leases, if alice crashed for example
+		expiredAt := time.Now().UTC().Add(-time.Second)
+		models.MustUpdate(conn, &models.Download{},
+			hades.Where(builder.Eq{"id": "first"}),
+			builder.Eq{"lease_expires_at": expiredAt.Format(time.RFC3339Nano)},
+		)
+		assert.True(t, models.AcquireDownloadLease(conn, "first", bob.owner))
+		assert.False(t, models.AcquireDownloadLease(conn, "first", alice.owner))
+	})
+}
+
+func TestDownloadUpdatesKeepLease(t *testing.T) {
+	dbtest.WithConn(t, func(conn *sqlite.Conn) {
+		models.MustSave(conn, &models.Download{ID: "other", Position: 0})
+		models.MustSave(conn, &models.Download{ID: "game", Position: 1})
+
+		// read before a drive takes the lease
+		stale := models.DownloadByID(conn, "game")
+		assert.True(t, models.AcquireDownloadLease(conn, "game", "alice"))
+
+		assertLease := func() {
+			download := models.DownloadByID(conn, "game")
+			assert.EqualValues(t, "alice", download.LeaseOwner)
+			assert.NotNil(t, download.LeaseExpiresAt)
+		}
+
+		stale.Prioritize(conn)
+		assertLease()
+		assert.EqualValues(t, -1, models.DownloadByID(conn, "game").Position)
+
+		errorMessage := "oh no"
+		errorCode := int64(500)
+		finishedAt := time.Now().UTC()
+		stale.Error = &errorMessage
+		stale.ErrorMessage = &errorMessage
+		stale.ErrorCode = &errorCode
+		stale.FinishedAt = &finishedAt
+		stale.SaveOutcome(conn)
+		assertLease()
+
+		failed := models.DownloadByID(conn, "game")
+		if assert.NotNil(t, failed.ErrorCode) {
+			assert.EqualValues(t, 500, *failed.ErrorCode)
+		}
+		assert.NotNil(t, failed.FinishedAt)
+
+		// retrying clears the outcome
+		stale.Error = nil
+		stale.ErrorMessage = nil
+		stale.ErrorCode = nil
+		stale.FinishedAt = nil
+		stale.SaveOutcome(conn)
+		assertLease()
+
+		retried := models.DownloadByID(conn, "game")
+		assert.Nil(t, retried.Error)
+		assert.Nil(t, retried.ErrorCode)
+		assert.Nil(t, retried.FinishedAt)
+
+		stale.Discard(conn)
+		assertLease()
+		assert.True(t, models.DownloadByID(conn, "game").Discarded)
+	})
+}
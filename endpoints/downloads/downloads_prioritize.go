@@ -10,8 +10,7 @@ func DownloadsPrioritize(rc *butlerd.RequestContext, params butlerd.DownloadsPri
 	var download *models.Download
 	rc.WithConn(func(conn *sqlite.Conn) {
 		download = ValidateDownload(conn, params.DownloadID)
-		download.Position = models.DownloadMinPosition(conn) - 1
-		download.Save(conn)
+		download.Prioritize(conn)
 	})
 
 	res := &butlerd.DownloadsPrioritizeResult{}
@@ -20,7 +20,7 @@ func DownloadsRetry(rc *butlerd.RequestContext, params butlerd.DownloadsRetryPar
 			download.ErrorCode = nil
 			download.ErrorMessage = nil
 			download.FinishedAt = nil
-			download.Save(conn)
+			download.SaveOutcome(conn)
 
 			consumer.Statf("Queued a retry for download for %s", operate.GameToString(download.Game))
 		}
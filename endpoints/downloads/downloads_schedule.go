@@ -0,0 +1,149 @@
+package downloads
+
+import (
+	"sync/atomic"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/models"
+	"github.com/pkg/errors"
+)
+
+func DownloadsScheduleGet(rc *butlerd.RequestContext, params butlerd.DownloadsScheduleGetParams) (*butlerd.DownloadsScheduleGetResult, error) {
+	var schedule *models.DownloadSchedule
+	rc.WithConn(func(conn *sqlite.Conn) {
+		schedule = models.GetDownloadSchedule(conn)
+	})
+
+	res := &butlerd.DownloadsScheduleGetResult{
+		Schedule: formatSchedule(schedule),
+	}
+	return res, nil
+}
+
+func DownloadsScheduleSet(rc *butlerd.RequestContext, params butlerd.DownloadsScheduleSetParams) (*butlerd.DownloadsScheduleSetResult, error) {
+	schedule := &models.DownloadSchedule{
+		PauseWhenMetered: params.Schedule.PauseWhenMetered,
+	}
+	if w := params.Schedule.Window; w != nil {
+		for _, t := range []string{w.Start, w.End} {
+			_, err := parseTimeOfDay(t)
+			if err != nil {
+				return nil, errors.WithMessage(err, "download window")
+			}
+		}
+		schedule.WindowStart = w.Start
+		schedule.WindowEnd = w.End
+		rc.Consumer.Statf("Downloads will only happen between %s and %s", w.Start, w.End)
+	}
+	rc.WithConn(schedule.Save)
+
+	res := &butlerd.DownloadsScheduleSetResult{}
+	return res, nil
+}
+
+func formatSchedule(schedule *models.DownloadSchedule) *butlerd.DownloadSchedule {
+	ds := &butlerd.DownloadSchedule{
+		PauseWhenMetered: schedule.PauseWhenMetered,
+	}
+	if schedule.WindowStart != "" && schedule.WindowEnd != "" {
+		ds.Window = &butlerd.DownloadWindow{
+			Start: schedule.WindowStart,
+			End:   schedule.WindowEnd,
+		}
+	}
+	return ds
+}
+
+var metered int32
+
+// SetMetered records whether the connection is metered,
+// as reported by the client.
+func SetMetered(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&metered, v)
+}
+
+func isMetered() bool {
+	return atomic.LoadInt32(&metered) == 1
+}
+
+// scheduleStatus returns whether the schedule allows downloads at
+// the given time, and if not, why. If the schedule's window is invalid,
+// it's ignored and an error is returned along with the status.
+func scheduleStatus(schedule *models.DownloadSchedule, now time.Time, metered bool) (butlerd.DownloadsDriveScheduleStatusNotification, error) {
+	var windowErr error
+	if schedule.WindowStart != "" && schedule.WindowEnd != "" {
+		allowed, resumesAt, err := inWindow(now, schedule.WindowStart, schedule.WindowEnd)
+		if err != nil {
+			windowErr = errors.WithMessage(err, "download window")
+		} else if !allowed {
+			return butlerd.DownloadsDriveScheduleStatusNotification{
+				Status:    butlerd.ScheduleStatusIdle,
+				Reason:    butlerd.ScheduleIdleReasonOutsideWindow,
+				ResumesAt: &resumesAt,
+			}, nil
+		}
+	}
+
+	if schedule.PauseWhenMetered && metered {
+		return butlerd.DownloadsDriveScheduleStatusNotification{
+			Status: butlerd.ScheduleStatusIdle,
+			Reason: butlerd.ScheduleIdleReasonMetered,
+		}, windowErr
+	}
+
+	return butlerd.DownloadsDriveScheduleStatusNotification{
+		Status: butlerd.ScheduleStatusActive,
+	}, windowErr
+}
+
+// inWindow returns whether now is within the daily window going from
+// start to end (as "HH:MM", in now's location), and if not, when it
+// opens next. A window whose end is before its start spans midnight,
+// one whose start and end are equal spans the whole day.
+func inWindow(now time.Time, start string, end string) (bool, time.Time, error) {
+	startMinutes, err := parseTimeOfDay(start)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	endMinutes, err := parseTimeOfDay(end)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+	var allowed bool
+	switch {
+	case startMinutes == endMinutes:
+		allowed = true
+	case startMinutes < endMinutes:
+		allowed = minutes >= startMinutes && minutes < endMinutes
+	default:
+		allowed = minutes >= startMinutes || minutes < endMinutes
+	}
+	if allowed {
+		return true, time.Time{}, nil
+	}
+
+	y, m, d := now.Date()
+	opens := time.Date(y, m, d, startMinutes/60, startMinutes%60, 0, 0, now.Location())
+	if !opens.After(now) {
+		opens = opens.AddDate(0, 0, 1)
+	}
+	return false, opens, nil
+}
+
+// parseTimeOfDay returns the number of minutes since midnight of a
+// time formatted as "HH:MM", the same as DownloadWindow.Validate accepts.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != len("15:04") {
+		return 0, errors.Errorf("invalid time of day (%s), expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
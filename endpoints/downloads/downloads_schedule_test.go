@@ -0,0 +1,151 @@
+package downloads
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, 3, 14, hour, minute, 0, 0, time.UTC)
+	}
+
+	allowed, _, err := inWindow(at(3, 0), "01:00", "07:00")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, opens, err := inWindow(at(7, 0), "01:00", "07:00")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.EqualValues(t, time.Date(2020, 3, 15, 1, 0, 0, 0, time.UTC), opens)
+
+	allowed, opens, err = inWindow(at(0, 30), "01:00", "07:00")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.EqualValues(t, at(1, 0), opens)
+
+	// spans midnight
+	allowed, _, err = inWindow(at(23, 30), "23:00", "06:00")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, _, err = inWindow(at(2, 0), "23:00", "06:00")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, opens, err = inWindow(at(12, 0), "23:00", "06:00")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.EqualValues(t, at(23, 0), opens)
+
+	// whole day
+	allowed, _, err = inWindow(at(12, 0), "04:00", "04:00")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	_, _, err = inWindow(at(12, 0), "25:00", "04:00")
+	assert.Error(t, err)
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	minutes, err := parseTimeOfDay("00:00")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, minutes)
+
+	minutes, err = parseTimeOfDay("23:59")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 23*60+59, minutes)
+
+	for _, s := range []string{"", "24:00", "12:60", "1:00", "01:5", "01:00pm", "01:00 ", " 01:00", "01:00:00", "-1:00"} {
+		_, err := parseTimeOfDay(s)
+		assert.Error(t, err, "%q", s)
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+	noon := time.Date(2020, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	status, err := scheduleStatus(&models.DownloadSchedule{}, noon, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, butlerd.ScheduleStatusActive, status.Status)
+
+	status, err = scheduleStatus(&models.DownloadSchedule{PauseWhenMetered: true}, noon, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, butlerd.ScheduleStatusIdle, status.Status)
+	assert.EqualValues(t, butlerd.ScheduleIdleReasonMetered, status.Reason)
+
+	status, err = scheduleStatus(&models.DownloadSchedule{
+		WindowStart: "01:00",
+		WindowEnd:   "07:00",
+	}, noon, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, butlerd.ScheduleStatusIdle, status.Status)
+	assert.EqualValues(t, butlerd.ScheduleIdleReasonOutsideWindow, status.Reason)
+	assert.NotNil(t, status.ResumesAt)
+
+	// a bad window is reported, and doesn't hide the rest of the schedule
+	status, err = scheduleStatus(&models.DownloadSchedule{
+		WindowStart:      "01:00pm",
+		WindowEnd:        "07:00",
+		PauseWhenMetered: true,
+	}, noon, true)
+	assert.Error(t, err)
+	assert.EqualValues(t, butlerd.ScheduleStatusIdle, status.Status)
+	assert.EqualValues(t, butlerd.ScheduleIdleReasonMetered, status.Reason)
+}
+
+func TestDownloadsScheduleSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "downloads-schedule")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	dbPool := dbtest.OpenPool(t, dir, 1)
+	defer dbPool.Close()
+
+	rc := butlerd.NewRequestContext(context.Background(), &state.Consumer{}, nil, dbPool)
+
+	set := func(window *butlerd.DownloadWindow) error {
+		_, err := DownloadsScheduleSet(rc, butlerd.DownloadsScheduleSetParams{
+			Schedule: &butlerd.DownloadSchedule{Window: window},
+		})
+		return err
+	}
+	get := func() *butlerd.DownloadSchedule {
+		res, err := DownloadsScheduleGet(rc, butlerd.DownloadsScheduleGetParams{})
+		wtest.Must(t, err)
+		return res.Schedule
+	}
+
+	wtest.Must(t, set(&butlerd.DownloadWindow{Start: "23:00", End: "06:00"}))
+	if window := get().Window; assert.NotNil(t, window) {
+		assert.EqualValues(t, "23:00", window.Start)
+		assert.EqualValues(t, "06:00", window.End)
+	}
+
+	for _, window := range []*butlerd.DownloadWindow{
+		{Start: "01:00pm", End: "06:00"},
+		{Start: "23:00", End: "6:00"},
+		{Start: "23:00", End: "24:00"},
+		{Start: "", End: "06:00"},
+	} {
+		assert.Error(t, set(window), "%s to %s", window.Start, window.End)
+	}
+
+	// the previous schedule is still there
+	if window := get().Window; assert.NotNil(t, window) {
+		assert.EqualValues(t, "23:00", window.Start)
+	}
+
+	wtest.Must(t, set(nil))
+	assert.Nil(t, get().Window)
+}
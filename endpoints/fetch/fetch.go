@@ -18,6 +18,7 @@ func Register(router *butlerd.Router) {
 	messages.FetchCommons.Register(router, FetchCommons)
 	messages.FetchCave.Register(router, FetchCave)
 	messages.FetchCaves.Register(router, FetchCaves)
+	messages.FetchCaveSessions.Register(router, FetchCaveSessions)
 	messages.FetchExpireAll.Register(router, FetchExpireAll)
 	messages.FetchDownloadKey.Register(router, FetchDownloadKey)
 }
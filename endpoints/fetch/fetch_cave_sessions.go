@@ -0,0 +1,54 @@
+package fetch
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
+	"github.com/itchio/butler/database/models"
+)
+
+func FetchCaveSessions(rc *butlerd.RequestContext, params butlerd.FetchCaveSessionsParams) (*butlerd.FetchCaveSessionsResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	res := &butlerd.FetchCaveSessionsResult{
+		Sessions: []*butlerd.LaunchSession{},
+	}
+	rc.WithConn(func(conn *sqlite.Conn) {
+		for _, ls := range models.LaunchSessionsByCaveID(conn, cave.ID, params.Limit) {
+			res.Sessions = append(res.Sessions, FormatLaunchSession(ls, true))
+		}
+	})
+	return res, nil
+}
+
+// FormatLaunchSession converts a launch session for butlerd clients. The
+// game's output can be large, so it's only included if withOutput is set.
+func FormatLaunchSession(ls *models.LaunchSession, withOutput bool) *butlerd.LaunchSession {
+	if ls == nil {
+		return nil
+	}
+
+	res := &butlerd.LaunchSession{
+		ID:       ls.ID,
+		CaveID:   ls.CaveID,
+		GameID:   ls.GameID,
+		UploadID: ls.UploadID,
+		BuildID:  ls.BuildID,
+
+		Strategy:   ls.Strategy,
+		ActionName: ls.ActionName,
+		Sandbox:    ls.Sandbox,
+
+		StartedAt: ls.StartedAt,
+		EndedAt:   ls.EndedAt,
+
+		ExitCode:     ls.ExitCode,
+		Crashed:      ls.Crashed,
+		ErrorMessage: ls.ErrorMessage,
+	}
+	if withOutput {
+		res.Stdout = ls.Stdout
+		res.Stderr = ls.Stderr
+	}
+	return res
+}
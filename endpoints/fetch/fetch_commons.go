@@ -61,8 +61,10 @@ func FetchCommons(rc *butlerd.RequestContext, params butlerd.FetchCommonsParams)
 
 func FormatInstallLocation(conn *sqlite.Conn, consumer *state.Consumer, il *models.InstallLocation) *butlerd.InstallLocationSummary {
 	sum := &butlerd.InstallLocationSummary{
-		ID:   il.ID,
-		Path: il.Path,
+		ID:         il.ID,
+		Path:       il.Path,
+		LANSharing: il.LANSharing,
+		StoreLinks: il.StoreLinks,
 		SizeInfo: &butlerd.InstallLocationSizeInfo{
 			InstalledSize: -1,
 			FreeSize:      -1,
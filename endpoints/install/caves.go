@@ -3,7 +3,9 @@ package install
 import (
 	"crawshaw.io/sqlite"
 	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
 	"github.com/itchio/butler/database/models"
+	"github.com/pkg/errors"
 )
 
 func CavesSetPinned(rc *butlerd.RequestContext, params butlerd.CavesSetPinnedParams) (*butlerd.CavesSetPinnedResult, error) {
@@ -15,3 +17,72 @@ func CavesSetPinned(rc *butlerd.RequestContext, params butlerd.CavesSetPinnedPar
 
 	return &butlerd.CavesSetPinnedResult{}, nil
 }
+
+func CavesSetSandboxPolicy(rc *butlerd.RequestContext, params butlerd.CavesSetSandboxPolicyParams) (*butlerd.CavesSetSandboxPolicyResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+	err := operate.SetCaveSandboxPolicy(cave, params.Policy)
+	if err != nil {
+		return nil, err
+	}
+	rc.WithConn(cave.Save)
+
+	return &butlerd.CavesSetSandboxPolicyResult{}, nil
+}
+
+func CavesGetSandboxPolicy(rc *butlerd.RequestContext, params butlerd.CavesGetSandboxPolicyParams) (*butlerd.CavesGetSandboxPolicyResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+	policy, err := operate.GetCaveSandboxPolicy(cave)
+	if err != nil {
+		return nil, err
+	}
+
+	return &butlerd.CavesGetSandboxPolicyResult{
+		Policy: policy,
+	}, nil
+}
+
+func CavesVerify(rc *butlerd.RequestContext, params butlerd.CavesVerifyParams) (*butlerd.CavesVerifyResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+	return operate.VerifyCave(rc.Ctx, rc, cave)
+}
+
+func CavesRepair(rc *butlerd.RequestContext, params butlerd.CavesRepairParams) (*butlerd.CavesRepairResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	// installing the same build over a cave heals it, which only
+	// fetches damaged files. non-wharf uploads are reinstalled.
+	queueRes, err := InstallQueue(rc, butlerd.InstallQueueParams{
+		CaveID: cave.ID,
+		Reason: butlerd.DownloadReasonReinstall,
+		Game:   cave.Game,
+		Upload: cave.Upload,
+		Build:  cave.Build,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	_, err = InstallPerform(rc, butlerd.InstallPerformParams{
+		ID:            queueRes.ID,
+		StagingFolder: queueRes.StagingFolder,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &butlerd.CavesRepairResult{}, nil
+}
+
+func CavesRollback(rc *butlerd.RequestContext, params butlerd.CavesRollbackParams) (*butlerd.CavesRollbackResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	receipt, err := operate.RollbackCave(rc.Ctx, rc, cave)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &butlerd.CavesRollbackResult{
+		Build: receipt.Build,
+	}
+	return res, nil
+}
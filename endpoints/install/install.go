@@ -10,14 +10,30 @@ func Register(router *butlerd.Router) {
 	messages.InstallPlan.Register(router, InstallPlan)
 	messages.InstallQueue.Register(router, InstallQueue)
 	messages.InstallPerform.Register(router, InstallPerform)
+	messages.InstallFromFile.Register(router, InstallFromFile)
 	messages.InstallCancel.Register(router, InstallCancel)
 	messages.UninstallPerform.Register(router, UninstallPerform)
+	messages.UninstallPlan.Register(router, UninstallPlan)
 	messages.InstallVersionSwitchQueue.Register(router, InstallVersionSwitchQueue)
 	messages.InstallLocationsGetByID.Register(router, InstallLocationsGetByID)
 	messages.InstallLocationsList.Register(router, InstallLocationsList)
 	messages.InstallLocationsAdd.Register(router, InstallLocationsAdd)
 	messages.InstallLocationsRemove.Register(router, InstallLocationsRemove)
 	messages.InstallLocationsScan.Register(router, InstallLocationsScan)
+	messages.InstallLocationsSetLANSharing.Register(router, InstallLocationsSetLANSharing)
+	messages.InstallLocationsSetStoreLinks.Register(router, InstallLocationsSetStoreLinks)
 
 	messages.CavesSetPinned.Register(router, CavesSetPinned)
+	messages.CavesSetSandboxPolicy.Register(router, CavesSetSandboxPolicy)
+	messages.CavesGetSandboxPolicy.Register(router, CavesGetSandboxPolicy)
+	messages.CavesVerify.Register(router, CavesVerify)
+	messages.CavesRepair.Register(router, CavesRepair)
+	messages.CavesRollback.Register(router, CavesRollback)
+	messages.CavesSavesList.Register(router, CavesSavesList)
+	messages.CavesSavesBackup.Register(router, CavesSavesBackup)
+	messages.CavesSavesRestore.Register(router, CavesSavesRestore)
+	messages.CavesScanIntegrity.Register(router, CavesScanIntegrity)
+	messages.CavesGetIntegrity.Register(router, CavesGetIntegrity)
+	messages.CavesScanScheduleGet.Register(router, CavesScanScheduleGet)
+	messages.CavesScanScheduleSet.Register(router, CavesScanScheduleSet)
 }
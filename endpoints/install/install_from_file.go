@@ -0,0 +1,198 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/hades"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/united"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+func InstallFromFile(rc *butlerd.RequestContext, params butlerd.InstallFromFileParams) (*butlerd.InstallFromFileResult, error) {
+	consumer := rc.Consumer
+
+	path, err := filepath.Abs(params.Path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stats, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	consumer.Infof("Hashing (%s)...", path)
+	hash, size, err := operate.HashLocalSource(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "hashing install source")
+	}
+	consumer.Infof("Source is %s, SHA-256 %s", united.FormatBytes(size), hash)
+
+	var match *sourceMatch
+	rc.WithConn(func(conn *sqlite.Conn) {
+		match, err = matchSource(conn, consumer, params, path, stats.IsDir(), hash, size)
+	})
+	if err != nil {
+		return nil, err
+	}
+	game, upload, build := match.game, match.upload, match.build
+
+	if match.by == butlerd.SourceMatchFilename {
+		consumer.Warnf("Guessed by file name and size:")
+	} else {
+		consumer.Infof("Matched by %s:", match.by)
+	}
+	operate.LogUpload(consumer, upload, build)
+
+	queueRes, err := InstallQueue(rc, butlerd.InstallQueueParams{
+		CaveID:            params.CaveID,
+		InstallLocationID: params.InstallLocationID,
+		Game:              game,
+		Upload:            upload,
+		Build:             build,
+		IgnoreInstallers:  params.IgnoreInstallers,
+		LocalSource:       path,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	_, err = InstallPerform(rc, butlerd.InstallPerformParams{
+		ID:            queueRes.ID,
+		StagingFolder: queueRes.StagingFolder,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// the build is only recorded if the installed files matched its signature
+	receipt, err := bfs.ReadReceipt(queueRes.InstallFolder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var installedBuild *itchio.Build
+	if receipt != nil {
+		installedBuild = receipt.Build
+	}
+	verified := build != nil && installedBuild != nil && installedBuild.ID == build.ID
+
+	// guesses are only remembered once a signature confirmed them
+	if match.by != butlerd.SourceMatchFilename || verified {
+		sh := &models.SourceHash{
+			Hash:     hash,
+			Size:     size,
+			GameID:   game.ID,
+			UploadID: upload.ID,
+		}
+		if verified {
+			sh.BuildID = build.ID
+		}
+		rc.WithConn(sh.Save)
+	}
+	return &butlerd.InstallFromFileResult{
+		CaveID:        queueRes.CaveID,
+		Game:          queueRes.Game,
+		Upload:        queueRes.Upload,
+		Build:         installedBuild,
+		InstallFolder: queueRes.InstallFolder,
+		MatchedBy:     match.by,
+		Verified:      verified,
+	}, nil
+}
+
+type sourceMatch struct {
+	by     butlerd.SourceMatch
+	game   *itchio.Game
+	upload *itchio.Upload
+	// nil if the upload isn't wharf-enabled
+	build *itchio.Build
+}
+
+// matchSource finds which upload (and build) a local source is, see
+// InstallFromFileParams. The build it returns still needs to be checked
+// against the installed files, it may not be the right one.
+func matchSource(conn *sqlite.Conn, consumer *state.Consumer, params butlerd.InstallFromFileParams, path string, isDir bool, hash string, size int64) (*sourceMatch, error) {
+	match := &sourceMatch{}
+	buildID := params.BuildID
+
+	var gu *models.GameUpload
+	if params.UploadID != 0 {
+		gu = gameUploadByUploadID(conn, params.UploadID)
+		if gu == nil {
+			return nil, errors.Errorf("Upload %d not found, its game's uploads must be fetched first", params.UploadID)
+		}
+		match.by = butlerd.SourceMatchID
+	} else if sh := models.SourceHashByHash(conn, hash); sh != nil {
+		gu = gameUploadByUploadID(conn, sh.UploadID)
+		if gu != nil {
+			match.by = butlerd.SourceMatchHash
+			if buildID == 0 {
+				buildID = sh.BuildID
+			}
+		}
+	}
+
+	if gu == nil && !isDir {
+		var candidates []*itchio.Upload
+		models.MustSelect(conn, &candidates, builder.And(
+			builder.Eq{
+				"filename": filepath.Base(path),
+				"size":     size,
+			},
+			builder.Expr("id IN (SELECT upload_id FROM game_uploads)"),
+		), hades.Search{})
+		if len(candidates) == 1 {
+			gu = gameUploadByUploadID(conn, candidates[0].ID)
+			match.by = butlerd.SourceMatchFilename
+		} else if len(candidates) > 1 {
+			consumer.Warnf("%d uploads have that name and size, not guessing", len(candidates))
+		}
+	}
+
+	if gu == nil {
+		return nil, errors.Errorf("Could not tell which upload (%s) is, specify an upload ID", path)
+	}
+	match.upload = gu.Upload
+
+	match.game = models.GameByID(conn, gu.GameID)
+	if match.game == nil {
+		return nil, errors.Errorf("Game %d not found, it must be fetched first", gu.GameID)
+	}
+
+	if buildID == 0 {
+		// a guess as well, the source may be of an older build
+		buildID = match.upload.BuildID
+	}
+	if buildID != 0 {
+		var b itchio.Build
+		if models.MustSelectOne(conn, &b, builder.Eq{"id": buildID}) {
+			match.build = &b
+		} else {
+			// the ID is all later updates need
+			match.build = &itchio.Build{ID: buildID}
+		}
+	}
+	return match, nil
+}
+
+// gameUploadByUploadID returns the game an upload belongs to,
+// with the upload preloaded.
+func gameUploadByUploadID(conn *sqlite.Conn, uploadID int64) *models.GameUpload {
+	var gu models.GameUpload
+	if !models.MustSelectOne(conn, &gu, builder.Eq{"upload_id": uploadID}) {
+		return nil
+	}
+	models.MustPreload(conn, &gu, hades.Assoc("Upload"))
+	if gu.Upload == nil {
+		return nil
+	}
+	return &gu
+}
@@ -0,0 +1,80 @@
+package install
+
+import (
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/hades"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchSource(t *testing.T) {
+	dbtest.WithConn(t, func(conn *sqlite.Conn) {
+		game := &itchio.Game{ID: 1, Title: "Garden"}
+		models.MustSave(conn, game)
+		uploads := []*itchio.Upload{
+			{ID: 10, Filename: "garden-linux.zip", Size: 1024, BuildID: 101},
+			{ID: 11, Filename: "garden-windows.zip", Size: 2048},
+			{ID: 12, Filename: "garden-extras.zip", Size: 512},
+			{ID: 13, Filename: "garden-extras.zip", Size: 512},
+		}
+		for i, u := range uploads {
+			models.MustSave(conn, &models.GameUpload{
+				GameID:   game.ID,
+				UploadID: u.ID,
+				Upload:   u,
+				Position: int64(i),
+			}, hades.Assoc("Upload"))
+		}
+		models.MustSave(conn, &models.SourceHash{Hash: "known", Size: 1024, GameID: 1, UploadID: 10, BuildID: 100})
+
+		consumer := &state.Consumer{}
+		match := func(params butlerd.InstallFromFileParams, name string, isDir bool, hash string, size int64) (*sourceMatch, error) {
+			return matchSource(conn, consumer, params, filepath.Join("downloads", name), isDir, hash, size)
+		}
+
+		// by ID, the build defaults to the upload's latest
+		m, err := match(butlerd.InstallFromFileParams{UploadID: 10}, "whatever.zip", false, "unknown", 1)
+		wtest.Must(t, err)
+		assert.EqualValues(t, butlerd.SourceMatchID, m.by)
+		assert.EqualValues(t, 1, m.game.ID)
+		assert.EqualValues(t, 10, m.upload.ID)
+		assert.EqualValues(t, 101, m.build.ID)
+
+		m, err = match(butlerd.InstallFromFileParams{UploadID: 10, BuildID: 99}, "whatever.zip", false, "unknown", 1)
+		wtest.Must(t, err)
+		assert.EqualValues(t, 99, m.build.ID)
+
+		_, err = match(butlerd.InstallFromFileParams{UploadID: 404}, "whatever.zip", false, "unknown", 1)
+		assert.Error(t, err)
+
+		// by hash, with the build it was installed as
+		m, err = match(butlerd.InstallFromFileParams{}, "renamed.zip", false, "known", 1024)
+		wtest.Must(t, err)
+		assert.EqualValues(t, butlerd.SourceMatchHash, m.by)
+		assert.EqualValues(t, 10, m.upload.ID)
+		assert.EqualValues(t, 100, m.build.ID)
+
+		// guessed by name and size
+		m, err = match(butlerd.InstallFromFileParams{}, "garden-windows.zip", false, "unknown", 2048)
+		wtest.Must(t, err)
+		assert.EqualValues(t, butlerd.SourceMatchFilename, m.by)
+		assert.EqualValues(t, 11, m.upload.ID)
+		assert.Nil(t, m.build)
+
+		// not when it's ambiguous, the size differs, or it's a folder
+		_, err = match(butlerd.InstallFromFileParams{}, "garden-extras.zip", false, "unknown", 512)
+		assert.Error(t, err)
+		_, err = match(butlerd.InstallFromFileParams{}, "garden-windows.zip", false, "unknown", 2047)
+		assert.Error(t, err)
+		_, err = match(butlerd.InstallFromFileParams{}, "garden-windows.zip", true, "unknown", 2048)
+		assert.Error(t, err)
+	})
+}
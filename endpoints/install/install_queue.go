@@ -88,6 +88,8 @@ func InstallQueue(rc *butlerd.RequestContext, queueParams butlerd.InstallQueuePa
 	params.StagingFolder = stagingFolder
 	params.Reason = reason
 	params.IgnoreInstallers = queueParams.IgnoreInstallers
+	params.LocalSource = queueParams.LocalSource
+	params.KeepRollback = queueParams.KeepRollback
 
 	if queueParams.Game == nil {
 		return nil, errors.New("Missing game in install")
@@ -135,6 +137,10 @@ func InstallQueue(rc *butlerd.RequestContext, queueParams butlerd.InstallQueuePa
 	params.Upload = queueParams.Upload
 	params.Build = queueParams.Build
 
+	if params.Upload == nil && params.LocalSource != "" {
+		return nil, errors.New("With localSource, upload must be specified")
+	}
+
 	if params.Upload == nil {
 		consumer.Infof("No upload specified, looking for compatible ones...")
 		uploadsFilterResult, err := operate.GetFilteredUploads(rc.Ctx, client, params.Game, params.Access.Credentials, consumer)
@@ -191,7 +197,7 @@ func InstallQueue(rc *butlerd.RequestContext, queueParams butlerd.InstallQueuePa
 	}
 
 	// params.Upload can't be nil by now
-	if params.Build == nil {
+	if params.Build == nil && params.LocalSource == "" {
 		// We were passed an upload but not a build:
 		// Let's refresh upload info so we can settle on a build we want to install (if any)
 
@@ -0,0 +1,68 @@
+package install
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
+	"github.com/itchio/butler/database/models"
+	"github.com/pkg/errors"
+)
+
+func CavesScanIntegrity(rc *butlerd.RequestContext, params butlerd.CavesScanIntegrityParams) (*butlerd.CavesScanIntegrityResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+	integrity, err := operate.ScanCave(rc.Ctx, rc, cave)
+	if err != nil {
+		return nil, err
+	}
+	if integrity == nil {
+		return nil, errors.Errorf("No list of installed files for cave %s, it can only be verified", cave.ID)
+	}
+
+	res := &butlerd.CavesScanIntegrityResult{
+		Integrity: integrity,
+	}
+	return res, nil
+}
+
+func CavesGetIntegrity(rc *butlerd.RequestContext, params butlerd.CavesGetIntegrityParams) (*butlerd.CavesGetIntegrityResult, error) {
+	var ci *models.CaveIntegrity
+	rc.WithConn(func(conn *sqlite.Conn) {
+		ci = models.CaveIntegrityByCaveID(conn, params.CaveID)
+	})
+
+	res := &butlerd.CavesGetIntegrityResult{}
+	if ci != nil {
+		integrity, err := operate.FormatCaveIntegrity(ci)
+		if err != nil {
+			return nil, err
+		}
+		res.Integrity = integrity
+	}
+	return res, nil
+}
+
+func CavesScanScheduleGet(rc *butlerd.RequestContext, params butlerd.CavesScanScheduleGetParams) (*butlerd.CavesScanScheduleGetResult, error) {
+	var schedule *models.IntegrityScanSchedule
+	rc.WithConn(func(conn *sqlite.Conn) {
+		schedule = models.GetIntegrityScanSchedule(conn)
+	})
+
+	res := &butlerd.CavesScanScheduleGetResult{
+		Schedule: &butlerd.IntegrityScanSchedule{
+			Enabled:       schedule.Enabled,
+			IntervalHours: int64(schedule.Interval().Hours()),
+		},
+	}
+	return res, nil
+}
+
+func CavesScanScheduleSet(rc *butlerd.RequestContext, params butlerd.CavesScanScheduleSetParams) (*butlerd.CavesScanScheduleSetResult, error) {
+	schedule := &models.IntegrityScanSchedule{
+		Enabled:       params.Schedule.Enabled,
+		IntervalHours: params.Schedule.IntervalHours,
+	}
+	rc.WithConn(schedule.Save)
+
+	res := &butlerd.CavesScanScheduleSetResult{}
+	return res, nil
+}
@@ -148,3 +148,49 @@ func InstallLocationsRemove(rc *butlerd.RequestContext, params butlerd.InstallLo
 	res := &butlerd.InstallLocationsRemoveResult{}
 	return res, nil
 }
+
+func InstallLocationsSetLANSharing(rc *butlerd.RequestContext, params butlerd.InstallLocationsSetLANSharingParams) (*butlerd.InstallLocationsSetLANSharingResult, error) {
+	conn := rc.GetConn()
+	defer rc.PutConn(conn)
+
+	il := models.InstallLocationByID(conn, params.ID)
+	if il == nil {
+		return nil, errors.Errorf("install location (%s) not found", params.ID)
+	}
+
+	models.MustUpdate(conn, &models.InstallLocation{},
+		hades.Where(builder.Eq{"id": il.ID}),
+		builder.Eq{"lan_sharing": params.Enabled},
+	)
+	if params.Enabled {
+		rc.Consumer.Statf("Sharing builds installed in (%s) on the local network", il.Path)
+	} else {
+		rc.Consumer.Statf("No longer sharing builds installed in (%s)", il.Path)
+	}
+
+	res := &butlerd.InstallLocationsSetLANSharingResult{}
+	return res, nil
+}
+
+func InstallLocationsSetStoreLinks(rc *butlerd.RequestContext, params butlerd.InstallLocationsSetStoreLinksParams) (*butlerd.InstallLocationsSetStoreLinksResult, error) {
+	conn := rc.GetConn()
+	defer rc.PutConn(conn)
+
+	il := models.InstallLocationByID(conn, params.ID)
+	if il == nil {
+		return nil, errors.Errorf("install location (%s) not found", params.ID)
+	}
+
+	models.MustUpdate(conn, &models.InstallLocation{},
+		hades.Where(builder.Eq{"id": il.ID}),
+		builder.Eq{"store_links": params.Enabled},
+	)
+	if params.Enabled {
+		rc.Consumer.Statf("Linking files of builds installed in (%s) from the store", il.Path)
+	} else {
+		rc.Consumer.Statf("No longer linking files of builds installed in (%s) from the store", il.Path)
+	}
+
+	res := &butlerd.InstallLocationsSetStoreLinksResult{}
+	return res, nil
+}
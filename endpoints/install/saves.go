@@ -0,0 +1,57 @@
+package install
+
+import (
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
+)
+
+func CavesSavesList(rc *butlerd.RequestContext, params butlerd.CavesSavesListParams) (*butlerd.CavesSavesListResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	var installFolder string
+	var sandboxHome string
+	rc.WithConn(func(conn *sqlite.Conn) {
+		installFolder = cave.GetInstallFolder(conn)
+		sandboxHome = cave.GetInstallLocation(conn).GetSandboxHomeFolder(cave.ID)
+	})
+
+	files, err := operate.FindSaves(rc.Consumer, cave.Game, installFolder, sandboxHome)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &butlerd.CavesSavesListResult{
+		Files:     files,
+		Snapshots: operate.ListSaveSnapshots(rc, cave.GameID),
+	}
+	return res, nil
+}
+
+func CavesSavesBackup(rc *butlerd.RequestContext, params butlerd.CavesSavesBackupParams) (*butlerd.CavesSavesBackupResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	snapshot, err := operate.BackupSaves(rc, cave, cave.Game, butlerd.SaveSnapshotReasonManual)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &butlerd.CavesSavesBackupResult{
+		Snapshot: snapshot,
+	}
+	return res, nil
+}
+
+func CavesSavesRestore(rc *butlerd.RequestContext, params butlerd.CavesSavesRestoreParams) (*butlerd.CavesSavesRestoreResult, error) {
+	cave := operate.ValidateCave(rc, params.CaveID)
+
+	snapshot, err := operate.RestoreSaves(rc, cave, cave.Game, params.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &butlerd.CavesSavesRestoreResult{
+		Snapshot: snapshot,
+	}
+	return res, nil
+}
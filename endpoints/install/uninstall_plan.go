@@ -0,0 +1,10 @@
+package install
+
+import (
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/cmd/operate"
+)
+
+func UninstallPlan(rc *butlerd.RequestContext, params butlerd.UninstallPlanParams) (*butlerd.UninstallPlanResult, error) {
+	return operate.UninstallPlan(rc, params)
+}
@@ -0,0 +1,72 @@
+package launch
+
+import (
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/google/uuid"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/endpoints/fetch"
+	"xorm.io/builder"
+)
+
+const defaultHistoryLimit = 50
+
+func LaunchHistory(rc *butlerd.RequestContext, params butlerd.LaunchHistoryParams) (*butlerd.LaunchHistoryResult, error) {
+	limit := params.Limit
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+
+	var cond builder.Cond = builder.NewCond()
+	if params.CrashedOnly {
+		cond = builder.Expr("crashed")
+	}
+
+	res := &butlerd.LaunchHistoryResult{
+		Sessions: []*butlerd.LaunchSession{},
+	}
+	rc.WithConn(func(conn *sqlite.Conn) {
+		for _, ls := range models.LaunchSessions(conn, cond, limit) {
+			res.Sessions = append(res.Sessions, fetch.FormatLaunchSession(ls, false))
+		}
+	})
+	return res, nil
+}
+
+// startLaunchSession records that a cave is being launched. It's saved
+// right away, so there's a trace of it even if butler doesn't survive
+// the launch. The strategy and action are filled in once they're known.
+func startLaunchSession(rc *butlerd.RequestContext, cave *models.Cave) *models.LaunchSession {
+	startedAt := time.Now().UTC()
+	ls := &models.LaunchSession{
+		ID:       uuid.New().String(),
+		CaveID:   cave.ID,
+		GameID:   cave.GameID,
+		UploadID: cave.UploadID,
+		BuildID:  cave.BuildID,
+
+		StartedAt: &startedAt,
+	}
+
+	rc.WithConn(ls.Save)
+	return ls
+}
+
+func endLaunchSession(rc *butlerd.RequestContext, ls *models.LaunchSession, launchErr error) {
+	endedAt := time.Now().UTC()
+	ls.EndedAt = &endedAt
+	if launchErr != nil {
+		ls.ErrorMessage = launchErr.Error()
+		// the player changing their mind isn't a crash
+		if be, ok := butlerd.AsButlerdError(launchErr); !ok || be.RpcErrorCode() != int64(butlerd.CodeOperationAborted) {
+			ls.Crashed = true
+		}
+	}
+
+	rc.WithConn(func(conn *sqlite.Conn) {
+		ls.Save(conn)
+		models.PruneLaunchSessions(conn, ls.CaveID)
+	})
+}
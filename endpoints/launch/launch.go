@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,15 +41,18 @@ var ErrCandidateDisappeared = goerrors.New("candidate disappeared from disk!")
 
 func Register(router *butlerd.Router) {
 	messages.Launch.Register(router, Launch)
+	messages.LaunchHistory.Register(router, LaunchHistory)
 }
 
-func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.LaunchResult, error) {
+func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (res *butlerd.LaunchResult, retErr error) {
 	consumer := rc.Consumer
 
 	cave := operate.ValidateCave(rc, params.CaveID)
 	var installFolder string
+	var sandboxHomeFolder string
 	rc.WithConn(func(conn *sqlite.Conn) {
 		installFolder = cave.GetInstallFolder(conn)
+		sandboxHomeFolder = cave.GetInstallLocation(conn).GetSandboxHomeFolder(cave.ID)
 	})
 
 	_, err := os.Stat(installFolder)
@@ -66,6 +70,15 @@ func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.L
 	}
 	defer rlock.Unlock()
 
+	// recorded from here on, so that launches that fail before
+	// the game even runs show up in the history too.
+	launchSession := startLaunchSession(rc, cave)
+	defer func() {
+		if launchSession.EndedAt == nil {
+			endLaunchSession(rc, launchSession, retErr)
+		}
+	}()
+
 	game := cave.Game
 	upload := cave.Upload
 	build := cave.Build
@@ -455,10 +468,20 @@ func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.L
 
 	var args = []string{}
 	var env = make(map[string]string)
+	var workingDirectory string
 
 	if manifestAction != nil {
 		args = append(args, manifestAction.Args...)
 
+		for k, v := range manifest.ExpandEnv(manifestAction, runtime, installFolder) {
+			env[k] = v
+		}
+
+		workingDirectory = manifest.ExpandCwd(manifestAction, runtime, installFolder)
+		if workingDirectory != "" {
+			consumer.Infof("Manifest asks for working directory (%s)", workingDirectory)
+		}
+
 		err = requestAPIKeyIfNecessary(rc, manifestAction, game, access, env)
 		if err != nil {
 			return nil, errors.WithMessage(err, "While requesting API key")
@@ -471,6 +494,22 @@ func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.L
 		sandbox = true
 	}
 
+	var manifestPolicy *butlerd.SandboxPolicy
+	if manifestAction != nil {
+		manifestPolicy = manifestAction.SandboxPolicy
+	}
+	playerPolicy, err := operate.GetCaveSandboxPolicy(cave)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sandboxPolicy := ResolveSandboxPolicy(runtime, installFolder, manifestPolicy, playerPolicy)
+	if sandbox {
+		consumer.Infof("Sandbox policy: %s", sandboxPolicy)
+		if len(sandboxPolicy.DeniedPaths) > 0 {
+			consumer.Warnf("The manifest asks for access to (%s), which only the player can allow", strings.Join(sandboxPolicy.DeniedPaths, ", "))
+		}
+	}
+
 	crashed := false
 	sessionWatcherDone := make(chan struct{})
 	sessionStartedChan := make(chan struct{})
@@ -589,6 +628,13 @@ func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.L
 
 	go sessionWatcher()
 
+	launchSession.Strategy = string(strategy)
+	launchSession.Sandbox = sandbox
+	if manifestAction != nil {
+		launchSession.ActionName = manifestAction.Name
+	}
+	rc.WithConn(launchSession.Save)
+
 	launcherParams := LauncherParams{
 		RequestContext: rc,
 		Ctx:            rc.Ctx,
@@ -598,24 +644,35 @@ func Launch(rc *butlerd.RequestContext, params butlerd.LaunchParams) (*butlerd.L
 		AppManifest:    appManifest,
 		Action:         manifestAction,
 		Sandbox:        sandbox,
+		SandboxPolicy:  sandboxPolicy,
 		Args:           args,
 		Env:            env,
 
+		WorkingDirectory: workingDirectory,
+
 		PrereqsDir:    params.PrereqsDir,
 		ForcePrereqs:  params.ForcePrereqs,
 		Access:        access,
 		InstallFolder: installFolder,
 		Runtime:       runtime,
 
+		SandboxHomeFolder: sandboxHomeFolder,
+
 		SessionStarted: func() {
 			startSessionOnce.Do(func() {
 				close(sessionStartedChan)
 			})
 		},
+		SessionExited: func(exitCode int64, stdout string, stderr string) {
+			launchSession.ExitCode = &exitCode
+			launchSession.Stdout = stdout
+			launchSession.Stderr = stderr
+		},
 	}
 
 	err = launcher.Do(launcherParams)
 	close(sessionEndedChan)
+	endLaunchSession(rc, launchSession, err)
 	if err != nil {
 		crashed = true
 		return nil, errors.WithStack(err)
@@ -0,0 +1,177 @@
+// +build linux
+
+package native
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+// bubblewrapBackend uses the system's bubblewrap, which
+// doesn't need to be setuid on most distributions.
+type bubblewrapBackend struct{}
+
+var _ sandboxBackend = (*bubblewrapBackend)(nil)
+
+func (bb *bubblewrapBackend) Name() string {
+	return "bubblewrap"
+}
+
+// bubblewrap's availability doesn't change while we're running,
+// and checking it means starting a process.
+var bubblewrapCheck struct {
+	once       sync.Once
+	binaryPath string
+	err        error
+}
+
+func findBubblewrap() (string, error) {
+	bubblewrapCheck.once.Do(func() {
+		binaryPath, err := exec.LookPath("bwrap")
+		if err != nil {
+			bubblewrapCheck.err = errors.WithStack(err)
+			return
+		}
+
+		// bubblewrap is useless if unprivileged user namespaces are disabled
+		// and it's not setuid, so make sure it can actually run something.
+		err = exec.Command(binaryPath, "--ro-bind", "/", "/", "--", "true").Run()
+		if err != nil {
+			bubblewrapCheck.err = errors.Errorf("bubblewrap found at (%s) but not usable: %s", binaryPath, err.Error())
+			return
+		}
+		bubblewrapCheck.binaryPath = binaryPath
+	})
+	return bubblewrapCheck.binaryPath, bubblewrapCheck.err
+}
+
+func (bb *bubblewrapBackend) Available(params launch.LauncherParams) bool {
+	_, err := findBubblewrap()
+	if err != nil {
+		params.RequestContext.Consumer.Infof("Not using bubblewrap: %s", err.Error())
+		return false
+	}
+	return true
+}
+
+func (bb *bubblewrapBackend) Prereqs(params launch.LauncherParams) []string {
+	return nil
+}
+
+func (bb *bubblewrapBackend) Runner(params launch.LauncherParams, profile *sandboxProfile, runParams runner.RunnerParams) (runner.Runner, error) {
+	binaryPath, err := findBubblewrap()
+	if err != nil {
+		return nil, err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	xauthority := os.Getenv("XAUTHORITY")
+	if xauthority == "" {
+		xauthority = filepath.Join(home, ".Xauthority")
+	}
+
+	return &sandboxRunner{
+		params:     runParams,
+		binaryPath: binaryPath,
+		args:       bubblewrapArgs(profile, home, os.Getenv("XDG_RUNTIME_DIR"), xauthority, runParams.Dir),
+	}, nil
+}
+
+// bubblewrapArgs translates a sandbox profile into bwrap arguments.
+// Mounts are applied in order, so later ones win.
+func bubblewrapArgs(profile *sandboxProfile, home string, runtimeDir string, xauthority string, cwd string) []string {
+	args := []string{
+		"--die-with-parent",
+		// the rest of the system is visible, but read-only
+		"--ro-bind", "/", "/",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	}
+
+	if !profile.Network {
+		args = append(args, "--unshare-net")
+	}
+
+	if profile.GPU && profile.Audio {
+		args = append(args, "--dev-bind", "/dev", "/dev")
+	} else {
+		// only the basics (null, zero, random, etc.)
+		args = append(args, "--dev", "/dev")
+		if profile.GPU {
+			args = append(args, "--dev-bind-try", "/dev/dri", "/dev/dri")
+			nvidiaDevices, _ := filepath.Glob("/dev/nvidia*")
+			for _, device := range nvidiaDevices {
+				args = append(args, "--dev-bind", device, device)
+			}
+		}
+		if profile.Audio {
+			args = append(args, "--dev-bind-try", "/dev/snd", "/dev/snd")
+		}
+	}
+
+	if !profile.Audio && runtimeDir != "" {
+		// hide sound servers' sockets
+		args = append(args, "--tmpfs", filepath.Join(runtimeDir, "pulse"))
+		pipewireSocket := filepath.Join(runtimeDir, "pipewire-0")
+		if _, err := os.Stat(pipewireSocket); err == nil {
+			args = append(args, "--ro-bind", "/dev/null", pipewireSocket)
+		}
+	}
+
+	// the X server socket lives in /tmp
+	const x11Sockets = "/tmp/.X11-unix"
+	if _, err := os.Stat(x11Sockets); err == nil {
+		args = append(args, "--ro-bind", x11Sockets, x11Sockets)
+	}
+
+	if profile.HomeFolder != "" {
+		// the private home hides the real one. If the install folder is in the
+		// real home, bwrap creates an empty folder in the private home to mount
+		// it on, which is why it comes after.
+		args = append(args, "--bind", profile.HomeFolder, home)
+	} else {
+		// the player opted out of the private home
+		args = append(args, "--bind", home, home)
+		for _, p := range hiddenHomePaths {
+			hiddenPath := filepath.Join(home, p)
+			stats, err := os.Stat(hiddenPath)
+			if err != nil {
+				continue
+			}
+			if stats.IsDir() {
+				args = append(args, "--tmpfs", hiddenPath)
+			} else {
+				args = append(args, "--ro-bind", "/dev/null", hiddenPath)
+			}
+		}
+	}
+
+	// X clients need the authority file, which is usually in the
+	// home folder, or in /tmp. Games have no business changing it.
+	if xauthority != "" {
+		args = append(args, "--ro-bind-try", xauthority, xauthority)
+	}
+
+	args = append(args, "--ro-bind", profile.InstallFolder, profile.InstallFolder)
+	for _, folder := range profile.ReadOnlyFolders {
+		args = append(args, "--ro-bind-try", folder, folder)
+	}
+	for _, folder := range profile.WritableFolders {
+		args = append(args, "--bind-try", folder, folder)
+	}
+
+	if cwd != "" {
+		args = append(args, "--chdir", cwd)
+	}
+	return args
+}
@@ -0,0 +1,87 @@
+// +build linux
+
+package native
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+// firejailBackend uses a setuid firejail binary, installed as a prereq
+type firejailBackend struct{}
+
+var _ sandboxBackend = (*firejailBackend)(nil)
+
+func (fb *firejailBackend) Name() string {
+	return "firejail"
+}
+
+func (fb *firejailBackend) Available(params launch.LauncherParams) bool {
+	// it's installed as a prereq if needed
+	return true
+}
+
+func (fb *firejailBackend) Prereqs(params launch.LauncherParams) []string {
+	return []string{firejailPrereqName(params)}
+}
+
+func (fb *firejailBackend) Runner(params launch.LauncherParams, profile *sandboxProfile, runParams runner.RunnerParams) (runner.Runner, error) {
+	consumer := params.RequestContext.Consumer
+
+	profilePath := filepath.Join(params.InstallFolder, ".itch", "isolate-app.profile")
+	consumer.Opf("Writing sandbox profile to (%s)", profilePath)
+	err := os.MkdirAll(filepath.Dir(profilePath), 0755)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = ioutil.WriteFile(profilePath, []byte(firejailProfile(profile)), 0644)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &sandboxRunner{
+		params:     runParams,
+		binaryPath: firejailBinaryPath(params),
+		args:       []string{fmt.Sprintf("--profile=%s", profilePath)},
+	}, nil
+}
+
+// firejailProfile translates a sandbox profile into a firejail profile
+func firejailProfile(profile *sandboxProfile) string {
+	var lines []string
+	lines = append(lines, "# Generated by butler, any changes will be overwritten")
+	if profile.HomeFolder != "" {
+		// note: folders in the real home folder are hidden by the
+		// private home, firejail can't make exceptions for them.
+		lines = append(lines, fmt.Sprintf("private %s", profile.HomeFolder))
+	} else {
+		for _, p := range hiddenHomePaths {
+			lines = append(lines, fmt.Sprintf("blacklist ~/%s", p))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("read-only %s", profile.InstallFolder))
+	for _, folder := range profile.ReadOnlyFolders {
+		lines = append(lines, fmt.Sprintf("read-only %s", folder))
+	}
+	for _, folder := range profile.WritableFolders {
+		lines = append(lines, fmt.Sprintf("read-write %s", folder))
+	}
+	if !profile.Network {
+		lines = append(lines, "net none")
+	}
+	if !profile.GPU {
+		lines = append(lines, "no3d")
+	}
+	if !profile.Audio {
+		lines = append(lines, "nosound")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,85 @@
+package native
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/butler/endpoints/launch/manifest"
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+type hookKind struct {
+	name string
+	code butlerd.Code
+}
+
+var (
+	hookPreLaunch = hookKind{name: "pre-launch", code: butlerd.CodePreLaunchHookFailed}
+	hookPostExit  = hookKind{name: "post-exit", code: butlerd.CodePostExitHookFailed}
+)
+
+// runHook runs a hook of the manifest action in the install folder. gameParams
+// are the runner params of the game itself, so the hook gets the same sandbox
+// settings and environment.
+func runHook(params launch.LauncherParams, gameParams runner.RunnerParams, kind hookKind, hook *butlerd.ActionHook) error {
+	if hook == nil {
+		return nil
+	}
+	consumer := params.RequestContext.Consumer
+
+	fullPath, args := manifest.ExpandHook(hook, params.Runtime, params.InstallFolder)
+	consumer.Infof("Running %s hook (%s)", kind.name, fullPath)
+
+	const maxBytes = 8 * 1024
+	stdout := newOutputCollector(maxBytes)
+	defer stdout.Close()
+	stderr := newOutputCollector(maxBytes)
+	defer stderr.Close()
+
+	runParams := gameParams
+	runParams.Console = false
+	runParams.FullTargetPath = fullPath
+	runParams.Name = fullPath
+	runParams.Dir = params.InstallFolder
+	runParams.Args = args
+	runParams.Stdout = stdout
+	runParams.Stderr = stderr
+
+	err := func() error {
+		run, err := getRunner(params, runParams)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		err = run.Prepare()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		exitCode, err := interpretRunError(run.Run())
+		stdout.Close()
+		stderr.Close()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if exitCode != 0 {
+			return errors.Errorf("Exit code %d for (%s)", exitCode, filepath.Base(fullPath))
+		}
+		return nil
+	}()
+	if err != nil {
+		consumer.Errorf("The %s hook failed: %s", kind.name, err.Error())
+		logOutput(consumer, stdout, stderr)
+		return errors.WithStack(&butlerd.RpcError{
+			Code:    int64(kind.code),
+			Message: fmt.Sprintf("%s %s", kind.code.RpcErrorMessage(), err.Error()),
+		})
+	}
+
+	consumer.Infof("The %s hook succeeded", kind.name)
+	return nil
+}
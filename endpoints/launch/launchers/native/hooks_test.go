@@ -0,0 +1,143 @@
+// +build linux
+
+package native
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/ox"
+	"github.com/itchio/smaug/runner"
+	"github.com/itchio/wharf/wtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type hookFixture struct {
+	installFolder string
+	params        launch.LauncherParams
+	gameParams    runner.RunnerParams
+
+	mutex    sync.Mutex
+	messages []string
+}
+
+func newHookFixture(t *testing.T) *hookFixture {
+	installFolder, err := ioutil.TempDir("", "hooks-test")
+	wtest.Must(t, err)
+
+	hf := &hookFixture{installFolder: installFolder}
+	consumer := &state.Consumer{
+		OnMessage: func(lvl string, msg string) {
+			hf.mutex.Lock()
+			defer hf.mutex.Unlock()
+			hf.messages = append(hf.messages, msg)
+		},
+	}
+
+	hf.params = launch.LauncherParams{
+		RequestContext: &butlerd.RequestContext{
+			Ctx:      context.Background(),
+			Consumer: consumer,
+		},
+		Ctx:           context.Background(),
+		InstallFolder: installFolder,
+		Runtime:       ox.CurrentRuntime(),
+	}
+	hf.gameParams = runner.RunnerParams{
+		Consumer:      consumer,
+		Ctx:           context.Background(),
+		InstallFolder: installFolder,
+		Runtime:       ox.CurrentRuntime(),
+	}
+	return hf
+}
+
+func (hf *hookFixture) Close() {
+	os.RemoveAll(hf.installFolder)
+}
+
+func (hf *hookFixture) writeScript(t *testing.T, name string, contents string) *butlerd.ActionHook {
+	err := ioutil.WriteFile(filepath.Join(hf.installFolder, name), []byte("#!/bin/sh\n"+contents), 0755)
+	wtest.Must(t, err)
+	return &butlerd.ActionHook{Path: name}
+}
+
+// outputLines returns the hook output that was logged
+func (hf *hookFixture) outputLines() []string {
+	hf.mutex.Lock()
+	defer hf.mutex.Unlock()
+
+	var lines []string
+	for _, msg := range hf.messages {
+		if strings.HasPrefix(msg, "  ") {
+			lines = append(lines, strings.TrimPrefix(msg, "  "))
+		}
+	}
+	return lines
+}
+
+func assertHookError(t *testing.T, err error, code butlerd.Code) {
+	if assert.Error(t, err) {
+		rpcErr, ok := errors.Cause(err).(*butlerd.RpcError)
+		if assert.True(t, ok, "hook errors should be RPC errors") {
+			assert.EqualValues(t, code, rpcErr.Code)
+		}
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	hf := newHookFixture(t)
+	defer hf.Close()
+
+	assert.NoError(t, runHook(hf.params, hf.gameParams, hookPreLaunch, nil))
+
+	ok := hf.writeScript(t, "ok.sh", "test \"$1\" = \"$(pwd)\"\n")
+	ok.Args = []string{"{{installDir}}"}
+	assert.NoError(t, runHook(hf.params, hf.gameParams, hookPreLaunch, ok))
+	assert.NoError(t, runHook(hf.params, hf.gameParams, hookPostExit, ok))
+
+	fail := hf.writeScript(t, "fail.sh", "echo 'something went wrong' >&2\nexit 3\n")
+	assertHookError(t, runHook(hf.params, hf.gameParams, hookPreLaunch, fail), butlerd.CodePreLaunchHookFailed)
+	assertHookError(t, runHook(hf.params, hf.gameParams, hookPostExit, fail), butlerd.CodePostExitHookFailed)
+	assert.Contains(t, hf.outputLines(), "something went wrong")
+
+	missing := &butlerd.ActionHook{Path: "missing.sh"}
+	assertHookError(t, runHook(hf.params, hf.gameParams, hookPreLaunch, missing), butlerd.CodePreLaunchHookFailed)
+}
+
+func TestRunHookOutputCap(t *testing.T) {
+	hf := newHookFixture(t)
+	defer hf.Close()
+
+	// 100 lines of 500 bytes each, way over the 8KB cap
+	noisy := hf.writeScript(t, "noisy.sh", `
+for i in $(seq 1 100); do
+  printf 'line %03d %0490d\n' $i 0 >&2
+done
+exit 1
+`)
+	assertHookError(t, runHook(hf.params, hf.gameParams, hookPostExit, noisy), butlerd.CodePostExitHookFailed)
+
+	lines := hf.outputLines()
+	size := 0
+	for _, l := range lines {
+		assert.Len(t, l, 499)
+		size += len(l) + 1
+	}
+	assert.True(t, size <= 8*1024, "logged %d bytes of output, expected at most 8KB", size)
+
+	// only the last lines are kept
+	if assert.NotEmpty(t, lines) {
+		assert.True(t, strings.HasPrefix(lines[len(lines)-1], "line 100 "))
+		assert.False(t, strings.HasPrefix(lines[0], "line 001 "))
+	}
+}
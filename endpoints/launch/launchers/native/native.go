@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/itchio/headway/state"
 	"github.com/itchio/httpkit/neterr"
 
 	"github.com/itchio/pelican"
@@ -24,6 +25,7 @@ import (
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/cmd/elevate"
 	"github.com/itchio/butler/cmd/wipe"
+	"github.com/itchio/butler/database/models"
 	"github.com/itchio/butler/endpoints/launch"
 	"github.com/itchio/smaug/runner"
 	"github.com/pkg/errors"
@@ -48,6 +50,9 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		// target is in
 		cwd = filepath.Dir(params.FullTargetPath)
 	}
+	if params.WorkingDirectory != "" {
+		cwd = params.WorkingDirectory
+	}
 
 	_, err = os.Stat(params.FullTargetPath)
 	if err != nil {
@@ -130,9 +135,10 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		envBlock = append(envBlock, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	const maxLines = 40
-	stdout := newOutputCollector(maxLines)
-	stderr := newOutputCollector(maxLines)
+	stdout := newOutputCollector(models.MaxLaunchSessionOutput)
+	defer stdout.Close()
+	stderr := newOutputCollector(models.MaxLaunchSessionOutput)
+	defer stderr.Close()
 
 	fullTargetPath := params.FullTargetPath
 	name := params.FullTargetPath
@@ -189,7 +195,7 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		FujiParams:     l.FujiParams(params),
 	}
 
-	run, err := runner.GetRunner(runParams)
+	run, err := getRunner(params, runParams)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -199,6 +205,17 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		return errors.WithStack(err)
 	}
 
+	var preLaunch, postExit *butlerd.ActionHook
+	if params.Action != nil {
+		preLaunch = params.Action.PreLaunch
+		postExit = params.Action.PostExit
+	}
+
+	err = runHook(params, runParams, hookPreLaunch, preLaunch)
+	if err != nil {
+		return err
+	}
+
 	err = func() error {
 		startTime := time.Now().UTC()
 		params.SessionStarted()
@@ -206,23 +223,31 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		messages.LaunchRunning.Notify(params.RequestContext, butlerd.LaunchRunningNotification{})
 		exitCode, err := interpretRunError(run.Run())
 		messages.LaunchExited.Notify(params.RequestContext, butlerd.LaunchExitedNotification{})
+
+		// make sure we have all of the output before recording it
+		stdout.Close()
+		stderr.Close()
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
 		runDuration := time.Since(startTime)
 
-		if exitCode != 0 {
-			var signedExitCode = int64(exitCode)
-			if runtime.GOOS == "windows" {
-				// Windows uses 32-bit unsigned integers as exit codes, although the
-				// command interpreter treats them as signed. If a process fails
-				// initialization, a Windows system error code may be returned.
-				signedExitCode = int64(int32(signedExitCode))
-
-				// The line above turns `4294967295` into -1
-			}
+		var signedExitCode = int64(exitCode)
+		if runtime.GOOS == "windows" {
+			// Windows uses 32-bit unsigned integers as exit codes, although the
+			// command interpreter treats them as signed. If a process fails
+			// initialization, a Windows system error code may be returned.
+			signedExitCode = int64(int32(signedExitCode))
 
+			// The line above turns `4294967295` into -1
+		}
+
+		if params.SessionExited != nil {
+			params.SessionExited(signedExitCode, stdout.String(), stderr.String())
+		}
+
+		if exitCode != 0 {
 			exeName := filepath.Base(params.FullTargetPath)
 			msg := fmt.Sprintf("Exit code 0x%x (%d) for (%s)", uint32(exitCode), signedExitCode, exeName)
 			consumer.Warnf(msg)
@@ -237,43 +262,64 @@ func (l *Launcher) Do(params launch.LauncherParams) error {
 		return nil
 	}()
 
+	// post-exit hooks run even if the game failed, they're
+	// typically used for cleanup
+	hookErr := runHook(params, runParams, hookPostExit, postExit)
+
 	if err != nil {
 		consumer.Errorf("Had error: %s", err.Error())
-		if len(stderr.Lines()) == 0 {
-			consumer.Errorf("No messages for standard error")
-			consumer.Errorf("→ Standard error: empty")
-		} else {
-			consumer.Errorf("→ Standard error ================")
-			for _, l := range stderr.Lines() {
-				consumer.Errorf("  %s", l)
-			}
-			consumer.Errorf("=================================")
-		}
-
-		if len(stdout.Lines()) == 0 {
-			consumer.Errorf("→ Standard output: empty")
-		} else {
-			consumer.Errorf("→ Standard output ===============")
-			for _, l := range stdout.Lines() {
-				consumer.Errorf("  %s", l)
-			}
-			consumer.Errorf("=================================")
-		}
+		logOutput(consumer, stdout, stderr)
 		consumer.Errorf("Relaying launch failure.")
 		return errors.WithStack(err)
 	}
 
+	if hookErr != nil {
+		return hookErr
+	}
+
 	return nil
 }
 
+func logOutput(consumer *state.Consumer, stdout *outputCollector, stderr *outputCollector) {
+	// the whole output is kept in the launch session
+	const maxLines = 40
+
+	if len(stderr.Lines()) == 0 {
+		consumer.Errorf("No messages for standard error")
+		consumer.Errorf("→ Standard error: empty")
+	} else {
+		consumer.Errorf("→ Standard error ================")
+		for _, l := range stderr.LastLines(maxLines) {
+			consumer.Errorf("  %s", l)
+		}
+		consumer.Errorf("=================================")
+	}
+
+	if len(stdout.Lines()) == 0 {
+		consumer.Errorf("→ Standard output: empty")
+	} else {
+		consumer.Errorf("→ Standard output ===============")
+		for _, l := range stdout.LastLines(maxLines) {
+			consumer.Errorf("  %s", l)
+		}
+		consumer.Errorf("=================================")
+	}
+}
+
 func (l *Launcher) FirejailParams(params launch.LauncherParams) runner.FirejailParams {
-	name := fmt.Sprintf("firejail-%s", params.Runtime.Arch())
-	binaryPath := filepath.Join(params.PrereqsDir, name, "firejail")
 	return runner.FirejailParams{
-		BinaryPath: binaryPath,
+		BinaryPath: firejailBinaryPath(params),
 	}
 }
 
+func firejailPrereqName(params launch.LauncherParams) string {
+	return fmt.Sprintf("firejail-%s", params.Runtime.Arch())
+}
+
+func firejailBinaryPath(params launch.LauncherParams) string {
+	return filepath.Join(params.PrereqsDir, firejailPrereqName(params), "firejail")
+}
+
 func (l *Launcher) FujiParams(params launch.LauncherParams) runner.FujiParams {
 	consumer := params.RequestContext.Consumer
 
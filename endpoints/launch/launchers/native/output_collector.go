@@ -3,30 +3,64 @@ package native
 import (
 	"bufio"
 	"io"
+	"strings"
+	"sync"
 )
 
+// lines longer than this are truncated
+const defaultMaxLineBytes = 64 * 1024
+
+// outputCollector keeps the last lines written to it, up to maxBytes
 type outputCollector struct {
-	lines  []string
-	writer io.Writer
+	lines    []string
+	size     int
+	maxBytes int
+
+	mutex  sync.Mutex
+	writer *io.PipeWriter
+	done   chan struct{}
 }
 
 var _ io.Writer = (*outputCollector)(nil)
 
-func newOutputCollector(maxLines int) *outputCollector {
+func newOutputCollector(maxBytes int) *outputCollector {
 	pipeR, pipeW := io.Pipe()
 
 	oc := &outputCollector{
-		writer: pipeW,
+		maxBytes: maxBytes,
+		writer:   pipeW,
+		done:     make(chan struct{}),
+	}
+
+	// a single line can't take up more than the whole collector
+	maxLineBytes := defaultMaxLineBytes
+	if maxBytes < maxLineBytes {
+		maxLineBytes = maxBytes
 	}
 
 	go func() {
-		s := bufio.NewScanner(pipeR)
-		for s.Scan() {
-			line := s.Text()
-			oc.lines = append(oc.lines, line)
+		defer close(oc.done)
+
+		r := bufio.NewReader(pipeR)
+		var line []byte
+		for {
+			chunk, isPrefix, err := r.ReadLine()
+			if err != nil {
+				// only fails once the writer is closed
+				return
+			}
 
-			if len(oc.lines) > maxLines {
-				oc.lines = oc.lines[1:]
+			// keep the start of long lines, drop the rest
+			if room := maxLineBytes - len(line); room > 0 {
+				if len(chunk) > room {
+					chunk = chunk[:room]
+				}
+				line = append(line, chunk...)
+			}
+
+			if !isPrefix {
+				oc.append(string(line))
+				line = line[:0]
 			}
 		}
 	}()
@@ -34,10 +68,49 @@ func newOutputCollector(maxLines int) *outputCollector {
 	return oc
 }
 
+func (oc *outputCollector) append(line string) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	oc.lines = append(oc.lines, line)
+	oc.size += len(line) + 1
+
+	for len(oc.lines) > 1 && oc.size > oc.maxBytes {
+		oc.size -= len(oc.lines[0]) + 1
+		oc.lines = oc.lines[1:]
+	}
+}
+
+// Lines returns the lines collected so far
 func (oc *outputCollector) Lines() []string {
-	return oc.lines
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	return append([]string(nil), oc.lines...)
+}
+
+// LastLines returns at most the n last lines collected so far
+func (oc *outputCollector) LastLines(n int) []string {
+	lines := oc.Lines()
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// String returns the lines collected so far
+func (oc *outputCollector) String() string {
+	return strings.Join(oc.Lines(), "\n")
 }
 
 func (oc *outputCollector) Write(p []byte) (int, error) {
 	return oc.writer.Write(p)
 }
+
+// Close waits for everything written so far to be collected.
+// Writing after Close fails.
+func (oc *outputCollector) Close() error {
+	oc.writer.Close()
+	<-oc.done
+	return nil
+}
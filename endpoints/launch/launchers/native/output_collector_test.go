@@ -0,0 +1,47 @@
+package native
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputCollector(t *testing.T) {
+	oc := newOutputCollector(32)
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(oc, "line %d\n", i)
+	}
+	fmt.Fprintf(oc, "no newline")
+	oc.Close()
+
+	// only the last lines that fit in 32 bytes are kept
+	assert.EqualValues(t, []string{"line 9", "line 10", "no newline"}, oc.Lines())
+	assert.EqualValues(t, []string{"line 10", "no newline"}, oc.LastLines(2))
+	assert.EqualValues(t, "line 9\nline 10\nno newline", oc.String())
+
+	_, err := oc.Write([]byte("too late\n"))
+	assert.Error(t, err)
+}
+
+func TestOutputCollectorLongLines(t *testing.T) {
+	oc := newOutputCollector(256 * 1024)
+	fmt.Fprintf(oc, "before\n")
+	fmt.Fprintf(oc, "%s\n", strings.Repeat("a", 200*1024))
+	fmt.Fprintf(oc, "after\n")
+	oc.Close()
+
+	// the long line is truncated, and collection goes on
+	lines := oc.Lines()
+	assert.Len(t, lines, 3)
+	assert.EqualValues(t, "before", lines[0])
+	assert.EqualValues(t, strings.Repeat("a", defaultMaxLineBytes), lines[1])
+	assert.EqualValues(t, "after", lines[2])
+
+	// lines can't be longer than the collector
+	oc = newOutputCollector(16)
+	fmt.Fprintf(oc, "%s\nshort\n", strings.Repeat("b", 1024))
+	oc.Close()
+	assert.EqualValues(t, []string{"short"}, oc.Lines())
+}
@@ -1,11 +1,9 @@
 package native
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/itchio/butler/butlerd/messages"
-	"github.com/itchio/ox"
 
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/cmd/prereqs"
@@ -52,11 +50,7 @@ func handlePrereqs(params launch.LauncherParams) error {
 	}
 
 	// append built-in params if we need some
-	runtime := params.Runtime
-	if runtime.Platform == ox.PlatformLinux && params.Sandbox {
-		firejailName := fmt.Sprintf("firejail-%s", runtime.Arch())
-		wanted = append(wanted, firejailName)
-	}
+	wanted = append(wanted, sandboxPrereqs(params)...)
 
 	if len(wanted) == 0 {
 		return nil
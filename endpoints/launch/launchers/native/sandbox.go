@@ -0,0 +1,155 @@
+package native
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+// A sandboxBackend runs games in a sandbox. Backends only differ in how
+// they do it: they all apply the same sandboxProfile.
+type sandboxBackend interface {
+	Name() string
+
+	// Available returns true if the backend can be used on this machine,
+	// once its prereqs are installed.
+	Available(params launch.LauncherParams) bool
+
+	// Prereqs returns the names of prereqs the backend needs
+	Prereqs(params launch.LauncherParams) []string
+
+	// Runner returns a runner for runParams, sandboxed according to profile
+	Runner(params launch.LauncherParams, profile *sandboxProfile, runParams runner.RunnerParams) (runner.Runner, error)
+}
+
+// A sandboxProfile describes what a sandboxed game can access.
+type sandboxProfile struct {
+	// The game can read, but not write, its install folder
+	InstallFolder string
+
+	// If set, the game gets its own home folder, where it can keep
+	// saves. It hides the user's actual home folder, and everything in
+	// it. It's only empty if the player opted out, then the game can use
+	// the user's home folder, except for hiddenHomePaths.
+	HomeFolder string
+
+	// Other folders the game can read, or read and write
+	ReadOnlyFolders []string
+	WritableFolders []string
+
+	Network bool
+	GPU     bool
+	Audio   bool
+}
+
+// hiddenHomePaths are relative to the user's home folder. Games that
+// don't have a private home can't see them: they hold the itch app's
+// credentials and browser profiles. Same list as smaug's firejail policy.
+var hiddenHomePaths = []string{
+	".config/itch/users",
+	".config/itch/butler_creds",
+	".config/itch/marketdb",
+	".config/itch/Cookies",
+	".config/itch/Partitions",
+
+	".config/kitch/users",
+	".config/kitch/butler_creds",
+	".config/kitch/marketdb",
+	".config/kitch/Cookies",
+	".config/kitch/Partitions",
+
+	".config/chromium",
+	".config/chrome",
+	".mozilla",
+}
+
+func newSandboxProfile(params launch.LauncherParams) (*sandboxProfile, error) {
+	policy := params.SandboxPolicy
+	if policy == nil {
+		policy = launch.ResolveSandboxPolicy(params.Runtime, params.InstallFolder, nil, nil)
+	}
+
+	tempFolder := filepath.Join(params.InstallFolder, ".itch", "temp")
+	profile := &sandboxProfile{
+		InstallFolder:   params.InstallFolder,
+		ReadOnlyFolders: policy.ReadOnlyPaths,
+		WritableFolders: append([]string{tempFolder}, policy.ReadWritePaths...),
+
+		Network: policy.Network,
+		GPU:     policy.GPU,
+		Audio:   policy.Audio,
+	}
+
+	folders := []string{tempFolder}
+	if policy.PrivateHome {
+		if params.SandboxHomeFolder == "" {
+			return nil, errors.Errorf("sandboxed games get a private home, but there's no folder for it")
+		}
+		profile.HomeFolder = params.SandboxHomeFolder
+		folders = append(folders, profile.HomeFolder)
+	}
+
+	for _, folder := range folders {
+		err := os.MkdirAll(folder, 0755)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return profile, nil
+}
+
+// pickSandboxBackend returns the first available sandbox backend, or
+// nil if the platform has none (and smaug's runners are used instead).
+func pickSandboxBackend(params launch.LauncherParams) sandboxBackend {
+	for _, backend := range sandboxBackends() {
+		if backend.Available(params) {
+			return backend
+		}
+	}
+	return nil
+}
+
+// sandboxPrereqs returns the prereqs needed to launch sandboxed
+func sandboxPrereqs(params launch.LauncherParams) []string {
+	if !params.Sandbox {
+		return nil
+	}
+
+	backend := pickSandboxBackend(params)
+	if backend == nil {
+		return nil
+	}
+	return backend.Prereqs(params)
+}
+
+// getRunner returns a runner for runParams, using one of our sandbox
+// backends if the platform has some.
+func getRunner(params launch.LauncherParams, runParams runner.RunnerParams) (runner.Runner, error) {
+	if runParams.Sandbox {
+		backend := pickSandboxBackend(params)
+		if backend != nil {
+			consumer := params.RequestContext.Consumer
+			consumer.Infof("Using sandbox backend (%s)", backend.Name())
+
+			profile, err := newSandboxProfile(params)
+			if err != nil {
+				return nil, errors.WithMessage(err, "preparing sandbox")
+			}
+			return backend.Runner(params, profile, runParams)
+		}
+
+		// smaug's sandbox only knows about file access, refuse to
+		// launch rather than give the game more than it should get.
+		// It has no private homes, but it only lets games into a few
+		// folders of the user's home, so that's not reason enough.
+		policy := params.SandboxPolicy
+		if policy != nil && (!policy.Network || !policy.GPU || !policy.Audio) {
+			return nil, errors.Errorf("sandbox policy (%s) can't be enforced on %s, the player has to allow more to launch", policy, params.Runtime)
+		}
+	}
+
+	return runner.GetRunner(runParams)
+}
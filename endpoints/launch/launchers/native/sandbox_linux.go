@@ -0,0 +1,74 @@
+// +build linux
+
+package native
+
+import (
+	"os/exec"
+
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+// in order of preference
+func sandboxBackends() []sandboxBackend {
+	return []sandboxBackend{
+		&bubblewrapBackend{},
+		&firejailBackend{},
+	}
+}
+
+// sandboxRunner runs a sandboxing tool, which in turn runs the game
+type sandboxRunner struct {
+	params runner.RunnerParams
+
+	binaryPath string
+	args       []string
+}
+
+var _ runner.Runner = (*sandboxRunner)(nil)
+
+func (sr *sandboxRunner) Prepare() error {
+	// nothing to prepare
+	return nil
+}
+
+func (sr *sandboxRunner) Run() error {
+	params := sr.params
+	consumer := params.Consumer
+
+	consumer.Opf("Running (%s) through (%s)", params.FullTargetPath, sr.binaryPath)
+
+	var args []string
+	args = append(args, sr.args...)
+	args = append(args, "--")
+	args = append(args, params.FullTargetPath)
+	args = append(args, params.Args...)
+
+	cmd := exec.Command(sr.binaryPath, args...)
+	cmd.Dir = params.Dir
+	cmd.Env = params.Env
+	cmd.Stdout = params.Stdout
+	cmd.Stderr = params.Stderr
+
+	pg, err := runner.NewProcessGroup(consumer, cmd, params.Ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = pg.AfterStart()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = pg.Wait()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
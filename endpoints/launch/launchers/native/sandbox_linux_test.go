@@ -0,0 +1,134 @@
+// +build linux
+
+package native
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/ox"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxProfileTranslation(t *testing.T) {
+	profile := &sandboxProfile{
+		InstallFolder:   "/home/player/Games/garden",
+		HomeFolder:      "/home/player/Games/homes/cave-1",
+		WritableFolders: []string{"/home/player/Games/garden/.itch/temp"},
+		Network:         true,
+		GPU:             true,
+		Audio:           true,
+	}
+
+	args := strings.Join(bubblewrapArgs(profile, "/home/player", "/run/user/1000", "/home/player/.Xauthority", "/home/player/Games/garden/bin"), " ")
+	assert.Contains(t, args, "--ro-bind / /")
+	assert.Contains(t, args, "--dev-bind /dev /dev")
+	assert.NotContains(t, args, "--unshare-net")
+	// the install folder must be mounted after the private home, which hides it
+	// the authority file is in the real home, hidden by the private home
+	assert.Contains(t, args, "--bind /home/player/Games/homes/cave-1 /home/player --ro-bind-try /home/player/.Xauthority /home/player/.Xauthority --ro-bind /home/player/Games/garden /home/player/Games/garden --bind-try /home/player/Games/garden/.itch/temp /home/player/Games/garden/.itch/temp")
+	assert.True(t, strings.HasSuffix(args, "--chdir /home/player/Games/garden/bin"))
+
+	assert.EqualValues(t, strings.Join([]string{
+		"# Generated by butler, any changes will be overwritten",
+		"private /home/player/Games/homes/cave-1",
+		"read-only /home/player/Games/garden",
+		"read-write /home/player/Games/garden/.itch/temp",
+	}, "\n")+"\n", firejailProfile(profile))
+}
+
+func TestSandboxPolicyTranslation(t *testing.T) {
+	profile := &sandboxProfile{
+		InstallFolder:   "/home/player/Games/garden",
+		HomeFolder:      "/home/player/Games/homes/cave-1",
+		ReadOnlyFolders: []string{"/srv/assets"},
+		WritableFolders: []string{"/home/player/Games/garden/.itch/temp", "/srv/mods"},
+	}
+
+	args := strings.Join(bubblewrapArgs(profile, "/home/player", "/run/user/1000", "", ""), " ")
+	assert.Contains(t, args, "--unshare-net")
+	assert.Contains(t, args, "--dev /dev")
+	assert.NotContains(t, args, "--dev-bind /dev /dev")
+	assert.NotContains(t, args, "/dev/snd")
+	assert.NotContains(t, args, "/dev/dri")
+	assert.Contains(t, args, "--tmpfs /run/user/1000/pulse")
+	assert.Contains(t, args, "--ro-bind-try /srv/assets /srv/assets")
+	assert.Contains(t, args, "--bind-try /srv/mods /srv/mods")
+	assert.NotContains(t, args, "--chdir")
+
+	assert.EqualValues(t, strings.Join([]string{
+		"# Generated by butler, any changes will be overwritten",
+		"private /home/player/Games/homes/cave-1",
+		"read-only /home/player/Games/garden",
+		"read-only /srv/assets",
+		"read-write /home/player/Games/garden/.itch/temp",
+		"read-write /srv/mods",
+		"net none",
+		"no3d",
+		"nosound",
+	}, "\n")+"\n", firejailProfile(profile))
+}
+
+func TestSandboxSharedHome(t *testing.T) {
+	home, err := ioutil.TempDir("", "sandbox-home")
+	wtest.Must(t, err)
+	defer os.RemoveAll(home)
+
+	wtest.Must(t, os.MkdirAll(filepath.Join(home, ".mozilla"), 0755))
+	wtest.Must(t, os.MkdirAll(filepath.Join(home, ".config", "itch"), 0755))
+	wtest.Must(t, ioutil.WriteFile(filepath.Join(home, ".config", "itch", "butler_creds"), []byte("{}"), 0600))
+
+	profile := &sandboxProfile{
+		InstallFolder: filepath.Join(home, "Games", "garden"),
+		Network:       true,
+		GPU:           true,
+		Audio:         true,
+	}
+
+	args := strings.Join(bubblewrapArgs(profile, home, "", "/tmp/xauth-1000", ""), " ")
+	assert.Contains(t, args, "--bind "+home+" "+home)
+	assert.Contains(t, args, "--tmpfs "+filepath.Join(home, ".mozilla"))
+	assert.Contains(t, args, "--ro-bind /dev/null "+filepath.Join(home, ".config", "itch", "butler_creds"))
+	// only what exists is hidden, so nothing gets created in the real home
+	assert.NotContains(t, args, ".config/chromium")
+	assert.Contains(t, args, "--ro-bind-try /tmp/xauth-1000 /tmp/xauth-1000")
+
+	firejail := firejailProfile(profile)
+	assert.NotContains(t, firejail, "private")
+	assert.Contains(t, firejail, "blacklist ~/.config/itch/butler_creds\n")
+	assert.Contains(t, firejail, "blacklist ~/.mozilla\n")
+}
+
+func TestSandboxProfileHome(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sandbox-profile")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	runtime := &ox.Runtime{Platform: ox.PlatformLinux, Is64: true}
+	params := launch.LauncherParams{
+		InstallFolder:     filepath.Join(dir, "garden"),
+		SandboxHomeFolder: filepath.Join(dir, "homes", "cave-1"),
+		Runtime:           runtime,
+	}
+
+	// games get a private home by default, even if the manifest says otherwise
+	no := false
+	params.SandboxPolicy = launch.ResolveSandboxPolicy(runtime, params.InstallFolder, &butlerd.SandboxPolicy{PrivateHome: &no}, nil)
+	profile, err := newSandboxProfile(params)
+	wtest.Must(t, err)
+	assert.EqualValues(t, params.SandboxHomeFolder, profile.HomeFolder)
+	_, err = os.Stat(profile.HomeFolder)
+	assert.NoError(t, err)
+
+	// unless the player opts out
+	params.SandboxPolicy = launch.ResolveSandboxPolicy(runtime, params.InstallFolder, nil, &butlerd.SandboxPolicy{PrivateHome: &no})
+	profile, err = newSandboxProfile(params)
+	wtest.Must(t, err)
+	assert.EqualValues(t, "", profile.HomeFolder)
+}
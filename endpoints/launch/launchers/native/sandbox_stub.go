@@ -0,0 +1,7 @@
+// +build !linux,!windows
+
+package native
+
+func sandboxBackends() []sandboxBackend {
+	return nil
+}
@@ -0,0 +1,137 @@
+// +build windows
+
+package native
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/itchio/butler/endpoints/launch"
+	"github.com/itchio/ox/winox"
+	"github.com/itchio/smaug/fuji"
+	"github.com/itchio/smaug/runner"
+	"github.com/pkg/errors"
+)
+
+// in order of preference
+func sandboxBackends() []sandboxBackend {
+	return []sandboxBackend{
+		&fujiBackend{},
+	}
+}
+
+// fujiBackend runs games as a separate, less privileged user, through
+// smaug's fuji runner. It can only restrict file access: games that
+// should be denied network, GPU or audio access don't run. The sandbox
+// user has its own home folder, so games always get a private home.
+type fujiBackend struct{}
+
+var _ sandboxBackend = (*fujiBackend)(nil)
+
+func (fb *fujiBackend) Name() string {
+	return "fuji"
+}
+
+func (fb *fujiBackend) Available(params launch.LauncherParams) bool {
+	// fuji sets itself up (with elevation) when first used
+	return true
+}
+
+func (fb *fujiBackend) Prereqs(params launch.LauncherParams) []string {
+	return nil
+}
+
+func (fb *fujiBackend) Runner(params launch.LauncherParams, profile *sandboxProfile, runParams runner.RunnerParams) (runner.Runner, error) {
+	if !profile.Network || !profile.GPU || !profile.Audio {
+		return nil, errors.Errorf("network, GPU and audio restrictions can't be enforced on Windows, the player has to allow them to launch")
+	}
+
+	run, err := runner.GetRunner(runParams)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &fujiSandboxRunner{
+		params:  runParams,
+		profile: profile,
+		inner:   run,
+	}, nil
+}
+
+// fujiSandboxRunner gives the sandbox user access to the profile's extra
+// folders for as long as the game runs.
+type fujiSandboxRunner struct {
+	params  runner.RunnerParams
+	profile *sandboxProfile
+	inner   runner.Runner
+}
+
+var _ runner.Runner = (*fujiSandboxRunner)(nil)
+
+func (fr *fujiSandboxRunner) Prepare() error {
+	return fr.inner.Prepare()
+}
+
+func (fr *fujiSandboxRunner) Run() error {
+	consumer := fr.params.Consumer
+
+	sp, err := fr.getSharingPolicy()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(sp.Entries) > 0 {
+		consumer.Infof("Extra sharing policy: %s", sp)
+		err = sp.Grant(consumer)
+		if err != nil {
+			consumer.Warnf(err.Error())
+			consumer.Warnf("Attempting launch anyway...")
+		}
+		defer sp.Revoke(consumer)
+	}
+
+	return fr.inner.Run()
+}
+
+func (fr *fujiSandboxRunner) getSharingPolicy() (*winox.SharingPolicy, error) {
+	fi, err := fuji.NewInstance(fr.params.FujiParams.Settings)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	creds, err := fi.GetCredentials()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sp := &winox.SharingPolicy{
+		Trustee: creds.Username,
+	}
+	for _, folder := range fr.profile.ReadOnlyFolders {
+		sp.Entries = append(sp.Entries, &winox.ShareEntry{
+			Path:        folder,
+			Inheritance: winox.InheritanceModeFull,
+			Rights:      winox.RightsRead,
+		})
+	}
+	for _, folder := range fr.profile.WritableFolders {
+		if isInFolder(folder, fr.profile.InstallFolder) {
+			// the fuji runner already shares the install folder
+			continue
+		}
+		sp.Entries = append(sp.Entries, &winox.ShareEntry{
+			Path:        folder,
+			Inheritance: winox.InheritanceModeFull,
+			Rights:      winox.RightsFull,
+		})
+	}
+	return sp, nil
+}
+
+func isInFolder(path string, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -18,6 +18,9 @@ var _ launch.Launcher = (*Launcher)(nil)
 func (l *Launcher) Do(params launch.LauncherParams) error {
 	_, err := messages.ShellLaunch.Call(params.RequestContext, butlerd.ShellLaunchParams{
 		ItemPath: params.FullTargetPath,
+
+		Env:              params.Env,
+		WorkingDirectory: params.WorkingDirectory,
 	})
 	if err != nil {
 		return errors.WithStack(err)
@@ -18,6 +18,9 @@ var _ launch.Launcher = (*Launcher)(nil)
 func (l *Launcher) Do(params launch.LauncherParams) error {
 	_, err := messages.URLLaunch.Call(params.RequestContext, butlerd.URLLaunchParams{
 		URL: params.FullTargetPath,
+
+		Env:              params.Env,
+		WorkingDirectory: params.WorkingDirectory,
 	})
 	if err != nil {
 		return errors.WithStack(err)
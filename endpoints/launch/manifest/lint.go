@@ -0,0 +1,504 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/redist"
+	"github.com/itchio/ox"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic codes, stable so that CI scripts can match on them
+const (
+	CodeParseError       = "parse-error"
+	CodeInvalidValue     = "invalid-value"
+	CodeUnknownKey       = "unknown-key"
+	CodeMissingPath      = "missing-path"
+	CodeUnknownPrereq    = "unknown-prereq"
+	CodeDuplicateAction  = "duplicate-action"
+	CodeInvalidScope     = "invalid-scope"
+	CodeInvalidPlatform  = "invalid-platform"
+	CodeOrphanLocales    = "orphan-locales"
+	CodeConsoleNonNative = "console-non-native"
+	CodeInvalidEnv       = "invalid-env"
+	CodeMissingCwd       = "missing-cwd"
+)
+
+// ValidScopes lists the API scopes an action may request
+var ValidScopes = []string{"profile:me"}
+
+// A Diagnostic is a problem found in a manifest
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+
+	// Key the diagnostic is about, like `actions[1].path`
+	Key string `json:"key,omitempty"`
+
+	// 1-based position of the key in the manifest, 0 if unknown
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s [%s]", d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+type LintParams struct {
+	// Build folder the manifest is for. If empty, action
+	// paths aren't checked.
+	Dir string
+
+	// Runtime to check actions for
+	Runtime *ox.Runtime
+
+	// Registry returns the list of known prereqs. It's only called if the
+	// manifest lists prereqs. If nil, prereqs aren't checked.
+	Registry func() (*redist.RedistRegistry, error)
+
+	// IsNative returns true if an action launches a native executable.
+	// If nil, only URLs and HTML files are assumed to be non-native.
+	IsNative func(a *butlerd.Action) bool
+}
+
+type LintResult struct {
+	// Decoded manifest, nil if it couldn't be parsed
+	Manifest    *butlerd.Manifest `json:"-"`
+	Diagnostics []*Diagnostic     `json:"diagnostics"`
+}
+
+// Errors returns the number of error-level diagnostics
+func (lr *LintResult) Errors() int {
+	count := 0
+	for _, d := range lr.Diagnostics {
+		if d.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+var nearLineRe = regexp.MustCompile(`^Near line (\d+)`)
+var mapstructureKeyRe = regexp.MustCompile(`^'([^']*)'`)
+
+// Lint checks the contents of an `.itch.toml` manifest. It only returns
+// an error if it couldn't perform the checks, problems with the manifest
+// itself are reported as diagnostics.
+func Lint(data []byte, params LintParams) (*LintResult, error) {
+	l := &linter{
+		params:    params,
+		positions: keyPositions(data),
+		res:       &LintResult{},
+	}
+	defer l.res.sort()
+
+	intermediate := make(map[string]interface{})
+	_, err := toml.Decode(string(data), &intermediate)
+	if err != nil {
+		d := &Diagnostic{
+			Severity: SeverityError,
+			Code:     CodeParseError,
+			Message:  err.Error(),
+		}
+		if matches := nearLineRe.FindStringSubmatch(err.Error()); matches != nil {
+			d.Line, _ = strconv.Atoi(matches[1])
+			d.Column = 1
+		}
+		l.res.Diagnostics = append(l.res.Diagnostics, d)
+		return l.res, nil
+	}
+
+	l.checkKeys(intermediate, reflect.TypeOf(butlerd.Manifest{}), "")
+
+	m := &butlerd.Manifest{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result: m,
+	})
+	if err != nil {
+		// internal error
+		return nil, errors.WithStack(err)
+	}
+
+	err = decoder.Decode(intermediate)
+	if err != nil {
+		if mse, ok := err.(*mapstructure.Error); ok {
+			for _, e := range mse.Errors {
+				key := ""
+				if matches := mapstructureKeyRe.FindStringSubmatch(e); matches != nil {
+					key = strings.ToLower(matches[1])
+				}
+				l.addf(SeverityError, CodeInvalidValue, key, "%s", e)
+			}
+			return l.res, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	l.res.Manifest = m
+
+	l.checkActions(m)
+	l.checkSaves(m)
+	err = l.checkPrereqs(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.res, nil
+}
+
+func (lr *LintResult) sort() {
+	sort.SliceStable(lr.Diagnostics, func(i, j int) bool {
+		a, b := lr.Diagnostics[i], lr.Diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Key < b.Key
+	})
+}
+
+type linter struct {
+	params    LintParams
+	positions map[string]position
+	res       *LintResult
+}
+
+func (l *linter) addf(severity Severity, code string, key string, msg string, args ...interface{}) {
+	pos := l.lookup(key)
+	l.res.Diagnostics = append(l.res.Diagnostics, &Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(msg, args...),
+		Key:      key,
+		Line:     pos.line,
+		Column:   pos.column,
+	})
+}
+
+// lookup returns the position of key, or of its closest
+// parent that has one.
+func (l *linter) lookup(key string) position {
+	for key != "" {
+		if pos, ok := l.positions[key]; ok {
+			return pos
+		}
+		cut := strings.LastIndexAny(key, ".[")
+		if cut < 0 {
+			break
+		}
+		key = key[:cut]
+	}
+	return position{}
+}
+
+// checkKeys reports keys that don't map to any field of typ. mapstructure
+// matches fields case-insensitively, so we do too.
+func (l *linter) checkKeys(value interface{}, typ reflect.Type, key string) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range fields {
+			childKey := joinKey(key, k)
+			field, ok := findField(typ, k)
+			if !ok {
+				l.addf(SeverityWarning, CodeUnknownKey, childKey, "Unknown key '%s', it will be ignored", k)
+				continue
+			}
+			l.checkKeys(v, field.Type, childKey)
+		}
+	case reflect.Slice:
+		switch items := value.(type) {
+		case []map[string]interface{}:
+			for i, item := range items {
+				l.checkKeys(item, typ.Elem(), fmt.Sprintf("%s[%d]", key, i))
+			}
+		case []interface{}:
+			for i, item := range items {
+				l.checkKeys(item, typ.Elem(), fmt.Sprintf("%s[%d]", key, i))
+			}
+		}
+	case reflect.Map:
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range entries {
+			l.checkKeys(v, typ.Elem(), joinKey(key, k))
+		}
+	}
+}
+
+func findField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func (l *linter) checkSaves(m *butlerd.Manifest) {
+	for i, pattern := range m.Saves {
+		key := fmt.Sprintf("saves[%d]", i)
+		if err := CheckSavePattern(pattern); err != nil {
+			l.addf(SeverityError, CodeInvalidValue, key, "Invalid save pattern '%s': %s", pattern, err.Error())
+		}
+	}
+}
+
+func (l *linter) checkActions(m *butlerd.Manifest) {
+	for i, a := range m.Actions {
+		key := fmt.Sprintf("actions[%d]", i)
+
+		switch a.Platform {
+		case "", ox.PlatformLinux, ox.PlatformOSX, ox.PlatformWindows:
+			// good
+		default:
+			l.addf(SeverityError, CodeInvalidPlatform, key+".platform", "Unknown platform '%s', should be one of: linux, osx, windows", a.Platform)
+		}
+
+		if a.Scope != "" && !isValidScope(a.Scope) {
+			l.addf(SeverityError, CodeInvalidScope, key+".scope", "Invalid API scope '%s', should be one of: %s", a.Scope, strings.Join(ValidScopes, ", "))
+		}
+
+		for j := 0; j < i; j++ {
+			b := m.Actions[j]
+			if a.Name == b.Name && platformsOverlap(a.Platform, b.Platform) {
+				l.addf(SeverityError, CodeDuplicateAction, key+".name", "Action '%s' is already defined by action %d", a.Name, j+1)
+				break
+			}
+		}
+
+		if len(a.Locales) > 0 {
+			if a.Name == "" {
+				l.addf(SeverityError, CodeOrphanLocales, key+".locales", "Action has localized names, but no base name")
+			}
+			for lang, locale := range a.Locales {
+				if locale == nil || locale.Name == "" {
+					l.addf(SeverityError, CodeOrphanLocales, key+".locales."+lang, "Locale '%s' has no name", lang)
+				}
+			}
+		}
+
+		for k := range a.Env {
+			if k == "" || strings.ContainsAny(k, "=\x00") {
+				l.addf(SeverityError, CodeInvalidEnv, key+".env", "Invalid environment variable name '%s'", k)
+			}
+		}
+
+		if a.Platform != "" && l.params.Runtime != nil && a.Platform != l.params.Runtime.Platform {
+			// paths are only checked for the target platform
+			continue
+		}
+
+		if a.Console && !l.isNative(a) {
+			l.addf(SeverityWarning, CodeConsoleNonNative, key+".console", "'console' only applies to native executables, and will be ignored for '%s'", a.Path)
+		}
+
+		if l.params.Dir != "" && l.params.Runtime != nil && !isURL(a.Path) {
+			fullPath := ExpandPath(a, l.params.Runtime, l.params.Dir)
+			_, err := os.Stat(fullPath)
+			if err != nil {
+				l.addf(SeverityError, CodeMissingPath, key+".path", "Path '%s' does not exist on %s (expected it at %s)", a.Path, l.params.Runtime.Platform, fullPath)
+			}
+		}
+
+		l.checkHook(key+".preLaunch", a.PreLaunch)
+		l.checkHook(key+".postExit", a.PostExit)
+
+		if l.params.Dir != "" && l.params.Runtime != nil && a.Cwd != "" {
+			cwd := ExpandCwd(a, l.params.Runtime, l.params.Dir)
+			stats, err := os.Stat(cwd)
+			if err != nil || !stats.IsDir() {
+				l.addf(SeverityError, CodeMissingCwd, key+".cwd", "Working directory '%s' is not a folder (expected it at %s)", a.Cwd, cwd)
+			}
+		}
+	}
+}
+
+func (l *linter) checkHook(key string, hook *butlerd.ActionHook) {
+	if hook == nil {
+		return
+	}
+
+	if hook.Path == "" {
+		l.addf(SeverityError, CodeInvalidValue, key, "Hook has no path")
+		return
+	}
+
+	if l.params.Dir != "" && l.params.Runtime != nil {
+		fullPath, _ := ExpandHook(hook, l.params.Runtime, l.params.Dir)
+		_, err := os.Stat(fullPath)
+		if err != nil {
+			l.addf(SeverityError, CodeMissingPath, key+".path", "Hook path '%s' does not exist on %s (expected it at %s)", hook.Path, l.params.Runtime.Platform, fullPath)
+		}
+	}
+}
+
+func (l *linter) isNative(a *butlerd.Action) bool {
+	if l.params.IsNative != nil {
+		return l.params.IsNative(a)
+	}
+
+	if isURL(a.Path) {
+		return false
+	}
+	lowerPath := strings.ToLower(a.Path)
+	return !strings.HasSuffix(lowerPath, ".html") && !strings.HasSuffix(lowerPath, ".htm")
+}
+
+func (l *linter) checkPrereqs(m *butlerd.Manifest) error {
+	if len(m.Prereqs) == 0 || l.params.Registry == nil {
+		return nil
+	}
+
+	reg, err := l.params.Registry()
+	if err != nil {
+		return errors.WithMessage(err, "getting prereqs registry")
+	}
+
+	for i, p := range m.Prereqs {
+		if reg.Entries[p.Name] == nil {
+			l.addf(SeverityError, CodeUnknownPrereq, fmt.Sprintf("prereqs[%d].name", i), "Unknown prerequisite '%s'", p.Name)
+		}
+	}
+	return nil
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func platformsOverlap(a ox.Platform, b ox.Platform) bool {
+	return a == "" || b == "" || a == b
+}
+
+func isURL(path string) bool {
+	u, err := url.Parse(path)
+	// single letters are windows drives, not schemes
+	return err == nil && len(u.Scheme) > 1
+}
+
+func joinKey(parent string, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// keyPositions maps keys of a TOML document (like `actions[1].path`)
+// to where they're defined. The TOML decoder doesn't keep track of
+// positions, but manifests are simple enough that looking at table
+// headers and `key = value` lines is enough. Keys of inline tables
+// aren't found, diagnostics about them point to their parent.
+func keyPositions(data []byte) map[string]position {
+	positions := make(map[string]position)
+	arrayCounts := make(map[string]int)
+	table := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Text()
+		line := strings.TrimLeft(raw, " \t")
+		pos := position{line: lineNumber, column: len(raw) - len(line) + 1}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[["):
+			end := strings.Index(line, "]]")
+			if end < 0 {
+				continue
+			}
+			name := normalizeKey(line[2:end])
+			arrayCounts[name]++
+			table = tablePath(name, arrayCounts)
+			if _, ok := positions[name]; !ok {
+				positions[name] = pos
+			}
+			positions[table] = pos
+		case strings.HasPrefix(line, "["):
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			table = tablePath(normalizeKey(line[1:end]), arrayCounts)
+			positions[table] = pos
+		default:
+			eq := strings.Index(line, "=")
+			if eq < 0 {
+				continue
+			}
+			positions[joinKey(table, normalizeKey(line[:eq]))] = pos
+		}
+	}
+	return positions
+}
+
+// normalizeKey turns `a . "b"` into `a.b`
+func normalizeKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// tablePath adds indices to the arrays of tables in name, so that
+// `actions.locales.fr` becomes `actions[2].locales.fr`
+func tablePath(name string, arrayCounts map[string]int) string {
+	var bare, path string
+	for i, part := range strings.Split(name, ".") {
+		if i > 0 {
+			bare += "."
+			path += "."
+		}
+		bare += part
+		path += part
+		if n, ok := arrayCounts[bare]; ok {
+			path += fmt.Sprintf("[%d]", n-1)
+		}
+	}
+	return path
+}
@@ -0,0 +1,114 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/itchio/butler/endpoints/launch/manifest"
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func lint(t *testing.T, data string) []*manifest.Diagnostic {
+	res, err := manifest.Lint([]byte(data), manifest.LintParams{
+		Runtime: &ox.Runtime{Platform: ox.PlatformWindows, Is64: true},
+	})
+	assert.NoError(t, err)
+	return res.Diagnostics
+}
+
+func TestLintClean(t *testing.T) {
+	diags := lint(t, `
+[[actions]]
+name = "play"
+path = "game.exe"
+scope = "profile:me"
+
+  [actions.locales.fr]
+  name = "jouer"
+`)
+	assert.Empty(t, diags)
+}
+
+func TestLintPositions(t *testing.T) {
+	diags := lint(t, `
+[[actions]]
+name = "play"
+path = "game.exe"
+
+[[actions]]
+name = "play"
+path = "https://example.org"
+  console = true
+  colour = "red"
+`)
+
+	assert.Len(t, diags, 3)
+
+	assert.EqualValues(t, manifest.CodeDuplicateAction, diags[0].Code)
+	assert.EqualValues(t, "actions[1].name", diags[0].Key)
+	assert.EqualValues(t, 7, diags[0].Line)
+
+	assert.EqualValues(t, manifest.CodeConsoleNonNative, diags[1].Code)
+	assert.EqualValues(t, 9, diags[1].Line)
+	assert.EqualValues(t, 3, diags[1].Column)
+
+	assert.EqualValues(t, manifest.CodeUnknownKey, diags[2].Code)
+	assert.EqualValues(t, manifest.SeverityWarning, diags[2].Severity)
+	assert.EqualValues(t, "actions[1].colour", diags[2].Key)
+	assert.EqualValues(t, 10, diags[2].Line)
+}
+
+func TestLintDuplicatesAcrossPlatforms(t *testing.T) {
+	diags := lint(t, `
+[[actions]]
+name = "play"
+path = "game.exe"
+platform = "windows"
+
+[[actions]]
+name = "play"
+path = "game.x86_64"
+platform = "linux"
+`)
+	assert.Empty(t, diags)
+}
+
+func TestLintParseError(t *testing.T) {
+	diags := lint(t, "[[actions]]\nname = \n")
+	assert.Len(t, diags, 1)
+	assert.EqualValues(t, manifest.CodeParseError, diags[0].Code)
+	assert.EqualValues(t, 2, diags[0].Line)
+}
+
+func TestLintSaves(t *testing.T) {
+	diags := lint(t, `
+saves = ["saves/*.sav", "~/.config/game", "[broken"]
+
+[[actions]]
+name = "play"
+path = "game.exe"
+`)
+	assert.Len(t, diags, 1)
+	assert.EqualValues(t, manifest.CodeInvalidValue, diags[0].Code)
+	assert.EqualValues(t, "saves[2]", diags[0].Key)
+	assert.EqualValues(t, 2, diags[0].Line)
+}
+
+func TestCheckSavePattern(t *testing.T) {
+	for _, pattern := range []string{"saves/*.sav", "~/.config/game", "~/Documents/Game/*.sav", "./saves"} {
+		assert.NoError(t, manifest.CheckSavePattern(pattern), pattern)
+	}
+	for _, pattern := range []string{
+		"[broken",
+		"/home/amos/.config/game",
+		"../other-game/saves",
+		"saves/../../other-game",
+		"~/../root",
+		"~/",
+		"~/.",
+		"~/*",
+		"~/*/saves",
+	} {
+		assert.Error(t, manifest.CheckSavePattern(pattern), pattern)
+	}
+}
@@ -14,8 +14,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-// TODO: linter
-
 func ListActions(m *butlerd.Manifest, runtime *ox.Runtime) []*butlerd.Action {
 	var result []*butlerd.Action
 
@@ -80,13 +78,12 @@ func Read(folder string) (*butlerd.Manifest, error) {
 	return manifest, nil
 }
 
-func ExpandPath(a *butlerd.Action, runtime *ox.Runtime, baseFolder string) string {
-	if filepath.IsAbs(a.Path) {
-		return a.Path
-	}
-
-	path := a.Path
-	if strings.Contains(path, "{{EXT}}") {
+// Interpolate replaces placeholders in a manifest value:
+//   - `{{EXT}}` with the executable extension of the platform
+//     (`.exe` on Windows, `.app` on macOS, nothing on Linux)
+//   - `{{installDir}}` with the install folder
+func Interpolate(s string, runtime *ox.Runtime, baseFolder string) string {
+	if strings.Contains(s, "{{EXT}}") {
 		var ext = ""
 		switch runtime.Platform {
 		case ox.PlatformWindows:
@@ -94,8 +91,89 @@ func ExpandPath(a *butlerd.Action, runtime *ox.Runtime, baseFolder string) strin
 		case ox.PlatformOSX:
 			ext = ".app"
 		}
-		path = strings.Replace(path, "{{EXT}}", ext, 1)
+		s = strings.Replace(s, "{{EXT}}", ext, -1)
+	}
+	return strings.Replace(s, "{{installDir}}", baseFolder, -1)
+}
+
+func ExpandPath(a *butlerd.Action, runtime *ox.Runtime, baseFolder string) string {
+	path := Interpolate(a.Path, runtime, baseFolder)
+	if filepath.IsAbs(path) {
+		return path
 	}
 
 	return filepath.Join(baseFolder, path)
 }
+
+// ExpandHook returns the full path and arguments of a hook
+func ExpandHook(h *butlerd.ActionHook, runtime *ox.Runtime, baseFolder string) (string, []string) {
+	path := Interpolate(h.Path, runtime, baseFolder)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseFolder, path)
+	}
+
+	var args []string
+	for _, arg := range h.Args {
+		args = append(args, Interpolate(arg, runtime, baseFolder))
+	}
+	return path, args
+}
+
+// ExpandCwd returns the working directory an action asks for,
+// or an empty string if it doesn't specify one.
+func ExpandCwd(a *butlerd.Action, runtime *ox.Runtime, baseFolder string) string {
+	if a.Cwd == "" {
+		return ""
+	}
+
+	cwd := Interpolate(a.Cwd, runtime, baseFolder)
+	if filepath.IsAbs(cwd) {
+		return cwd
+	}
+
+	return filepath.Join(baseFolder, cwd)
+}
+
+// ExpandEnv returns the environment variables an action asks for,
+// with their values interpolated.
+func ExpandEnv(a *butlerd.Action, runtime *ox.Runtime, baseFolder string) map[string]string {
+	env := make(map[string]string)
+	for k, v := range a.Env {
+		env[k] = Interpolate(v, runtime, baseFolder)
+	}
+	return env
+}
+
+// CheckSavePattern returns an error if a pattern of the manifest's
+// `saves` list is invalid, or could match files that aren't the game's.
+func CheckSavePattern(pattern string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return errors.WithStack(err)
+	}
+	if filepath.IsAbs(pattern) {
+		return errors.New("should be relative to the install folder, or start with '~/'")
+	}
+
+	segments := strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+	for _, s := range segments {
+		if s == ".." {
+			return errors.New("should not contain '..'")
+		}
+	}
+
+	if strings.HasPrefix(pattern, "~/") {
+		var first string
+		for _, s := range segments[1:] {
+			if s != "." {
+				first = s
+				break
+			}
+		}
+		if strings.Trim(first, "*") == "" {
+			return errors.New("would match the whole home folder")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package manifest_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/endpoints/launch/manifest"
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandAction(t *testing.T) {
+	runtime := &ox.Runtime{Platform: ox.PlatformWindows, Is64: true}
+	installDir := filepath.FromSlash("/games/garden")
+
+	a := &butlerd.Action{
+		Path: "bin/garden{{EXT}}",
+		Cwd:  "data",
+		Env: map[string]string{
+			"SDL_VIDEODRIVER": "windows",
+			"GARDEN_CONFIG":   "{{installDir}}/config.ini",
+		},
+	}
+
+	assert.EqualValues(t, filepath.Join(installDir, "bin", "garden.exe"), manifest.ExpandPath(a, runtime, installDir))
+	assert.EqualValues(t, filepath.Join(installDir, "data"), manifest.ExpandCwd(a, runtime, installDir))
+	assert.EqualValues(t, map[string]string{
+		"SDL_VIDEODRIVER": "windows",
+		"GARDEN_CONFIG":   installDir + "/config.ini",
+	}, manifest.ExpandEnv(a, runtime, installDir))
+
+	a.Cwd = ""
+	assert.EqualValues(t, "", manifest.ExpandCwd(a, runtime, installDir))
+}
@@ -0,0 +1,131 @@
+package launch
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/endpoints/launch/manifest"
+	"github.com/itchio/ox"
+)
+
+// SandboxPolicy is what a sandboxed game can do, once the manifest's
+// policy and the player's override have been applied to the defaults.
+type SandboxPolicy struct {
+	Network bool
+	GPU     bool
+	Audio   bool
+
+	// If set, the game gets its own home folder, see
+	// LauncherParams.SandboxHomeFolder. Only the player can unset it.
+	PrivateHome bool
+
+	// Absolute paths
+	ReadOnlyPaths  []string
+	ReadWritePaths []string
+
+	// Absolute paths the manifest asks for, but the player
+	// hasn't allowed. The game can't access them.
+	DeniedPaths []string
+}
+
+// ResolveSandboxPolicy applies the manifest's policy, then the player's
+// override, on top of the defaults. Either can be nil.
+//
+// The manifest comes with the game, so it can only take access away:
+// paths it lists are only accessible if the player's override lists them
+// too, and it can't give the game the player's home folder. The player's
+// override wins, and its path lists replace the previous ones, so the
+// player can also take access away.
+func ResolveSandboxPolicy(runtime *ox.Runtime, installFolder string, manifestPolicy *butlerd.SandboxPolicy, playerPolicy *butlerd.SandboxPolicy) *SandboxPolicy {
+	res := &SandboxPolicy{
+		Network:     true,
+		GPU:         true,
+		Audio:       true,
+		PrivateHome: true,
+	}
+
+	expandPaths := func(paths []string) []string {
+		var res []string
+		for _, p := range paths {
+			p = manifest.Interpolate(p, runtime, installFolder)
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(installFolder, p)
+			}
+			res = append(res, filepath.Clean(p))
+		}
+		return res
+	}
+
+	if mp := manifestPolicy; mp != nil {
+		if mp.Network != nil && !*mp.Network {
+			res.Network = false
+		}
+		if mp.GPU != nil && !*mp.GPU {
+			res.GPU = false
+		}
+		if mp.Audio != nil && !*mp.Audio {
+			res.Audio = false
+		}
+	}
+
+	if pp := playerPolicy; pp != nil {
+		if pp.Network != nil {
+			res.Network = *pp.Network
+		}
+		if pp.GPU != nil {
+			res.GPU = *pp.GPU
+		}
+		if pp.Audio != nil {
+			res.Audio = *pp.Audio
+		}
+		if pp.PrivateHome != nil {
+			res.PrivateHome = *pp.PrivateHome
+		}
+		res.ReadOnlyPaths = expandPaths(pp.ReadOnlyPaths)
+		res.ReadWritePaths = expandPaths(pp.ReadWritePaths)
+	}
+
+	if manifestPolicy != nil {
+		allowed := make(map[string]bool)
+		for _, p := range res.ReadOnlyPaths {
+			allowed[p] = true
+		}
+		for _, p := range res.ReadWritePaths {
+			allowed[p] = true
+		}
+
+		requested := append(expandPaths(manifestPolicy.ReadOnlyPaths), expandPaths(manifestPolicy.ReadWritePaths)...)
+		for _, p := range requested {
+			if !allowed[p] {
+				res.DeniedPaths = append(res.DeniedPaths, p)
+			}
+		}
+	}
+	return res
+}
+
+func (sp *SandboxPolicy) String() string {
+	allowed := func(b bool) string {
+		if b {
+			return "allowed"
+		}
+		return "denied"
+	}
+
+	s := fmt.Sprintf("network %s, GPU %s, audio %s", allowed(sp.Network), allowed(sp.GPU), allowed(sp.Audio))
+	if !sp.PrivateHome {
+		s += ", player's home"
+	}
+	if len(sp.ReadOnlyPaths) > 0 {
+		s += fmt.Sprintf(", read-only (%s)", strings.Join(sp.ReadOnlyPaths, ", "))
+	}
+	if len(sp.ReadWritePaths) > 0 {
+		s += fmt.Sprintf(", read-write (%s)", strings.Join(sp.ReadWritePaths, ", "))
+	}
+	if len(sp.DeniedPaths) > 0 {
+		s += fmt.Sprintf(", not allowed by player (%s)", strings.Join(sp.DeniedPaths, ", "))
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package launch
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSandboxPolicy(t *testing.T) {
+	runtime := &ox.Runtime{Platform: ox.PlatformLinux, Is64: true}
+	installFolder := filepath.Join(string(filepath.Separator)+"games", "garden")
+	yes, no := true, false
+
+	t.Run("defaults", func(t *testing.T) {
+		policy := ResolveSandboxPolicy(runtime, installFolder, nil, nil)
+		assert.EqualValues(t, &SandboxPolicy{Network: true, GPU: true, Audio: true, PrivateHome: true}, policy)
+	})
+
+	t.Run("manifest can take access away", func(t *testing.T) {
+		policy := ResolveSandboxPolicy(runtime, installFolder, &butlerd.SandboxPolicy{
+			Network: &no,
+			Audio:   &no,
+		}, nil)
+		assert.False(t, policy.Network)
+		assert.True(t, policy.GPU)
+		assert.False(t, policy.Audio)
+		assert.True(t, policy.PrivateHome)
+	})
+
+	t.Run("manifest can't give access", func(t *testing.T) {
+		policy := ResolveSandboxPolicy(runtime, installFolder, &butlerd.SandboxPolicy{
+			PrivateHome:    &no,
+			ReadOnlyPaths:  []string{"/srv/assets"},
+			ReadWritePaths: []string{"{{installDir}}/../mods"},
+		}, nil)
+		assert.True(t, policy.PrivateHome)
+		assert.Empty(t, policy.ReadOnlyPaths)
+		assert.Empty(t, policy.ReadWritePaths)
+		assert.EqualValues(t, []string{
+			filepath.Clean("/srv/assets"),
+			filepath.Join(installFolder, "..", "mods"),
+		}, policy.DeniedPaths)
+	})
+
+	t.Run("player wins over manifest", func(t *testing.T) {
+		policy := ResolveSandboxPolicy(runtime, installFolder, &butlerd.SandboxPolicy{
+			Network:        &no,
+			GPU:            &no,
+			PrivateHome:    &yes,
+			ReadWritePaths: []string{"saves", "/srv/mods"},
+		}, &butlerd.SandboxPolicy{
+			Network:       &yes,
+			PrivateHome:   &no,
+			ReadOnlyPaths: []string{"/srv/mods"},
+		})
+		assert.True(t, policy.Network)
+		// unset in the override, kept from the manifest
+		assert.False(t, policy.GPU)
+		assert.False(t, policy.PrivateHome)
+		assert.EqualValues(t, []string{filepath.Clean("/srv/mods")}, policy.ReadOnlyPaths)
+		assert.Empty(t, policy.ReadWritePaths)
+		assert.EqualValues(t, []string{filepath.Join(installFolder, "saves")}, policy.DeniedPaths)
+	})
+
+	t.Run("player can give access", func(t *testing.T) {
+		policy := ResolveSandboxPolicy(runtime, installFolder, nil, &butlerd.SandboxPolicy{
+			Network:        &no,
+			PrivateHome:    &no,
+			ReadWritePaths: []string{"{{installDir}}/saves", "/srv/mods"},
+		})
+		assert.False(t, policy.Network)
+		assert.True(t, policy.Audio)
+		assert.False(t, policy.PrivateHome)
+		assert.EqualValues(t, []string{
+			filepath.Join(installFolder, "saves"),
+			filepath.Clean("/srv/mods"),
+		}, policy.ReadWritePaths)
+		assert.Empty(t, policy.DeniedPaths)
+	})
+}
@@ -51,12 +51,22 @@ type LauncherParams struct {
 	// If true, enable sandbox
 	Sandbox bool
 
+	// What the game can do, if sandboxed
+	SandboxPolicy *SandboxPolicy
+
+	// The game's home folder, if sandboxed, unless the player opted
+	// out of private homes. Outside of the install folder.
+	SandboxHomeFolder string
+
 	// Additional command-line arguments
 	Args []string
 
 	// Additional environment variables
 	Env map[string]string
 
+	// If empty, launchers pick their own
+	WorkingDirectory string
+
 	PrereqsDir    string
 	ForcePrereqs  bool
 	Access        *operate.GameAccess
@@ -64,6 +74,10 @@ type LauncherParams struct {
 	Runtime       *ox.Runtime
 
 	SessionStarted func()
+
+	// Called by launchers that wait for the game to exit, with its exit
+	// code and the end of its output. Not called if the game couldn't run.
+	SessionExited func(exitCode int64, stdout string, stderr string)
 }
 
 // cf. https://github.com/itchio/itch/issues/1751
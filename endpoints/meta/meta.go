@@ -30,6 +30,8 @@ func Register(router *butlerd.Router) {
 			return nil, errors.Errorf("Cannot establish Meta.Flow twice in the same daemon instance. Last established %s", lastEstablished)
 		}
 
+		rc.ReceiveBroadcasts()
+
 		messages.MetaFlowEstablished.Notify(rc, butlerd.MetaFlowEstablishedNotification{
 			PID: int64(os.Getpid()),
 		})
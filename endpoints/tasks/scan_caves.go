@@ -0,0 +1,120 @@
+package tasks
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/butlerd"
+	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/cmd/operate"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/hades"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+// give the client time to get going before scanning anything
+const integrityScanDelay = 5 * time.Minute
+
+const integrityScanCheckInterval = 1 * time.Hour
+
+var scanningCaves int32
+
+// ScanCaves scans caves that haven't been scanned within the interval
+// of the integrity scan schedule, one at a time, and notifies clients of
+// the ones that aren't healthy. Caves that are in use are left for later.
+func ScanCaves() butlerd.BackgroundTask {
+	return butlerd.BackgroundTask{
+		Desc: "scan caves for missing or corrupted files",
+		Do: func(rc *butlerd.RequestContext) error {
+			if !atomic.CompareAndSwapInt32(&scanningCaves, 0, 1) {
+				rc.Consumer.Debugf("Already scanning caves")
+				return nil
+			}
+			defer atomic.StoreInt32(&scanningCaves, 0)
+
+			consumer := rc.Consumer
+
+			var schedule *models.IntegrityScanSchedule
+			var caves []*models.Cave
+			rc.WithConn(func(conn *sqlite.Conn) {
+				schedule = models.GetIntegrityScanSchedule(conn)
+				if !schedule.Enabled {
+					return
+				}
+
+				var checked []*models.CaveIntegrity
+				models.MustSelect(conn, &checked, builder.NewCond(), hades.Search{})
+				cutoff := time.Now().UTC().Add(-schedule.Interval())
+				recent := make(map[string]bool)
+				for _, ci := range checked {
+					if ci.CheckedAt != nil && ci.CheckedAt.After(cutoff) {
+						recent[ci.CaveID] = true
+					}
+				}
+
+				var all []*models.Cave
+				models.MustSelect(conn, &all, builder.Eq{"morphing": false}, hades.Search{})
+				for _, cave := range all {
+					if !recent[cave.ID] {
+						caves = append(caves, cave)
+					}
+				}
+				if len(caves) > 0 {
+					models.PreloadCaves(conn, caves)
+				}
+			})
+			if !schedule.Enabled || len(caves) == 0 {
+				return nil
+			}
+
+			consumer.Infof("Scanning %d caves for missing or corrupted files", len(caves))
+			for _, cave := range caves {
+				if err := rc.Ctx.Err(); err != nil {
+					return errors.WithStack(err)
+				}
+
+				// caves that are busy are scanned next time
+				integrity, err := operate.ScanIdleCave(rc.Ctx, rc, cave)
+				if errors.Cause(err) == operate.ErrCaveBusy {
+					consumer.Debugf("Cave %s is in use, not scanning it for now", cave.ID)
+					continue
+				}
+				if err != nil {
+					consumer.Warnf("Could not scan cave %s: %+v", cave.ID, err)
+					continue
+				}
+				if integrity == nil || integrity.Healthy {
+					continue
+				}
+
+				consumer.Warnf("Cave %s has %d damaged files", cave.ID, len(integrity.Wounds))
+				err = messages.CavesIntegrityProblem.Notify(rc, butlerd.CavesIntegrityProblemNotification{
+					Integrity: integrity,
+				})
+				if err != nil {
+					consumer.Warnf("Could not notify integrity problem: %+v", err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// ScheduleIntegrityScans periodically queues a ScanCaves task,
+// until ctx is done.
+func ScheduleIntegrityScans(ctx context.Context, router *butlerd.Router) {
+	delay := integrityScanDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = integrityScanCheckInterval
+
+		router.QueueBackgroundTask(ScanCaves())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/efarrer/iothrottler"
 	"github.com/itchio/butler/butlerd"
 	"github.com/itchio/butler/butlerd/messages"
+	"github.com/itchio/butler/endpoints/downloads"
 	"github.com/itchio/httpkit/timeout"
 )
 
@@ -38,4 +39,12 @@ func Register(router *butlerd.Router) {
 		res := &butlerd.NetworkSetBandwidthThrottleResult{}
 		return res, nil
 	})
+
+	messages.NetworkSetMetered.Register(router, func(rc *butlerd.RequestContext, params butlerd.NetworkSetMeteredParams) (*butlerd.NetworkSetMeteredResult, error) {
+		rc.Consumer.Infof("Setting metered connection to: %v", params.Metered)
+		downloads.SetMetered(params.Metered)
+
+		res := &butlerd.NetworkSetMeteredResult{}
+		return res, nil
+	})
 }
@@ -2,7 +2,12 @@ package filtering
 
 import (
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/itchio/lake/tlc"
+	"github.com/pkg/errors"
 )
 
 var IgnoredPaths = []string{
@@ -29,3 +34,115 @@ func FilterPaths(fileInfo os.FileInfo) bool {
 
 	return true
 }
+
+// FilterPathsWith returns a filter that ignores everything FilterPaths
+// does, along with anything whose name matches one of the given patterns.
+// Walk filters only see names, so patterns with a slash never match here:
+// use PruneContainer once the walk is done to apply those. Patterns are
+// expected to have gone through CheckPattern.
+func FilterPathsWith(patterns []string) func(fileInfo os.FileInfo) bool {
+	return func(fileInfo os.FileInfo) bool {
+		if !FilterPaths(fileInfo) {
+			return false
+		}
+
+		name := fileInfo.Name()
+		for _, pattern := range patterns {
+			match, _ := path.Match(pattern, name)
+			if match {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// CheckPattern returns an error if pattern can't be used to ignore files:
+// if it's malformed, or if it's absolute.
+func CheckPattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return errors.Errorf("invalid pattern '%s': %s", pattern, err.Error())
+	}
+	if strings.HasPrefix(pattern, "/") {
+		return errors.Errorf("invalid pattern '%s': should be relative to the build folder", pattern)
+	}
+	return nil
+}
+
+// MatchPath returns true if relPath, a slash-separated path, or one of its
+// parent folders matches one of the given patterns. Patterns without a slash
+// are matched against names at any depth, like FilterPathsWith does, and
+// patterns with a slash against the whole path, so "*.log" ignores all logs
+// but "logs/*.log" only the ones in the top-level logs folder. A trailing
+// slash is ignored.
+func MatchPath(patterns []string, relPath string) bool {
+	components := strings.Split(relPath, "/")
+	for i, name := range components {
+		prefix := strings.Join(components[:i+1], "/")
+		for _, pattern := range patterns {
+			pattern = strings.TrimSuffix(pattern, "/")
+
+			var match bool
+			if strings.Contains(pattern, "/") {
+				match, _ = path.Match(pattern, prefix)
+			} else {
+				match, _ = path.Match(pattern, name)
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PruneContainer removes the files, folders and symlinks of a walked
+// container that match one of the given patterns (see MatchPath), and
+// adjusts file offsets and the container size to match. Paths are taken
+// relative to root, a folder of the container that may be empty: it's the
+// WrappedDir when the walk was wrapped.
+func PruneContainer(container *tlc.Container, root string, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+
+	ignored := func(p string) bool {
+		if root != "" {
+			if !strings.HasPrefix(p, root+"/") {
+				return false
+			}
+			p = strings.TrimPrefix(p, root+"/")
+		}
+		return MatchPath(patterns, p)
+	}
+
+	var dirs []*tlc.Dir
+	for _, d := range container.Dirs {
+		if !ignored(d.Path) {
+			dirs = append(dirs, d)
+		}
+	}
+	container.Dirs = dirs
+
+	var symlinks []*tlc.Symlink
+	for _, s := range container.Symlinks {
+		if !ignored(s.Path) {
+			symlinks = append(symlinks, s)
+		}
+	}
+	container.Symlinks = symlinks
+
+	var files []*tlc.File
+	var offset int64
+	for _, f := range container.Files {
+		if ignored(f.Path) {
+			continue
+		}
+		f.Offset = offset
+		offset += f.Size
+		files = append(files, f)
+	}
+	container.Files = files
+	container.Size = offset
+}
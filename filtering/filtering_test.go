@@ -0,0 +1,85 @@
+package filtering
+
+import (
+	"testing"
+
+	"github.com/itchio/lake/tlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPattern(t *testing.T) {
+	assert.NoError(t, CheckPattern("*.pdb"))
+	assert.NoError(t, CheckPattern("assets/raw/*"))
+	assert.Error(t, CheckPattern("[a-"), "malformed")
+	assert.Error(t, CheckPattern("*.pdb\\"), "malformed")
+	assert.Error(t, CheckPattern("/etc"), "absolute")
+}
+
+func TestMatchPath(t *testing.T) {
+	patterns := []string{"*.pdb", "assets/raw", "logs/*.log", "tmp/"}
+
+	// names match at any depth
+	assert.True(t, MatchPath(patterns, "game.pdb"))
+	assert.True(t, MatchPath(patterns, "bin/x64/game.pdb"))
+
+	// paths match from the build folder
+	assert.True(t, MatchPath(patterns, "assets/raw"))
+	assert.True(t, MatchPath(patterns, "assets/raw/hero.psd"))
+	assert.False(t, MatchPath(patterns, "mods/assets/raw/hero.psd"))
+	assert.False(t, MatchPath(patterns, "assets/rawr"))
+
+	assert.True(t, MatchPath(patterns, "logs/today.log"))
+	assert.False(t, MatchPath(patterns, "logs/old/today.log"), "* doesn't match slashes")
+	assert.False(t, MatchPath(patterns, "data/logs/today.log"))
+
+	assert.True(t, MatchPath(patterns, "tmp/cache.bin"))
+	assert.True(t, MatchPath(patterns, "data/tmp"))
+
+	assert.False(t, MatchPath(patterns, "game.exe"))
+	assert.False(t, MatchPath(nil, "game.pdb"))
+}
+
+func TestPruneContainer(t *testing.T) {
+	newContainer := func() *tlc.Container {
+		return &tlc.Container{
+			Dirs: []*tlc.Dir{
+				{Path: "Game.app"},
+				{Path: "Game.app/assets"},
+				{Path: "Game.app/assets/raw"},
+			},
+			Symlinks: []*tlc.Symlink{
+				{Path: "Game.app/assets/raw/latest", Dest: "hero.psd"},
+			},
+			Files: []*tlc.File{
+				{Path: "Game.app/assets/hero.png", Size: 10, Offset: 0},
+				{Path: "Game.app/assets/raw/hero.psd", Size: 100, Offset: 10},
+				{Path: "Game.app/game", Size: 20, Offset: 110},
+			},
+			Size: 130,
+		}
+	}
+
+	c := newContainer()
+	PruneContainer(c, "Game.app", []string{"assets/raw"})
+	assert.Len(t, c.Dirs, 2)
+	assert.Len(t, c.Symlinks, 0)
+	if assert.Len(t, c.Files, 2) {
+		assert.EqualValues(t, "Game.app/assets/hero.png", c.Files[0].Path)
+		assert.EqualValues(t, 0, c.Files[0].Offset)
+		assert.EqualValues(t, "Game.app/game", c.Files[1].Path)
+		assert.EqualValues(t, 10, c.Files[1].Offset)
+	}
+	assert.EqualValues(t, 30, c.Size)
+
+	// without the root, paths don't line up
+	c = newContainer()
+	PruneContainer(c, "", []string{"assets/raw"})
+	assert.Len(t, c.Files, 3)
+	assert.EqualValues(t, 130, c.Size)
+
+	// the root itself is never pruned
+	c = newContainer()
+	PruneContainer(c, "Game.app", []string{"*.app"})
+	assert.Len(t, c.Dirs, 3)
+	assert.Len(t, c.Files, 3)
+}
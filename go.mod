@@ -20,7 +20,7 @@ require (
 	github.com/google/gops v0.3.6
 	github.com/google/uuid v1.1.1
 	github.com/gorilla/handlers v1.4.0
-	github.com/gorilla/websocket v1.4.0 // indirect
+	github.com/gorilla/websocket v1.4.0
 	github.com/itchio/arkive v0.0.0-20190702114012-1bb6c7241ec3
 	github.com/itchio/boar v0.0.0-20190703124333-bda796911ceb
 	github.com/itchio/damage v0.0.0-20190703135837-76df725fc766
@@ -58,6 +58,7 @@ require (
 	github.com/winlabs/gowin32 v0.0.0-20190620203850-8e8c5160a38b
 	github.com/xlab/treeprint v0.0.0-20181112141820-a009c3971eca // indirect
 	golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
+	golang.org/x/net v0.0.0-20190628185345-da137c7871d7
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58
 	golang.org/x/sys v0.0.0-20190712062909-fae7ac547cb7
 	golang.org/x/text v0.3.2
@@ -65,7 +66,7 @@ require (
 	golang.org/x/tools v0.0.0-20190715044752-607ca053a137 // indirect
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
-	gopkg.in/yaml.v2 v2.2.2 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 	rsc.io/goversion v1.2.0 // indirect
 	xorm.io/builder v0.3.5
 )
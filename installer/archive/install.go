@@ -12,6 +12,7 @@ import (
 	"github.com/itchio/butler/installer"
 	"github.com/itchio/butler/installer/archive/intervalsaveconsumer"
 	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/headway/united"
 	"github.com/pkg/errors"
 )
 
@@ -75,6 +76,14 @@ func (m *Manager) Install(params installer.InstallParams) (*installer.InstallRes
 		consumer.Warnf("Could not load checkpoint: %s", err.Error())
 	}
 
+	if params.Store != nil {
+		// extracting over a previous install writes into existing files
+		err = params.Store.Detach(consumer, params.InstallFolderPath)
+		if err != nil {
+			return nil, errors.WithMessage(err, "detaching files from store")
+		}
+	}
+
 	sink := &savior.FolderSink{
 		Directory: params.InstallFolderPath,
 		Consumer:  consumer,
@@ -126,5 +135,15 @@ func (m *Manager) Install(params installer.InstallParams) (*installer.InstallRes
 		return nil, errors.WithStack(err)
 	}
 
+	if params.Store != nil {
+		ingestRes, err := params.Store.Ingest(consumer, params.InstallFolderPath, res.Files)
+		if err != nil {
+			// the install is fine without it
+			consumer.Warnf("Could not link files from store: %+v", err)
+		} else {
+			consumer.Infof("%d files linked from store, %s shared with other builds", ingestRes.Linked, united.FormatBytes(ingestRes.Shared))
+		}
+	}
+
 	return &res, nil
 }
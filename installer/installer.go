@@ -7,6 +7,7 @@ import (
 
 	"github.com/itchio/boar"
 	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
 	"github.com/itchio/savior"
 	"github.com/itchio/httpkit/eos"
 	"github.com/itchio/headway/state"
@@ -38,6 +39,9 @@ type InstallParams struct {
 
 	InstallerInfo *InstallerInfo
 
+	// Store to link installed files from, if any
+	Store *store.Store
+
 	// For cancellation
 	Context context.Context
 }
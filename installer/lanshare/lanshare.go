@@ -0,0 +1,109 @@
+// Package lanshare lets butlerd instances on the same local network share
+// the builds they have installed, so that studios and LAN parties don't
+// download the same builds from the CDN over and over.
+//
+// Instances advertise which builds they have via mDNS, and serve the files
+// of those builds over HTTP. Sharing is opt-in, per install location. Files
+// fetched from peers are checked against the build's wharf signature,
+// which comes from itch.io, so peers can't tamper with builds: anything
+// that doesn't match is left for healing to fetch from the CDN.
+//
+// Adverts are readable by anything on the network, so they carry no
+// secret. Instead, each request proves that the requester has the build's
+// signature, which itch.io only hands out to those entitled to the build,
+// see ProofHeader.
+package lanshare
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// InstanceID identifies this butler process in adverts, so
+// it doesn't try to fetch builds from itself.
+var InstanceID = uuid.New().String()
+
+// How long to wait for peers to answer when browsing
+const BrowseTimeout = 1 * time.Second
+
+// ProofHeader carries the proof that the requester has the signature of
+// the build it asks files of, see fileProof. Peers only serve requests
+// that have a valid one. It doesn't hide that a peer shares a build, only
+// the contents of its files.
+const ProofHeader = "X-Butler-Lanshare-Proof"
+
+// fileProof returns an HMAC of a file's path in a build, keyed on the
+// file's store key. Requesters derive the key from the build's signature,
+// peers from the file they have, so it only matches if both have the
+// same file.
+func fileProof(key string, buildID int64, filePath string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%d/%s", buildID, filePath)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BuildRef identifies a build a peer has installed
+type BuildRef struct {
+	GameID   int64
+	UploadID int64
+	BuildID  int64
+}
+
+func (b BuildRef) String() string {
+	return fmt.Sprintf("%d/%d/%d", b.GameID, b.UploadID, b.BuildID)
+}
+
+// ParseBuildRef parses the output of (BuildRef).String
+func ParseBuildRef(s string) (BuildRef, error) {
+	var b BuildRef
+	_, err := fmt.Sscanf(strings.Replace(s, "/", " ", -1), "%d %d %d", &b.GameID, &b.UploadID, &b.BuildID)
+	if err != nil {
+		return b, errors.Errorf("invalid build ref (%s)", s)
+	}
+	return b, nil
+}
+
+// Peer is another butlerd instance sharing builds on the local network
+type Peer struct {
+	InstanceID string
+	// host:port of its HTTP server
+	Addr   string
+	Builds []BuildRef
+}
+
+// Has returns true if the peer advertised build
+func (p *Peer) Has(build BuildRef) bool {
+	for _, b := range p.Builds {
+		if b == build {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPeers returns peers on the local network that have build installed
+func FindPeers(ctx context.Context, build BuildRef) ([]*Peer, error) {
+	peers, err := browse(ctx, BrowseTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Peer
+	for _, peer := range peers {
+		if peer.InstanceID == InstanceID {
+			continue
+		}
+		if peer.Has(build) {
+			res = append(res, peer)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package lanshare
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestAdvertRoundTrip(t *testing.T) {
+	a := &advert{
+		InstanceID: "some-instance",
+		Port:       4321,
+		Builds: []BuildRef{
+			{GameID: 1, UploadID: 2, BuildID: 3},
+			{GameID: 4, UploadID: 5, BuildID: 6},
+		},
+	}
+
+	question := dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(ServiceName),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET,
+	}
+	msg, err := buildAnswer(42, question, a)
+	assert.NoError(t, err)
+
+	peer, err := parseAnswer(msg, &net.UDPAddr{IP: net.IPv4(192, 168, 1, 12), Port: 5353})
+	assert.NoError(t, err)
+	assert.NotNil(t, peer)
+	assert.EqualValues(t, "some-instance", peer.InstanceID)
+	assert.EqualValues(t, "192.168.1.12:4321", peer.Addr)
+	assert.EqualValues(t, a.Builds, peer.Builds)
+	assert.True(t, peer.Has(BuildRef{GameID: 4, UploadID: 5, BuildID: 6}))
+	assert.False(t, peer.Has(BuildRef{GameID: 4, UploadID: 5, BuildID: 7}))
+}
+
+func TestResolveInFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lanshare-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	folder := filepath.Join(dir, "game")
+	assert.NoError(t, os.MkdirAll(filepath.Join(folder, "data"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, "data", "file.bin"), []byte("hi"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("no"), 0644))
+	_, ok := resolveInFolder(folder, "data/file.bin")
+	assert.True(t, ok)
+
+	_, ok = resolveInFolder(folder, "../secret.txt")
+	assert.False(t, ok)
+
+	// creating symlinks needs privileges on Windows
+	if os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(folder, "escape.txt")) == nil {
+		_, ok = resolveInFolder(folder, "escape.txt")
+		assert.False(t, ok)
+	}
+}
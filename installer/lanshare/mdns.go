@@ -0,0 +1,364 @@
+package lanshare
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// This is a minimal mDNS (RFC 6762) implementation: responders answer PTR
+// queries for ServiceName, and browsers send "legacy unicast" queries
+// from an ephemeral port, so answers are sent straight back to them.
+
+// ServiceName is the DNS-SD service type butlerd instances advertise
+const ServiceName = "_butler-share._tcp.local."
+
+var mdnsAddr = &net.UDPAddr{
+	IP:   net.IPv4(224, 0, 0, 251),
+	Port: 5353,
+}
+
+// mDNS allows messages up to 9000 bytes
+const maxMessageSize = 9000
+
+// TTL of the records we answer with, in seconds
+const recordTTL = 120
+
+// An advert is what a responder answers with
+type advert struct {
+	InstanceID string
+	Port       int
+	Builds     []BuildRef
+}
+
+func (a *advert) instanceName() string {
+	return a.InstanceID + "." + ServiceName
+}
+
+func (a *advert) txt() []string {
+	txt := []string{"v=2", "id=" + a.InstanceID}
+	for _, b := range a.Builds {
+		txt = append(txt, "build="+b.String())
+	}
+	return txt
+}
+
+// responder answers mDNS queries for ServiceName with its current advert
+type responder struct {
+	consumer *state.Consumer
+	conn     *net.UDPConn
+
+	mutex  sync.Mutex
+	advert *advert
+}
+
+func newResponder(consumer *state.Consumer) (*responder, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsAddr)
+	if err != nil {
+		return nil, errors.WithMessage(err, "listening for mDNS queries")
+	}
+
+	r := &responder{
+		consumer: consumer,
+		conn:     conn,
+	}
+	go r.serve()
+	return r, nil
+}
+
+func (r *responder) setAdvert(a *advert) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.advert = a
+}
+
+func (r *responder) Close() error {
+	return r.conn.Close()
+}
+
+func (r *responder) serve() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			// closed
+			return
+		}
+
+		reply, err := r.answer(buf[:n])
+		if err != nil {
+			r.consumer.Debugf("lanshare: ignoring mDNS query from %s: %v", src, err)
+			continue
+		}
+		if reply == nil {
+			continue
+		}
+
+		dest := src
+		if src.Port == mdnsAddr.Port {
+			// a full mDNS querier, answer to everyone
+			dest = mdnsAddr
+		}
+		_, err = r.conn.WriteToUDP(reply, dest)
+		if err != nil {
+			r.consumer.Debugf("lanshare: could not answer %s: %v", src, err)
+		}
+	}
+}
+
+// answer returns a reply to a query, or nil if it's not about us
+func (r *responder) answer(query []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	if h.Response {
+		return nil, nil
+	}
+
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	var question *dnsmessage.Question
+	for _, q := range questions {
+		if (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) && strings.EqualFold(q.Name.String(), ServiceName) {
+			q := q
+			question = &q
+			break
+		}
+	}
+	if question == nil {
+		return nil, nil
+	}
+
+	r.mutex.Lock()
+	a := r.advert
+	r.mutex.Unlock()
+	if a == nil {
+		return nil, nil
+	}
+
+	return buildAnswer(h.ID, *question, a)
+}
+
+func buildAnswer(id uint16, question dnsmessage.Question, a *advert) ([]byte, error) {
+	serviceName, err := dnsmessage.NewName(ServiceName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	instanceName, err := dnsmessage.NewName(a.instanceName())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	hostName, err := dnsmessage.NewName(a.InstanceID + ".local.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	header := func(name dnsmessage.Name, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{
+			Name:  name,
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+			TTL:   recordTTL,
+		}
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{
+		ID:            id,
+		Response:      true,
+		Authoritative: true,
+	})
+	b.EnableCompression()
+
+	// legacy unicast answers must repeat the question
+	err = b.StartQuestions()
+	if err == nil {
+		err = b.Question(question)
+	}
+	if err == nil {
+		err = b.StartAnswers()
+	}
+	if err == nil {
+		err = b.PTRResource(header(serviceName, dnsmessage.TypePTR), dnsmessage.PTRResource{
+			PTR: instanceName,
+		})
+	}
+	if err == nil {
+		err = b.StartAdditionals()
+	}
+	if err == nil {
+		err = b.SRVResource(header(instanceName, dnsmessage.TypeSRV), dnsmessage.SRVResource{
+			Port:   uint16(a.Port),
+			Target: hostName,
+		})
+	}
+	if err == nil {
+		err = b.TXTResource(header(instanceName, dnsmessage.TypeTXT), dnsmessage.TXTResource{
+			TXT: a.txt(),
+		})
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return msg, nil
+}
+
+// browse queries the local network for butlerd instances sharing builds,
+// and returns the ones that answered within timeout.
+func browse(ctx context.Context, timeout time.Duration) ([]*Peer, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	serviceName, err := dnsmessage.NewName(ServiceName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{})
+	err = b.StartQuestions()
+	if err == nil {
+		err = b.Question(dnsmessage.Question{
+			Name:  serviceName,
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		})
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	_, err = conn.WriteToUDP(query, mdnsAddr)
+	if err != nil {
+		return nil, errors.WithMessage(err, "sending mDNS query")
+	}
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+
+	peers := make(map[string]*Peer)
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// deadline reached
+			break
+		}
+
+		peer, err := parseAnswer(buf[:n], src)
+		if err != nil || peer == nil {
+			continue
+		}
+		peers[peer.InstanceID] = peer
+	}
+
+	var res []*Peer
+	for _, peer := range peers {
+		res = append(res, peer)
+	}
+	return res, nil
+}
+
+// parseAnswer returns the peer an answer is from, or nil if
+// it's not about us
+func parseAnswer(msg []byte, src *net.UDPAddr) (*Peer, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !h.Response {
+		return nil, nil
+	}
+
+	err = p.SkipAllQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []dnsmessage.Resource
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, answers...)
+	err = p.SkipAllAuthorities()
+	if err != nil {
+		return nil, err
+	}
+	additionals, err := p.AllAdditionals()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, additionals...)
+
+	var port int
+	var txt []string
+	for _, r := range resources {
+		if !strings.HasSuffix(strings.ToLower(r.Header.Name.String()), ServiceName) {
+			continue
+		}
+		switch body := r.Body.(type) {
+		case *dnsmessage.SRVResource:
+			port = int(body.Port)
+		case *dnsmessage.TXTResource:
+			txt = body.TXT
+		}
+	}
+	if port == 0 || txt == nil {
+		return nil, nil
+	}
+
+	peer := &Peer{
+		Addr: net.JoinHostPort(src.IP.String(), strconv.Itoa(port)),
+	}
+	for _, entry := range txt {
+		tokens := strings.SplitN(entry, "=", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		switch tokens[0] {
+		case "v":
+			if tokens[1] != "2" {
+				// from another version, which we can't talk to
+				return nil, nil
+			}
+		case "id":
+			peer.InstanceID = tokens[1]
+		case "build":
+			ref, err := ParseBuildRef(tokens[1])
+			if err == nil {
+				peer.Builds = append(peer.Builds, ref)
+			}
+		}
+	}
+	if peer.InstanceID == "" {
+		return nil, nil
+	}
+	return peer, nil
+}
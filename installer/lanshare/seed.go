@@ -0,0 +1,156 @@
+package lanshare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/httpkit/neterr"
+	"github.com/itchio/httpkit/timeout"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/pkg/errors"
+)
+
+// suffix of files being fetched from a peer
+const tmpSuffix = ".butler-lanshare-tmp"
+
+// Seed fetches the files of a signature that are missing from folder from
+// peers that have build, and checks them against the signature. It returns
+// how many files and bytes were fetched. Files that no peer had intact are
+// left for healing to fetch.
+func Seed(ctx context.Context, consumer *state.Consumer, peers []*Peer, build BuildRef, folder string, sigInfo *pwr.SignatureInfo) (int64, int64, error) {
+	keys := store.SignatureKeys(sigInfo)
+
+	var missing []*tlc.File
+	var missingBytes int64
+	for _, f := range sigInfo.Container.Files {
+		if f.Size == 0 {
+			// not worth asking anyone
+			continue
+		}
+
+		path := filepath.Join(folder, filepath.FromSlash(f.Path))
+		_, err := os.Lstat(path)
+		if err == nil {
+			// healing will check it
+			continue
+		}
+		missing = append(missing, f)
+		missingBytes += f.Size
+	}
+
+	// peers that go away are dropped
+	peers = append([]*Peer(nil), peers...)
+	client := timeout.NewDefaultClient()
+
+	var numFiles, numBytes, doneBytes int64
+	for _, f := range missing {
+		if len(peers) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return numFiles, numBytes, errors.WithStack(ctx.Err())
+		default:
+		}
+
+		path := filepath.Join(folder, filepath.FromSlash(f.Path))
+		for i := 0; i < len(peers); i++ {
+			peer := peers[i]
+			err := fetchFile(ctx, client, peer, build, f, path, keys[f.Path])
+			if err != nil {
+				consumer.Debugf("lanshare: could not fetch (%s) from %s: %v", f.Path, peer.Addr, err)
+				if neterr.IsNetworkError(err) {
+					consumer.Infof("lanshare: peer %s went away", peer.Addr)
+					peers = append(peers[:i], peers[i+1:]...)
+					i--
+				}
+				continue
+			}
+
+			numFiles++
+			numBytes += f.Size
+			break
+		}
+
+		doneBytes += f.Size
+		consumer.Progress(float64(doneBytes) / float64(missingBytes))
+	}
+
+	consumer.Debugf("lanshare: seeded %d files in %s", numFiles, folder)
+	return numFiles, numBytes, nil
+}
+
+// fetchFile fetches a file from a peer to dest, if its key matches
+func fetchFile(ctx context.Context, client *http.Client, peer *Peer, build BuildRef, f *tlc.File, dest string, key string) error {
+	u := &url.URL{
+		Scheme: "http",
+		Host:   peer.Addr,
+		Path:   fmt.Sprintf("/builds/%d/files/%s", build.BuildID, f.Path),
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set(ProofHeader, fileProof(key, build.BuildID, f.Path))
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("peer answered with HTTP %d", res.StatusCode)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmpPath := dest + tmpSuffix
+	defer os.Remove(tmpPath)
+
+	err = func() error {
+		out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer out.Close()
+
+		n, err := io.Copy(out, io.LimitReader(res.Body, f.Size+1))
+		if err != nil {
+			return err
+		}
+		if n != f.Size {
+			return errors.Errorf("expected %d bytes, got %d", f.Size, n)
+		}
+		return errors.WithStack(out.Close())
+	}()
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(f.Mode)
+	actualKey, err := store.FileKey(tmpPath, f.Size, mode)
+	if err != nil {
+		return err
+	}
+	if actualKey != key {
+		return errors.Errorf("contents don't match the build's signature")
+	}
+
+	err = os.Chmod(tmpPath, mode.Perm()|0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmpPath, dest))
+}
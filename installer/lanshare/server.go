@@ -0,0 +1,249 @@
+package lanshare
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/hades"
+	"github.com/itchio/headway/state"
+	"xorm.io/builder"
+)
+
+// MaxAdvertisedBuilds is how many builds are advertised at most,
+// most recently installed first, so adverts fit in a single packet.
+const MaxAdvertisedBuilds = 200
+
+// sharedCaves returns caves in install locations that have LAN sharing
+// enabled, that are wharf-enabled (peers need a signature to check files
+// against) and not being installed or updated.
+func sharedCaves(conn *sqlite.Conn, cond builder.Cond) []*models.Cave {
+	var caves []*models.Cave
+	models.MustSelect(conn, &caves, builder.And(
+		cond,
+		builder.Neq{"build_id": 0},
+		builder.Not{builder.Expr("morphing")},
+		builder.Expr("install_location_id IN (SELECT id FROM install_locations WHERE lan_sharing)"),
+	), hades.Search{}.OrderBy("installed_at DESC").Limit(MaxAdvertisedBuilds))
+	return caves
+}
+
+func sharedBuilds(conn *sqlite.Conn) []BuildRef {
+	var builds []BuildRef
+	seen := make(map[BuildRef]bool)
+	for _, cave := range sharedCaves(conn, builder.NewCond()) {
+		ref := BuildRef{
+			GameID:   cave.GameID,
+			UploadID: cave.UploadID,
+			BuildID:  cave.BuildID,
+		}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		builds = append(builds, ref)
+	}
+	return builds
+}
+
+// handler serves files of shared builds, at /builds/:buildID/files/:path.
+// Only files listed in the receipt of the build are served, to peers
+// that send a valid proof for them, see ProofHeader.
+type handler struct {
+	withConn store.WithConnFunc
+	consumer *state.Consumer
+
+	mutex sync.Mutex
+	// by install folder, see buildFiles
+	receipts map[string]*receiptFiles
+	// by full path, see fileKey
+	keys map[string]*cachedKey
+}
+
+type receiptFiles struct {
+	modTime time.Time
+	buildID int64
+	files   map[string]bool
+}
+
+type cachedKey struct {
+	size    int64
+	modTime time.Time
+	key     string
+}
+
+var _ http.Handler = (*handler)(nil)
+
+func newHandler(withConn store.WithConnFunc, consumer *state.Consumer) *handler {
+	return &handler{
+		withConn: withConn,
+		consumer: consumer,
+		receipts: make(map[string]*receiptFiles),
+		keys:     make(map[string]*cachedKey),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	proof := r.Header.Get(ProofHeader)
+	if proof == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	tokens := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 4)
+	if len(tokens) != 4 || tokens[0] != "builds" || tokens[2] != "files" {
+		http.NotFound(w, r)
+		return
+	}
+	buildID, err := strconv.ParseInt(tokens[1], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// cleaning it as an absolute path gets rid of any ".."
+	filePath := strings.TrimPrefix(path.Clean("/"+tokens[3]), "/")
+
+	var installFolder string
+	h.withConn(func(conn *sqlite.Conn) {
+		caves := sharedCaves(conn, builder.Eq{"build_id": buildID})
+		if len(caves) > 0 {
+			installFolder = caves[0].GetInstallFolder(conn)
+		}
+	})
+	if installFolder == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// saves, logs, or anything else the game wrote isn't shared
+	if !h.buildFiles(installFolder, buildID)[filePath] {
+		http.NotFound(w, r)
+		return
+	}
+
+	fullPath, ok := resolveInFolder(installFolder, filePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	stats, err := f.Stat()
+	if err != nil || !stats.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	key, err := h.fileKey(fullPath, stats)
+	if err != nil {
+		h.consumer.Warnf("lanshare: hashing (%s): %v", fullPath, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(proof), []byte(fileProof(key, buildID, filePath))) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	h.consumer.Debugf("lanshare: serving (%s) of build %d to %s", filePath, buildID, r.RemoteAddr)
+	http.ServeContent(w, r, filepath.Base(fullPath), stats.ModTime(), f)
+}
+
+// buildFiles returns the files listed in the receipt of installFolder,
+// if it's for buildID. Receipts are cached until they change.
+func (h *handler) buildFiles(installFolder string, buildID int64) map[string]bool {
+	stats, err := os.Stat(bfs.ReceiptPath(installFolder))
+	if err != nil {
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	rf := h.receipts[installFolder]
+	if rf == nil || !rf.modTime.Equal(stats.ModTime()) {
+		receipt, err := bfs.ReadReceipt(installFolder)
+		if err != nil || receipt == nil || receipt.Build == nil {
+			return nil
+		}
+
+		rf = &receiptFiles{
+			modTime: stats.ModTime(),
+			buildID: receipt.Build.ID,
+			files:   make(map[string]bool),
+		}
+		for _, f := range receipt.Files {
+			rf.files[f] = true
+		}
+		h.receipts[installFolder] = rf
+	}
+
+	if rf.buildID != buildID {
+		return nil
+	}
+	return rf.files
+}
+
+// fileKey returns the store key of a file we serve, to check proofs
+// against. Keys are cached until the file changes.
+func (h *handler) fileKey(fullPath string, stats os.FileInfo) (string, error) {
+	h.mutex.Lock()
+	ck := h.keys[fullPath]
+	h.mutex.Unlock()
+	if ck != nil && ck.size == stats.Size() && ck.modTime.Equal(stats.ModTime()) {
+		return ck.key, nil
+	}
+
+	key, err := store.FileKey(fullPath, stats.Size(), stats.Mode())
+	if err != nil {
+		return "", err
+	}
+
+	h.mutex.Lock()
+	h.keys[fullPath] = &cachedKey{
+		size:    stats.Size(),
+		modTime: stats.ModTime(),
+		key:     key,
+	}
+	h.mutex.Unlock()
+	return key, nil
+}
+
+// resolveInFolder returns the real path of a file inside folder, following
+// symlinks, as long as it doesn't end up outside of folder.
+func resolveInFolder(folder string, filePath string) (string, bool) {
+	realFolder, err := filepath.EvalSymlinks(folder)
+	if err != nil {
+		return "", false
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(folder, filepath.FromSlash(filePath)))
+	if err != nil {
+		return "", false
+	}
+	if !strings.HasPrefix(realPath, realFolder+string(filepath.Separator)) {
+		return "", false
+	}
+	return realPath, true
+}
@@ -0,0 +1,212 @@
+package lanshare
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/butler/installer/bfs"
+	"github.com/itchio/butler/installer/store"
+	itchio "github.com/itchio/go-itchio"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools/fspool"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBuild = BuildRef{GameID: 1, UploadID: 2, BuildID: 3}
+
+var testBuildFiles = map[string]int{
+	"game.bin":         96 * 1024,
+	"data/level1.dat":  32 * 1024,
+	"data/sounds.pack": 64 * 1024,
+}
+
+// testInstance is a butlerd instance sharing testBuild
+type testInstance struct {
+	dir           string
+	installFolder string
+	dbPool        *sqlite.Pool
+}
+
+func newTestInstance(t *testing.T, dir string) *testInstance {
+	ti := &testInstance{
+		dir:           dir,
+		installFolder: filepath.Join(dir, "location", "game"),
+	}
+
+	ti.dbPool = dbtest.OpenPool(t, dir, 1)
+	ti.withConn(func(conn *sqlite.Conn) {
+		installedAt := time.Now().UTC()
+		models.MustSave(conn, &models.InstallLocation{
+			ID:         "location",
+			Path:       filepath.Join(dir, "location"),
+			LANSharing: true,
+		})
+		models.MustSave(conn, &models.Cave{
+			ID:                "cave",
+			GameID:            testBuild.GameID,
+			UploadID:          testBuild.UploadID,
+			BuildID:           testBuild.BuildID,
+			InstalledAt:       &installedAt,
+			InstallLocationID: "location",
+			InstallFolderName: "game",
+		})
+	})
+	return ti
+}
+
+func (ti *testInstance) withConn(f func(conn *sqlite.Conn)) {
+	conn := ti.dbPool.Get(context.Background().Done())
+	defer ti.dbPool.Put(conn)
+	f(conn)
+}
+
+func (ti *testInstance) Close() {
+	ti.dbPool.Close()
+}
+
+func writeBuild(t *testing.T, folder string) {
+	for name, size := range testBuildFiles {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(len(name)))).Read(data)
+		path := filepath.Join(folder, filepath.FromSlash(name))
+		wtest.Must(t, os.MkdirAll(filepath.Dir(path), 0755))
+		wtest.Must(t, ioutil.WriteFile(path, data, 0644))
+	}
+}
+
+// zipBuild makes an archive of folder, like the one the CDN would serve
+func zipBuild(t *testing.T, folder string, zipPath string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name := range testBuildFiles {
+		data, err := ioutil.ReadFile(filepath.Join(folder, filepath.FromSlash(name)))
+		wtest.Must(t, err)
+		w, err := zw.Create(name)
+		wtest.Must(t, err)
+		_, err = w.Write(data)
+		wtest.Must(t, err)
+	}
+	wtest.Must(t, zw.Close())
+	wtest.Must(t, ioutil.WriteFile(zipPath, buf.Bytes(), 0644))
+}
+
+func TestSeedFromPeer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lanshare-seed")
+	wtest.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	consumer := &state.Consumer{}
+
+	// the build, as itch.io has it
+	upstream := filepath.Join(dir, "upstream")
+	writeBuild(t, upstream)
+	container, err := tlc.WalkDir(upstream, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+	pool := fspool.New(container, upstream)
+	hashes, err := pwr.ComputeSignature(context.Background(), container, pool, consumer)
+	pool.Close()
+	wtest.Must(t, err)
+	sigInfo := &pwr.SignatureInfo{Container: container, Hashes: hashes}
+	archivePath := filepath.Join(dir, "upstream.zip")
+	zipBuild(t, upstream, archivePath)
+
+	// alice has the build installed, with a file that went bad and a save
+	alice := newTestInstance(t, filepath.Join(dir, "alice"))
+	defer alice.Close()
+	writeBuild(t, alice.installFolder)
+	receipt := &bfs.Receipt{
+		Build: &itchio.Build{ID: testBuild.BuildID},
+		Files: bfs.ContainerPaths(container),
+	}
+	wtest.Must(t, receipt.WriteReceipt(alice.installFolder))
+	wtest.Must(t, ioutil.WriteFile(filepath.Join(alice.installFolder, "data", "level1.dat"), bytes.Repeat([]byte{0xff}, 32*1024), 0644))
+	wtest.Must(t, ioutil.WriteFile(filepath.Join(alice.installFolder, "save.dat"), []byte("secret progress"), 0644))
+
+	server := httptest.NewServer(newHandler(alice.withConn, consumer))
+	defer server.Close()
+
+	peer := &Peer{
+		InstanceID: "alice",
+		Addr:       strings.TrimPrefix(server.URL, "http://"),
+		Builds:     []BuildRef{testBuild},
+	}
+
+	get := func(path string, proof string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		wtest.Must(t, err)
+		if proof != "" {
+			req.Header.Set(ProofHeader, proof)
+		}
+		res, err := http.DefaultClient.Do(req)
+		wtest.Must(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	keys := store.SignatureKeys(sigInfo)
+	gameProof := fileProof(keys["game.bin"], testBuild.BuildID, "game.bin")
+	assert.EqualValues(t, http.StatusOK, get("/builds/3/files/game.bin", gameProof))
+	// without the signature, there's no way to ask for files
+	assert.EqualValues(t, http.StatusForbidden, get("/builds/3/files/game.bin", ""))
+	assert.EqualValues(t, http.StatusForbidden, get("/builds/3/files/game.bin", "guess"))
+	assert.EqualValues(t, http.StatusForbidden, get("/builds/3/files/game.bin", fileProof("", testBuild.BuildID, "game.bin")))
+	assert.EqualValues(t, http.StatusForbidden, get("/builds/3/files/data/sounds.pack", gameProof))
+	// nor for files that went bad
+	level1Proof := fileProof(keys["data/level1.dat"], testBuild.BuildID, "data/level1.dat")
+	assert.EqualValues(t, http.StatusForbidden, get("/builds/3/files/data/level1.dat", level1Proof))
+	// only files of the build are shared
+	assert.EqualValues(t, http.StatusNotFound, get("/builds/3/files/save.dat", gameProof))
+	assert.EqualValues(t, http.StatusNotFound, get("/builds/3/files/.itch/receipt.json.gz", gameProof))
+	assert.EqualValues(t, http.StatusNotFound, get("/builds/4/files/game.bin", gameProof))
+
+	// bob installs the same build
+	bob := newTestInstance(t, filepath.Join(dir, "bob"))
+	defer bob.Close()
+	wtest.Must(t, os.MkdirAll(bob.installFolder, 0755))
+
+	numFiles, numBytes, err := Seed(context.Background(), consumer, []*Peer{peer}, testBuild, bob.installFolder, sigInfo)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 2, numFiles)
+	assert.EqualValues(t, (96+64)*1024, numBytes)
+
+	// the bad file was rejected, and left for healing
+	_, err = os.Stat(filepath.Join(bob.installFolder, "data", "level1.dat"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(bob.installFolder, "save.dat"))
+	assert.True(t, os.IsNotExist(err))
+
+	// healing fetches it from upstream
+	vc := &pwr.ValidatorContext{
+		Consumer:   consumer,
+		NumWorkers: 1,
+		HealPath:   "archive," + archivePath,
+	}
+	wtest.Must(t, vc.Validate(context.Background(), bob.installFolder, sigInfo))
+
+	for name := range testBuildFiles {
+		expected, err := ioutil.ReadFile(filepath.Join(upstream, filepath.FromSlash(name)))
+		wtest.Must(t, err)
+		actual, err := ioutil.ReadFile(filepath.Join(bob.installFolder, filepath.FromSlash(name)))
+		wtest.Must(t, err)
+		assert.True(t, bytes.Equal(expected, actual), "%s should be intact", name)
+	}
+
+	// and it checks out
+	vc = &pwr.ValidatorContext{Consumer: consumer, NumWorkers: 1, FailFast: true}
+	assert.NoError(t, vc.Validate(context.Background(), bob.installFolder, sigInfo))
+}
@@ -0,0 +1,117 @@
+package lanshare
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/installer/store"
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+)
+
+// How often the list of shared builds is refreshed
+const RefreshInterval = 10 * time.Second
+
+// Serve shares builds installed in install locations that have LAN sharing
+// enabled, until ctx is done. It only listens on the network while there's
+// something to share.
+func Serve(ctx context.Context, withConn store.WithConnFunc, consumer *state.Consumer) {
+	s := &service{
+		withConn: withConn,
+		consumer: consumer,
+	}
+	defer s.stop()
+
+	for {
+		err := s.refresh()
+		if err != nil {
+			consumer.Warnf("lanshare: %+v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(RefreshInterval):
+		}
+	}
+}
+
+type service struct {
+	withConn store.WithConnFunc
+	consumer *state.Consumer
+
+	listener  net.Listener
+	server    *http.Server
+	responder *responder
+	numBuilds int
+}
+
+func (s *service) refresh() error {
+	var builds []BuildRef
+	s.withConn(func(conn *sqlite.Conn) {
+		builds = sharedBuilds(conn)
+	})
+
+	if len(builds) == 0 {
+		if s.server != nil {
+			s.consumer.Infof("lanshare: nothing left to share, stopping")
+			s.stop()
+		}
+		return nil
+	}
+
+	if s.server == nil {
+		err := s.start()
+		if err != nil {
+			s.stop()
+			return err
+		}
+	}
+
+	if len(builds) != s.numBuilds {
+		s.consumer.Infof("lanshare: sharing %d builds on %s", len(builds), s.listener.Addr())
+		s.numBuilds = len(builds)
+	}
+	s.responder.setAdvert(&advert{
+		InstanceID: InstanceID,
+		Port:       s.listener.Addr().(*net.TCPAddr).Port,
+		Builds:     builds,
+	})
+	return nil
+}
+
+func (s *service) start() error {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return errors.WithMessage(err, "listening for peers")
+	}
+	s.listener = listener
+
+	s.server = &http.Server{
+		Handler: newHandler(s.withConn, s.consumer),
+	}
+	go s.server.Serve(listener)
+
+	s.responder, err = newResponder(s.consumer)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *service) stop() {
+	if s.responder != nil {
+		s.responder.Close()
+		s.responder = nil
+	}
+	if s.server != nil {
+		// also closes the listener
+		s.server.Close()
+		s.server = nil
+		s.listener = nil
+	}
+	s.numBuilds = 0
+}
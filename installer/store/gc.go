@@ -0,0 +1,118 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+// GCResult describes what GC removed, or would remove
+type GCResult struct {
+	// Refs to files that were deleted, or modified since they were linked
+	StaleRefs int64 `json:"staleRefs"`
+	// Blobs no file links to anymore
+	RemovedBlobs int64 `json:"removedBlobs"`
+	// Size of removed blobs, in bytes
+	FreedBytes int64 `json:"freedBytes"`
+	// Blobs still in use
+	KeptBlobs int64 `json:"keptBlobs"`
+}
+
+// GC forgets about links that are gone, and removes blobs nothing links
+// to anymore. If dryRun is true, nothing is actually removed.
+func (s *Store) GC(consumer *state.Consumer, dryRun bool) (*GCResult, error) {
+	res := &GCResult{}
+
+	var refs []*models.StoreRef
+	s.withConn(func(conn *sqlite.Conn) {
+		refs = models.AllStoreRefs(conn)
+	})
+
+	used := make(map[string]bool)
+	for _, ref := range refs {
+		if s.isLinked(ref) {
+			used[ref.Hash] = true
+			continue
+		}
+
+		consumer.Debugf("Stale ref: %s", ref.Path)
+		res.StaleRefs++
+		if !dryRun {
+			s.withConn(func(conn *sqlite.Conn) {
+				models.MustDelete(conn, &models.StoreRef{}, builder.Eq{"path": ref.Path})
+			})
+		}
+	}
+
+	blobsDir := filepath.Join(s.Dir, "blobs")
+	err := filepath.Walk(blobsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == blobsDir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		key := info.Name()
+		if !strings.HasSuffix(key, tmpSuffix) && used[key] {
+			res.KeptBlobs++
+			return nil
+		}
+
+		consumer.Debugf("Unused blob: %s", key)
+		res.RemovedBlobs++
+		res.FreedBytes += info.Size()
+		if dryRun {
+			return nil
+		}
+
+		// read-only files can't be removed on some platforms
+		os.Chmod(path, 0644)
+		return os.Remove(path)
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "removing unused blobs")
+	}
+
+	if !dryRun {
+		s.withConn(func(conn *sqlite.Conn) {
+			for _, blob := range models.AllStoreBlobs(conn) {
+				if !used[blob.Hash] {
+					models.MustDelete(conn, &models.StoreBlob{}, builder.Eq{"hash": blob.Hash})
+				}
+			}
+		})
+	}
+
+	return res, nil
+}
+
+// isLinked returns true if a ref's file still has the blob's contents
+func (s *Store) isLinked(ref *models.StoreRef) bool {
+	stats, err := os.Stat(ref.Path)
+	if err != nil {
+		return false
+	}
+
+	blobStats, err := os.Stat(s.blobPath(ref.Hash))
+	if err != nil {
+		return false
+	}
+
+	if os.SameFile(stats, blobStats) {
+		return true
+	}
+
+	// reflinks don't share an inode, but they haven't changed if
+	// their size and modification time haven't
+	return stats.Size() == ref.Size && stats.ModTime().UnixNano() == ref.ModTime
+}
@@ -0,0 +1,37 @@
+//+build linux
+
+package store
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// from linux/fs.h
+const ficlone = 0x40049409
+
+// reflink makes dst a copy-on-write clone of src, on filesystems
+// that support it (btrfs, xfs...)
+func reflink(src string, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer w.Close()
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, w.Fd(), ficlone, r.Fd())
+	if errno != 0 {
+		w.Close()
+		os.Remove(dst)
+		return errors.WithStack(errno)
+	}
+	return nil
+}
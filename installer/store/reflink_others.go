@@ -0,0 +1,9 @@
+//+build !linux
+
+package store
+
+import "github.com/pkg/errors"
+
+func reflink(src string, dst string) error {
+	return errors.New("reflinks are not supported on this platform")
+}
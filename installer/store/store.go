@@ -0,0 +1,420 @@
+// Package store implements a local, content-addressed store of files
+// that install folders link to, so that files which are identical across
+// builds of a game only take up disk space once.
+//
+// Files are keyed by their wharf block hashes, the same ones found in
+// build signatures, so the store can also be checked against a signature
+// before anything is downloaded. Files are linked from the store with
+// a reflink where the filesystem supports it, and a hardlink otherwise.
+// Hardlinked files share their data with the store, so they're made
+// read-only, and must be detached before being patched in place. Games
+// that rewrite their own files don't expect that, which is why butlerd
+// only links files of install locations that opt in.
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wsync"
+	"github.com/pkg/errors"
+	"xorm.io/builder"
+)
+
+// Files smaller than this aren't worth linking
+const minFileSize = 16 * 1024
+
+// suffix of files being moved into place
+const tmpSuffix = ".butler-store-tmp"
+
+// WithConnFunc gives access to the butlerd database. Both
+// (*butlerd.RequestContext).WithConn and OpenWithPool fit.
+type WithConnFunc func(f func(conn *sqlite.Conn))
+
+// Store is a folder of read-only files ("blobs") named after their key,
+// along with an index of links to them in the database.
+type Store struct {
+	Dir      string
+	withConn WithConnFunc
+}
+
+// New returns a store in dir, indexed in the database accessed through withConn
+func New(dir string, withConn WithConnFunc) *Store {
+	return &Store{
+		Dir:      dir,
+		withConn: withConn,
+	}
+}
+
+// OpenWithPool returns a store whose index is kept in the database behind dbPool
+func OpenWithPool(dir string, dbPool *sqlite.Pool) *Store {
+	return New(dir, func(f func(conn *sqlite.Conn)) {
+		conn := dbPool.Get(context.Background().Done())
+		defer dbPool.Put(conn)
+		f(conn)
+	})
+}
+
+func (s *Store) blobPath(key string) string {
+	return filepath.Join(s.Dir, "blobs", key[:2], key)
+}
+
+// Key returns the key of a file, given its size, mode, and the strong
+// hashes of its blocks. Only the executable bit of the mode counts,
+// since hardlinks share their mode with the blob.
+func Key(size int64, mode os.FileMode, strongHashes [][]byte) string {
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, size)
+	if mode&0111 != 0 {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	for _, sh := range strongHashes {
+		h.Write(sh)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FileKey hashes a file on disk the same way wharf signatures do, and returns its key
+func FileKey(path string, size int64, mode os.FileMode) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var strongHashes [][]byte
+	sctx := wsync.NewContext(int(pwr.BlockSize))
+	err = sctx.CreateSignature(context.Background(), 0, f, func(bh wsync.BlockHash) error {
+		strongHashes = append(strongHashes, bh.StrongHash)
+		return nil
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return Key(size, mode, strongHashes), nil
+}
+
+// SignatureKeys returns the keys of all files in a signature, by path
+func SignatureKeys(sigInfo *pwr.SignatureInfo) map[string]string {
+	strongHashes := make([][][]byte, len(sigInfo.Container.Files))
+	for _, bh := range sigInfo.Hashes {
+		strongHashes[bh.FileIndex] = append(strongHashes[bh.FileIndex], bh.StrongHash)
+	}
+
+	keys := make(map[string]string)
+	for i, f := range sigInfo.Container.Files {
+		keys[f.Path] = Key(f.Size, os.FileMode(f.Mode), strongHashes[i])
+	}
+	return keys
+}
+
+// IngestResult describes what Ingest did
+type IngestResult struct {
+	// Files now linked from the store
+	Linked int64
+	// Size of linked files that were already in the store, and
+	// are now shared with other folders
+	Shared int64
+}
+
+// Ingest replaces files of a folder with links to the store, adding
+// them to the store if they're not there yet. Only the given paths are
+// considered, which should be the files of the build in that folder:
+// anything else (saves, configs, etc.) is the game's, and must stay
+// writable and unshared.
+func (s *Store) Ingest(consumer *state.Consumer, folder string, paths []string) (*IngestResult, error) {
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &IngestResult{}
+	for _, relPath := range paths {
+		path := filepath.Join(folder, filepath.FromSlash(relPath))
+		fileStats, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		if !fileStats.Mode().IsRegular() || fileStats.Size() < minFileSize {
+			continue
+		}
+		mode := fileStats.Mode().Perm()
+		size := fileStats.Size()
+
+		key, err := FileKey(path, size, mode)
+		if err != nil {
+			return nil, errors.WithMessage(err, "hashing file")
+		}
+
+		blobPath := s.blobPath(key)
+		blobStats, err := os.Stat(blobPath)
+		existed := err == nil
+		if existed {
+			stats, err := os.Stat(path)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if os.SameFile(stats, blobStats) {
+				// already linked
+				s.saveRef(path, key, stats)
+				res.Linked++
+				res.Shared += size
+				continue
+			}
+		} else {
+			err = s.addBlob(path, blobPath, mode)
+			if err != nil {
+				return nil, errors.WithMessage(err, "adding file to store")
+			}
+		}
+
+		err = replaceWithLink(blobPath, path, mode)
+		if err != nil {
+			return nil, errors.WithMessage(err, "linking file from store (the store must be on the same filesystem as the install folder)")
+		}
+
+		stats, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.withConn(func(conn *sqlite.Conn) {
+			now := time.Now().UTC()
+			models.MustSave(conn, &models.StoreBlob{
+				Hash:      key,
+				Size:      size,
+				CreatedAt: &now,
+			})
+		})
+		s.saveRef(path, key, stats)
+
+		res.Linked++
+		if existed {
+			res.Shared += size
+		}
+	}
+
+	consumer.Debugf("Store: linked %d files in %s", res.Linked, folder)
+	return res, nil
+}
+
+func (s *Store) saveRef(path string, key string, stats os.FileInfo) {
+	s.withConn(func(conn *sqlite.Conn) {
+		models.MustSave(conn, &models.StoreRef{
+			Path:    path,
+			Hash:    key,
+			Size:    stats.Size(),
+			ModTime: stats.ModTime().UnixNano(),
+		})
+	})
+}
+
+// addBlob adds a file to the store, sharing its data if possible
+func (s *Store) addBlob(path string, blobPath string, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(blobPath), 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmpPath := blobPath + tmpSuffix
+	os.Remove(tmpPath)
+
+	err = reflink(path, tmpPath)
+	if err != nil {
+		err = os.Link(path, tmpPath)
+		if err != nil {
+			err = copyFile(path, tmpPath, mode)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = os.Chmod(tmpPath, readOnly(mode))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(tmpPath, blobPath))
+}
+
+// Seed links files of a signature that are missing from folder from the
+// store, if they're in there. It returns how many files and bytes were linked.
+func (s *Store) Seed(consumer *state.Consumer, folder string, sigInfo *pwr.SignatureInfo) (int64, int64, error) {
+	keys := SignatureKeys(sigInfo)
+
+	var numFiles, numBytes int64
+	for _, f := range sigInfo.Container.Files {
+		if f.Size < minFileSize {
+			continue
+		}
+
+		path := filepath.Join(folder, filepath.FromSlash(f.Path))
+		_, err := os.Lstat(path)
+		if err == nil {
+			// healing will check it
+			continue
+		}
+
+		blobPath := s.blobPath(keys[f.Path])
+		_, err = os.Stat(blobPath)
+		if err != nil {
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return 0, 0, errors.WithStack(err)
+		}
+
+		err = replaceWithLink(blobPath, path, os.FileMode(f.Mode))
+		if err != nil {
+			return 0, 0, errors.WithMessage(err, "linking file from store (the store must be on the same filesystem as the install folder)")
+		}
+		numFiles++
+		numBytes += f.Size
+	}
+
+	consumer.Debugf("Store: seeded %d files in %s", numFiles, folder)
+	return numFiles, numBytes, nil
+}
+
+// Detach gives hardlinked files of a folder their own copy of their data,
+// so they can be modified in place (by patching or healing) without
+// corrupting the store. Their refs are removed, so folders should
+// be ingested again once they've been modified.
+func (s *Store) Detach(consumer *state.Consumer, folder string) error {
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	prefix := folder + string(filepath.Separator)
+
+	var refs []*models.StoreRef
+	s.withConn(func(conn *sqlite.Conn) {
+		refs = models.StoreRefsInFolder(conn, prefix)
+	})
+
+	numDetached := 0
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref.Path, prefix) {
+			continue
+		}
+
+		err := s.detachFile(ref)
+		if err != nil {
+			return errors.WithMessage(err, "detaching file from store")
+		}
+		numDetached++
+
+		s.withConn(func(conn *sqlite.Conn) {
+			models.MustDelete(conn, &models.StoreRef{}, builder.Eq{"path": ref.Path})
+		})
+	}
+
+	consumer.Debugf("Store: detached %d files in %s", numDetached, folder)
+	return nil
+}
+
+func (s *Store) detachFile(ref *models.StoreRef) error {
+	stats, err := os.Stat(ref.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+
+	blobStats, err := os.Stat(s.blobPath(ref.Hash))
+	if err != nil || !os.SameFile(stats, blobStats) {
+		// not sharing data with the store
+		return nil
+	}
+
+	mode := stats.Mode() | 0200
+	tmpPath := ref.Path + tmpSuffix
+	err = copyFile(ref.Path, tmpPath, mode)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.Rename(tmpPath, ref.Path))
+}
+
+// replaceWithLink makes path a link to blobPath
+func replaceWithLink(blobPath string, path string, mode os.FileMode) error {
+	if sameFile(blobPath, path) {
+		// renaming a hardlink over another link to
+		// the same file does nothing, so don't.
+		return nil
+	}
+
+	tmpPath := path + tmpSuffix
+	os.Remove(tmpPath)
+
+	err := reflink(blobPath, tmpPath)
+	if err == nil {
+		// reflinks have their own mode, they can stay writable
+		err = os.Chmod(tmpPath, mode)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		err = os.Link(blobPath, tmpPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(os.Rename(tmpPath, path))
+}
+
+func sameFile(a string, b string) bool {
+	aStats, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bStats, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aStats, bStats)
+}
+
+func copyFile(src string, dst string, mode os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(w.Close())
+}
+
+func readOnly(mode os.FileMode) os.FileMode {
+	return (mode.Perm() &^ 0222) | 0444
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"crawshaw.io/sqlite"
+	"github.com/itchio/butler/database/dbtest"
+	"github.com/itchio/butler/database/models"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/lake/pools/fspool"
+	"github.com/itchio/lake/tlc"
+	"github.com/itchio/wharf/pwr"
+	"github.com/itchio/wharf/wtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type storeFixture struct {
+	dir    string
+	store  *Store
+	dbPool *sqlite.Pool
+}
+
+func newStoreFixture(t *testing.T) *storeFixture {
+	dir, err := ioutil.TempDir("", "store-test")
+	wtest.Must(t, err)
+
+	dbPool := dbtest.OpenPool(t, dir, 1)
+	return &storeFixture{
+		dir:    dir,
+		store:  OpenWithPool(filepath.Join(dir, "store"), dbPool),
+		dbPool: dbPool,
+	}
+}
+
+func (sf *storeFixture) Close() {
+	sf.dbPool.Close()
+	os.RemoveAll(sf.dir)
+}
+
+func (sf *storeFixture) refs() []*models.StoreRef {
+	var refs []*models.StoreRef
+	sf.store.withConn(func(conn *sqlite.Conn) {
+		refs = models.AllStoreRefs(conn)
+	})
+	return refs
+}
+
+func writeRandomFile(t *testing.T, path string, seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	wtest.Must(t, os.MkdirAll(filepath.Dir(path), 0755))
+	wtest.Must(t, ioutil.WriteFile(path, data, 0644))
+	return data
+}
+
+func assertWritable(t *testing.T, path string) {
+	stats, err := os.Stat(path)
+	wtest.Must(t, err)
+	assert.True(t, stats.Mode()&0200 != 0, "%s should be writable", path)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	wtest.Must(t, err)
+	f.Close()
+}
+
+func TestIngest(t *testing.T) {
+	sf := newStoreFixture(t)
+	defer sf.Close()
+
+	// two builds of a game, and a save left by the game in each
+	gameA := filepath.Join(sf.dir, "game-a")
+	gameB := filepath.Join(sf.dir, "game-b")
+	writeRandomFile(t, filepath.Join(gameA, "data.pak"), 1, 64*1024)
+	writeRandomFile(t, filepath.Join(gameB, "data.pak"), 1, 64*1024)
+	writeRandomFile(t, filepath.Join(gameA, "tiny.txt"), 2, 128)
+	writeRandomFile(t, filepath.Join(gameA, "saves", "slot1.sav"), 3, 32*1024)
+	writeRandomFile(t, filepath.Join(gameB, "saves", "slot1.sav"), 3, 32*1024)
+
+	buildFiles := []string{"data.pak", "tiny.txt", "missing.pak"}
+
+	res, err := sf.store.Ingest(&state.Consumer{}, gameA, buildFiles)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, res.Linked)
+	assert.EqualValues(t, 0, res.Shared)
+
+	res, err = sf.store.Ingest(&state.Consumer{}, gameB, buildFiles)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, res.Linked)
+	assert.EqualValues(t, 64*1024, res.Shared)
+
+	assert.True(t, sameFile(filepath.Join(gameA, "data.pak"), filepath.Join(gameB, "data.pak")))
+	assert.Len(t, sf.refs(), 2)
+
+	// ingesting again changes nothing
+	res, err = sf.store.Ingest(&state.Consumer{}, gameA, buildFiles)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, res.Linked)
+	assert.Len(t, sf.refs(), 2)
+
+	// files that aren't part of the build are left alone, even
+	// if they're identical across games
+	saveA := filepath.Join(gameA, "saves", "slot1.sav")
+	saveB := filepath.Join(gameB, "saves", "slot1.sav")
+	assert.False(t, sameFile(saveA, saveB))
+	assertWritable(t, saveA)
+	assertWritable(t, saveB)
+	assertWritable(t, filepath.Join(gameA, "tiny.txt"))
+}
+
+func TestSeed(t *testing.T) {
+	sf := newStoreFixture(t)
+	defer sf.Close()
+
+	gameA := filepath.Join(sf.dir, "game-a")
+	data := writeRandomFile(t, filepath.Join(gameA, "data.pak"), 1, 64*1024)
+	writeRandomFile(t, filepath.Join(gameA, "other.pak"), 2, 64*1024)
+
+	container, err := tlc.WalkDir(gameA, &tlc.WalkOpts{})
+	wtest.Must(t, err)
+
+	pool := fspool.New(container, gameA)
+	hashes, err := pwr.ComputeSignature(context.Background(), container, pool, &state.Consumer{})
+	pool.Close()
+	wtest.Must(t, err)
+	sigInfo := &pwr.SignatureInfo{Container: container, Hashes: hashes}
+
+	// only data.pak makes it to the store
+	_, err = sf.store.Ingest(&state.Consumer{}, gameA, []string{"data.pak"})
+	wtest.Must(t, err)
+
+	// a new folder, with a save already in it
+	gameB := filepath.Join(sf.dir, "game-b")
+	writeRandomFile(t, filepath.Join(gameB, "save.dat"), 3, 32*1024)
+
+	numFiles, numBytes, err := sf.store.Seed(&state.Consumer{}, gameB, sigInfo)
+	wtest.Must(t, err)
+	assert.EqualValues(t, 1, numFiles)
+	assert.EqualValues(t, 64*1024, numBytes)
+
+	seeded, err := ioutil.ReadFile(filepath.Join(gameB, "data.pak"))
+	wtest.Must(t, err)
+	assert.True(t, bytes.Equal(data, seeded))
+
+	// not in the store, left for healing
+	_, err = os.Stat(filepath.Join(gameB, "other.pak"))
+	assert.True(t, os.IsNotExist(err))
+
+	assertWritable(t, filepath.Join(gameB, "save.dat"))
+}
+
+func TestDetach(t *testing.T) {
+	sf := newStoreFixture(t)
+	defer sf.Close()
+
+	game := filepath.Join(sf.dir, "game")
+	dataPath := filepath.Join(game, "data.pak")
+	data := writeRandomFile(t, dataPath, 1, 64*1024)
+	savePath := filepath.Join(game, "save.dat")
+	writeRandomFile(t, savePath, 2, 32*1024)
+
+	_, err := sf.store.Ingest(&state.Consumer{}, game, []string{"data.pak"})
+	wtest.Must(t, err)
+
+	blobPath := sf.store.blobPath(sf.refs()[0].Hash)
+	if !sameFile(dataPath, blobPath) {
+		t.Skip("filesystem uses reflinks, nothing to detach")
+	}
+
+	err = sf.store.Detach(&state.Consumer{}, game)
+	wtest.Must(t, err)
+
+	assert.False(t, sameFile(dataPath, blobPath))
+	assert.Len(t, sf.refs(), 0)
+	assertWritable(t, dataPath)
+	assertWritable(t, savePath)
+
+	// the blob is untouched when the detached file is modified
+	wtest.Must(t, ioutil.WriteFile(dataPath, []byte("patched"), 0644))
+	blob, err := ioutil.ReadFile(blobPath)
+	wtest.Must(t, err)
+	assert.True(t, bytes.Equal(data, blob))
+}
@@ -62,6 +62,7 @@ var appArgs = struct {
 	address              *string
 	userAgentAddition    *string
 	dbPath               *string
+	storeDir             *string
 	compressionAlgorithm *string
 	compressionQuality   *int
 
@@ -85,6 +86,7 @@ var appArgs = struct {
 	app.Flag("address", "itch.io server to talk to").Default("https://api.itch.io").Short('a').Hidden().String(),
 	app.Flag("user-agent", "string to include in user-agent for all http requests").Default("").Hidden().String(),
 	app.Flag("dbpath", "Path of the sqlite database path to use (for butlerd)").Default("").Hidden().String(),
+	app.Flag("store-dir", "Share identical files between fetched and installed builds through a store in this folder (must be on the same drive). Unless the filesystem supports reflinks, shared files are read-only hardlinks: games that rewrite their own files break, so butlerd only uses it for install locations that opt in").Default("").Hidden().String(),
 
 	app.Flag("compression", "Compression algorithm to use when writing patch or signature files").Default("brotli").Hidden().Enum("none", "brotli", "gzip"),
 	app.Flag("quality", "Quality level to use when writing patch or signature files").Default("1").Short('q').Hidden().Int(),
@@ -235,6 +237,7 @@ func doMain(args []string) {
 	ctx.SetAddress(*appArgs.address)
 	ctx.UserAgentAddition = *appArgs.userAgentAddition
 	ctx.DBPath = *appArgs.dbPath
+	ctx.StoreDir = *appArgs.storeDir
 	ctx.VersionString = butlerVersionString
 	ctx.Version = butlerVersion
 	ctx.Commit = butlerCommit
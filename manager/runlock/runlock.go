@@ -16,6 +16,9 @@ import (
 
 type Lock interface {
 	Lock(ctx context.Context, task string) error
+	// TryLock locks for task and returns true if nobody else is holding
+	// the lock, and returns false right away otherwise.
+	TryLock(task string) (bool, error)
 	Unlock() error
 }
 
@@ -50,36 +53,7 @@ func (rl *lock) Lock(ctx context.Context, task string) error {
 			debugf = func(f string, a ...interface{}) {}
 		}
 
-		rp, _ := rl.read()
-		if rp == nil {
-			return false
-		}
-		debugf("Has runlock file at (%s), PID (%d)", rl.file(), rp.ButlerPID)
-		proc, _ := os.FindProcess(int(rp.ButlerPID))
-		if proc != nil {
-			debugf("Got a process handle, %#v", proc)
-
-			if runtime.GOOS == "windows" {
-				debugf("...on Windows, that means the process is still running")
-			} else {
-				debugf("...trying to poke it with a 0 signal")
-				err := proc.Signal(syscall.Signal(0))
-				if err != nil {
-					debugf("Got error while signalling PID (%d), assuming dead: %#v", rp.ButlerPID, err)
-
-					// not running anymore
-					rl.Unlock()
-					return false
-				}
-			}
-
-			debugf("PID (%d) still running!", rp.ButlerPID)
-			proc.Release()
-		} else {
-			debugf("Didn't get a process handle, assuming dead")
-
-			// not running anymore
-			rl.Unlock()
+		if !rl.held(debugf) {
 			return false
 		}
 
@@ -104,6 +78,56 @@ func (rl *lock) Lock(ctx context.Context, task string) error {
 		}
 	}
 
+	return rl.acquire(task)
+}
+
+func (rl *lock) TryLock(task string) (bool, error) {
+	if rl.held(rl.consumer.Debugf) {
+		return false, nil
+	}
+	return true, rl.acquire(task)
+}
+
+// held returns true if the runlock file exists and the butler
+// process that wrote it is still running. Stale runlock files
+// are removed.
+func (rl *lock) held(debugf func(f string, a ...interface{})) bool {
+	rp, _ := rl.read()
+	if rp == nil {
+		return false
+	}
+	debugf("Has runlock file at (%s), PID (%d)", rl.file(), rp.ButlerPID)
+	proc, _ := os.FindProcess(int(rp.ButlerPID))
+	if proc != nil {
+		debugf("Got a process handle, %#v", proc)
+
+		if runtime.GOOS == "windows" {
+			debugf("...on Windows, that means the process is still running")
+		} else {
+			debugf("...trying to poke it with a 0 signal")
+			err := proc.Signal(syscall.Signal(0))
+			if err != nil {
+				debugf("Got error while signalling PID (%d), assuming dead: %#v", rp.ButlerPID, err)
+
+				// not running anymore
+				rl.Unlock()
+				return false
+			}
+		}
+
+		debugf("PID (%d) still running!", rp.ButlerPID)
+		proc.Release()
+	} else {
+		debugf("Didn't get a process handle, assuming dead")
+
+		// not running anymore
+		rl.Unlock()
+		return false
+	}
+	return true
+}
+
+func (rl *lock) acquire(task string) error {
 	rl.consumer.Debugf("Locking (%s) for %s", rl.file(), task)
 	return rl.write(&runlockPayload{
 		Task:      task,
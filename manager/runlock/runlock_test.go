@@ -3,6 +3,7 @@ package runlock_test
 import (
 	"context"
 	"io/ioutil"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -64,3 +65,27 @@ func Test_Runlock(t *testing.T) {
 		"r2-lock",
 	}, steps)
 }
+
+func Test_TryLock(t *testing.T) {
+	installFolder, err := ioutil.TempDir("", "runlock-test-installfolder")
+	wtest.Must(t, err)
+	defer os.RemoveAll(installFolder)
+
+	consumer := &state.Consumer{}
+
+	rl1 := runlock.New(consumer, installFolder)
+	locked, err := rl1.TryLock("rl1")
+	wtest.Must(t, err)
+	assert.True(t, locked)
+
+	rl2 := runlock.New(consumer, installFolder)
+	locked, err = rl2.TryLock("rl2")
+	wtest.Must(t, err)
+	assert.False(t, locked, "rl1 is holding it")
+
+	wtest.Must(t, rl1.Unlock())
+	locked, err = rl2.TryLock("rl2")
+	wtest.Must(t, err)
+	assert.True(t, locked)
+	wtest.Must(t, rl2.Unlock())
+}
@@ -46,6 +46,9 @@ type Context struct {
 	// Path to the local sqlite database
 	DBPath string
 
+	// Folder of the dedupe store, if files should be shared between builds
+	StoreDir string
+
 	CompressionAlgorithm string
 	CompressionQuality   int
 